@@ -0,0 +1,747 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	txtypes "github.com/cosmos/cosmos-sdk/types/tx"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, message string) error
+}
+
+const (
+	AlertMissCounterIncreased = "MissCounterIncreased"
+	AlertValidatorJailed      = "ValidatorJailed"
+	AlertFeederBalanceLow     = "FeederBalanceLow"
+	AlertIncident             = "Incident"
+)
+
+// missCounterQuietChecks is how many checks in a row must see no new misses
+// for MissCounterIncreased to resolve, so a validator missing a vote every
+// few checks doesn't get an alert and a resolution each time.
+const missCounterQuietChecks = 5
+
+// incidentNodeLag is how old the latest block may get before the node is
+// considered lagging.
+const incidentNodeLag = time.Minute
+
+// incidentConsensusRound is the round from which the commit of the latest
+// block counts as a struggling consensus, the first round is 0.
+const incidentConsensusRound = 1
+
+// incidentFeederTxs is how many of the latest transactions of the feeder are
+// checked for failures.
+const incidentFeederTxs = 10
+
+// ParseAlertRoutes parses the notifiers of every alert rule, given as
+// notifier names joined with "+", e.g. ValidatorJailed=telegram+slack.
+func ParseAlertRoutes(values map[string]string) (map[string][]string, error) {
+	routes := make(map[string][]string, len(values))
+	for name, value := range values {
+		// the config file keys come lowercased
+		var rule string
+		for _, known := range []string{AlertMissCounterIncreased, AlertValidatorJailed, AlertFeederBalanceLow, AlertIncident} {
+			if strings.EqualFold(name, known) {
+				rule = known
+			}
+		}
+
+		if rule == "" {
+			return nil, fmt.Errorf("unknown alert rule %q", name)
+		}
+
+		routes[rule] = strings.Split(strings.ToLower(value), "+")
+	}
+
+	return routes, nil
+}
+
+// validatorAlertState keeps what was observed on the previous check,
+// so alerts are only sent when the condition changes.
+type validatorAlertState struct {
+	MissCounter    uint64 `json:"miss_counter"`
+	HasMissCounter bool   `json:"has_miss_counter"`
+	Jailed         bool   `json:"jailed"`
+	LowBalance     bool   `json:"low_balance"`
+
+	// checks left until MissCounterIncreased resolves
+	MissCounterFiringChecks int `json:"miss_counter_firing_checks,omitempty"`
+
+	MissedBlocks    uint64 `json:"missed_blocks,omitempty"`
+	HasMissedBlocks bool   `json:"has_missed_blocks,omitempty"`
+
+	// height of the latest feeder transaction checked for failures
+	FeederTxHeight int64 `json:"feeder_tx_height,omitempty"`
+}
+
+// pendingAlert is the outcome of an alert rule, held back until the signals
+// of the check are correlated.
+type pendingAlert struct {
+	rule    string
+	message string
+	firing  bool
+}
+
+type Alerter struct {
+	node       *NodeConnection
+	dispatcher *AlertDispatcher
+
+	// settings below can be changed on config reload
+	valopers         []string
+	interval         time.Duration
+	feederMinBalance uint64
+	feederDenom      string
+
+	// alert rule -> notifier names, rules without a route go to every notifier
+	routes map[string][]string
+
+	// nil if correlation is disabled
+	incidents *IncidentCorrelator
+
+	stateFile        string
+	historyInterval  time.Duration
+	historyRetention time.Duration
+	reportDir        string
+
+	mutex   sync.Mutex
+	states  map[string]*validatorAlertState
+	history map[string][]HistorySample
+	// when the last check finished, for the watchdog
+	lastCheck time.Time
+	logger    zerolog.Logger
+}
+
+func NewAlerter(
+	node *NodeConnection,
+	dispatcher *AlertDispatcher,
+	routes map[string][]string,
+	valopers []string,
+	interval time.Duration,
+	feederMinBalance uint64,
+	feederDenom string,
+	stateFile string,
+	historyInterval time.Duration,
+	historyRetention time.Duration,
+	reportDir string,
+	incidentWindow time.Duration,
+) *Alerter {
+	var incidents *IncidentCorrelator
+	if incidentWindow > 0 {
+		incidents = NewIncidentCorrelator(incidentWindow)
+	}
+
+	alerter := &Alerter{
+		node:             node,
+		dispatcher:       dispatcher,
+		routes:           routes,
+		valopers:         valopers,
+		interval:         interval,
+		feederMinBalance: feederMinBalance,
+		feederDenom:      feederDenom,
+		stateFile:        stateFile,
+		historyInterval:  historyInterval,
+		historyRetention: historyRetention,
+		reportDir:        reportDir,
+		incidents:        incidents,
+		states:           make(map[string]*validatorAlertState),
+		history:          make(map[string][]HistorySample),
+		logger:           log.With().Str("component", "alerter").Logger(),
+	}
+
+	alerter.loadState()
+	return alerter
+}
+
+func (a *Alerter) loadState() {
+	if a.stateFile == "" {
+		return
+	}
+
+	snapshot, err := LoadState(a.stateFile)
+	if err != nil {
+		a.logger.Error().Err(err).Str("file", a.stateFile).Msg("Could not load alerting state")
+		return
+	}
+
+	for valoper, state := range snapshot.Alerts {
+		state := state
+		a.states[valoper] = &state
+	}
+
+	a.history = snapshot.History
+	a.dispatcher.RestoreAPIMutes(snapshot.Mutes)
+
+	if snapshot.Notifications != nil {
+		a.dispatcher.Restore(snapshot.Notifications)
+		return
+	}
+
+	// states written before the dispatcher only know the conditions, which
+	// were notified when they started
+	notifications := make(map[string]map[string]alertRuleState)
+	for valoper, state := range snapshot.Alerts {
+		notifications[valoper] = map[string]alertRuleState{
+			AlertValidatorJailed:  {Firing: state.Jailed, Notified: state.Jailed},
+			AlertFeederBalanceLow: {Firing: state.LowBalance, Notified: state.LowBalance},
+		}
+	}
+	a.dispatcher.Restore(notifications)
+}
+
+func (a *Alerter) saveState() {
+	if a.stateFile == "" {
+		return
+	}
+
+	a.mutex.Lock()
+	snapshot := NewStateSnapshot()
+	for valoper, state := range a.states {
+		snapshot.Alerts[valoper] = *state
+	}
+	for valoper, samples := range a.history {
+		snapshot.History[valoper] = samples
+	}
+	a.mutex.Unlock()
+
+	snapshot.Notifications = a.dispatcher.Export()
+	snapshot.Mutes = a.dispatcher.APIMutes()
+
+	if err := SaveState(a.stateFile, snapshot); err != nil {
+		a.logger.Error().Err(err).Str("file", a.stateFile).Msg("Could not save alerting state")
+	}
+}
+
+func (a *Alerter) Start() {
+	a.logger.Info().
+		Strs("valopers", a.valopers).
+		Dur("interval", a.interval).
+		Int("notifiers", len(a.dispatcher.notifiers)).
+		Msg("Started alerting")
+
+	a.mutex.Lock()
+	a.lastCheck = time.Now()
+	a.mutex.Unlock()
+
+	for {
+		a.check()
+
+		a.mutex.Lock()
+		a.lastCheck = time.Now()
+		interval := a.interval
+		a.mutex.Unlock()
+
+		<-time.After(interval)
+	}
+}
+
+// Ticking fails once no check finished for a few intervals, e.g. because a
+// check hangs.
+func (a *Alerter) Ticking() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if since := time.Since(a.lastCheck); since > 3*a.interval+time.Minute {
+		return fmt.Errorf("no alert check finished for %s", since.Round(time.Second))
+	}
+
+	return nil
+}
+
+// Update applies settings from a reloaded config, the state of validators
+// that are still watched is kept so no duplicate alerts are sent.
+func (a *Alerter) Update(
+	valopers []string,
+	routes map[string][]string,
+	interval time.Duration,
+	feederMinBalance uint64,
+	feederDenom string,
+) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.routes = routes
+	a.valopers = valopers
+	a.interval = interval
+	a.feederMinBalance = feederMinBalance
+	a.feederDenom = feederDenom
+
+	watched := make(map[string]bool, len(valopers))
+	for _, valoper := range valopers {
+		watched[valoper] = true
+	}
+
+	for valoper := range a.states {
+		if !watched[valoper] {
+			delete(a.states, valoper)
+			a.dispatcher.Forget(valoper)
+			if a.incidents != nil {
+				a.incidents.Forget(valoper)
+			}
+		}
+	}
+
+	a.logger.Info().
+		Strs("valopers", a.valopers).
+		Dur("interval", a.interval).
+		Msg("Updated alerting settings")
+}
+
+func (a *Alerter) check() {
+	var wg sync.WaitGroup
+
+	a.mutex.Lock()
+	valopers := a.valopers
+	a.mutex.Unlock()
+
+	var height int64
+	serviceClient := tmservice.NewServiceClient(a.node.Get())
+	response, err := serviceClient.GetLatestBlock(context.Background(), &tmservice.GetLatestBlockRequest{})
+	if err != nil {
+		a.logger.Error().Err(err).Msg("Could not get latest block")
+	} else {
+		height = response.Block.Header.Height
+	}
+
+	if a.incidents != nil && (err != nil || time.Since(response.Block.Header.Time) > incidentNodeLag) {
+		for _, valoper := range valopers {
+			a.incidents.Observe(valoper, SignalNodeLag, time.Now())
+		}
+	}
+
+	// the commit of the previous block tells how many rounds it took
+	if a.incidents != nil && err == nil && response.Block.LastCommit != nil && response.Block.LastCommit.Round >= incidentConsensusRound {
+		for _, valoper := range valopers {
+			a.incidents.Observe(valoper, SignalConsensusRound, time.Now())
+		}
+	}
+
+	for _, valoper := range valopers {
+		wg.Add(1)
+		go func(valoper string) {
+			defer wg.Done()
+			a.checkValidator(valoper)
+		}(valoper)
+	}
+
+	wg.Wait()
+
+	// the alerts of all validators are sent together
+	a.dispatcher.Flush()
+
+	for _, valoper := range valopers {
+		a.recordHistory(valoper, height)
+	}
+
+	a.saveState()
+	a.renderReport()
+}
+
+// recordHistory appends the current state of the validator to its history
+// once per history interval and drops samples older than the retention.
+func (a *Alerter) recordHistory(valoper string, height int64) {
+	if a.stateFile == "" {
+		return
+	}
+
+	state := a.state(valoper)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := time.Now().UTC()
+	samples := a.history[valoper]
+	if len(samples) > 0 && now.Sub(samples[len(samples)-1].Time) < a.historyInterval {
+		return
+	}
+
+	samples = append(samples, HistorySample{
+		Time:        now,
+		MissCounter: state.MissCounter,
+		Jailed:      state.Jailed,
+		Height:      height,
+	})
+
+	for len(samples) > 0 && now.Sub(samples[0].Time) > a.historyRetention {
+		samples = samples[1:]
+	}
+
+	a.history[valoper] = samples
+}
+
+// renderReport writes the report of the previous month to the report
+// directory, once the month is over and if it wasn't rendered yet.
+func (a *Alerter) renderReport() {
+	if a.reportDir == "" || a.stateFile == "" {
+		return
+	}
+
+	month := time.Now().UTC().AddDate(0, -1, 0)
+	path := filepath.Join(a.reportDir, "report-"+month.Format("2006-01")+".html")
+	if _, err := os.Stat(path); err == nil {
+		return
+	}
+
+	snapshot, err := LoadState(a.stateFile)
+	if err != nil {
+		a.logger.Error().Err(err).Str("file", a.stateFile).Msg("Could not load history for the report")
+		return
+	}
+
+	if err := WriteReportFile(path, NewReport(snapshot.History, month)); err != nil {
+		a.logger.Error().Err(err).Str("file", path).Msg("Could not render report")
+		return
+	}
+
+	a.logger.Info().Str("file", path).Msg("Rendered monthly report")
+}
+
+// History returns a copy of the recorded history of the validator.
+func (a *Alerter) History(valoper string) []HistorySample {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return append([]HistorySample{}, a.history[valoper]...)
+}
+
+func (a *Alerter) state(valoper string) *validatorAlertState {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	state, ok := a.states[valoper]
+	if !ok {
+		state = &validatorAlertState{}
+		a.states[valoper] = state
+	}
+
+	return state
+}
+
+func (a *Alerter) checkValidator(valoper string) {
+	if _, err := ValAddressFromBech32(valoper); err != nil {
+		a.logger.Error().
+			Str("valoper", valoper).
+			Err(err).
+			Msg("Could not get validator address")
+		return
+	}
+
+	a.mutex.Lock()
+	feederMinBalance := a.feederMinBalance
+	feederDenom := a.feederDenom
+	a.mutex.Unlock()
+
+	state := a.state(valoper)
+
+	var alerts []pendingAlert
+	defer func() {
+		a.flush(valoper, alerts)
+	}()
+
+	grpcConn := a.node.Get()
+	oracle, err := NewOracleProvider(CurrentConfig().Chain.Type, grpcConn)
+	if err != nil {
+		a.logger.Error().Err(err).Msg("Could not create oracle provider")
+		return
+	}
+
+	missCounter, err := oracle.MissCounter(context.Background(), valoper)
+	if errors.Is(err, ErrNotSupported) {
+		a.logger.Trace().Msg("Miss counter is not supported by the chain")
+	} else if err != nil {
+		a.logger.Error().
+			Str("valoper", valoper).
+			Err(err).
+			Msg("Could not get validator current miss counter")
+	} else {
+		if state.HasMissCounter {
+			increased := missCounter > state.MissCounter
+			if increased {
+				a.observe(valoper, SignalOracleMisses)
+				state.MissCounterFiringChecks = missCounterQuietChecks
+			} else if state.MissCounterFiringChecks > 0 {
+				state.MissCounterFiringChecks--
+			}
+
+			// fires as long as one of the last checks saw new misses
+			alerts = append(alerts, pendingAlert{AlertMissCounterIncreased, fmt.Sprintf(
+				"🔥 <b>MissCounterIncreased</b>\nValidator: %s\nMiss counter: %d → %d",
+				valoper, state.MissCounter, missCounter,
+			), state.MissCounterFiringChecks > 0})
+		}
+
+		state.MissCounter = missCounter
+		state.HasMissCounter = true
+	}
+
+	stakingClient := stakingtypes.NewQueryClient(grpcConn)
+	validatorResponse, err := stakingClient.Validator(
+		context.Background(),
+		&stakingtypes.QueryValidatorRequest{ValidatorAddr: valoper},
+	)
+	if err != nil {
+		a.logger.Error().
+			Str("valoper", valoper).
+			Err(err).
+			Msg("Could not get validator")
+	} else {
+		jailed := validatorResponse.Validator.Jailed
+		alerts = append(alerts, pendingAlert{AlertValidatorJailed, fmt.Sprintf("🔥 <b>ValidatorJailed</b>\nValidator: %s", valoper), jailed})
+
+		state.Jailed = jailed
+
+		if a.incidents != nil {
+			a.checkMissedBlocks(valoper, validatorResponse.Validator, state)
+		}
+	}
+
+	if feederMinBalance == 0 && a.incidents == nil {
+		return
+	}
+
+	feeder, err := oracle.FeederDelegation(context.Background(), valoper)
+	if err != nil {
+		a.logger.Error().
+			Str("valoper", valoper).
+			Err(err).
+			Msg("Could not get feeder account associated with the validator")
+		return
+	}
+
+	if a.incidents != nil {
+		a.checkFeederTxs(valoper, feeder, state)
+	}
+
+	if feederMinBalance == 0 {
+		return
+	}
+
+	bankClient := banktypes.NewQueryClient(grpcConn)
+	balanceResponse, err := bankClient.Balance(
+		context.Background(),
+		&banktypes.QueryBalanceRequest{Address: feeder, Denom: feederDenom},
+	)
+	if err != nil {
+		a.logger.Error().
+			Str("valoper", valoper).
+			Str("feeder", feeder).
+			Err(err).
+			Msg("Could not get feeder balance")
+		return
+	}
+
+	lowBalance := balanceResponse.Balance.Amount.LT(sdk.NewIntFromUint64(feederMinBalance))
+	if lowBalance {
+		a.observe(valoper, SignalFeederBalance)
+	}
+
+	alerts = append(alerts, pendingAlert{AlertFeederBalanceLow, fmt.Sprintf(
+		"🔥 <b>FeederBalanceLow</b>\nValidator: %s\nFeeder: %s\nBalance: %s (threshold %d%s)",
+		valoper, feeder, balanceResponse.Balance.String(), feederMinBalance, feederDenom,
+	), lowBalance})
+
+	state.LowBalance = lowBalance
+}
+
+// checkMissedBlocks observes the missed blocks signal when the validator
+// missed blocks since the previous check, only used for correlation.
+func (a *Alerter) checkMissedBlocks(valoper string, validator stakingtypes.Validator, state *validatorAlertState) {
+	consAddress, err := ConsensusAddress(validator)
+	if err != nil {
+		a.logger.Error().
+			Str("valoper", valoper).
+			Err(err).
+			Msg("Could not get validator consensus address")
+		return
+	}
+
+	addresses, err := AddressCodecOf(valoper)
+	if err != nil {
+		a.logger.Error().
+			Str("valoper", valoper).
+			Err(err).
+			Msg("Could not get validator address")
+		return
+	}
+
+	slashingClient := slashingtypes.NewQueryClient(a.node.Get())
+	signingInfoResponse, err := slashingClient.SigningInfo(
+		context.Background(),
+		&slashingtypes.QuerySigningInfoRequest{ConsAddress: addresses.ConsAddress(consAddress)},
+	)
+	if err != nil {
+		a.logger.Error().
+			Str("valoper", valoper).
+			Err(err).
+			Msg("Could not get validator signing info")
+		return
+	}
+
+	missedBlocks := uint64(signingInfoResponse.ValSigningInfo.MissedBlocksCounter)
+	if state.HasMissedBlocks && missedBlocks > state.MissedBlocks {
+		a.observe(valoper, SignalMissedBlocks)
+	}
+
+	state.MissedBlocks = missedBlocks
+	state.HasMissedBlocks = true
+}
+
+// checkFeederTxs observes the feeder tx failures signal when one of the
+// latest transactions of the feeder since the previous check failed, e.g. out
+// of gas or with a rejected vote, only used for correlation. Nodes without a
+// tx index can't tell, the signal is never observed then.
+func (a *Alerter) checkFeederTxs(valoper string, feeder string, state *validatorAlertState) {
+	txClient := txtypes.NewServiceClient(a.node.Get())
+	txsResponse, err := txClient.GetTxsEvent(
+		context.Background(),
+		&txtypes.GetTxsEventRequest{
+			Events:  []string{fmt.Sprintf("message.sender='%s'", feeder)},
+			OrderBy: txtypes.OrderBy_ORDER_BY_DESC,
+			Page:    1,
+			Limit:   incidentFeederTxs,
+		},
+	)
+	if err != nil {
+		a.logger.Debug().
+			Str("valoper", valoper).
+			Str("feeder", feeder).
+			Err(err).
+			Msg("Could not get feeder transactions")
+		return
+	}
+
+	latest := state.FeederTxHeight
+	failed := false
+	for _, tx := range txsResponse.TxResponses {
+		if tx.Height > latest {
+			latest = tx.Height
+		}
+
+		// the transactions before the first check may be long resolved
+		if state.FeederTxHeight > 0 && tx.Height > state.FeederTxHeight && tx.Code != 0 {
+			failed = true
+		}
+	}
+
+	if failed {
+		a.observe(valoper, SignalFeederTxFailures)
+	}
+
+	state.FeederTxHeight = latest
+}
+
+func (a *Alerter) observe(valoper string, signal string) {
+	if a.incidents != nil {
+		a.incidents.Observe(valoper, signal, time.Now())
+	}
+}
+
+// flush hands the alerts of a check to the dispatcher. While an incident of
+// the validator is open only the incident is notified, jailing is always sent
+// as it needs action on its own.
+func (a *Alerter) flush(valoper string, alerts []pendingAlert) {
+	if a.incidents == nil {
+		for _, alert := range alerts {
+			a.set(valoper, alert)
+		}
+		return
+	}
+
+	incident, opened, closed := a.incidents.Evaluate(valoper, time.Now())
+
+	if opened {
+		selfIncidentActive.With(prometheus.Labels{"valoper": valoper, "cause": incident.Cause}).Set(1)
+		a.logger.Warn().
+			Str("valoper", valoper).
+			Str("cause", incident.Cause).
+			Strs("signals", incident.Signals).
+			Msg("Incident opened")
+		a.notify(valoper, AlertIncident, fmt.Sprintf(
+			"🔥 <b>Incident</b>\nValidator: %s\nProbable cause: %s\nSignals: %s",
+			valoper, incident.Cause, formatSignals(incident.Signals),
+		))
+	}
+
+	if closed != nil {
+		selfIncidentActive.Delete(prometheus.Labels{"valoper": valoper, "cause": closed.Cause})
+		a.logger.Info().
+			Str("valoper", valoper).
+			Str("cause", closed.Cause).
+			Msg("Incident resolved")
+		a.notify(valoper, AlertIncident, fmt.Sprintf(
+			"✅ <b>Incident resolved</b>\nValidator: %s\nProbable cause: %s\nSignals: %s",
+			valoper, closed.Cause, formatSignals(closed.Signals),
+		))
+	}
+
+	for _, alert := range alerts {
+		// resolutions still go out, the alert was notified before the incident
+		if incident != nil && alert.firing && alert.rule != AlertValidatorJailed {
+			continue
+		}
+
+		a.set(valoper, alert)
+	}
+}
+
+func (a *Alerter) set(valoper string, alert pendingAlert) {
+	a.dispatcher.Set(alert.rule, valoper, alert.firing, alert.message, a.route(alert.rule))
+}
+
+func (a *Alerter) notify(valoper string, rule string, message string) {
+	a.dispatcher.Send(valoper, message, a.route(rule))
+}
+
+func (a *Alerter) route(rule string) []string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.routes[rule]
+}
+
+// sendNotification sends the message to the notifiers named in route,
+// or to every notifier if the route is empty.
+func sendNotification(logger zerolog.Logger, notifiers []Notifier, route []string, message string) {
+	for _, notifier := range notifiers {
+		if len(route) > 0 && !containsString(route, notifier.Name()) {
+			continue
+		}
+
+		if err := notifier.Notify(context.Background(), message); err != nil {
+			logger.Error().
+				Str("notifier", notifier.Name()).
+				Err(err).
+				Msg("Could not send notification")
+			continue
+		}
+
+		logger.Debug().
+			Str("notifier", notifier.Name()).
+			Msg("Notification sent")
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
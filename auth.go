@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// unauthenticatedPaths stay open so Kubernetes probes don't need credentials.
+var unauthenticatedPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// AuthMiddleware requires basic auth or a bearer token when either is
+// configured, so the exporter can be exposed without leaking validator data.
+func AuthMiddleware(next http.Handler, user string, password string, token string) http.Handler {
+	if user == "" && token == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unauthenticatedPaths[r.URL.Path] || authorized(r, user, password, token) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		log.Debug().
+			Str("endpoint", r.URL.Path).
+			Str("remote-address", r.RemoteAddr).
+			Msg("Unauthorized request")
+
+		if user != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oracle-exporter"`)
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+func authorized(r *http.Request, user string, password string, token string) bool {
+	if token != "" {
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			return secureEqual(bearer, token)
+		}
+	}
+
+	if user != "" {
+		if requestUser, requestPassword, ok := r.BasicAuth(); ok {
+			// evaluate both so the timing doesn't tell which one was wrong
+			userMatches := secureEqual(requestUser, user)
+			passwordMatches := secureEqual(requestPassword, password)
+			return userMatches && passwordMatches
+		}
+	}
+
+	return false
+}
+
+func secureEqual(given string, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
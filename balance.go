@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// BalanceFlow is the change of one denom balance since the previous
+// collection and the cumulative flows since the exporter started.
+type BalanceFlow struct {
+	Denom   string
+	Change  sdk.Int
+	Inflow  sdk.Int
+	Outflow sdk.Int
+}
+
+// balanceMaxAddresses bounds the addresses the tracker keeps, they come from
+// the scrape requests.
+const balanceMaxAddresses = 100
+
+type balanceRecord struct {
+	Amount  sdk.Int `json:"amount"`
+	Inflow  sdk.Int `json:"inflow"`
+	Outflow sdk.Int `json:"outflow"`
+}
+
+// BalanceTracker remembers balances between scrapes, so changes are computed
+// on base denom integers instead of on converted floats in PromQL.
+type BalanceTracker struct {
+	mutex    sync.Mutex
+	balances map[string]map[string]*balanceRecord
+	// when the addresses were last observed, restored ones are evicted first
+	seen  map[string]time.Time
+	store *StateStore
+}
+
+// NewBalanceTracker restores the balances kept in the store, if any, so the
+// flow counters continue from their values before a restart.
+func NewBalanceTracker(store *StateStore) *BalanceTracker {
+	tracker := &BalanceTracker{
+		balances: make(map[string]map[string]*balanceRecord),
+		seen:     make(map[string]time.Time),
+		store:    store,
+	}
+
+	err := store.ForEach(storeBucketBalances, func(key string, value []byte) error {
+		records := make(map[string]*balanceRecord)
+		if err := json.Unmarshal(value, &records); err != nil {
+			return err
+		}
+
+		tracker.balances[key] = records
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Could not restore the feeder balances")
+	}
+
+	return tracker
+}
+
+// Observe records the current balances of the address and returns the flows
+// of every denom it holds or held before. Denoms missing from coins are
+// treated as spent to zero, as the bank module omits empty balances.
+func (t *BalanceTracker) Observe(address string, coins sdk.Coins) []BalanceFlow {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// saved under the lock, so a concurrent scrape can't write older balances last
+	flows, records := t.observe(address, coins)
+	if err := t.store.Put(storeBucketBalances, address, records); err != nil {
+		log.Error().Err(err).Str("address", address).Msg("Could not save the balances")
+	}
+
+	return flows
+}
+
+func (t *BalanceTracker) evictOldest() {
+	oldest := ""
+	for address := range t.balances {
+		if oldest == "" || t.seen[address].Before(t.seen[oldest]) {
+			oldest = address
+		}
+	}
+
+	delete(t.balances, oldest)
+	delete(t.seen, oldest)
+	if err := t.store.Delete(storeBucketBalances, oldest); err != nil {
+		log.Error().Err(err).Str("address", oldest).Msg("Could not delete the balances")
+	}
+}
+
+func (t *BalanceTracker) observe(address string, coins sdk.Coins) ([]BalanceFlow, map[string]balanceRecord) {
+	records, known := t.balances[address]
+	if !known {
+		if len(t.balances) >= balanceMaxAddresses {
+			t.evictOldest()
+		}
+
+		records = make(map[string]*balanceRecord)
+		t.balances[address] = records
+	}
+	t.seen[address] = time.Now()
+
+	current := make(map[string]sdk.Int, len(coins))
+	for _, coin := range coins {
+		current[coin.Denom] = coin.Amount
+	}
+
+	for denom := range records {
+		if _, ok := current[denom]; !ok {
+			current[denom] = sdk.ZeroInt()
+		}
+	}
+
+	flows := make([]BalanceFlow, 0, len(current))
+	snapshot := make(map[string]balanceRecord, len(current))
+	for denom, amount := range current {
+		record, ok := records[denom]
+		if !ok {
+			record = &balanceRecord{Amount: amount, Inflow: sdk.ZeroInt(), Outflow: sdk.ZeroInt()}
+			records[denom] = record
+
+			// a denom showing up on an already known address is an inflow
+			if known {
+				record.Amount = sdk.ZeroInt()
+			}
+		}
+
+		change := amount.Sub(record.Amount)
+		if change.IsPositive() {
+			record.Inflow = record.Inflow.Add(change)
+		} else if change.IsNegative() {
+			record.Outflow = record.Outflow.Sub(change)
+		}
+		record.Amount = amount
+
+		flows = append(flows, BalanceFlow{
+			Denom:   denom,
+			Change:  change,
+			Inflow:  record.Inflow,
+			Outflow: record.Outflow,
+		})
+		snapshot[denom] = *record
+	}
+
+	return flows, snapshot
+}
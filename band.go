@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+)
+
+const bandOracleService = "/band.oracle.v1.Query/"
+
+// Hand-written messages of the BandChain x/oracle module, only the fields
+// the exporter reads are declared.
+
+type bandValidatorRequest struct {
+	ValidatorAddress string `protobuf:"bytes,1,opt,name=validator_address,json=validatorAddress,proto3"`
+}
+
+func (m *bandValidatorRequest) Reset()         { *m = bandValidatorRequest{} }
+func (m *bandValidatorRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*bandValidatorRequest) ProtoMessage()    {}
+
+type bandParams struct {
+	ExpirationBlocks uint64 `protobuf:"varint,5,opt,name=expiration_blocks,json=expirationBlocks,proto3"`
+}
+
+func (m *bandParams) Reset()         { *m = bandParams{} }
+func (m *bandParams) String() string { return fmt.Sprintf("%+v", *m) }
+func (*bandParams) ProtoMessage()    {}
+
+type bandParamsResponse struct {
+	Params *bandParams `protobuf:"bytes,1,opt,name=params,proto3"`
+}
+
+func (m *bandParamsResponse) Reset()         { *m = bandParamsResponse{} }
+func (m *bandParamsResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*bandParamsResponse) ProtoMessage()    {}
+
+type bandPendingRequestsResponse struct {
+	RequestIDs []uint64 `protobuf:"varint,1,rep,packed,name=request_ids,json=requestIds,proto3"`
+}
+
+func (m *bandPendingRequestsResponse) Reset()         { *m = bandPendingRequestsResponse{} }
+func (m *bandPendingRequestsResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*bandPendingRequestsResponse) ProtoMessage()    {}
+
+type bandRequestRequest struct {
+	RequestID uint64 `protobuf:"varint,1,opt,name=request_id,json=requestId,proto3"`
+}
+
+func (m *bandRequestRequest) Reset()         { *m = bandRequestRequest{} }
+func (m *bandRequestRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*bandRequestRequest) ProtoMessage()    {}
+
+type bandRequest struct {
+	OracleScriptID uint64 `protobuf:"varint,1,opt,name=oracle_script_id,json=oracleScriptId,proto3"`
+	RequestHeight  int64  `protobuf:"varint,5,opt,name=request_height,json=requestHeight,proto3"`
+}
+
+func (m *bandRequest) Reset()         { *m = bandRequest{} }
+func (m *bandRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*bandRequest) ProtoMessage()    {}
+
+type bandReport struct {
+	Validator string `protobuf:"bytes,1,opt,name=validator,proto3"`
+}
+
+func (m *bandReport) Reset()         { *m = bandReport{} }
+func (m *bandReport) String() string { return fmt.Sprintf("%+v", *m) }
+func (*bandReport) ProtoMessage()    {}
+
+type bandRequestResponse struct {
+	Request *bandRequest  `protobuf:"bytes,1,opt,name=request,proto3"`
+	Reports []*bandReport `protobuf:"bytes,2,rep,name=reports,proto3"`
+}
+
+func (m *bandRequestResponse) Reset()         { *m = bandRequestResponse{} }
+func (m *bandRequestResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*bandRequestResponse) ProtoMessage()    {}
+
+type bandValidatorStatus struct {
+	IsActive bool `protobuf:"varint,1,opt,name=is_active,json=isActive,proto3"`
+}
+
+func (m *bandValidatorStatus) Reset()         { *m = bandValidatorStatus{} }
+func (m *bandValidatorStatus) String() string { return fmt.Sprintf("%+v", *m) }
+func (*bandValidatorStatus) ProtoMessage()    {}
+
+type bandValidatorResponse struct {
+	Status *bandValidatorStatus `protobuf:"bytes,1,opt,name=status,proto3"`
+}
+
+func (m *bandValidatorResponse) Reset()         { *m = bandValidatorResponse{} }
+func (m *bandValidatorResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*bandValidatorResponse) ProtoMessage()    {}
+
+type bandReportersResponse struct {
+	Reporter []string `protobuf:"bytes,1,rep,name=reporter,proto3"`
+}
+
+func (m *bandReportersResponse) Reset()         { *m = bandReportersResponse{} }
+func (m *bandReportersResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*bandReportersResponse) ProtoMessage()    {}
+
+// bandMaxTrackedValidators bounds the validators the tracker keeps, they
+// come from the requests.
+const bandMaxTrackedValidators = 100
+
+type bandValidatorReports struct {
+	// ids of the requests pending on the previous scrape
+	pending  map[uint64]bool
+	missed   int
+	lastSeen time.Time
+}
+
+// BandReportTracker remembers the requests pending for a validator between
+// scrapes. The chain only tells which requests are pending, until the
+// validator reports them or they expire, so a request no longer pending and
+// without a report of the validator expired without one.
+type BandReportTracker struct {
+	mutex      sync.Mutex
+	validators map[string]*bandValidatorReports
+}
+
+func NewBandReportTracker() *BandReportTracker {
+	return &BandReportTracker{
+		validators: make(map[string]*bandValidatorReports),
+	}
+}
+
+// Pending records the requests pending for the validator and returns those
+// pending on the previous scrape and no longer, reported or expired. The
+// validator seen the longest time ago is dropped when too many are tracked.
+func (t *BandReportTracker) Pending(valoper string, pending []uint64) []uint64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	reports, ok := t.validators[valoper]
+	if !ok {
+		if len(t.validators) >= bandMaxTrackedValidators {
+			t.evictOldest()
+		}
+
+		reports = &bandValidatorReports{}
+		t.validators[valoper] = reports
+	}
+
+	current := make(map[uint64]bool, len(pending))
+	for _, id := range pending {
+		current[id] = true
+	}
+
+	var done []uint64
+	for id := range reports.pending {
+		if !current[id] {
+			done = append(done, id)
+		}
+	}
+	sort.Slice(done, func(i, j int) bool { return done[i] < done[j] })
+
+	reports.pending = current
+	reports.lastSeen = time.Now()
+
+	return done
+}
+
+// Missed adds requests the validator let expire and returns the number of
+// requests it missed since the exporter started.
+func (t *BandReportTracker) Missed(valoper string, count int) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	reports, ok := t.validators[valoper]
+	if !ok {
+		return 0
+	}
+
+	reports.missed += count
+	return reports.missed
+}
+
+// evictOldest drops the validator seen the longest time ago, the caller
+// holds the mutex.
+func (t *BandReportTracker) evictOldest() {
+	var oldest string
+	for valoper, reports := range t.validators {
+		if oldest == "" || reports.lastSeen.Before(t.validators[oldest].lastSeen) {
+			oldest = valoper
+		}
+	}
+
+	delete(t.validators, oldest)
+}
+
+// BandCollector exports the oracle requests assigned to a BandChain
+// validator and not reported yet, whether the validator is active in the
+// oracle and the requests it missed. A validator missing a report is
+// deactivated and gets no more requests, nor their rewards.
+type BandCollector struct {
+	uncheckedCollector
+
+	ctx      context.Context
+	grpcConn *grpc.ClientConn
+	tracker  *BandReportTracker
+	valoper  string
+	logger   zerolog.Logger
+}
+
+func NewBandCollector(
+	ctx context.Context,
+	grpcConn *grpc.ClientConn,
+	tracker *BandReportTracker,
+	valoper string,
+	logger zerolog.Logger,
+) *BandCollector {
+	return &BandCollector{
+		ctx:      ctx,
+		grpcConn: grpcConn,
+		tracker:  tracker,
+		valoper:  valoper,
+		logger:   logger,
+	}
+}
+
+func (c *BandCollector) Collect(ch chan<- prometheus.Metric) {
+	bandValidatorActiveGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "band_validator_active",
+			Help:        "Whether a given validator is active in the oracle and gets requests assigned",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	bandReportersGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "band_reporters",
+			Help:        "Number of reporter accounts granted by a given validator",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	bandPendingRequestsGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "band_pending_requests",
+			Help:        "Requests assigned to a given validator it hasn't reported yet",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	bandOldestPendingRequestGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "band_oldest_pending_request_age_blocks",
+			Help:        "Blocks since the oldest request a given validator hasn't reported yet was made",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	bandExpirationBlocksGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "band_expiration_blocks",
+			Help:        "Blocks a validator has to report a request before it expires",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	bandMissedReportsCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "band_missed_reports_total",
+			Help:        "Requests a given validator let expire without a report since the exporter started",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	metrics := []prometheus.Collector{
+		bandValidatorActiveGauge,
+		bandReportersGauge,
+		bandPendingRequestsGauge,
+		bandOldestPendingRequestGauge,
+		bandExpirationBlocksGauge,
+		bandMissedReportsCounter,
+	}
+
+	ctx, height, err := PinHeight(c.ctx, c.grpcConn)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Could not get latest block height")
+		return
+	}
+
+	labels := prometheus.Labels{"valoper": c.valoper}
+
+	c.logger.Debug().Msg("Started querying oracle params")
+	queryStart := time.Now()
+
+	paramsResponse := &bandParamsResponse{}
+	if err := c.grpcConn.Invoke(ctx, bandOracleService+"Params", &emptyRequest{}, paramsResponse); err != nil {
+		c.logger.Error().Err(err).Msg("Could not get oracle params")
+		return
+	}
+
+	if paramsResponse.Params == nil {
+		c.logger.Error().Msg("Empty oracle params")
+		return
+	}
+
+	c.logger.Debug().
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying oracle params")
+
+	expirationBlocks := int64(paramsResponse.Params.ExpirationBlocks)
+	bandExpirationBlocksGauge.Set(float64(expirationBlocks))
+
+	c.logger.Debug().
+		Str("valoper", c.valoper).
+		Msg("Started querying validator oracle status")
+	queryStart = time.Now()
+
+	validatorResponse := &bandValidatorResponse{}
+	if err := c.grpcConn.Invoke(ctx, bandOracleService+"Validator", &bandValidatorRequest{ValidatorAddress: c.valoper}, validatorResponse); err != nil {
+		c.logger.Error().
+			Str("valoper", c.valoper).
+			Err(err).
+			Msg("Could not get validator oracle status")
+		return
+	}
+
+	active := 0.0
+	if validatorResponse.Status != nil && validatorResponse.Status.IsActive {
+		active = 1
+	}
+	bandValidatorActiveGauge.With(labels).Set(active)
+
+	reportersResponse := &bandReportersResponse{}
+	if err := c.grpcConn.Invoke(ctx, bandOracleService+"Reporters", &bandValidatorRequest{ValidatorAddress: c.valoper}, reportersResponse); err != nil {
+		c.logger.Error().
+			Str("valoper", c.valoper).
+			Err(err).
+			Msg("Could not get validator reporters")
+		return
+	}
+	bandReportersGauge.With(labels).Set(float64(len(reportersResponse.Reporter)))
+
+	c.logger.Debug().
+		Str("valoper", c.valoper).
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying validator oracle status")
+
+	c.logger.Debug().
+		Str("valoper", c.valoper).
+		Msg("Started querying pending requests")
+	queryStart = time.Now()
+
+	pendingResponse := &bandPendingRequestsResponse{}
+	if err := c.grpcConn.Invoke(ctx, bandOracleService+"PendingRequests", &bandValidatorRequest{ValidatorAddress: c.valoper}, pendingResponse); err != nil {
+		c.logger.Error().
+			Str("valoper", c.valoper).
+			Err(err).
+			Msg("Could not get pending requests")
+		return
+	}
+
+	var oldestAge int64
+	for _, id := range pendingResponse.RequestIDs {
+		requestResponse := &bandRequestResponse{}
+		if err := c.grpcConn.Invoke(ctx, bandOracleService+"Request", &bandRequestRequest{RequestID: id}, requestResponse); err != nil {
+			c.logger.Error().
+				Uint64("request-id", id).
+				Err(err).
+				Msg("Could not get request")
+			continue
+		}
+
+		if requestResponse.Request == nil {
+			continue
+		}
+
+		age := height - requestResponse.Request.RequestHeight
+		if age > oldestAge {
+			oldestAge = age
+		}
+	}
+
+	c.logger.Debug().
+		Str("valoper", c.valoper).
+		Int("pending", len(pendingResponse.RequestIDs)).
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying pending requests")
+
+	bandPendingRequestsGauge.With(labels).Set(float64(len(pendingResponse.RequestIDs)))
+	bandOldestPendingRequestGauge.With(labels).Set(float64(oldestAge))
+
+	// the requests no longer pending were reported or expired
+	var missed int
+	for _, id := range c.tracker.Pending(c.valoper, pendingResponse.RequestIDs) {
+		requestResponse := &bandRequestResponse{}
+		if err := c.grpcConn.Invoke(ctx, bandOracleService+"Request", &bandRequestRequest{RequestID: id}, requestResponse); err != nil {
+			c.logger.Error().
+				Uint64("request-id", id).
+				Err(err).
+				Msg("Could not get request")
+			continue
+		}
+
+		if !bandReported(requestResponse.Reports, c.valoper) {
+			missed++
+			c.logger.Warn().
+				Str("valoper", c.valoper).
+				Uint64("request-id", id).
+				Msg("Oracle request expired without a report")
+		}
+	}
+	bandMissedReportsCounter.With(labels).Add(float64(c.tracker.Missed(c.valoper, missed)))
+
+	collectMetrics(ch, metrics)
+}
+
+func bandReported(reports []*bandReport, valoper string) bool {
+	for _, report := range reports {
+		if report != nil && report.Validator == valoper {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BandHandler serves the oracle requests of the validator given with
+// ?valoper=, see BandCollector.
+func BandHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn, tracker *BandReportTracker) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	valoper := r.URL.Query().Get("valoper")
+	if valoper == "" {
+		http.Error(w, "valoper parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	registry := collectorRegistry(NewBandCollector(r.Context(), grpcConn, tracker, valoper, sublogger))
+
+	h := promhttp.HandlerFor(ExportTargetGatherer(registry, valoper), promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/band?valoper="+valoper).
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
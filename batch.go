@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type batchResult struct {
+	value []byte
+	err   error
+	// the batch request failed as a whole, the query is sent over gRPC instead
+	fallback bool
+}
+
+type batchCall struct {
+	path   string
+	data   []byte
+	height string
+	done   chan batchResult
+}
+
+// QueryBatcher sends the module queries of a scrape as abci_query calls in
+// JSON-RPC batches to the Tendermint RPC, instead of a gRPC request each.
+// Queries issued within the window share a single round trip, which matters
+// for nodes far away. The SDK routes gRPC query methods through ABCI with
+// the method as path, so the responses are the same.
+type QueryBatcher struct {
+	endpoint string
+	window   time.Duration
+	maxSize  int
+	client   *http.Client
+	logger   zerolog.Logger
+
+	mutex   sync.Mutex
+	pending []*batchCall
+	timer   *time.Timer
+}
+
+// queryBatcher is set with --batch-rpc.
+var queryBatcher *QueryBatcher
+
+func NewQueryBatcher(endpoint string, window time.Duration, maxSize int) *QueryBatcher {
+	return &QueryBatcher{
+		endpoint: endpoint,
+		window:   window,
+		maxSize:  maxSize,
+		client:   newHTTPClient(30 * time.Second),
+		logger:   log.With().Str("component", "query-batcher").Logger(),
+	}
+}
+
+func (b *QueryBatcher) Interceptor() grpc.UnaryClientInterceptor {
+	codec := encoding.GetCodec("proto")
+
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		// only the Query services of the modules are served over ABCI, not
+		// e.g. the Tendermint service
+		if !strings.Contains(method, ".Query/") {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		data, err := codec.Marshal(req)
+		if err != nil {
+			return err
+		}
+
+		call := &batchCall{
+			path: method,
+			data: data,
+			done: make(chan batchResult, 1),
+		}
+
+		if md, ok := metadata.FromOutgoingContext(ctx); ok {
+			if values := md.Get(grpctypes.GRPCBlockHeightHeader); len(values) > 0 {
+				call.height = values[0]
+			}
+		}
+
+		b.enqueue(call)
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case result := <-call.done:
+			if result.fallback {
+				return invoker(ctx, method, req, reply, cc, opts...)
+			}
+
+			if result.err != nil {
+				return result.err
+			}
+
+			return codec.Unmarshal(result.value, reply)
+		}
+	}
+}
+
+func (b *QueryBatcher) enqueue(call *batchCall) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.pending = append(b.pending, call)
+	if len(b.pending) >= b.maxSize {
+		if b.timer != nil {
+			b.timer.Stop()
+			b.timer = nil
+		}
+
+		calls := b.pending
+		b.pending = nil
+		go b.send(calls)
+		return
+	}
+
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.window, b.flush)
+	}
+}
+
+func (b *QueryBatcher) flush() {
+	b.mutex.Lock()
+	calls := b.pending
+	b.pending = nil
+	b.timer = nil
+	b.mutex.Unlock()
+
+	if len(calls) > 0 {
+		b.send(calls)
+	}
+}
+
+type rpcRequest struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      int               `json:"id"`
+	Method  string            `json:"method"`
+	Params  map[string]string `json:"params"`
+}
+
+type rpcResponse struct {
+	ID     int `json:"id"`
+	Result struct {
+		Response struct {
+			Code      uint32 `json:"code"`
+			Log       string `json:"log"`
+			Value     string `json:"value"`
+			Codespace string `json:"codespace"`
+		} `json:"response"`
+	} `json:"result"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    string `json:"data"`
+	} `json:"error"`
+}
+
+func (b *QueryBatcher) send(calls []*batchCall) {
+	start := time.Now()
+
+	requests := make([]rpcRequest, 0, len(calls))
+	for id, call := range calls {
+		params := map[string]string{
+			"path": call.path,
+			"data": hex.EncodeToString(call.data),
+		}
+		if call.height != "" {
+			params["height"] = call.height
+		}
+
+		requests = append(requests, rpcRequest{JSONRPC: "2.0", ID: id, Method: "abci_query", Params: params})
+	}
+
+	responses, err := b.post(requests)
+	if err != nil {
+		b.logger.Warn().Err(err).Int("queries", len(calls)).Msg("Could not send query batch, falling back to gRPC")
+		for _, call := range calls {
+			call.done <- batchResult{fallback: true}
+		}
+		return
+	}
+
+	answered := make(map[int]bool, len(responses))
+	for _, response := range responses {
+		if response.ID < 0 || response.ID >= len(calls) || answered[response.ID] {
+			continue
+		}
+		answered[response.ID] = true
+
+		call := calls[response.ID]
+		switch {
+		case response.Error != nil:
+			call.done <- batchResult{err: status.Errorf(codes.Unknown, "%s: %s", response.Error.Message, response.Error.Data)}
+		case response.Result.Response.Code != 0:
+			call.done <- batchResult{err: abciQueryError(
+				response.Result.Response.Codespace,
+				response.Result.Response.Code,
+				response.Result.Response.Log,
+			)}
+		default:
+			value, err := base64.StdEncoding.DecodeString(response.Result.Response.Value)
+			call.done <- batchResult{value: value, err: err}
+		}
+	}
+
+	for id, call := range calls {
+		if !answered[id] {
+			call.done <- batchResult{fallback: true}
+		}
+	}
+
+	b.logger.Debug().
+		Int("queries", len(calls)).
+		Float64("request-time", time.Since(start).Seconds()).
+		Msg("Sent query batch")
+}
+
+func (b *QueryBatcher) post(requests []rpcRequest) ([]rpcResponse, error) {
+	body, err := json.Marshal(requests)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := b.client.Post(b.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+
+	var responses []rpcResponse
+	if err := json.NewDecoder(response.Body).Decode(&responses); err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// abciQueryError turns the error of an ABCI query back into the gRPC status
+// the query would have failed with over gRPC. The SDK maps the codes of gRPC
+// query handlers to its own errors when routing them through ABCI.
+func abciQueryError(codespace string, code uint32, message string) error {
+	grpcCode := codes.Unknown
+	if codespace == "sdk" {
+		switch code {
+		case 4: // ErrUnauthorized
+			grpcCode = codes.Unauthenticated
+		case 6: // ErrUnknownRequest, also for a module the chain doesn't have
+			if strings.Contains(message, "unknown query path") {
+				grpcCode = codes.Unimplemented
+			}
+		case 18, 26: // ErrInvalidRequest, ErrInvalidHeight
+			grpcCode = codes.InvalidArgument
+		case 22, 38: // ErrKeyNotFound, ErrNotFound
+			grpcCode = codes.NotFound
+		}
+	}
+
+	return status.Error(grpcCode, message)
+}
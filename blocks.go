@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/metadata"
+)
+
+// blockReadTimeout is how long the subscription waits for the next block
+// before reconnecting, a stalled node is reported by /metrics/node.
+const blockReadTimeout = time.Minute
+
+type blockVoteState struct {
+	Prevoted       bool
+	Voted          bool
+	MissCounter    uint64
+	HasMissCounter bool
+	// number of blocks the miss counter increased at
+	MissIncreases float64
+}
+
+// BlockWatcher subscribes to the NewBlock events of the Tendermint RPC and
+// checks the votes of the validators at every block, so a missed vote is
+// seen one block after it happened instead of on the next scrape.
+type BlockWatcher struct {
+	uncheckedCollector
+
+	node     *NodeConnection
+	endpoint string
+	valopers []string
+	logger   zerolog.Logger
+
+	mutex     sync.RWMutex
+	connected bool
+	height    int64
+	blockTime time.Time
+	states    map[string]*blockVoteState
+}
+
+func NewBlockWatcher(node *NodeConnection, rpc string, valopers []string) *BlockWatcher {
+	endpoint := strings.TrimSuffix(rpc, "/") + "/websocket"
+	endpoint = strings.Replace(endpoint, "http://", "ws://", 1)
+	endpoint = strings.Replace(endpoint, "https://", "wss://", 1)
+
+	return &BlockWatcher{
+		node:     node,
+		endpoint: endpoint,
+		valopers: valopers,
+		logger:   log.With().Str("component", "block-watcher").Logger(),
+		states:   make(map[string]*blockVoteState),
+	}
+}
+
+type newBlockEvent struct {
+	Result struct {
+		Data struct {
+			Value struct {
+				Block struct {
+					Header struct {
+						Height string    `json:"height"`
+						Time   time.Time `json:"time"`
+					} `json:"header"`
+				} `json:"block"`
+			} `json:"value"`
+		} `json:"data"`
+	} `json:"result"`
+}
+
+func (w *BlockWatcher) Start() {
+	w.logger.Info().
+		Str("endpoint", w.endpoint).
+		Strs("valopers", w.valopers).
+		Msg("Started watching blocks")
+
+	// only the latest block is checked if checking falls behind
+	blocks := make(chan newBlockEvent, 1)
+	go func() {
+		for block := range blocks {
+			w.check(block)
+		}
+	}()
+
+	backoff := time.Second
+	for {
+		err := w.subscribe(blocks)
+
+		w.mutex.Lock()
+		w.connected = false
+		w.mutex.Unlock()
+
+		w.logger.Warn().Err(err).Dur("backoff", backoff).Msg("Block subscription closed, reconnecting")
+		<-time.After(backoff)
+
+		if backoff *= 2; backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+}
+
+func (w *BlockWatcher) subscribe(blocks chan newBlockEvent) error {
+	conn, _, err := websocket.DefaultDialer.Dial(w.endpoint, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "subscribe",
+		"params":  map[string]string{"query": "tm.event='NewBlock'"},
+	}); err != nil {
+		return err
+	}
+
+	w.mutex.Lock()
+	w.connected = true
+	w.mutex.Unlock()
+
+	w.logger.Info().Msg("Subscribed to new blocks")
+
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(blockReadTimeout)); err != nil {
+			return err
+		}
+
+		var event newBlockEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			return err
+		}
+
+		// the reply to the subscription has no block
+		if event.Result.Data.Value.Block.Header.Height == "" {
+			continue
+		}
+
+		select {
+		case <-blocks:
+		default:
+		}
+		blocks <- event
+	}
+}
+
+func (w *BlockWatcher) check(event newBlockEvent) {
+	header := event.Result.Data.Value.Block.Header
+	height, err := strconv.ParseInt(header.Height, 10, 64)
+	if err != nil {
+		w.logger.Warn().Err(err).Str("height", header.Height).Msg("Could not parse block height")
+		return
+	}
+
+	chainType := CurrentConfig().Chain.Type
+	oracle, err := NewOracleProvider(chainType, w.node.Get())
+	if err != nil {
+		w.logger.Error().Err(err).Str("chain-type", chainType).Msg("Could not create oracle provider")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), blockReadTimeout)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, header.Height)
+
+	states := make(map[string]*blockVoteState, len(w.valopers))
+	for _, valoper := range w.valopers {
+		state := &blockVoteState{}
+
+		w.mutex.RLock()
+		previous, hasPrevious := w.states[valoper]
+		w.mutex.RUnlock()
+
+		if hasPrevious {
+			state.MissIncreases = previous.MissIncreases
+		}
+
+		if _, err := oracle.LastPrevoteBlock(ctx, valoper); err == nil {
+			state.Prevoted = true
+		} else if !errors.Is(err, ErrNotSupported) {
+			w.logger.Debug().Str("valoper", valoper).Int64("height", height).Err(err).Msg("No aggregate prevote")
+		}
+
+		if denoms, err := oracle.VotedDenoms(ctx, valoper); err == nil {
+			state.Voted = len(denoms) > 0
+		}
+
+		missCounter, err := oracle.MissCounter(ctx, valoper)
+		if err != nil {
+			w.logger.Warn().Str("valoper", valoper).Int64("height", height).Err(err).Msg("Could not get miss counter")
+		} else {
+			state.MissCounter = missCounter
+			state.HasMissCounter = true
+
+			// the counter is reset at the end of the slash window
+			if hasPrevious && previous.HasMissCounter && missCounter > previous.MissCounter {
+				state.MissIncreases++
+				w.logger.Warn().
+					Str("valoper", valoper).
+					Int64("height", height).
+					Uint64("miss-counter", missCounter).
+					Msg("Validator missed a vote")
+			}
+		}
+
+		states[valoper] = state
+	}
+
+	w.mutex.Lock()
+	w.height = height
+	w.blockTime = header.Time
+	w.states = states
+	w.mutex.Unlock()
+}
+
+// Collect exports the vote state of the validators at the latest block seen.
+func (w *BlockWatcher) Collect(ch chan<- prometheus.Metric) {
+	blockSubscriptionConnectedGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "block_subscription_connected",
+			Help:        "Whether the exporter is subscribed to new blocks",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	blockHeightGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "block_height",
+			Help:        "Height of the latest block the votes were checked at",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	blockTimeGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "block_time",
+			Help:        "Unix time of the latest block the votes were checked at",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	blockPrevotePresentGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "block_prevote_present",
+			Help:        "Whether a given validator has an aggregate prevote at the latest block",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	blockVotePresentGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "block_vote_present",
+			Help:        "Whether a given validator has an aggregate vote at the latest block",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	blockMissCounterGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "block_miss_counter",
+			Help:        "Miss counter of a given validator at the latest block",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	blockMissIncreasesCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "block_miss_counter_increases_total",
+			Help:        "Number of blocks the miss counter of a given validator increased at",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	metrics := []prometheus.Collector{
+		blockSubscriptionConnectedGauge,
+		blockHeightGauge,
+		blockTimeGauge,
+		blockPrevotePresentGauge,
+		blockVotePresentGauge,
+		blockMissCounterGauge,
+		blockMissIncreasesCounter,
+	}
+
+	boolToFloat := func(value bool) float64 {
+		if value {
+			return 1
+		}
+		return 0
+	}
+
+	w.mutex.RLock()
+	blockSubscriptionConnectedGauge.Set(boolToFloat(w.connected))
+	if w.height > 0 {
+		blockHeightGauge.Set(float64(w.height))
+		blockTimeGauge.Set(float64(w.blockTime.Unix()))
+	}
+
+	for valoper, state := range w.states {
+		labels := prometheus.Labels{"valoper": valoper}
+		blockPrevotePresentGauge.With(labels).Set(boolToFloat(state.Prevoted))
+		blockVotePresentGauge.With(labels).Set(boolToFloat(state.Voted))
+		blockMissIncreasesCounter.With(labels).Add(state.MissIncreases)
+		if state.HasMissCounter {
+			blockMissCounterGauge.With(labels).Set(float64(state.MissCounter))
+		}
+	}
+	w.mutex.RUnlock()
+
+	collectMetrics(ch, metrics)
+}
+
+// BlocksHandler serves the vote state of the validators at the latest block
+// seen by the block watcher.
+func BlocksHandler(w http.ResponseWriter, r *http.Request, watcher *BlockWatcher) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	registry := collectorRegistry(watcher)
+
+	h := promhttp.HandlerFor(ExportGatherer(registry), promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/blocks").
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// queryCacheAliases are the names the cache TTLs can be set for in the config.
+// Full gRPC method names can't be used as keys, viper splits keys on dots.
+var queryCacheAliases = map[string]func(method string) bool{
+	"oracle-params": func(method string) bool {
+		return strings.Contains(method, ".oracle.") && strings.HasSuffix(method, "/Params")
+	},
+	"staking-params": func(method string) bool {
+		return method == "/cosmos.staking.v1beta1.Query/Params"
+	},
+	"slashing-params": func(method string) bool {
+		return method == "/cosmos.slashing.v1beta1.Query/Params"
+	},
+	"validator": func(method string) bool {
+		return method == "/cosmos.staking.v1beta1.Query/Validator"
+	},
+	"denom-metadata": func(method string) bool {
+		return method == "/cosmos.bank.v1beta1.Query/DenomMetadata"
+	},
+}
+
+type cachedResponse struct {
+	reply   interface{}
+	expires time.Time
+}
+
+// QueryCache keeps responses of queries whose data rarely changes, so they
+// aren't sent to the node on every scrape. Only successful responses are kept.
+type QueryCache struct {
+	mutex     sync.Mutex
+	ttls      map[string]time.Duration
+	responses map[string]cachedResponse
+}
+
+// queryCache is shared by every connection to the node, so cached responses
+// survive switching nodes on config reload.
+var queryCache = NewQueryCache()
+
+func NewQueryCache() *QueryCache {
+	return &QueryCache{
+		ttls:      make(map[string]time.Duration),
+		responses: make(map[string]cachedResponse),
+	}
+}
+
+// SetTTLs replaces the TTLs, given as alias or method name to duration.
+func (c *QueryCache) SetTTLs(values map[string]string) error {
+	ttls := make(map[string]time.Duration, len(values))
+	for name, value := range values {
+		if _, ok := queryCacheAliases[name]; !ok {
+			return fmt.Errorf("unknown cached query %q", name)
+		}
+
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid cache ttl of %q: %w", name, err)
+		}
+
+		ttls[name] = ttl
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.ttls = ttls
+	c.responses = make(map[string]cachedResponse)
+
+	return nil
+}
+
+func (c *QueryCache) ttl(method string) time.Duration {
+	for name, ttl := range c.ttls {
+		if queryCacheAliases[name](method) {
+			return ttl
+		}
+	}
+
+	return 0
+}
+
+// evictExpired removes the expired responses, so responses of requests that
+// aren't sent anymore don't pile up. It's called with the mutex held.
+func (c *QueryCache) evictExpired(now time.Time) {
+	for key, response := range c.responses {
+		if !now.Before(response.expires) {
+			delete(c.responses, key)
+		}
+	}
+}
+
+func (c *QueryCache) Interceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		c.mutex.Lock()
+		ttl := c.ttl(method)
+		// the pinned height isn't part of the key, every scrape pins a new one
+		// and the ttl already bounds how stale a response can be. The target is
+		// part of it, as probes of other nodes share the cache with the node.
+		key := cc.Target() + " " + method + " " + fmt.Sprintf("%v", req)
+		cached, ok := c.responses[key]
+		c.mutex.Unlock()
+
+		// responses at past heights are neither served from nor kept in the cache
+		if ttl <= 0 || IsHistorical(ctx) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		// handlers only read the responses, so a shallow copy is enough
+		if ok && time.Now().Before(cached.expires) {
+			reflect.ValueOf(reply).Elem().Set(reflect.ValueOf(cached.reply).Elem())
+			return nil
+		}
+
+		if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+			return err
+		}
+
+		stored := reflect.New(reflect.TypeOf(reply).Elem())
+		stored.Elem().Set(reflect.ValueOf(reply).Elem())
+
+		now := time.Now()
+		c.mutex.Lock()
+		c.evictExpired(now)
+		c.responses[key] = cachedResponse{reply: stored.Interface(), expires: now.Add(ttl)}
+		c.mutex.Unlock()
+
+		return nil
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const (
+	DefaultChainRegistryURL = "https://raw.githubusercontent.com/cosmos/chain-registry/master"
+
+	// chainRegistryMaxAge is how long the cached files are used without
+	// asking the registry again.
+	chainRegistryMaxAge = 24 * time.Hour
+)
+
+// RegistryChain is the subset of a chain.json of the chain registry the
+// exporter is configured from.
+type RegistryChain struct {
+	ChainName    string `json:"chain_name"`
+	ChainID      string `json:"chain_id"`
+	Bech32Prefix string `json:"bech32_prefix"`
+	APIs         struct {
+		RPC  []RegistryEndpoint `json:"rpc"`
+		GRPC []RegistryEndpoint `json:"grpc"`
+	} `json:"apis"`
+}
+
+type RegistryEndpoint struct {
+	Address  string `json:"address"`
+	Provider string `json:"provider"`
+}
+
+// RegistryAssetList is the subset of an assetlist.json of the chain registry.
+type RegistryAssetList struct {
+	Assets []struct {
+		Base       string `json:"base"`
+		Display    string `json:"display"`
+		Symbol     string `json:"symbol"`
+		DenomUnits []struct {
+			Denom    string `json:"denom"`
+			Exponent uint32 `json:"exponent"`
+		} `json:"denom_units"`
+	} `json:"assets"`
+}
+
+// DenomInfos returns the display unit of every asset with a known exponent.
+func (a RegistryAssetList) DenomInfos() []DenomInfo {
+	infos := make([]DenomInfo, 0, len(a.Assets))
+	for _, asset := range a.Assets {
+		for _, unit := range asset.DenomUnits {
+			if unit.Denom == asset.Display {
+				infos = append(infos, DenomInfo{Base: asset.Base, Display: asset.Display, Exponent: unit.Exponent})
+				break
+			}
+		}
+	}
+
+	return infos
+}
+
+// ChainRegistry fetches the files of a chain from the cosmos/chain-registry,
+// keeping a copy on disk to start when GitHub is unreachable.
+type ChainRegistry struct {
+	baseURL  string
+	cacheDir string
+	client   *http.Client
+}
+
+func NewChainRegistry(baseURL string, cacheDir string) (*ChainRegistry, error) {
+	if cacheDir == "" {
+		userCache, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("could not find the cache directory, set --chain-registry-cache: %w", err)
+		}
+
+		cacheDir = filepath.Join(userCache, "oracle-exporter", "chain-registry")
+	}
+
+	return &ChainRegistry{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		cacheDir: cacheDir,
+		client:   newHTTPClient(10 * time.Second),
+	}, nil
+}
+
+func (r *ChainRegistry) Chain(ctx context.Context, chain string) (*RegistryChain, error) {
+	var result RegistryChain
+	if err := r.fetch(ctx, chain, "chain.json", &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (r *ChainRegistry) AssetList(ctx context.Context, chain string) (*RegistryAssetList, error) {
+	var result RegistryAssetList
+	if err := r.fetch(ctx, chain, "assetlist.json", &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// fetch reads the file from the cache while it is fresh, otherwise from the
+// registry, falling back to the stale cache if the registry can't be reached.
+func (r *ChainRegistry) fetch(ctx context.Context, chain string, file string, target interface{}) error {
+	path := filepath.Join(r.cacheDir, chain, file)
+
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < chainRegistryMaxAge {
+		if err := readJSONFile(path, target); err == nil {
+			return nil
+		}
+	}
+
+	var raw json.RawMessage
+	err := getJSON(ctx, r.client, r.baseURL+"/"+chain+"/"+file, nil, &raw)
+	if err != nil {
+		if cacheErr := readJSONFile(path, target); cacheErr == nil {
+			log.Warn().Err(err).Str("file", path).Msg("Could not fetch from the chain registry, using the cached copy")
+			return nil
+		}
+
+		return fmt.Errorf("could not fetch %s of %s from the chain registry: %w", file, chain, err)
+	}
+
+	if err := json.Unmarshal(raw, target); err != nil {
+		return fmt.Errorf("could not parse %s of %s: %w", file, chain, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Warn().Err(err).Str("dir", r.cacheDir).Msg("Could not create the chain registry cache")
+	} else if err := os.WriteFile(path, raw, 0o644); err != nil {
+		log.Warn().Err(err).Str("file", path).Msg("Could not cache the chain registry file")
+	}
+
+	return nil
+}
+
+func readJSONFile(path string, target interface{}) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, target)
+}
+
+// ApplyChainRegistry fills the bech32 prefix, the node endpoints and the
+// display units of the chain from the registry. Flags set on the command line
+// or in the config file are left untouched.
+func ApplyChainRegistry(flags *pflag.FlagSet, chain string) error {
+	registry, err := NewChainRegistry(ChainRegistryURL, ChainRegistryCache)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	info, err := registry.Chain(ctx, chain)
+	if err != nil {
+		return err
+	}
+
+	set := func(name string, value string) error {
+		if value == "" || commandLineFlags[name] || flagInConfig(name) {
+			return nil
+		}
+
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("could not set flag %s: %w", name, err)
+		}
+
+		log.Info().Str("flag", name).Str("value", value).Str("chain", chain).Msg("Configured from the chain registry")
+		return nil
+	}
+
+	if err := set("bech32-prefix", info.Bech32Prefix); err != nil {
+		return err
+	}
+	if len(info.APIs.GRPC) > 0 {
+		if err := set("node", registryGRPCAddress(info.APIs.GRPC[0].Address)); err != nil {
+			return err
+		}
+	}
+	if len(info.APIs.RPC) > 0 {
+		if err := set("tendermint-rpc", info.APIs.RPC[0].Address); err != nil {
+			return err
+		}
+	}
+
+	// the assets are only a fallback, the chain can still be served without them
+	assets, err := registry.AssetList(ctx, chain)
+	if err != nil {
+		log.Warn().Err(err).Str("chain", chain).Msg("Could not load the asset list, denoms without bank metadata are shown in base units")
+		return nil
+	}
+
+	addDenomPack(info.ChainID, assets.DenomInfos())
+	return nil
+}
+
+// flagInConfig reports whether the flag is set in the config file or the
+// environment.
+func flagInConfig(name string) bool {
+	return viper.IsSet(name)
+}
+
+// registryGRPCAddress turns a registry gRPC address, given either as host:port
+// or as a URL, into the host:port form --node expects.
+func registryGRPCAddress(address string) string {
+	if strings.Contains(address, "://") {
+		u, err := url.Parse(address)
+		if err != nil {
+			return address
+		}
+		if u.Port() != "" {
+			return u.Host
+		}
+		if u.Scheme == "https" {
+			return net.JoinHostPort(u.Hostname(), "443")
+		}
+		return net.JoinHostPort(u.Hostname(), "9090")
+	}
+
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return net.JoinHostPort(address, "9090")
+	}
+
+	return address
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	cryptocodec "github.com/cosmos/cosmos-sdk/crypto/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+)
+
+var interfaceRegistry = newInterfaceRegistry()
+
+func newInterfaceRegistry() codectypes.InterfaceRegistry {
+	registry := codectypes.NewInterfaceRegistry()
+	cryptocodec.RegisterInterfaces(registry)
+	return registry
+}
+
+// ConsensusAddress unpacks the consensus pubkey of the validator returned
+// over gRPC and returns its consensus address.
+func ConsensusAddress(validator stakingtypes.Validator) (sdk.ConsAddress, error) {
+	if err := validator.UnpackInterfaces(interfaceRegistry); err != nil {
+		return nil, err
+	}
+
+	return validator.GetConsAddr()
+}
+
+// AddressCodec encodes and decodes the bech32 addresses of one chain. The
+// global sdk.Config holds the prefixes of a single chain, so they are kept
+// per chain instead and targets of chains with different prefixes can be
+// served by one process.
+type AddressCodec struct {
+	AccountPrefix string
+}
+
+func NewAddressCodec(accountPrefix string) AddressCodec {
+	return AddressCodec{AccountPrefix: accountPrefix}
+}
+
+// AddressCodecOf returns the codec of the chain of a bech32 address, e.g.
+// umee for umeevaloper1..., or of --bech32-prefix if set.
+func AddressCodecOf(address string) (AddressCodec, error) {
+	if prefix := CurrentConfig().Chain.Bech32Prefix; prefix != "" {
+		return NewAddressCodec(prefix), nil
+	}
+
+	if address == "" {
+		return AddressCodec{}, errors.New("empty address string is not allowed")
+	}
+
+	hrp, _, err := bech32.DecodeAndConvert(address)
+	if err != nil {
+		return AddressCodec{}, err
+	}
+
+	for _, suffix := range []string{sdk.PrefixValidator + sdk.PrefixOperator, sdk.PrefixValidator + sdk.PrefixConsensus} {
+		if strings.HasSuffix(hrp, suffix) {
+			return NewAddressCodec(strings.TrimSuffix(hrp, suffix)), nil
+		}
+	}
+
+	return NewAddressCodec(hrp), nil
+}
+
+func (c AddressCodec) ValidatorPrefix() string {
+	return c.AccountPrefix + sdk.PrefixValidator + sdk.PrefixOperator
+}
+
+func (c AddressCodec) ConsensusPrefix() string {
+	return c.AccountPrefix + sdk.PrefixValidator + sdk.PrefixConsensus
+}
+
+func (c AddressCodec) decode(address string, prefix string) ([]byte, error) {
+	if address == "" {
+		return nil, errors.New("empty address string is not allowed")
+	}
+
+	hrp, data, err := bech32.DecodeAndConvert(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if hrp != prefix {
+		return nil, fmt.Errorf("invalid Bech32 prefix; expected %s, got %s", prefix, hrp)
+	}
+
+	return data, nil
+}
+
+func (c AddressCodec) ValAddressFromBech32(address string) (sdk.ValAddress, error) {
+	return c.decode(address, c.ValidatorPrefix())
+}
+
+func (c AddressCodec) AccAddressFromBech32(address string) (sdk.AccAddress, error) {
+	return c.decode(address, c.AccountPrefix)
+}
+
+func (c AddressCodec) ValAddress(address sdk.ValAddress) string {
+	encoded, _ := bech32.ConvertAndEncode(c.ValidatorPrefix(), address)
+	return encoded
+}
+
+func (c AddressCodec) AccAddress(address sdk.AccAddress) string {
+	encoded, _ := bech32.ConvertAndEncode(c.AccountPrefix, address)
+	return encoded
+}
+
+func (c AddressCodec) ConsAddress(address sdk.ConsAddress) string {
+	encoded, _ := bech32.ConvertAndEncode(c.ConsensusPrefix(), address)
+	return encoded
+}
+
+// ValAddressFromBech32 decodes a validator address with the prefix of its
+// own chain, see AddressCodecOf.
+func ValAddressFromBech32(address string) (sdk.ValAddress, error) {
+	addresses, err := AddressCodecOf(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return addresses.ValAddressFromBech32(address)
+}
+
+// AccAddressFromBech32 decodes an account address with the prefix of its
+// own chain, see AddressCodecOf.
+func AccAddressFromBech32(address string) (sdk.AccAddress, error) {
+	addresses, err := AddressCodecOf(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return addresses.AccAddressFromBech32(address)
+}
+
+// OperatorAccount returns the account of a validator operator, which it
+// self-delegates and votes on proposals with.
+func OperatorAccount(valoper string) (string, error) {
+	addresses, err := AddressCodecOf(valoper)
+	if err != nil {
+		return "", err
+	}
+
+	address, err := addresses.ValAddressFromBech32(valoper)
+	if err != nil {
+		return "", err
+	}
+
+	return addresses.AccAddress(sdk.AccAddress(address)), nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// uncheckedCollector is embedded in the collectors of the metrics endpoints.
+// They create their metrics on every Collect, as which metrics and labels
+// are exported depends on the chain and the request, so the metrics aren't
+// described upfront and the registry only checks them when gathering.
+type uncheckedCollector struct{}
+
+func (uncheckedCollector) Describe(chan<- *prometheus.Desc) {}
+
+// collectMetrics sends the metrics filled by a scrape to ch.
+func collectMetrics(ch chan<- prometheus.Metric, metrics []prometheus.Collector) {
+	for _, metric := range metrics {
+		metric.Collect(ch)
+	}
+}
+
+// collectorRegistry returns the registry of a request with its collector.
+func collectorRegistry(collector prometheus.Collector) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	return registry
+}
+
+// CollectResult counts the queries of a collection that failed. Collectors
+// log their errors and export what they could get, the result tells whether
+// that was everything, e.g. for probe_success.
+type CollectResult struct {
+	failed atomic.Int64
+}
+
+type collectResultKey struct{}
+
+// WithCollectResult returns a context whose queries are counted in the
+// returned result, the one of ctx if it has one already.
+func WithCollectResult(ctx context.Context) (context.Context, *CollectResult) {
+	if result, ok := ctx.Value(collectResultKey{}).(*CollectResult); ok {
+		return ctx, result
+	}
+
+	result := &CollectResult{}
+	return context.WithValue(ctx, collectResultKey{}, result), result
+}
+
+// Fail records a failure outside of the queries, e.g. an unreachable target.
+func (r *CollectResult) Fail() {
+	r.failed.Add(1)
+}
+
+func (r *CollectResult) Failed() int64 {
+	return r.failed.Load()
+}
+
+// collectResultInterceptor counts the failed queries in the result of their
+// context. Queries the node doesn't serve, or whose object doesn't exist,
+// are expected by the collectors and don't count.
+func collectResultInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil {
+			return nil
+		}
+
+		if result, ok := ctx.Value(collectResultKey{}).(*CollectResult); ok {
+			switch status.Code(err) {
+			case codes.Unimplemented, codes.NotFound:
+			default:
+				result.failed.Add(1)
+			}
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// the collector is turned off in the config
+	ReasonDisabled = "disabled"
+	// the node doesn't serve the module the collector needs
+	ReasonCapabilityMissing = "capability-missing"
+	// the capability couldn't be probed yet, e.g. the node is down
+	ReasonUnknown = "unknown"
+)
+
+// collectorCapabilityInterval is how often the modules are probed again, they
+// may appear or go away with a chain upgrade.
+const collectorCapabilityInterval = 10 * time.Minute
+
+type collectorState struct {
+	Configured bool
+	Active     bool
+	Reason     string
+}
+
+// CollectorStatus tracks which collectors are configured and which of them
+// actually produce metrics, so the monitoring coverage of a fleet can be
+// audited from exporter_collector_active alone.
+type CollectorStatus struct {
+	node   *NodeConnection
+	banner bool
+	logger zerolog.Logger
+
+	mutex  sync.Mutex
+	states map[string]collectorState
+	// capability probes of the collectors needing an optional module
+	probes map[string]func(ctx context.Context, grpcConn *grpc.ClientConn) error
+}
+
+func NewCollectorStatus(node *NodeConnection, banner bool) *CollectorStatus {
+	return &CollectorStatus{
+		node:   node,
+		banner: banner,
+		logger: log.With().Str("component", "collectors").Logger(),
+		states: make(map[string]collectorState),
+		probes: map[string]func(ctx context.Context, grpcConn *grpc.ClientConn) error{
+			"marketmap": func(ctx context.Context, grpcConn *grpc.ClientConn) error {
+				return grpcConn.Invoke(ctx, slinkyMarketMapService+"Params", &emptyRequest{}, &emptyRequest{})
+			},
+			"slinky": func(ctx context.Context, grpcConn *grpc.ClientConn) error {
+				return invokeSlinkyOracle(ctx, grpcConn, "GetAllCurrencyPairs", &emptyRequest{}, &emptyRequest{})
+			},
+			"band": func(ctx context.Context, grpcConn *grpc.ClientConn) error {
+				return grpcConn.Invoke(ctx, bandOracleService+"Params", &emptyRequest{}, &emptyRequest{})
+			},
+			"icq": func(ctx context.Context, grpcConn *grpc.ClientConn) error {
+				return grpcConn.Invoke(ctx, neutronICQService+"RegisteredQueries", &icqRegisteredQueriesRequest{
+					Pagination: &icqPageRequest{Limit: 1},
+				}, &emptyRequest{})
+			},
+		},
+	}
+}
+
+// Configure sets whether a collector is turned on. Collectors with a probe
+// stay inactive until the node is known to serve their module.
+func (c *CollectorStatus) Configure(name string, configured bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	state := collectorState{Configured: configured, Active: configured}
+	switch {
+	case !configured:
+		state.Reason = ReasonDisabled
+	case c.probes[name] != nil:
+		previous, ok := c.states[name]
+		if ok && previous.Configured {
+			state = previous
+		} else {
+			state.Active = false
+			state.Reason = ReasonUnknown
+		}
+	}
+
+	c.states[name] = state
+	c.export(name, state)
+}
+
+func (c *CollectorStatus) export(name string, state collectorState) {
+	configured := 0.0
+	if state.Configured {
+		configured = 1
+	}
+
+	active := 0.0
+	if state.Active {
+		active = 1
+	}
+
+	selfCollectorConfigured.WithLabelValues(name).Set(configured)
+	selfCollectorActive.DeletePartialMatch(map[string]string{"collector": name})
+	selfCollectorActive.WithLabelValues(name, state.Reason).Set(active)
+}
+
+func (c *CollectorStatus) Start() {
+	for {
+		c.probe()
+
+		if c.banner {
+			c.logBanner()
+			c.banner = false
+		}
+
+		<-time.After(collectorCapabilityInterval)
+	}
+}
+
+func (c *CollectorStatus) probe() {
+	grpcConn := c.node.Get()
+
+	for name, probe := range c.probes {
+		c.mutex.Lock()
+		state := c.states[name]
+		c.mutex.Unlock()
+
+		if !state.Configured {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := probe(ctx, grpcConn)
+		cancel()
+
+		switch status.Code(err) {
+		case codes.OK:
+			state.Active, state.Reason = true, ""
+		case codes.Unimplemented:
+			state.Active, state.Reason = false, ReasonCapabilityMissing
+		default:
+			// keep the last known state, the node may only be down for a while
+			c.logger.Debug().Err(err).Str("collector", name).Msg("Could not probe collector capability")
+			continue
+		}
+
+		c.mutex.Lock()
+		if c.states[name].Configured {
+			c.states[name] = state
+			c.export(name, state)
+		}
+		c.mutex.Unlock()
+	}
+}
+
+func (c *CollectorStatus) logBanner() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	names := make([]string, 0, len(c.states))
+	for name := range c.states {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var active, inactive []string
+	for _, name := range names {
+		state := c.states[name]
+		if state.Active {
+			active = append(active, name)
+		} else {
+			inactive = append(inactive, name+" ("+state.Reason+")")
+		}
+	}
+
+	c.logger.Info().
+		Str("chain-type", CurrentConfig().Chain.Type).
+		Strs("active", active).
+		Strs("inactive", inactive).
+		Msg("Collectors")
+}
@@ -0,0 +1,29 @@
+package main
+
+import "github.com/spf13/cobra"
+
+var logLevels = []string{"trace", "debug", "info", "warn", "error", "fatal", "panic"}
+
+// RegisterCompletions completes the values of the flags taking one of a known
+// set, for the shell completion scripts of `oracle-exporter completion`.
+func RegisterCompletions(root *cobra.Command) {
+	completeValues(root, "chain-type", append([]string{ChainTypeAuto}, chainTypes...))
+	completeValues(root, "log-level", logLevels)
+	completeValues(root, "ip-family", []string{"any", "ipv4", "ipv6"})
+	completeValues(root, "disable-collectors", collectorNames)
+
+	root.MarkPersistentFlagFilename("config", "yaml", "yml")
+	// the other commands share the flags of the root command
+	root.MarkFlagFilename("tls-cert")
+	root.MarkFlagFilename("tls-key")
+	root.MarkFlagFilename("state-file")
+
+	completeValues(scrapeCmd, "modules", probeModules)
+	completeValues(scrapeCmd, "output", []string{"text", "json"})
+}
+
+func completeValues(cmd *cobra.Command, flag string, values []string) {
+	cmd.RegisterFlagCompletionFunc(flag, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	})
+}
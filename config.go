@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var (
+	// configMutex guards the flag variables and the config built from them
+	// while the config file is being reloaded.
+	configMutex sync.RWMutex
+
+	// commandLineFlags holds the flags passed explicitly, they always win over the config file.
+	commandLineFlags = map[string]bool{}
+
+	// configFileFlags holds the flags set from the config file, they go back
+	// to their defaults when their key is removed from it.
+	configFileFlags = map[string]bool{}
+)
+
+// envPrefix names the environment variables the flags are also taken from,
+// e.g. ORACLE_EXPORTER_LISTEN_ADDRESS for --listen-address. They win over the
+// config file, so a container's health check, which runs without the
+// container's arguments, sees the same settings as the exporter.
+const envPrefix = "ORACLE_EXPORTER"
+
+func init() {
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_", ".", "_"))
+	viper.AutomaticEnv()
+}
+
+func LoadConfig(flags *pflag.FlagSet) error {
+	flags.Visit(func(f *pflag.Flag) {
+		commandLineFlags[f.Name] = true
+		if target, ok := flagAliasTarget(f.Name); ok {
+			commandLineFlags[target] = true
+		}
+	})
+
+	if err := applyConfigFile(flags); err != nil {
+		return err
+	}
+
+	return refreshConfig()
+}
+
+func applyConfigFile(flags *pflag.FlagSet) error {
+	if ConfigPath == "" {
+		ConfigPath = os.Getenv(envPrefix + "_CONFIG")
+	}
+
+	viper.SetConfigFile(ConfigPath)
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			log.Info().Err(err).Msg("Error reading config file")
+			return err
+		}
+	}
+
+	// keys removed from the config file go back to their defaults, before
+	// the keys still set are applied as an alias shares its target's value
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || !configFileFlags[f.Name] || viper.IsSet(f.Name) {
+			return
+		}
+
+		delete(configFileFlags, f.Name)
+		if err = resetFlag(f); err != nil {
+			err = fmt.Errorf("could not reset flag %s: %w", f.Name, err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Credits to https://carolynvanslyck.com/blog/2020/08/sting-of-the-viper/
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || commandLineFlags[f.Name] || !viper.IsSet(f.Name) {
+			return
+		}
+
+		if target, ok := flagAliasTarget(f.Name); ok {
+			// the new key wins if both are set
+			if commandLineFlags[target] || viper.IsSet(target) {
+				return
+			}
+
+			log.Warn().
+				Str("key", f.Name).
+				Str("replacement", target).
+				Msg("Config key is deprecated")
+		}
+
+		// slices and maps would be added to on every reload otherwise, the
+		// environment gives them as on the command line
+		raw, isString := viper.Get(f.Name).(string)
+		switch value := f.Value.(type) {
+		case pflag.SliceValue:
+			values := viper.GetStringSlice(f.Name)
+			if isString {
+				values, err = splitFlagList(raw)
+			}
+			if err == nil {
+				err = value.Replace(values)
+			}
+		case *mapValue[string]:
+			err = replaceMapFlag(value, f.Name, raw, isString)
+		case *mapValue[int64]:
+			err = replaceMapFlag(value, f.Name, raw, isString)
+		default:
+			err = f.Value.Set(fmt.Sprintf("%v", viper.Get(f.Name)))
+		}
+
+		if err != nil {
+			err = fmt.Errorf("could not set flag %s: %w", f.Name, err)
+			return
+		}
+		configFileFlags[f.Name] = true
+	})
+
+	return err
+}
+
+// replaceMapFlag sets a map flag to the pairs of its config key, raw in the
+// flag syntax when the key is an environment variable.
+func replaceMapFlag[V string | int64](value *mapValue[V], key, raw string, isString bool) error {
+	if !isString {
+		return value.Replace(viper.GetStringMapString(key))
+	}
+
+	pairs, err := splitFlagList(raw)
+	if err != nil {
+		return err
+	}
+
+	value.Reset()
+	for _, pair := range pairs {
+		if err := value.Set(pair); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitFlagList splits a comma-separated flag value, quoted as CSV.
+func splitFlagList(raw string) ([]string, error) {
+	if raw == "" {
+		return []string{}, nil
+	}
+
+	return csv.NewReader(strings.NewReader(raw)).Read()
+}
+
+// resetFlag sets a flag back to its default value.
+func resetFlag(f *pflag.Flag) error {
+	switch value := f.Value.(type) {
+	case interface{ Reset() }:
+		value.Reset()
+		return nil
+	case pflag.SliceValue:
+		defaults := []string{}
+		if trimmed := strings.Trim(f.DefValue, "[]"); trimmed != "" {
+			defaults = strings.Split(trimmed, ",")
+		}
+		return value.Replace(defaults)
+	default:
+		return f.Value.Set(f.DefValue)
+	}
+}
+
+// WatchConfig re-applies the config file when it changes on disk or on SIGHUP,
+// then calls onReload so the running components can pick up the new values.
+// Reloads run one at a time on a single goroutine.
+func WatchConfig(flags *pflag.FlagSet, onReload func()) {
+	if ConfigPath == "" {
+		return
+	}
+
+	reloads := make(chan string, 1)
+	requestReload := func(source string) {
+		// a pending reload reads the latest file anyway
+		select {
+		case reloads <- source:
+		default:
+		}
+	}
+
+	if err := watchConfigFile(ConfigPath, requestReload); err != nil {
+		log.Error().Err(err).Msg("Could not watch config file, reload it with SIGHUP")
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			requestReload("SIGHUP")
+		}
+	}()
+
+	go func() {
+		for source := range reloads {
+			configMutex.Lock()
+			err := applyConfigFile(flags)
+			if err == nil {
+				var config ExporterConfig
+				if config, err = configFromFlags(); err == nil {
+					exporterConfig = config
+				}
+			}
+			configMutex.Unlock()
+
+			if err != nil {
+				log.Error().Err(err).Str("source", source).Msg("Could not reload config file")
+				continue
+			}
+
+			log.Info().Str("source", source).Msg("Reloaded config file")
+			onReload()
+		}
+	}()
+}
+
+// watchConfigFile calls onChange when the config file is written or replaced.
+// It watches the directory, as editors and ConfigMap updates replace the file.
+func watchConfigFile(path string, onChange func(source string)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	file := filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == file && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					onChange("file-watch")
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Error().Err(err).Msg("Error watching config file")
+			}
+		}
+	}()
+
+	return nil
+}
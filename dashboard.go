@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	dashboardTitle  string
+	dashboardOutput string
+)
+
+type dashboardPanelSpec struct {
+	Title  string
+	Expr   string
+	Legend string
+	Unit   string
+}
+
+// dashboardPanels are the panels of the generated dashboard, $selector is
+// replaced with the const labels and the validator variable.
+var dashboardPanels = []dashboardPanelSpec{
+	{Title: "Miss counter", Expr: "miss_counter{$selector}", Legend: "{{valoper}}", Unit: "short"},
+	{Title: "Miss rate", Expr: "miss_rate{$selector}", Legend: "{{valoper}}", Unit: "percentunit"},
+	{Title: "Slash window progress", Expr: "window_progress{$constSelector} / window_size{$constSelector}", Legend: "progress", Unit: "percentunit"},
+	{Title: "Uptime", Expr: "validator_uptime_percent{$selector}", Legend: "{{valoper}}", Unit: "percent"},
+	{Title: "Missed blocks", Expr: "validator_missed_blocks{$selector}", Legend: "{{valoper}}", Unit: "short"},
+	{Title: "Feeder balance", Expr: "feeder_balance{$constSelector}", Legend: "{{feeder}} {{denom}}", Unit: "short"},
+	{Title: "Feeder spending", Expr: "rate(feeder_balance_outflow_total{$constSelector}[1h]) * 86400", Legend: "{{feeder}} {{denom}} per day", Unit: "short"},
+	{Title: "Commission and rewards", Expr: "validator_commission{$selector} or validator_rewards{$selector}", Legend: "{{__name__}} {{denom}}", Unit: "short"},
+	{Title: "Exchange rates", Expr: "oracle_exchange_rate{$constSelector}", Legend: "{{denom}}", Unit: "short"},
+	{Title: "Price deviation", Expr: "oracle_price_deviation_percent{$constSelector}", Legend: "{{denom}}", Unit: "percent"},
+}
+
+// dashboardMetricRegexp matches the metric names of the panel expressions,
+// the names are followed by their selector.
+var dashboardMetricRegexp = regexp.MustCompile(`[a-zA-Z_:][a-zA-Z0-9_:]*\{`)
+
+// dashboardExpr names the metrics of the expression like the exporter does.
+func dashboardExpr(expr string, namer *MetricNamer) string {
+	return dashboardMetricRegexp.ReplaceAllStringFunc(expr, func(match string) string {
+		return namer.Name(strings.TrimSuffix(match, "{")) + "{"
+	})
+}
+
+// dashboardSelectors returns the label matchers of the const labels, and
+// those with the validator variable too.
+func dashboardSelectors(constLabels map[string]string) (string, string) {
+	names := make([]string, 0, len(constLabels))
+	for name := range constLabels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	matchers := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		matchers = append(matchers, fmt.Sprintf("%s=%q", name, constLabels[name]))
+	}
+
+	constSelector := strings.Join(matchers, ",")
+	selector := strings.Join(append(matchers, `valoper=~"$valoper"`), ",")
+
+	return constSelector, selector
+}
+
+// NewDashboard builds a Grafana dashboard for the metrics of the exporter,
+// filtered by the const labels and with the validators as a variable.
+func NewDashboard(title string, constLabels map[string]string, valopers []string, namer *MetricNamer) map[string]interface{} {
+	constSelector, selector := dashboardSelectors(constLabels)
+
+	panels := make([]map[string]interface{}, 0, len(dashboardPanels))
+	for index, spec := range dashboardPanels {
+		expr := strings.ReplaceAll(dashboardExpr(spec.Expr, namer), "$constSelector", constSelector)
+		expr = strings.ReplaceAll(expr, "$selector", selector)
+
+		panels = append(panels, map[string]interface{}{
+			"id":         index + 1,
+			"type":       "timeseries",
+			"title":      spec.Title,
+			"datasource": map[string]string{"type": "prometheus", "uid": "${datasource}"},
+			"gridPos":    map[string]int{"h": 8, "w": 12, "x": (index % 2) * 12, "y": (index / 2) * 8},
+			"fieldConfig": map[string]interface{}{
+				"defaults":  map[string]interface{}{"unit": spec.Unit},
+				"overrides": []interface{}{},
+			},
+			"targets": []map[string]interface{}{
+				{"refId": "A", "expr": expr, "legendFormat": spec.Legend},
+			},
+		})
+	}
+
+	valoperVariable := map[string]interface{}{
+		"name":       "valoper",
+		"label":      "Validator",
+		"type":       "query",
+		"datasource": map[string]string{"type": "prometheus", "uid": "${datasource}"},
+		"query":      fmt.Sprintf("label_values(%s{%s}, valoper)", namer.Name("miss_counter"), constSelector),
+		"refresh":    2,
+		"multi":      true,
+		"includeAll": true,
+	}
+
+	if len(valopers) > 0 {
+		valoperVariable["current"] = map[string]interface{}{"text": valopers, "value": valopers}
+	}
+
+	return map[string]interface{}{
+		"title":         title,
+		"uid":           "oracle-exporter",
+		"schemaVersion": 38,
+		"editable":      true,
+		"refresh":       "1m",
+		"time":          map[string]string{"from": "now-24h", "to": "now"},
+		"tags":          []string{"oracle", "cosmos"},
+		"templating": map[string]interface{}{
+			"list": []map[string]interface{}{
+				{
+					"name":  "datasource",
+					"label": "Data source",
+					"type":  "datasource",
+					"query": "prometheus",
+				},
+				valoperVariable,
+			},
+		},
+		"panels": panels,
+	}
+}
+
+func WriteDashboard(writer io.Writer, dashboard map[string]interface{}) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(dashboard)
+}
+
+var dashboardCmd = &cobra.Command{
+	Use:   "dashboard",
+	Short: "Print a Grafana dashboard for the metrics of the exporter",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config := CurrentConfig()
+
+		// the panels use the metric names the exporter exports
+		namer, err := NewMetricNamer(config.Metrics.Namespace, config.Metrics.Renames)
+		if err != nil {
+			return err
+		}
+
+		dashboard := NewDashboard(dashboardTitle, config.Metrics.ConstLabels, config.Validators, namer)
+		if dashboardOutput == "" {
+			return WriteDashboard(os.Stdout, dashboard)
+		}
+
+		file, err := os.Create(dashboardOutput)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if err := WriteDashboard(file, dashboard); err != nil {
+			return err
+		}
+
+		log.Info().
+			Str("file", dashboardOutput).
+			Int("panels", len(dashboardPanels)).
+			Msg("Wrote dashboard")
+		return nil
+	},
+}
+
+func init() {
+	dashboardCmd.Flags().StringVar(&dashboardTitle, "title", "Oracle exporter", "Title of the dashboard")
+	dashboardCmd.Flags().StringVarP(&dashboardOutput, "output", "o", "", "File to write the dashboard to, stdout by default")
+}
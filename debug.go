@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// maxDebugResponseSize caps the JSON of a single response on /debug/query,
+// e.g. the full validator set of a large chain.
+const maxDebugResponseSize = 256 * 1024
+
+type debugResponse struct {
+	// wire format and type of the reply, decoded only when requested
+	response   []byte
+	replyType  reflect.Type
+	err        string
+	receivedAt time.Time
+}
+
+// DebugResponses keeps the last node response of every gRPC method, so it
+// can be told whether wrong metrics come from the exporter or the chain.
+type DebugResponses struct {
+	mutex     sync.Mutex
+	responses map[string]debugResponse
+	limiter   *TokenBucket
+}
+
+// debugResponses is set with --debug-query-interval.
+var debugResponses *DebugResponses
+
+func NewDebugResponses(interval time.Duration) *DebugResponses {
+	return &DebugResponses{
+		responses: make(map[string]debugResponse),
+		limiter:   NewTokenBucket(1/interval.Seconds(), 1),
+	}
+}
+
+func (d *DebugResponses) Interceptor() grpc.UnaryClientInterceptor {
+	codec := encoding.GetCodec("proto")
+
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		response := debugResponse{
+			replyType:  reflect.TypeOf(reply),
+			receivedAt: time.Now().UTC(),
+		}
+
+		if err != nil {
+			response.err = err.Error()
+		} else if response.response, err = codec.Marshal(reply); err != nil {
+			// the query itself succeeded
+			return nil
+		}
+
+		d.mutex.Lock()
+		d.responses[method] = response
+		d.mutex.Unlock()
+
+		return err
+	}
+}
+
+// collectorForMethod names the module a gRPC method belongs to, e.g. oracle
+// for /umee.oracle.v1.Query/ExchangeRates, without the version segments.
+func collectorForMethod(method string) string {
+	service, _, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	parts := strings.Split(service, ".")
+
+	for index := len(parts) - 2; index >= 0; index-- {
+		part := parts[index]
+		if len(part) > 1 && part[0] == 'v' && part[1] >= '0' && part[1] <= '9' {
+			continue
+		}
+
+		return part
+	}
+
+	return service
+}
+
+// DebugQueryHandler serves the last responses of the methods of the given
+// collector as JSON. The responses are decoded back into the messages the
+// exporter knows, so only the fields it actually reads are shown.
+func DebugQueryHandler(w http.ResponseWriter, r *http.Request, debug *DebugResponses) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	if !debug.limiter.Allow() {
+		sublogger.Debug().Msg("Debug query rate limited")
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+		return
+	}
+
+	collector := r.URL.Query().Get("collector")
+	if collector == "" {
+		http.Error(w, "The collector parameter is required, e.g. ?collector=oracle", http.StatusBadRequest)
+		return
+	}
+
+	type methodResponse struct {
+		Method     string          `json:"method"`
+		ReceivedAt time.Time       `json:"received_at"`
+		Error      string          `json:"error,omitempty"`
+		Truncated  bool            `json:"truncated,omitempty"`
+		Response   json.RawMessage `json:"response,omitempty"`
+	}
+
+	codec := encoding.GetCodec("proto")
+	responses := []methodResponse{}
+
+	debug.mutex.Lock()
+	for method, response := range debug.responses {
+		if !strings.EqualFold(collectorForMethod(method), collector) {
+			continue
+		}
+
+		entry := methodResponse{
+			Method:     method,
+			ReceivedAt: response.receivedAt,
+			Error:      response.err,
+		}
+
+		if response.response != nil {
+			reply := reflect.New(response.replyType.Elem()).Interface()
+			if err := codec.Unmarshal(response.response, reply); err != nil {
+				entry.Error = err.Error()
+			} else if value, err := json.Marshal(reply); err != nil {
+				entry.Error = err.Error()
+			} else if len(value) > maxDebugResponseSize {
+				entry.Truncated = true
+			} else {
+				entry.Response = value
+			}
+		}
+
+		responses = append(responses, entry)
+	}
+	debug.mutex.Unlock()
+
+	sort.Slice(responses, func(i, j int) bool {
+		return responses[i].Method < responses[j].Method
+	})
+
+	if len(responses) == 0 {
+		http.Error(w, "No responses of this collector yet", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(map[string]interface{}{
+		"collector": collector,
+		"responses": responses,
+	}); err != nil {
+		sublogger.Error().Err(err).Msg("Could not write debug query response")
+	}
+
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/debug/query?collector="+collector).
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// QueryDeduplicator sends identical queries that are in flight at the same
+// time to the node once, so several Prometheus servers scraping together
+// don't multiply the load on the node. Identical means the same target,
+// method, request and height.
+type QueryDeduplicator struct {
+	group singleflight.Group
+}
+
+// queryDeduplicator is set with --dedup-queries.
+var queryDeduplicator *QueryDeduplicator
+
+func NewQueryDeduplicator() *QueryDeduplicator {
+	return &QueryDeduplicator{}
+}
+
+func (d *QueryDeduplicator) Interceptor() grpc.UnaryClientInterceptor {
+	codec := encoding.GetCodec("proto")
+
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		data, err := codec.Marshal(req)
+		if err != nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		key := cc.Target() + " " + method + " " + string(data)
+		if md, ok := metadata.FromOutgoingContext(ctx); ok {
+			key += " " + strings.Join(md.Get(grpctypes.GRPCBlockHeightHeader), ",")
+		}
+
+		// the response is shared encoded, as every caller has its own reply
+		leader := false
+		var leaderErr error
+		value, err, _ := d.group.Do(key, func() (interface{}, error) {
+			leader = true
+			if leaderErr = invoker(ctx, method, req, reply, cc, opts...); leaderErr != nil {
+				return nil, leaderErr
+			}
+
+			return codec.Marshal(reply)
+		})
+
+		if leader {
+			return leaderErr
+		}
+
+		selfGRPCDeduplicated.WithLabelValues(method).Inc()
+
+		// the query was cancelled with the scrape that sent it, not this one
+		if err != nil && ctx.Err() == nil && isContextError(err) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return codec.Unmarshal(value.([]byte), reply)
+	}
+}
+
+func isContextError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	switch status.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type DenomInfo struct {
+	Base     string
+	Display  string
+	Exponent uint32
+	// IBCBaseDenom and IBCPath are the denom on the origin chain of an ibc/
+	// denom and the path it was transferred through, e.g. transfer/channel-0
+	IBCBaseDenom string
+	IBCPath      string
+}
+
+// DenomResolver converts base denom amounts to display units using the bank
+// DenomsMetadata, the built-in denom pack of the chain for denoms without
+// metadata, and overrides from the config for chains that publish wrong
+// metadata.
+type DenomResolver struct {
+	displayOverrides  map[string]string
+	exponentOverrides map[string]int64
+	// decimals to round converted values to, negative to keep them as is
+	precision int
+	// chain-id of the denom pack, auto to use the one of the node
+	pack string
+	// base or display denoms balances and rewards are exported for, all if empty
+	allowlist map[string]bool
+
+	mutex sync.Mutex
+	cache map[string]DenomInfo
+}
+
+func NewDenomResolver(displayOverrides map[string]string, exponentOverrides map[string]int64, precision int, pack string, allowlist []string) *DenomResolver {
+	resolver := &DenomResolver{
+		displayOverrides:  displayOverrides,
+		exponentOverrides: exponentOverrides,
+		precision:         precision,
+		pack:              pack,
+		allowlist:         make(map[string]bool, len(allowlist)),
+		cache:             make(map[string]DenomInfo),
+	}
+
+	for _, denom := range allowlist {
+		resolver.allowlist[denom] = true
+	}
+
+	return resolver
+}
+
+// Allowed tells whether balances and rewards in the denom are exported,
+// matching the allowlist against the base and the display denom.
+func (d *DenomResolver) Allowed(info DenomInfo) bool {
+	return len(d.allowlist) == 0 || d.allowlist[info.Base] || d.allowlist[info.Display]
+}
+
+// packEntry looks the denom up in the denom pack, resolving the chain-id of
+// the node on first use.
+func (d *DenomResolver) packEntry(ctx context.Context, grpcConn *grpc.ClientConn, denom string) (DenomInfo, bool) {
+	d.mutex.Lock()
+	pack := d.pack
+	d.mutex.Unlock()
+
+	switch pack {
+	case DenomPackNone:
+		return DenomInfo{}, false
+	case DenomPackAuto:
+		serviceClient := tmservice.NewServiceClient(grpcConn)
+		response, err := serviceClient.GetNodeInfo(ctx, &tmservice.GetNodeInfoRequest{})
+		if err != nil || response.DefaultNodeInfo == nil {
+			return DenomInfo{}, false
+		}
+
+		pack = response.DefaultNodeInfo.Network
+		d.mutex.Lock()
+		d.pack = pack
+		d.mutex.Unlock()
+	}
+
+	return denomPackEntry(pack, denom)
+}
+
+func (d *DenomResolver) Resolve(ctx context.Context, grpcConn *grpc.ClientConn, denom string) DenomInfo {
+	d.mutex.Lock()
+	info, ok := d.cache[denom]
+	d.mutex.Unlock()
+
+	if ok {
+		return info
+	}
+
+	info = DenomInfo{Base: denom, Display: denom}
+	cacheable := true
+
+	bankClient := banktypes.NewQueryClient(grpcConn)
+	response, err := bankClient.DenomMetadata(ctx, &banktypes.QueryDenomMetadataRequest{Denom: denom})
+	// IBC and factory denoms often have no metadata, the chain says so with NotFound
+	notFound := status.Code(err) == codes.NotFound
+	var packInfo DenomInfo
+	inPack := false
+	if err != nil || len(response.Metadata.DenomUnits) == 0 {
+		packInfo, inPack = d.packEntry(ctx, grpcConn, denom)
+	}
+
+	switch {
+	case inPack:
+		// the pack is static, no need to query the metadata again
+		info = packInfo
+	case notFound:
+		log.Debug().
+			Str("denom", denom).
+			Msg("Denom has no metadata, using base denom")
+	case err != nil:
+		log.Debug().
+			Str("denom", denom).
+			Err(err).
+			Msg("Could not get denom metadata, using base denom")
+		// might be a temporary failure, try again on the next scrape
+		cacheable = false
+	default:
+		info = displayUnit(denom, response.Metadata)
+	}
+
+	// IBC vouchers without metadata are shown as the denom on their origin chain
+	if cacheable && !inPack && info.Display == denom && IsIBCDenom(denom) {
+		trace, err := DenomTrace(ctx, grpcConn, denom)
+		switch {
+		case err == nil:
+			info.Display = trace.BaseDenom
+			info.IBCBaseDenom = trace.BaseDenom
+			info.IBCPath = trace.Path
+		case status.Code(err) == codes.NotFound:
+			log.Debug().
+				Str("denom", denom).
+				Msg("Denom has no IBC trace, using base denom")
+		default:
+			log.Debug().
+				Str("denom", denom).
+				Err(err).
+				Msg("Could not get IBC denom trace, using base denom")
+			cacheable = false
+		}
+	}
+
+	if display, ok := d.displayOverrides[denom]; ok {
+		info.Display = display
+	}
+
+	if exponent, ok := d.exponentOverrides[denom]; ok {
+		info.Exponent = uint32(exponent)
+	}
+
+	if cacheable {
+		d.mutex.Lock()
+		d.cache[denom] = info
+		d.mutex.Unlock()
+	}
+
+	return info
+}
+
+// displayUnit picks the display unit from the metadata, or the unit with the
+// largest exponent if the metadata doesn't name one of its units as display.
+func displayUnit(denom string, metadata banktypes.Metadata) DenomInfo {
+	info := DenomInfo{Base: denom, Display: denom}
+	for _, unit := range metadata.DenomUnits {
+		if unit.Denom == metadata.Display {
+			info.Display = unit.Denom
+			info.Exponent = unit.Exponent
+			return info
+		}
+
+		if unit.Exponent > info.Exponent {
+			info.Display = unit.Denom
+			info.Exponent = unit.Exponent
+		}
+	}
+
+	return info
+}
+
+// Resolved reports whether the exponent of the denom is known, either from
+// the fetched metadata or from an override.
+func (d *DenomResolver) Resolved(denom string) bool {
+	if _, ok := d.exponentOverrides[denom]; ok {
+		return true
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	_, ok := d.cache[denom]
+	return ok
+}
+
+// DenomOverride changes the conversion for a single scrape, so the same
+// endpoint can be viewed in base and display units by different consumers.
+type DenomOverride struct {
+	// display denom label, "base" to keep base units
+	Display     string
+	Exponent    uint32
+	HasExponent bool
+}
+
+// maxOverrideExponent bounds ?exponent=, no chain uses more than 18 decimals
+const maxOverrideExponent = 30
+
+func ParseDenomOverride(query url.Values) (DenomOverride, error) {
+	override := DenomOverride{Display: query.Get("denom")}
+
+	if value := query.Get("exponent"); value != "" {
+		exponent, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || exponent > maxOverrideExponent {
+			return override, fmt.Errorf("invalid exponent %q, expected 0 to %d", value, maxOverrideExponent)
+		}
+
+		override.Exponent = uint32(exponent)
+		override.HasExponent = true
+	}
+
+	return override, nil
+}
+
+func (o DenomOverride) Apply(info DenomInfo) DenomInfo {
+	switch o.Display {
+	case "":
+	case "base":
+		info.Display = info.Base
+		info.Exponent = 0
+	default:
+		info.Display = o.Display
+	}
+
+	if o.HasExponent {
+		info.Exponent = o.Exponent
+	}
+
+	return info
+}
+
+// Convert returns the amount of the coin in display units.
+func (d *DenomResolver) Convert(info DenomInfo, amount sdk.Int) float64 {
+	value := new(big.Float).SetInt(amount.BigInt())
+	if info.Exponent > 0 {
+		divisor := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(info.Exponent)), nil))
+		value.Quo(value, divisor)
+	}
+
+	converted, _ := value.Float64()
+	if d.precision < 0 {
+		return converted
+	}
+
+	multiplier := math.Pow10(d.precision)
+	return math.Round(converted*multiplier) / multiplier
+}
+
+func RawAmount(amount sdk.Int) float64 {
+	value, _ := new(big.Float).SetInt(amount.BigInt()).Float64()
+	return value
+}
@@ -0,0 +1,57 @@
+package main
+
+const (
+	DenomPackAuto = "auto"
+	DenomPackNone = "none"
+)
+
+// denomPacks are the display units of well-known chains by chain-id, used
+// for denoms the chain publishes no bank metadata for.
+var denomPacks = map[string][]DenomInfo{
+	"umee-1": {
+		{Base: "uumee", Display: "umee", Exponent: 6},
+	},
+	"agamotto": {
+		{Base: "uojo", Display: "ojo", Exponent: 6},
+	},
+	"kaiyo-1": {
+		{Base: "ukuji", Display: "kuji", Exponent: 6},
+	},
+	"phoenix-1": {
+		{Base: "uluna", Display: "luna", Exponent: 6},
+	},
+	"columbus-5": {
+		{Base: "uluna", Display: "lunc", Exponent: 6},
+		{Base: "uusd", Display: "ustc", Exponent: 6},
+	},
+	"pacific-1": {
+		{Base: "usei", Display: "sei", Exponent: 6},
+	},
+	"injective-1": {
+		{Base: "inj", Display: "inj", Exponent: 18},
+	},
+	"neutron-1": {
+		{Base: "untrn", Display: "ntrn", Exponent: 6},
+	},
+}
+
+// denomPackEntry looks the denom up in the pack of the chain.
+func denomPackEntry(chainID string, denom string) (DenomInfo, bool) {
+	for _, info := range denomPacks[chainID] {
+		if info.Base == denom {
+			return info, true
+		}
+	}
+
+	return DenomInfo{}, false
+}
+
+// addDenomPack adds the denoms to the pack of the chain, the built-in entries
+// win over the added ones.
+func addDenomPack(chainID string, infos []DenomInfo) {
+	for _, info := range infos {
+		if _, ok := denomPackEntry(chainID, info.Base); !ok {
+			denomPacks[chainID] = append(denomPacks[chainID], info)
+		}
+	}
+}
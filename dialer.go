@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+// networkForFamily maps the --ip-family value to the network name
+// understood by net.Dialer and net.Listen.
+func networkForFamily(family string) (string, error) {
+	switch strings.ToLower(family) {
+	case "", "any":
+		return "tcp", nil
+	case "ipv4", "4":
+		return "tcp4", nil
+	case "ipv6", "6":
+		return "tcp6", nil
+	default:
+		return "", fmt.Errorf("unsupported ip family %q, expected any, ipv4 or ipv6", family)
+	}
+}
+
+func newNetDialer() *net.Dialer {
+	// with the "tcp" network and both A and AAAA records resolved,
+	// net.Dialer races the families (RFC 6555, Happy Eyeballs)
+	return &net.Dialer{
+		Timeout:       30 * time.Second,
+		KeepAlive:     30 * time.Second,
+		FallbackDelay: 300 * time.Millisecond,
+	}
+}
+
+// DialNode opens a gRPC connection to the node. The address may be a hostname,
+// an IPv4 literal or a bracketed IPv6 literal, e.g. [2001:db8::1]:9090.
+// With a non-zero dnsRefreshInterval the hostname is re-resolved periodically,
+// unless the node is reached through a proxy.
+func DialNode(address string, family string, dnsRefreshInterval time.Duration) (*grpc.ClientConn, error) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	network, err := networkForFamily(family)
+	if err != nil {
+		return nil, err
+	}
+
+	// the outcome of a query after the retries and from the cache counts
+	interceptors := []grpc.UnaryClientInterceptor{collectResultInterceptor()}
+	if tracer != nil {
+		interceptors = append(interceptors, tracer.Interceptor())
+	}
+	interceptors = append(interceptors, queryCache.Interceptor())
+	if queryDeduplicator != nil {
+		interceptors = append(interceptors, queryDeduplicator.Interceptor())
+	}
+	if upstreamRecording != nil {
+		interceptors = append(interceptors, upstreamRecording.Interceptor())
+	}
+	if debugResponses != nil {
+		interceptors = append(interceptors, debugResponses.Interceptor())
+	}
+	// every attempt is rate limited, counted in the self-metrics and
+	// authenticated, also when it is sent in a batch
+	interceptors = append(interceptors,
+		retryPolicy.Interceptor(),
+		queryRateLimiter.Interceptor(address),
+		selfMetricsInterceptor(address),
+	)
+	// the batches go to the RPC of --node, the fallbacks and probe targets
+	// are queried over gRPC
+	if queryBatcher != nil && address == CurrentConfig().Endpoints.Node {
+		interceptors = append(interceptors, queryBatcher.Interceptor())
+	}
+	interceptors = append(interceptors, endpointAuth.Interceptor(address))
+
+	// gRPC doesn't look at the proxy environment variables with a custom dialer
+	proxyURL, err := proxyConfig.URL(address, "https")
+	if err != nil {
+		return nil, err
+	}
+
+	queries := CurrentConfig().Queries
+
+	dialer := newNetDialer()
+	options := []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialProxy(ctx, dialer, network, proxyURL, addr)
+		}),
+		grpc.WithChainUnaryInterceptor(interceptors...),
+		// validator sets and denom metadata of big chains exceed the 4MB default
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(queries.MaxRecvMsgSize)),
+	}
+
+	if queries.KeepaliveTime > 0 {
+		// a connection whose pings aren't answered within the timeout is
+		// closed and redialed instead of hanging the queries, or the next
+		// scrape after a dead idle period. Nodes with the default policy
+		// answer pings more often than every 5 minutes with too_many_pings,
+		// gRPC then doubles the interval for the connection.
+		options = append(options, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                queries.KeepaliveTime,
+			Timeout:             queries.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		}))
+	}
+
+	if port == "443" {
+		creds := credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})
+		options = append(options, grpc.WithTransportCredentials(creds))
+	} else {
+		options = append(options, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	// through a proxy the proxy resolves the hostname, e.g. with socks5h,
+	// re-resolving it here would dial the proxy with an address instead
+	target := address
+	if dnsRefreshInterval > 0 && proxyURL == nil {
+		builder, err := newReresolveBuilder(dnsRefreshInterval, family)
+		if err != nil {
+			return nil, err
+		}
+
+		target = reresolveScheme + ":///" + address
+		options = append(options, grpc.WithResolvers(builder))
+	}
+
+	return grpc.Dial(target, options...)
+}
+
+// Listen opens the HTTP listener, binding only to the preferred family if set.
+func Listen(address string, family string) (net.Listener, error) {
+	network, err := networkForFamily(family)
+	if err != nil {
+		return nil, err
+	}
+
+	return net.Listen(network, address)
+}
+
+type nodeEndpoint struct {
+	address string
+	conn    *grpc.ClientConn
+}
+
+// NodeConnection holds the gRPC connections to the node and its fallbacks,
+// queries go to the selected one, see CheckHealth. The connection to the node
+// is swapped when its address changes on config reload.
+type NodeConnection struct {
+	mutex sync.RWMutex
+	// the node first, then the fallbacks
+	endpoints []*nodeEndpoint
+	selected  int
+}
+
+func NewNodeConnection(address string, fallbacks []string, family string, dnsRefreshInterval time.Duration) (*NodeConnection, error) {
+	n := &NodeConnection{}
+
+	for _, target := range append([]string{address}, fallbacks...) {
+		conn, err := DialNode(target, family, dnsRefreshInterval)
+		if err != nil {
+			n.Close()
+			return nil, fmt.Errorf("could not dial %s: %w", target, err)
+		}
+
+		n.endpoints = append(n.endpoints, &nodeEndpoint{address: target, conn: conn})
+	}
+
+	return n, nil
+}
+
+// Get returns the connection to the selected endpoint.
+func (n *NodeConnection) Get() *grpc.ClientConn {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+
+	return n.endpoints[n.selected].conn
+}
+
+// WatchState exports whether the connection to the node is up and its state.
+// gRPC keeps reconnecting with backoff on its own, an idle connection is woken
+// up so the state tells about the node and not about the absence of queries,
+// and a failing one is retried right away at every interval rather than after
+// a backoff grown during a long outage.
+func (n *NodeConnection) WatchState(interval time.Duration) {
+	logger := log.With().Str("component", "node-connection").Logger()
+
+	var address string
+	previous := n.Get().GetState()
+
+	for {
+		conn := n.Get()
+		state := conn.GetState()
+
+		switch state {
+		case connectivity.Idle:
+			conn.Connect()
+		case connectivity.TransientFailure:
+			conn.ResetConnectBackoff()
+		}
+
+		if endpoint := n.Address(); endpoint != address {
+			// the node changed on reload
+			selfGRPCConnectionState.Reset()
+			address = endpoint
+		}
+		selfGRPCConnectionState.WithLabelValues(address).Set(float64(state))
+
+		if state != previous {
+			event := logger.Info()
+			if state == connectivity.TransientFailure {
+				event = logger.Warn()
+			}
+			event.
+				Str("address", address).
+				Str("state", state.String()).
+				Str("previous-state", previous.String()).
+				Msg("Node connection state changed")
+			previous = state
+		}
+
+		connected := 0.0
+		if state == connectivity.Ready {
+			connected = 1
+		}
+		selfNodeConnected.Set(connected)
+
+		// also wakes up periodically to pick up a connection swapped on reload
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		conn.WaitForStateChange(ctx, state)
+		cancel()
+	}
+}
+
+// Address returns the address of the selected endpoint.
+func (n *NodeConnection) Address() string {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+
+	return n.endpoints[n.selected].address
+}
+
+// NodeAddress returns the address of the node, whichever endpoint is selected.
+func (n *NodeConnection) NodeAddress() string {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+
+	return n.endpoints[0].address
+}
+
+// Redial replaces the connection to the node, the fallbacks are kept.
+func (n *NodeConnection) Redial(address string, family string, dnsRefreshInterval time.Duration) error {
+	conn, err := DialNode(address, family, dnsRefreshInterval)
+	if err != nil {
+		return err
+	}
+
+	n.mutex.Lock()
+	old := n.endpoints[0].conn
+	n.endpoints[0] = &nodeEndpoint{address: address, conn: conn}
+	n.mutex.Unlock()
+
+	// let in-flight queries on the old connection finish
+	time.AfterFunc(time.Minute, func() {
+		old.Close()
+	})
+
+	return nil
+}
+
+func (n *NodeConnection) Close() error {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+
+	var firstErr error
+	for _, endpoint := range n.endpoints {
+		if err := endpoint.conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
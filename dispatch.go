@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// alertRuleState is whether an alert rule fires for a validator and when it
+// was last notified, it is kept in the state file with the alerting state.
+type alertRuleState struct {
+	Firing       bool      `json:"firing"`
+	Notified     bool      `json:"notified"`
+	LastNotified time.Time `json:"last_notified,omitempty"`
+	// the firing alert wasn't notified because the validator was muted
+	Muted bool `json:"muted,omitempty"`
+}
+
+type queuedNotification struct {
+	route   []string
+	message string
+}
+
+// AlertDispatcher sits between the alert checks and the notifiers: an alert
+// is sent when its rule starts firing, not again while it keeps firing, and
+// not within the cooldown of its previous notification, it is sent once the
+// cooldown is over if it still fires then. An alert firing while its
+// validator is muted is sent when the mute ends. A resolution is sent
+// once the rule stops firing, if the firing was notified. Nothing is sent
+// for muted validators. The notifications queued during a check are sent
+// together, one message per route.
+type AlertDispatcher struct {
+	notifiers []Notifier
+
+	mutex    sync.Mutex
+	cooldown time.Duration
+	// valoper -> rule -> state
+	states map[string]map[string]*alertRuleState
+	// target -> end of the mute, set through the API and in the config
+	mutes       map[string]time.Time
+	configMutes map[string]time.Time
+	queue       []queuedNotification
+	logger      zerolog.Logger
+}
+
+func NewAlertDispatcher(notifiers []Notifier, cooldown time.Duration) *AlertDispatcher {
+	return &AlertDispatcher{
+		notifiers: notifiers,
+		cooldown:  cooldown,
+		states:    make(map[string]map[string]*alertRuleState),
+		mutes:     make(map[string]time.Time),
+		logger:    log.With().Str("component", "alert-dispatcher").Logger(),
+	}
+}
+
+func (d *AlertDispatcher) SetCooldown(cooldown time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.cooldown = cooldown
+}
+
+// Set records whether the rule fires for the validator and queues the
+// notification or the resolution when that changed. message describes the
+// firing alert, the resolution is generated from the rule name.
+func (d *AlertDispatcher) Set(rule string, valoper string, firing bool, message string, route []string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.states[valoper] == nil {
+		d.states[valoper] = make(map[string]*alertRuleState)
+	}
+
+	state, ok := d.states[valoper][rule]
+	if !ok {
+		state = &alertRuleState{}
+		d.states[valoper][rule] = state
+	}
+
+	switch {
+	case firing:
+		if !state.Firing {
+			state.Firing = true
+			state.Notified = false
+		} else if state.Notified {
+			return
+		}
+
+		// an alert not notified when it started firing is sent on a later
+		// check, once the validator isn't muted and the cooldown is over
+		if d.muted(valoper) {
+			d.logger.Debug().
+				Str("rule", rule).
+				Str("valoper", valoper).
+				Msg("Validator is muted, alert not notified")
+			state.Muted = true
+			return
+		}
+
+		// the cooldown doesn't hold back an alert that was muted, it would
+		// otherwise go unnoticed after the maintenance
+		now := time.Now()
+		if state.Muted {
+			d.logger.Info().
+				Str("rule", rule).
+				Str("valoper", valoper).
+				Msg("Mute ended, notifying the alert still firing")
+		} else if !state.LastNotified.IsZero() && now.Sub(state.LastNotified) < d.cooldown {
+			d.logger.Debug().
+				Str("rule", rule).
+				Str("valoper", valoper).
+				Time("last-notified", state.LastNotified).
+				Msg("Alert is in cooldown, not notified")
+			return
+		}
+
+		state.Notified = true
+		state.Muted = false
+		state.LastNotified = now
+		d.queue = append(d.queue, queuedNotification{route: route, message: message})
+	case !firing && state.Firing:
+		state.Firing = false
+		state.Muted = false
+		if !state.Notified {
+			return
+		}
+
+		state.Notified = false
+		if d.muted(valoper) {
+			return
+		}
+
+		d.queue = append(d.queue, queuedNotification{route: route, message: fmt.Sprintf(
+			"✅ <b>%s resolved</b>\nValidator: %s",
+			rule, valoper,
+		)})
+	}
+}
+
+// Send queues a message about the validator that has no firing state of its
+// own, e.g. an incident.
+func (d *AlertDispatcher) Send(valoper string, message string, route []string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.muted(valoper) {
+		return
+	}
+
+	d.queue = append(d.queue, queuedNotification{route: route, message: message})
+}
+
+// Flush sends the queued notifications, those with the same route are
+// joined into a single message.
+func (d *AlertDispatcher) Flush() {
+	d.mutex.Lock()
+	queue := d.queue
+	d.queue = nil
+	d.mutex.Unlock()
+
+	routes := make(map[string][]string)
+	messages := make(map[string][]string)
+	for _, notification := range queue {
+		key := strings.Join(notification.route, "+")
+		routes[key] = notification.route
+		messages[key] = append(messages[key], notification.message)
+	}
+
+	keys := make([]string, 0, len(messages))
+	for key := range messages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		sendNotification(d.logger, d.notifiers, routes[key], strings.Join(messages[key], "\n\n"))
+	}
+}
+
+// Forget drops the state of a validator that isn't watched anymore.
+func (d *AlertDispatcher) Forget(valoper string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	delete(d.states, valoper)
+}
+
+// Export returns a copy of the states to persist.
+func (d *AlertDispatcher) Export() map[string]map[string]alertRuleState {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	states := make(map[string]map[string]alertRuleState, len(d.states))
+	for valoper, rules := range d.states {
+		states[valoper] = make(map[string]alertRuleState, len(rules))
+		for rule, state := range rules {
+			states[valoper][rule] = *state
+		}
+	}
+
+	return states
+}
+
+// Restore replaces the states with persisted ones.
+func (d *AlertDispatcher) Restore(states map[string]map[string]alertRuleState) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.states = make(map[string]map[string]*alertRuleState, len(states))
+	for valoper, rules := range states {
+		d.states[valoper] = make(map[string]*alertRuleState, len(rules))
+		for rule, state := range rules {
+			state := state
+			d.states[valoper][rule] = &state
+		}
+	}
+}
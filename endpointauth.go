@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// EndpointAuth holds the headers sent to given endpoints, e.g. the API key of
+// a managed node provider, as gRPC metadata and with the HTTP requests.
+// Endpoints are given as host:port or host.
+type EndpointAuth struct {
+	// endpoint -> lowercase header -> value
+	headers map[string]map[string]string
+}
+
+var endpointAuth = &EndpointAuth{}
+
+// NewEndpointAuth parses the --endpoint-headers map of endpoint/header to
+// value and the --endpoint-basic-auth map of endpoint to user:password.
+// Values are secrets, see resolveSecret.
+func NewEndpointAuth(headers map[string]string, basicAuth map[string]string) (*EndpointAuth, error) {
+	auth := &EndpointAuth{headers: make(map[string]map[string]string)}
+
+	for key, value := range headers {
+		endpoint, header, ok := strings.Cut(key, "/")
+		if !ok || endpoint == "" || header == "" {
+			return nil, fmt.Errorf("invalid endpoint header %q, expected endpoint/header like grpc.example.com:443/x-api-key", key)
+		}
+
+		secret, err := resolveSecret(value)
+		if err != nil {
+			return nil, fmt.Errorf("header %s of %s: %w", header, endpoint, err)
+		}
+
+		auth.set(endpoint, header, secret)
+	}
+
+	for endpoint, value := range basicAuth {
+		secret, err := resolveSecret(value)
+		if err != nil {
+			return nil, fmt.Errorf("basic auth of %s: %w", endpoint, err)
+		}
+
+		if !strings.Contains(secret, ":") {
+			return nil, fmt.Errorf("basic auth of %s has to be user:password", endpoint)
+		}
+
+		auth.set(endpoint, "authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(secret)))
+	}
+
+	return auth, nil
+}
+
+func (a *EndpointAuth) set(endpoint string, header string, value string) {
+	endpoint = strings.ToLower(endpoint)
+	if a.headers[endpoint] == nil {
+		a.headers[endpoint] = make(map[string]string)
+	}
+
+	a.headers[endpoint][strings.ToLower(header)] = value
+}
+
+// resolveSecret reads env:NAME from the environment variable and file:PATH
+// from the file, so secrets don't have to be in the config. Other values are
+// taken as they are.
+func resolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "env:"):
+		name := strings.TrimPrefix(value, "env:")
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, "file:"):
+		content, err := os.ReadFile(strings.TrimPrefix(value, "file:"))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(content)), nil
+	default:
+		return value, nil
+	}
+}
+
+// Headers returns the headers of the endpoint, the ones set for host:port
+// taking precedence over the ones set for the host.
+func (a *EndpointAuth) Headers(address string) map[string]string {
+	address = strings.ToLower(address)
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+
+	headers := make(map[string]string)
+	for _, key := range []string{host, address} {
+		for header, value := range a.headers[key] {
+			headers[header] = value
+		}
+	}
+
+	return headers
+}
+
+// Interceptor adds the headers of the endpoint to the metadata of the queries.
+func (a *EndpointAuth) Interceptor(address string) grpc.UnaryClientInterceptor {
+	var pairs []string
+	for header, value := range a.Headers(address) {
+		pairs = append(pairs, header, value)
+	}
+
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if len(pairs) > 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, pairs...)
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// authTransport adds the headers of the endpoints to the HTTP requests sent
+// to them, e.g. to the Tendermint RPC of a provider.
+type authTransport struct {
+	next http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	address := r.URL.Host
+	if r.URL.Port() == "" {
+		port := "80"
+		if r.URL.Scheme == "https" {
+			port = "443"
+		}
+		address = net.JoinHostPort(r.URL.Hostname(), port)
+	}
+
+	headers := endpointAuth.Headers(address)
+	if len(headers) == 0 {
+		return t.next.RoundTrip(r)
+	}
+
+	// a RoundTripper must not modify the request
+	r = r.Clone(r.Context())
+	for header, value := range headers {
+		r.Header.Set(header, value)
+	}
+
+	return t.next.RoundTrip(r)
+}
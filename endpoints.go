@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+)
+
+type endpointHealth struct {
+	height  int64
+	latency time.Duration
+	err     error
+}
+
+// CheckHealth queries the latest height of every endpoint each interval and
+// exports their latency and their lag behind the highest height seen. The
+// queries stay on the selected endpoint while it answers and lags at most
+// maxLag blocks, otherwise they move to the freshest endpoint, the fastest
+// one among those at the same height.
+func (n *NodeConnection) CheckHealth(interval time.Duration, maxLag int64) {
+	logger := log.With().Str("component", "endpoint-health").Logger()
+	logger.Info().Dur("interval", interval).Int64("max-lag", maxLag).Msg("Started checking endpoints")
+
+	for {
+		n.checkHealth(logger, maxLag)
+		time.Sleep(interval)
+	}
+}
+
+func (n *NodeConnection) checkHealth(logger zerolog.Logger, maxLag int64) {
+	n.mutex.RLock()
+	endpoints := append([]*nodeEndpoint{}, n.endpoints...)
+	selected := n.selected
+	n.mutex.RUnlock()
+
+	health := make([]endpointHealth, len(endpoints))
+	var wg sync.WaitGroup
+	for index, endpoint := range endpoints {
+		wg.Add(1)
+		go func(index int, conn *grpc.ClientConn) {
+			defer wg.Done()
+			health[index] = checkEndpoint(conn)
+		}(index, endpoint.conn)
+	}
+	wg.Wait()
+
+	var maxHeight int64
+	for _, h := range health {
+		if h.err == nil && h.height > maxHeight {
+			maxHeight = h.height
+		}
+	}
+
+	freshest := -1
+	for index, h := range health {
+		address := endpoints[index].address
+		if h.err != nil {
+			logger.Warn().Err(h.err).Str("endpoint", address).Msg("Could not get latest height of endpoint")
+			// the last values would look current otherwise
+			selfEndpointHeight.DeleteLabelValues(address)
+			selfEndpointLag.DeleteLabelValues(address)
+			selfEndpointLatency.DeleteLabelValues(address)
+			continue
+		}
+
+		selfEndpointHeight.WithLabelValues(address).Set(float64(h.height))
+		selfEndpointLag.WithLabelValues(address).Set(float64(maxHeight - h.height))
+		selfEndpointLatency.WithLabelValues(address).Set(h.latency.Seconds())
+
+		if freshest < 0 || h.height > health[freshest].height ||
+			(h.height == health[freshest].height && h.latency < health[freshest].latency) {
+			freshest = index
+		}
+	}
+
+	current := health[selected]
+	if freshest >= 0 && freshest != selected && (current.err != nil || maxHeight-current.height > maxLag) {
+		n.mutex.Lock()
+		// the node may have been redialed on reload in the meantime
+		switched := n.endpoints[freshest] == endpoints[freshest]
+		if switched {
+			n.selected = freshest
+		}
+		n.mutex.Unlock()
+
+		if switched {
+			logger.Warn().
+				Str("from", endpoints[selected].address).
+				Str("to", endpoints[freshest].address).
+				Int64("height", health[freshest].height).
+				Msg("Switched to the freshest endpoint")
+			selected = freshest
+		}
+	}
+
+	for index, endpoint := range endpoints {
+		value := 0.0
+		if index == selected {
+			value = 1
+		}
+		selfEndpointSelected.WithLabelValues(endpoint.address).Set(value)
+	}
+}
+
+// checkEndpoint gets the latest height of the endpoint and how long that took.
+func checkEndpoint(conn *grpc.ClientConn) endpointHealth {
+	timeout := CurrentConfig().Queries.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	response, err := tmservice.NewServiceClient(conn).GetLatestBlock(ctx, &tmservice.GetLatestBlockRequest{})
+	if err != nil {
+		return endpointHealth{err: err}
+	}
+	if response.Block == nil {
+		return endpointHealth{err: errors.New("the latest block is missing from the response")}
+	}
+
+	return endpointHealth{
+		height:  response.Block.Header.Height,
+		latency: time.Since(start),
+	}
+}
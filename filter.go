@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// collectorNames are the collectors that can be turned off with --disable-collectors.
+var collectorNames = []string{
+	"band", "blocks", "general", "gov", "icq", "marketmap", "network",
+	"node", "slinky", "slo", "upgrade", "validators", "wallet",
+}
+
+// MetricFilter bounds the cardinality and the scrape time: disabled
+// collectors aren't served at all, and excluded metrics are dropped from the
+// responses. Collectors also skip the queries only excluded metrics need,
+// e.g. counting the delegators of a validator.
+type MetricFilter struct {
+	disabled map[string]bool
+	// glob patterns of metric names, all metrics are included if empty
+	include []string
+	exclude []string
+}
+
+var metricFilter = &MetricFilter{}
+
+// CurrentMetricFilter returns the filter in effect, a reload replaces it.
+func CurrentMetricFilter() *MetricFilter {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	return metricFilter
+}
+
+func SetMetricFilter(filter *MetricFilter) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	metricFilter = filter
+}
+
+func NewMetricFilter(disabledCollectors []string, include []string, exclude []string) (*MetricFilter, error) {
+	filter := &MetricFilter{
+		disabled: make(map[string]bool, len(disabledCollectors)),
+		include:  include,
+		exclude:  exclude,
+	}
+
+	for _, name := range disabledCollectors {
+		if !containsString(collectorNames, name) {
+			return nil, fmt.Errorf("unknown collector %q, expected one of %v", name, collectorNames)
+		}
+
+		filter.disabled[name] = true
+	}
+
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid metric pattern %q: %w", pattern, err)
+		}
+	}
+
+	return filter, nil
+}
+
+func (f *MetricFilter) CollectorEnabled(name string) bool {
+	return !f.disabled[name]
+}
+
+// Enabled tells whether the metric is exported, the patterns match the name
+// of schema 1 as well as the name of schema 2.
+func (f *MetricFilter) Enabled(name string) bool {
+	names := []string{name}
+	if rename, ok := metricsSchemaV2[name]; ok {
+		names = append(names, rename.Name)
+	}
+
+	return (len(f.include) == 0 || matchesAny(f.include, names)) && !matchesAny(f.exclude, names)
+}
+
+func matchesAny(patterns []string, names []string) bool {
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if matched, _ := path.Match(pattern, name); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (f *MetricFilter) Gatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	if len(f.include) == 0 && len(f.exclude) == 0 {
+		return gatherer
+	}
+
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+
+		filtered := make([]*dto.MetricFamily, 0, len(families))
+		for _, family := range families {
+			if f.Enabled(family.GetName()) {
+				filtered = append(filtered, family)
+			}
+		}
+
+		return filtered, err
+	})
+}
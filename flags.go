@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// flagAlias is a former flag name that keeps working, on the command line
+// and in the config file, so old configs don't break when flags are renamed.
+type flagAlias struct {
+	Name   string
+	Target string
+}
+
+var flagAliases = []flagAlias{
+	// the gRPC endpoint, named after the API endpoint of other exporters
+	{Name: "api", Target: "node"},
+	{Name: "denom", Target: "alert-feeder-denom"},
+}
+
+// RegisterFlagAliases adds the aliases as hidden flags sharing the value of
+// their target, pflag prints a deprecation warning when they are used. Only
+// the aliases of flags in the set are added.
+func RegisterFlagAliases(flags *pflag.FlagSet) {
+	for _, alias := range flagAliases {
+		target := flags.Lookup(alias.Target)
+		if target == nil {
+			continue
+		}
+
+		flags.Var(target.Value, alias.Name, target.Usage)
+		if err := flags.MarkDeprecated(alias.Name, "use --"+alias.Target+" instead"); err != nil {
+			log.Fatal().Err(err).Str("flag", alias.Name).Msg("Could not deprecate flag alias")
+		}
+	}
+}
+
+// addFlags adds the named flags of src to dst, for the commands using only a
+// few of the flags of a group.
+func addFlags(dst, src *pflag.FlagSet, names ...string) {
+	for _, name := range names {
+		flag := src.Lookup(name)
+		if flag == nil {
+			log.Fatal().Str("flag", name).Msg("Flag is not defined")
+		}
+
+		dst.AddFlag(flag)
+	}
+}
+
+func flagAliasTarget(name string) (string, bool) {
+	for _, alias := range flagAliases {
+		if alias.Name == name {
+			return alias.Target, true
+		}
+	}
+
+	return "", false
+}
+
+// mapValue is a key=value,... flag like pflag's stringToString and
+// stringToInt64. Unlike those, it never changes a map once set but replaces
+// it, so the maps handed out in configs stay as they are, and the config file
+// replaces the whole map on reload instead of merging into it.
+type mapValue[V string | int64] struct {
+	value    *map[string]V
+	defaults map[string]V
+	kind     string
+	parse    func(string) (V, error)
+	changed  bool
+}
+
+// StringMapVar registers a stringToString flag with replace semantics.
+func StringMapVar(flags *pflag.FlagSet, p *map[string]string, name string, value map[string]string, usage string) {
+	parse := func(s string) (string, error) { return s, nil }
+	flags.Var(newMapValue(p, value, "stringToString", parse), name, usage)
+}
+
+// Int64MapVar registers a stringToInt64 flag with replace semantics.
+func Int64MapVar(flags *pflag.FlagSet, p *map[string]int64, name string, value map[string]int64, usage string) {
+	parse := func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) }
+	flags.Var(newMapValue(p, value, "stringToInt64", parse), name, usage)
+}
+
+func newMapValue[V string | int64](p *map[string]V, defaults map[string]V, kind string, parse func(string) (V, error)) *mapValue[V] {
+	m := &mapValue[V]{value: p, defaults: defaults, kind: kind, parse: parse}
+	m.Reset()
+	return m
+}
+
+// Set parses key=value pairs, repeated flags on the command line add to the
+// pairs of the previous ones.
+func (m *mapValue[V]) Set(s string) error {
+	pairs, err := csv.NewReader(strings.NewReader(s)).Read()
+	if err != nil {
+		return err
+	}
+
+	values := map[string]V{}
+	if m.changed {
+		for key, value := range *m.value {
+			values[key] = value
+		}
+	}
+
+	for _, pair := range pairs {
+		key, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("%s must be formatted as key=value", pair)
+		}
+
+		value, err := m.parse(raw)
+		if err != nil {
+			return err
+		}
+		values[key] = value
+	}
+
+	*m.value = values
+	m.changed = true
+	return nil
+}
+
+// Replace sets the map to exactly the given pairs, e.g. from the config file.
+func (m *mapValue[V]) Replace(pairs map[string]string) error {
+	values := make(map[string]V, len(pairs))
+	for key, raw := range pairs {
+		value, err := m.parse(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		values[key] = value
+	}
+
+	*m.value = values
+	m.changed = true
+	return nil
+}
+
+// Reset sets the map back to a copy of the default.
+func (m *mapValue[V]) Reset() {
+	values := make(map[string]V, len(m.defaults))
+	for key, value := range m.defaults {
+		values[key] = value
+	}
+
+	*m.value = values
+	m.changed = false
+}
+
+func (m *mapValue[V]) Type() string {
+	return m.kind
+}
+
+func (m *mapValue[V]) String() string {
+	pairs := make([]string, 0, len(*m.value))
+	for key, value := range *m.value {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, value))
+	}
+	sort.Strings(pairs)
+
+	return "[" + strings.Join(pairs, ",") + "]"
+}
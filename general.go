@@ -1,43 +1,103 @@
 package main
 
 import (
+	"bytes"
 	"context"
-	sdk "github.com/cosmos/cosmos-sdk/types"
+	"errors"
+	"math"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	querytypes "github.com/cosmos/cosmos-sdk/types/query"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	distributiontypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
 	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	oracletypes "github.com/umee-network/umee/v6/x/oracle/types"
+	"github.com/rs/zerolog"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn, blockTime uint64) {
-	requestStart := time.Now()
+// blockStreakMaxBlocks bounds the blocks queried by a scrape for the streak
+// of missed blocks.
+const blockStreakMaxBlocks = 100
 
-	sublogger := log.With().
-		Str("request-id", uuid.New().String()).
-		Logger()
+// GeneralCollector exports the oracle, signing, feeder and staking metrics of
+// the given validator, all queried at the same height.
+type GeneralCollector struct {
+	uncheckedCollector
 
-	valoper := r.URL.Query().Get("valoper")
-	myAddress, err := sdk.ValAddressFromBech32(valoper)
+	ctx              context.Context
+	grpcConn         *grpc.ClientConn
+	oracle           OracleProvider
+	blockTime        uint64
+	priceReference   *PriceReference
+	denoms           *DenomResolver
+	balances         *BalanceTracker
+	streaks          *StreakTracker
+	exportRawAmounts bool
+	expectedFeeders  map[string]string
+	whitelist        *WhitelistTracker
+	valoper          string
+	myAddress        sdk.ValAddress
+	denomOverride    DenomOverride
+	requestedHeight  int64
+	logger           zerolog.Logger
+}
 
-	if err != nil {
-		sublogger.Error().
-			Str("valoper", valoper).
-			Err(err).
-			Msg("Could not get validator address")
-		return
+func NewGeneralCollector(
+	ctx context.Context,
+	grpcConn *grpc.ClientConn,
+	oracle OracleProvider,
+	blockTime uint64,
+	priceReference *PriceReference,
+	denoms *DenomResolver,
+	balances *BalanceTracker,
+	streaks *StreakTracker,
+	exportRawAmounts bool,
+	expectedFeeders map[string]string,
+	whitelist *WhitelistTracker,
+	valoper string,
+	myAddress sdk.ValAddress,
+	denomOverride DenomOverride,
+	requestedHeight int64,
+	logger zerolog.Logger,
+) *GeneralCollector {
+	return &GeneralCollector{
+		ctx:              ctx,
+		grpcConn:         grpcConn,
+		oracle:           oracle,
+		blockTime:        blockTime,
+		priceReference:   priceReference,
+		denoms:           denoms,
+		balances:         balances,
+		streaks:          streaks,
+		exportRawAmounts: exportRawAmounts,
+		expectedFeeders:  expectedFeeders,
+		whitelist:        whitelist,
+		valoper:          valoper,
+		myAddress:        myAddress,
+		denomOverride:    denomOverride,
+		requestedHeight:  requestedHeight,
+		logger:           logger,
 	}
+}
 
+func (c *GeneralCollector) Collect(ch chan<- prometheus.Metric) {
 	generalWindowProgressGauge := prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name:        "window_progress",
 			Help:        "Current slash window progress, block number in the current window",
-			ConstLabels: ConstLabels,
+			ConstLabels: constLabels(),
 		},
 	)
 
@@ -45,7 +105,7 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 		prometheus.GaugeOpts{
 			Name:        "window_size",
 			Help:        "Current window size",
-			ConstLabels: ConstLabels,
+			ConstLabels: constLabels(),
 		},
 	)
 
@@ -53,7 +113,7 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 		prometheus.GaugeOpts{
 			Name:        "slash_window",
 			Help:        "Number of blocks during which validators can miss votes",
-			ConstLabels: ConstLabels,
+			ConstLabels: constLabels(),
 		},
 	)
 
@@ -61,7 +121,7 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 		prometheus.GaugeOpts{
 			Name:        "min_valid_per_window",
 			Help:        "Percentage of misses triggering a slash at the end of the slash window",
-			ConstLabels: ConstLabels,
+			ConstLabels: constLabels(),
 		},
 	)
 
@@ -69,7 +129,15 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 		prometheus.GaugeOpts{
 			Name:        "slash_fraction",
 			Help:        "Slash fraction",
-			ConstLabels: ConstLabels,
+			ConstLabels: constLabels(),
+		},
+	)
+
+	paramsRewardBandGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "reward_band",
+			Help:        "Deviation from the median a vote may have to be valid, on Umee and Ojo",
+			ConstLabels: constLabels(),
 		},
 	)
 
@@ -77,7 +145,7 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 		prometheus.GaugeOpts{
 			Name:        "vote_period",
 			Help:        "Number of block to submit the next vote",
-			ConstLabels: ConstLabels,
+			ConstLabels: constLabels(),
 		},
 	)
 
@@ -85,42 +153,266 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 		prometheus.GaugeOpts{
 			Name:        "symbols_count",
 			Help:        "Number of symbols the feeder is supposed to broadcast",
-			ConstLabels: ConstLabels,
+			ConstLabels: constLabels(),
+		},
+	)
+
+	oracleWhitelistedAssetGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_whitelisted_asset",
+			Help:        "Denoms whitelisted in the oracle params, which validators have to vote for",
+			ConstLabels: constLabels(),
+		},
+		[]string{"denom"},
+	)
+
+	oracleWhitelistedAssetsGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "oracle_whitelisted_assets",
+			Help:        "Number of denoms whitelisted in the oracle params, same as symbols_count",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	oracleWhitelistChangesCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "oracle_whitelist_changes_total",
+			Help:        "Denoms added to or removed from the oracle whitelist since the exporter started",
+			ConstLabels: constLabels(),
 		},
+		[]string{"denom", "change"},
 	)
 
 	validatorMissCounterGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "miss_counter",
 			Help:        "Current miss counter for a given validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	votePenaltyMissGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "vote_penalty_miss_count",
+			Help:        "Vote periods a given validator missed in the current slash window, on Sei",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	votePenaltyAbstainGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "vote_penalty_abstain_count",
+			Help:        "Vote periods a given validator abstained from in the current slash window, on Sei",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	votePenaltySuccessGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "vote_penalty_success_count",
+			Help:        "Vote periods a given validator voted successfully in the current slash window, on Sei",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	performanceWindowSizeGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_performance_window_size",
+			Help:        "Vote periods in the slash window, on Umee and Ojo",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	performanceWindowElapsedGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_performance_window_elapsed",
+			Help:        "Vote periods elapsed in the current slash window, on Umee and Ojo",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	performanceWindowMissesGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_performance_window_misses",
+			Help:        "Vote periods a given validator missed or voted outside the reward band in the current slash window, on Umee and Ojo",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	performanceWindowValidRatioGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_performance_window_valid_ratio",
+			Help:        "Share of valid votes of a given validator in the elapsed vote periods of the slash window, on Umee and Ojo",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	performanceWindowProjectedValidRatioGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_performance_window_projected_valid_ratio",
+			Help:        "Share of valid votes of a given validator at the end of the slash window if it misses no further vote, compared to min_valid_per_window, on Umee and Ojo",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	performanceWindowMissesRemainingGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_performance_window_misses_remaining",
+			Help:        "Vote periods a given validator can still miss in the slash window without being slashed, negative once it will be, on Umee and Ojo",
+			ConstLabels: constLabels(),
 		},
 		[]string{"valoper"},
 	)
 
+	oraclePriceAgeGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_price_age_seconds",
+			Help:        "Seconds since the price of a given market was last relayed, on Injective",
+			ConstLabels: constLabels(),
+		},
+		[]string{"market", "source"},
+	)
+
+	oraclePriceRelayerGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_price_relayer",
+			Help:        "Accounts allowed to relay the price of a given market, on Injective",
+			ConstLabels: constLabels(),
+		},
+		[]string{"market", "source", "relayer"},
+	)
+
 	validatorAggregateVoteGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "aggregated_votes",
 			Help:        "Current aggregate vote for a given validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: constLabels(),
 		},
 		[]string{"asset"},
 	)
 
+	oracleVoteMissingDenomGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_vote_missing_denom",
+			Help:        "Whether a whitelisted denom is missing from the latest aggregate vote of the validator, aggregated_votes labeled by denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"denom"},
+	)
+
 	validatorFeederAccountGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "feeder_account",
 			Help:        "Account delegated account for a given validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: constLabels(),
 		},
 		[]string{"valoper", "feeder"},
 	)
 
+	feederMismatchGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "feeder_mismatch",
+			Help:        "Whether the feeder delegated on-chain differs from the expected feeder of a given validator",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "expected", "actual"},
+	)
+
+	feederBalanceGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "feeder_balance",
+			Help:        "Balance of the feeder account in display denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"feeder", "denom", "ibc_path"},
+	)
+
+	feederBalanceRawGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "feeder_balance_raw",
+			Help:        "Balance of the feeder account in base denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"feeder", "denom"},
+	)
+
+	denomExponentGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "denom_exponent",
+			Help:        "Exponent the base denom amounts are divided by to get the display denom amounts",
+			ConstLabels: constLabels(),
+		},
+		[]string{"base", "display"},
+	)
+
+	ibcDenomTraceGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "ibc_denom_trace",
+			Help:        "Origin denom and transfer path of an IBC denom, always 1",
+			ConstLabels: constLabels(),
+		},
+		[]string{"denom", "base_denom", "path"},
+	)
+
+	feederBalanceChangeGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "feeder_balance_change",
+			Help:        "Change of the feeder balance in display denom since the previous scrape",
+			ConstLabels: constLabels(),
+		},
+		[]string{"feeder", "denom", "ibc_path"},
+	)
+
+	feederBalanceInflowCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "feeder_balance_inflow_total",
+			Help:        "Total amount received by the feeder in display denom since the exporter started",
+			ConstLabels: constLabels(),
+		},
+		[]string{"feeder", "denom", "ibc_path"},
+	)
+
+	feederBalanceOutflowCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "feeder_balance_outflow_total",
+			Help:        "Total amount spent by the feeder in display denom since the exporter started",
+			ConstLabels: constLabels(),
+		},
+		[]string{"feeder", "denom", "ibc_path"},
+	)
+
+	feederBalanceInflowRawCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "feeder_balance_inflow_raw_total",
+			Help:        "Total amount received by the feeder in base denom since the exporter started",
+			ConstLabels: constLabels(),
+		},
+		[]string{"feeder", "denom"},
+	)
+
+	feederBalanceOutflowRawCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "feeder_balance_outflow_raw_total",
+			Help:        "Total amount spent by the feeder in base denom since the exporter started",
+			ConstLabels: constLabels(),
+		},
+		[]string{"feeder", "denom"},
+	)
+
 	validatorMissRateGauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "miss_rate",
 			Help:        "Current miss rate for given validator",
-			ConstLabels: ConstLabels,
+			ConstLabels: constLabels(),
 		},
 		[]string{"valoper"},
 	)
@@ -129,7 +421,7 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 		prometheus.GaugeOpts{
 			Name:        "next_window_start",
 			Help:        "Timestamp of the next estimated windows start in UTC",
-			ConstLabels: ConstLabels,
+			ConstLabels: constLabels(),
 		},
 		[]string{"valoper"},
 	)
@@ -137,252 +429,1300 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 		prometheus.GaugeOpts{
 			Name:        "last_block_vote",
 			Help:        "Last block validator voted",
-			ConstLabels: ConstLabels,
+			ConstLabels: constLabels(),
 		},
 		[]string{"valoper"},
 	)
 
-	registry := prometheus.NewRegistry()
-	registry.MustRegister(generalWindowProgressGauge)
-	registry.MustRegister(generalWindowSizeGauge)
-	registry.MustRegister(paramsSlashWindowGauge)
-	registry.MustRegister(paramsMinValidPerWindowGauge)
-	registry.MustRegister(paramsSlashFractionGauge)
-	registry.MustRegister(paramsVotePeriodGauge)
-	registry.MustRegister(paramsSymbolsCountGauge)
-	registry.MustRegister(validatorMissCounterGauge)
-	registry.MustRegister(validatorFeederAccountGauge)
-	registry.MustRegister(validatorMissRateGauge)
-	registry.MustRegister(validatorNextWindowStartGauge)
-	registry.MustRegister(validatorLastBlockVoteGauge)
+	validatorJailedGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_jailed",
+			Help:        "Whether a given validator is jailed",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
 
-	registry.MustRegister(validatorAggregateVoteGauge)
+	validatorTombstonedGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_tombstoned",
+			Help:        "Whether a given validator is tombstoned",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
 
-	// doing this not in goroutine as we'll need slash window value later
-	sublogger.Debug().Msg("Started querying current slash window progress")
-	slashWindowQueryStart := time.Now()
+	validatorMissedBlocksGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_missed_blocks",
+			Help:        "Number of blocks a given validator missed in the current signed blocks window",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
 
-	oracleClient := oracletypes.NewQueryClient(grpcConn)
-	slashWindowResponse, err := oracleClient.SlashWindow(
-		context.Background(),
-		&oracletypes.QuerySlashWindow{},
+	validatorConsecutiveMissedBlocksGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_consecutive_missed_blocks",
+			Help:        "Number of blocks missed in a row up to the latest scrape, a lower bound between scrapes",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
 	)
-	if err != nil {
-		sublogger.Error().Err(err).Msg("Could not get current slash window progress")
-		return
-	}
 
-	sublogger.Debug().
-		Float64("request-time", time.Since(slashWindowQueryStart).Seconds()).
-		Msg("Finished querying current slash window progress")
+	oracleConsecutiveMissedVotesGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_consecutive_missed_votes",
+			Help:        "Number of oracle vote periods missed in a row up to the latest scrape, a lower bound between scrapes",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
 
-	generalWindowProgressGauge.Set(float64(slashWindowResponse.WindowProgress))
+	validatorMissedBlocksWindowGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_missed_blocks_window",
+			Help:        "Number of blocks a given validator missed in the current signed blocks window, same as validator_missed_blocks",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
 
-	// doing this not in goroutine as we'll need params from oracle params response for calculation
-	sublogger.Debug().Msg("Started querying oracle params")
-	queryStart := time.Now()
+	validatorSignedBlocksWindowGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "validator_signed_blocks_window",
+			Help:        "Size of the signed blocks window the missed blocks are counted in",
+			ConstLabels: constLabels(),
+		},
+	)
 
-	oracleParamsResponse, err := oracleClient.Params(
-		context.Background(),
-		&oracletypes.QueryParams{},
+	validatorUptimePercentGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_uptime_percent",
+			Help:        "Percentage of the signed blocks window a given validator signed",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
 	)
-	if err != nil {
-		sublogger.Error().
-			Err(err).
-			Msg("Could not get oracle params")
-		return
-	}
 
-	sublogger.Debug().
-		Float64("request-time", time.Since(queryStart).Seconds()).
-		Msg("Finished querying oracle params")
+	validatorCommissionGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_commission",
+			Help:        "Accumulated commission of the validator in display denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "denom", "ibc_path"},
+	)
 
-	windowSize := oracleParamsResponse.Params.SlashWindow / oracleParamsResponse.Params.VotePeriod
-	generalWindowSizeGauge.Set(float64(windowSize))
-	paramsSlashWindowGauge.Set(float64(oracleParamsResponse.Params.SlashWindow))
-	paramsMinValidPerWindowGauge.Set(oracleParamsResponse.Params.MinValidPerWindow.MustFloat64())
-	paramsSlashFractionGauge.Set(oracleParamsResponse.Params.SlashFraction.MustFloat64())
-	paramsVotePeriodGauge.Set(float64(oracleParamsResponse.Params.VotePeriod))
-	paramsSymbolsCountGauge.Set(float64(len(oracleParamsResponse.Params.AcceptList)))
+	validatorCommissionRawGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_commission_raw",
+			Help:        "Accumulated commission of the validator in base denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "denom"},
+	)
 
-	var wg sync.WaitGroup
+	validatorRewardsGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_rewards",
+			Help:        "Outstanding rewards of the validator in display denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "denom", "ibc_path"},
+	)
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	validatorRewardsRawGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_rewards_raw",
+			Help:        "Outstanding rewards of the validator in base denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "denom"},
+	)
 
-		sublogger.Debug().
-			Str("valoper", valoper).
-			Msg("Started querying validator current miss counter")
-		queryStart := time.Now()
+	validatorDelegatorRewardsGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_delegator_rewards",
+			Help:        "Pending rewards of the self-delegation of the validator in display denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "denom", "ibc_path"},
+	)
 
-		oracleClient := oracletypes.NewQueryClient(grpcConn)
-		missCounterResponse, err := oracleClient.MissCounter(
-			context.Background(),
-			&oracletypes.QueryMissCounter{ValidatorAddr: myAddress.String()},
-		)
-		if err != nil {
-			sublogger.Error().
-				Str("valoper", valoper).
-				Err(err).
-				Msg("Could not get validator current miss counter")
-			return
-		}
+	validatorDelegatorRewardsRawGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_delegator_rewards_raw",
+			Help:        "Pending rewards of the self-delegation of the validator in base denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "denom"},
+	)
 
-		sublogger.Debug().
-			Str("valoper", valoper).
-			Float64("request-time", time.Since(queryStart).Seconds()).
-			Msg("Finished querying validator current miss counter")
+	validatorDelegatedTokensGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_delegated_tokens",
+			Help:        "Tokens delegated to the validator in display denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "denom"},
+	)
 
-		validatorMissCounterGauge.With(prometheus.Labels{
-			"valoper": valoper,
-		}).Set(float64(missCounterResponse.MissCounter))
+	validatorDelegatedTokensRawGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_delegated_tokens_raw",
+			Help:        "Tokens delegated to the validator in base denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "denom"},
+	)
 
-		sublogger.Debug().
-			Str("valoper", valoper).
-			Msg("Started calculate the miss rate")
-		missRateStart := time.Now()
+	validatorSelfDelegationGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_self_delegation",
+			Help:        "Self-delegation of the validator in display denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "denom"},
+	)
 
-		missRate := float64(missCounterResponse.MissCounter) / float64(slashWindowResponse.WindowProgress)
+	validatorSelfDelegationRawGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_self_delegation_raw",
+			Help:        "Self-delegation of the validator in base denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "denom"},
+	)
 
-		sublogger.Debug().
-			Str("valoper", valoper).
-			Float64("request-time", time.Since(missRateStart).Seconds()).
-			Msg("Finished calculate the miss rate")
+	validatorUnbondingGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_unbonding",
+			Help:        "Tokens being unbonded from the validator in display denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "denom"},
+	)
 
-		validatorMissRateGauge.With(prometheus.Labels{
-			"valoper": valoper,
-		}).Set(missRate)
+	validatorUnbondingRawGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_unbonding_raw",
+			Help:        "Tokens being unbonded from the validator in base denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "denom"},
+	)
 
-		sublogger.Debug().
-			Str("valoper", valoper).
-			Msg("Started calculate calculate the estimated windows start")
-		windowStart := time.Now()
+	validatorDelegatorsGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_delegators",
+			Help:        "Number of delegators of the validator",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
 
-		oracleParams := oracleParamsResponse.Params
-		seconds := (windowSize - slashWindowResponse.WindowProgress + 1) * blockTime * oracleParams.VotePeriod
+	oracleExchangeRateGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_exchange_rate",
+			Help:        "Current on-chain exchange rate for a given denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"denom"},
+	)
 
-		sublogger.Debug().
-			Str("valoper", valoper).
-			Float64("request-time", time.Since(windowStart).Seconds()).
-			Msg("Finished calculate the estimated windows start")
+	oracleReferencePriceGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_reference_price",
+			Help:        "Market price of a given denom from the external price reference",
+			ConstLabels: constLabels(),
+		},
+		[]string{"denom", "provider"},
+	)
 
-		validatorNextWindowStartGauge.With(prometheus.Labels{
-			"valoper": valoper,
-		}).Set(float64(time.Now().Add(time.Duration(seconds) * time.Second).UTC().UnixMilli()))
-	}()
+	oraclePriceDeviationGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_price_deviation_percent",
+			Help:        "Deviation of the on-chain exchange rate from the market price in percent",
+			ConstLabels: constLabels(),
+		},
+		[]string{"denom"},
+	)
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	oracleReferenceProviderUpGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_reference_provider_up",
+			Help:        "Whether the last request to a given external price provider succeeded",
+			ConstLabels: constLabels(),
+		},
+		[]string{"provider"},
+	)
 
-		sublogger.Debug().
-			Str("valoper", valoper).
-			Msg("Started querying feeder account associated with the validator")
-		queryStart := time.Now()
+	oracleReferenceProviderLastSuccessGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "oracle_reference_provider_last_success",
+			Help:        "Timestamp of the last successful request to a given external price provider in UTC",
+			ConstLabels: constLabels(),
+		},
+		[]string{"provider"},
+	)
 
-		oracleClient := oracletypes.NewQueryClient(grpcConn)
-		response, err := oracleClient.FeederDelegation(
-			context.Background(),
-			&oracletypes.QueryFeederDelegation{ValidatorAddr: myAddress.String()},
-		)
-		if err != nil {
-			sublogger.Error().
-				Str("valoper", valoper).
-				Err(err).
+	scrapeBlockHeightGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "scrape_block_height",
+			Help:        "Block height all queries of the scrape were made at",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	metrics := []prometheus.Collector{
+		scrapeBlockHeightGauge,
+		generalWindowProgressGauge,
+		generalWindowSizeGauge,
+		paramsSlashWindowGauge,
+		paramsMinValidPerWindowGauge,
+		paramsSlashFractionGauge,
+		paramsVotePeriodGauge,
+		paramsSymbolsCountGauge,
+		oracleWhitelistedAssetGauge,
+		oracleWhitelistedAssetsGauge,
+		oracleWhitelistChangesCounter,
+		validatorMissCounterGauge,
+		votePenaltyMissGauge,
+		votePenaltyAbstainGauge,
+		votePenaltySuccessGauge,
+		performanceWindowSizeGauge,
+		performanceWindowElapsedGauge,
+		performanceWindowMissesGauge,
+		performanceWindowValidRatioGauge,
+		performanceWindowProjectedValidRatioGauge,
+		performanceWindowMissesRemainingGauge,
+		oraclePriceAgeGauge,
+		oraclePriceRelayerGauge,
+		validatorFeederAccountGauge,
+		feederBalanceGauge,
+		feederBalanceChangeGauge,
+		feederBalanceInflowCounter,
+		feederBalanceOutflowCounter,
+		validatorMissRateGauge,
+		validatorNextWindowStartGauge,
+		validatorLastBlockVoteGauge,
+		validatorAggregateVoteGauge,
+		oracleVoteMissingDenomGauge,
+		validatorJailedGauge,
+		validatorTombstonedGauge,
+		validatorMissedBlocksGauge,
+		validatorMissedBlocksWindowGauge,
+		validatorConsecutiveMissedBlocksGauge,
+		oracleConsecutiveMissedVotesGauge,
+		validatorSignedBlocksWindowGauge,
+		validatorUptimePercentGauge,
+		validatorCommissionGauge,
+		validatorRewardsGauge,
+		validatorDelegatorRewardsGauge,
+		validatorDelegatedTokensGauge,
+		validatorSelfDelegationGauge,
+		validatorUnbondingGauge,
+		validatorDelegatorsGauge,
+		oracleExchangeRateGauge,
+		oracleReferencePriceGauge,
+		oraclePriceDeviationGauge,
+		denomExponentGauge,
+		ibcDenomTraceGauge,
+	}
+	if _, ok := c.expectedFeeders[c.valoper]; ok {
+		metrics = append(metrics, feederMismatchGauge)
+	}
+	if c.exportRawAmounts {
+		metrics = append(metrics,
+			feederBalanceRawGauge,
+			feederBalanceInflowRawCounter,
+			feederBalanceOutflowRawCounter,
+			validatorCommissionRawGauge,
+			validatorRewardsRawGauge,
+			validatorDelegatorRewardsRawGauge,
+			validatorDelegatedTokensRawGauge,
+			validatorSelfDelegationRawGauge,
+			validatorUnbondingRawGauge,
+		)
+	}
+	if c.priceReference != nil {
+		metrics = append(metrics, oracleReferenceProviderUpGauge, oracleReferenceProviderLastSuccessGauge)
+	}
+
+	// the addresses are encoded with the prefixes of the validator's chain
+	addresses, err := AddressCodecOf(c.valoper)
+	if err != nil {
+		c.logger.Error().
+			Str("valoper", c.valoper).
+			Err(err).
+			Msg("Could not get validator address")
+		return
+	}
+
+	var heightCtx context.Context
+	var height int64
+	if c.requestedHeight > 0 {
+		c.logger.Debug().
+			Int64("height", c.requestedHeight).
+			Msg("Querying at the requested height")
+
+		heightCtx = AtHeight(c.ctx, c.requestedHeight)
+		scrapeBlockHeightGauge.Set(float64(c.requestedHeight))
+	} else {
+		c.logger.Debug().Msg("Started querying latest block height")
+		queryStart := time.Now()
+
+		var err error
+		heightCtx, height, err = PinHeight(c.ctx, c.grpcConn)
+		if err != nil {
+			c.logger.Warn().
+				Err(err).
+				Msg("Could not get latest block height, querying without pinning the height")
+		} else {
+			c.logger.Debug().
+				Int64("height", height).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying latest block height")
+
+			scrapeBlockHeightGauge.Set(float64(height))
+		}
+	}
+
+	// queries log their own errors and return nil, so a failed query doesn't cancel
+	// the others, ctx is only cancelled when Prometheus gives up on the scrape
+	group, ctx := errgroup.WithContext(heightCtx)
+
+	group.Go(func() error {
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Msg("Started querying feeder account associated with the validator")
+		queryStart := time.Now()
+
+		feeder, err := c.oracle.FeederDelegation(ctx, addresses.ValAddress(c.myAddress))
+		if errors.Is(err, ErrNotSupported) {
+			return nil
+		} else if err != nil {
+			c.logger.Error().
+				Str("valoper", c.valoper).
+				Err(err).
 				Msg("Could not get feeder account associated with the validator")
-			return
+			return nil
 		}
 
-		sublogger.Debug().
-			Str("valoper", valoper).
+		c.logger.Debug().
+			Str("valoper", c.valoper).
 			Float64("request-time", time.Since(queryStart).Seconds()).
 			Msg("Finished querying feeder account associated with the validator")
 
 		validatorFeederAccountGauge.With(prometheus.Labels{
-			"valoper": valoper,
-			"feeder":  response.FeederAddr,
+			"valoper": c.valoper,
+			"feeder":  feeder,
 		}).Set(1)
-	}()
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+		if expected, ok := c.expectedFeeders[c.valoper]; ok {
+			mismatch := 0.0
+			if feeder != expected {
+				mismatch = 1
+				c.logger.Warn().
+					Str("valoper", c.valoper).
+					Str("expected", expected).
+					Str("actual", feeder).
+					Msg("Feeder differs from the expected feeder")
+			}
 
-		sublogger.Debug().
-			Str("valoper", valoper).
-			Msg("Started querying validator prevote aggregate")
+			feederMismatchGauge.With(prometheus.Labels{
+				"valoper":  c.valoper,
+				"expected": expected,
+				"actual":   feeder,
+			}).Set(mismatch)
+		}
+
+		c.logger.Debug().
+			Str("feeder", feeder).
+			Msg("Started querying feeder balance")
+		queryStart = time.Now()
+
+		bankClient := banktypes.NewQueryClient(c.grpcConn)
+		balancesResponse, err := bankClient.AllBalances(
+			ctx,
+			&banktypes.QueryAllBalancesRequest{Address: feeder},
+		)
+		if err != nil {
+			c.logger.Error().
+				Str("feeder", feeder).
+				Err(err).
+				Msg("Could not get feeder balance")
+			return nil
+		}
+
+		c.logger.Debug().
+			Str("feeder", feeder).
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying feeder balance")
+
+		for _, balance := range balancesResponse.Balances {
+			denom := c.denomOverride.Apply(c.denoms.Resolve(ctx, c.grpcConn, balance.Denom))
+			if !c.denoms.Allowed(denom) {
+				continue
+			}
+
+			feederBalanceGauge.With(prometheus.Labels{
+				"feeder":   feeder,
+				"denom":    denom.Display,
+				"ibc_path": denom.IBCPath,
+			}).Set(c.denoms.Convert(denom, balance.Amount))
+
+			denomExponentGauge.With(prometheus.Labels{
+				"base":    denom.Base,
+				"display": denom.Display,
+			}).Set(float64(denom.Exponent))
+
+			if denom.IBCBaseDenom != "" {
+				ibcDenomTraceGauge.With(prometheus.Labels{
+					"denom":      denom.Base,
+					"base_denom": denom.IBCBaseDenom,
+					"path":       denom.IBCPath,
+				}).Set(1)
+			}
+
+			feederBalanceRawGauge.With(prometheus.Labels{
+				"feeder": feeder,
+				"denom":  denom.Base,
+			}).Set(RawAmount(balance.Amount))
+		}
+
+		// balance flows are tracked between live scrapes only
+		if IsHistorical(ctx) {
+			return nil
+		}
+
+		for _, flow := range c.balances.Observe(feeder, balancesResponse.Balances) {
+			denom := c.denomOverride.Apply(c.denoms.Resolve(ctx, c.grpcConn, flow.Denom))
+			if !c.denoms.Allowed(denom) {
+				continue
+			}
+
+			labels := prometheus.Labels{"feeder": feeder, "denom": denom.Display, "ibc_path": denom.IBCPath}
+			rawLabels := prometheus.Labels{"feeder": feeder, "denom": denom.Base}
+
+			feederBalanceChangeGauge.With(labels).Set(c.denoms.Convert(denom, flow.Change))
+			feederBalanceInflowCounter.With(labels).Add(c.denoms.Convert(denom, flow.Inflow))
+			feederBalanceOutflowCounter.With(labels).Add(c.denoms.Convert(denom, flow.Outflow))
+			feederBalanceInflowRawCounter.With(rawLabels).Add(RawAmount(flow.Inflow))
+			feederBalanceOutflowRawCounter.With(rawLabels).Add(RawAmount(flow.Outflow))
+		}
+
+		return nil
+	})
+
+	group.Go(func() error {
+		// rewards are decimal, the fraction of the base denom is dropped
+		setRewards := func(gauge *prometheus.GaugeVec, rawGauge *prometheus.GaugeVec, coins sdk.DecCoins) {
+			for _, coin := range coins {
+				amount := coin.Amount.TruncateInt()
+				denom := c.denomOverride.Apply(c.denoms.Resolve(ctx, c.grpcConn, coin.Denom))
+				if !c.denoms.Allowed(denom) {
+					continue
+				}
+
+				gauge.With(prometheus.Labels{
+					"valoper":  c.valoper,
+					"denom":    denom.Display,
+					"ibc_path": denom.IBCPath,
+				}).Set(c.denoms.Convert(denom, amount))
+
+				denomExponentGauge.With(prometheus.Labels{
+					"base":    denom.Base,
+					"display": denom.Display,
+				}).Set(float64(denom.Exponent))
+
+				rawGauge.With(prometheus.Labels{
+					"valoper": c.valoper,
+					"denom":   denom.Base,
+				}).Set(RawAmount(amount))
+			}
+		}
+
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Msg("Started querying validator commission and rewards")
+		queryStart := time.Now()
+
+		distributionClient := distributiontypes.NewQueryClient(c.grpcConn)
+		commissionResponse, err := distributionClient.ValidatorCommission(
+			ctx,
+			&distributiontypes.QueryValidatorCommissionRequest{ValidatorAddress: addresses.ValAddress(c.myAddress)},
+		)
+		if err != nil {
+			c.logger.Error().
+				Str("valoper", c.valoper).
+				Err(err).
+				Msg("Could not get validator commission")
+		} else {
+			setRewards(validatorCommissionGauge, validatorCommissionRawGauge, commissionResponse.Commission.Commission)
+		}
+
+		outstandingResponse, err := distributionClient.ValidatorOutstandingRewards(
+			ctx,
+			&distributiontypes.QueryValidatorOutstandingRewardsRequest{ValidatorAddress: addresses.ValAddress(c.myAddress)},
+		)
+		if err != nil {
+			c.logger.Error().
+				Str("valoper", c.valoper).
+				Err(err).
+				Msg("Could not get validator outstanding rewards")
+		} else {
+			setRewards(validatorRewardsGauge, validatorRewardsRawGauge, outstandingResponse.Rewards.Rewards)
+		}
+
+		delegationResponse, err := distributionClient.DelegationRewards(
+			ctx,
+			&distributiontypes.QueryDelegationRewardsRequest{
+				DelegatorAddress: addresses.AccAddress(sdk.AccAddress(c.myAddress)),
+				ValidatorAddress: addresses.ValAddress(c.myAddress),
+			},
+		)
+		if err != nil {
+			c.logger.Warn().
+				Str("valoper", c.valoper).
+				Err(err).
+				Msg("Could not get self-delegation rewards")
+		} else {
+			setRewards(validatorDelegatorRewardsGauge, validatorDelegatorRewardsRawGauge, delegationResponse.Rewards)
+		}
+
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying validator commission and rewards")
+
+		return nil
+	})
+
+	group.Go(func() error {
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Msg("Started querying validator delegations")
 		queryStart := time.Now()
 
-		oracleClient := oracletypes.NewQueryClient(grpcConn)
-		response, err := oracleClient.AggregatePrevote(
-			context.Background(),
-			&oracletypes.QueryAggregatePrevote{ValidatorAddr: myAddress.String()},
+		filter := CurrentMetricFilter()
+		stakingClient := stakingtypes.NewQueryClient(c.grpcConn)
+		paramsResponse, err := stakingClient.Params(ctx, &stakingtypes.QueryParamsRequest{})
+		if err != nil {
+			c.logger.Error().
+				Err(err).
+				Msg("Could not get staking params")
+			return nil
+		}
+
+		denom := c.denomOverride.Apply(c.denoms.Resolve(ctx, c.grpcConn, paramsResponse.Params.BondDenom))
+		denomExponentGauge.With(prometheus.Labels{
+			"base":    denom.Base,
+			"display": denom.Display,
+		}).Set(float64(denom.Exponent))
+
+		setTokens := func(gauge *prometheus.GaugeVec, rawGauge *prometheus.GaugeVec, amount sdk.Int) {
+			gauge.With(prometheus.Labels{
+				"valoper": c.valoper,
+				"denom":   denom.Display,
+			}).Set(c.denoms.Convert(denom, amount))
+
+			rawGauge.With(prometheus.Labels{
+				"valoper": c.valoper,
+				"denom":   denom.Base,
+			}).Set(RawAmount(amount))
+		}
+
+		validatorResponse, err := stakingClient.Validator(
+			ctx,
+			&stakingtypes.QueryValidatorRequest{ValidatorAddr: addresses.ValAddress(c.myAddress)},
 		)
 		if err != nil {
-			sublogger.Warn().
-				Str("valoper", valoper).
+			c.logger.Error().
+				Str("valoper", c.valoper).
+				Err(err).
+				Msg("Could not get validator")
+			return nil
+		}
+
+		setTokens(validatorDelegatedTokensGauge, validatorDelegatedTokensRawGauge, validatorResponse.Validator.Tokens)
+
+		// counting the delegators is slow on chains with many of them
+		if filter.Enabled("validator_delegators") {
+			// only the total is needed, so a single delegation is requested
+			delegationsResponse, err := stakingClient.ValidatorDelegations(ctx, &stakingtypes.QueryValidatorDelegationsRequest{
+				ValidatorAddr: addresses.ValAddress(c.myAddress),
+				Pagination:    &querytypes.PageRequest{Limit: 1, CountTotal: true},
+			})
+			if err != nil {
+				c.logger.Error().
+					Str("valoper", c.valoper).
+					Err(err).
+					Msg("Could not get validator delegations")
+			} else if delegationsResponse.Pagination != nil {
+				validatorDelegatorsGauge.With(prometheus.Labels{
+					"valoper": c.valoper,
+				}).Set(float64(delegationsResponse.Pagination.Total))
+			}
+		}
+
+		selfDelegationResponse, err := stakingClient.Delegation(ctx, &stakingtypes.QueryDelegationRequest{
+			DelegatorAddr: addresses.AccAddress(sdk.AccAddress(c.myAddress)),
+			ValidatorAddr: addresses.ValAddress(c.myAddress),
+		})
+		switch {
+		case status.Code(err) == codes.NotFound:
+			setTokens(validatorSelfDelegationGauge, validatorSelfDelegationRawGauge, sdk.ZeroInt())
+		case err != nil:
+			c.logger.Error().
+				Str("valoper", c.valoper).
+				Err(err).
+				Msg("Could not get validator self-delegation")
+		case selfDelegationResponse.DelegationResponse != nil:
+			setTokens(validatorSelfDelegationGauge, validatorSelfDelegationRawGauge, selfDelegationResponse.DelegationResponse.Balance.Amount)
+		}
+
+		if filter.Enabled("validator_unbonding") || filter.Enabled("validator_unbonding_raw") {
+			unbonding := sdk.ZeroInt()
+
+			err := paginate("unbonding_delegations", func(key []byte, limit uint64) ([]byte, error) {
+				unbondingResponse, err := stakingClient.ValidatorUnbondingDelegations(ctx, &stakingtypes.QueryValidatorUnbondingDelegationsRequest{
+					ValidatorAddr: addresses.ValAddress(c.myAddress),
+					Pagination:    &querytypes.PageRequest{Key: key, Limit: limit},
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				for _, delegation := range unbondingResponse.UnbondingResponses {
+					for _, entry := range delegation.Entries {
+						unbonding = unbonding.Add(entry.Balance)
+					}
+				}
+
+				return nextPageKey(unbondingResponse.Pagination), nil
+			})
+			if err != nil {
+				c.logger.Error().
+					Str("valoper", c.valoper).
+					Err(err).
+					Msg("Could not get validator unbonding delegations")
+				return nil
+			}
+
+			setTokens(validatorUnbondingGauge, validatorUnbondingRawGauge, unbonding)
+		}
+
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying validator delegations")
+
+		return nil
+	})
+
+	group.Go(func() error {
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Msg("Started querying validator prevote aggregate")
+		queryStart := time.Now()
+
+		submitBlock, err := c.oracle.LastPrevoteBlock(ctx, addresses.ValAddress(c.myAddress))
+		if errors.Is(err, ErrNotSupported) {
+			return nil
+		} else if err != nil {
+			c.logger.Warn().
+				Str("valoper", c.valoper).
 				Err(err).
 				Msg("Could not get validator prevote aggregate")
-			return
+			return nil
 		}
 
-		sublogger.Debug().
-			Str("valoper", valoper).
+		c.logger.Debug().
+			Str("valoper", c.valoper).
 			Float64("request-time", time.Since(queryStart).Seconds()).
 			Msg("Finished querying validator prevote aggregate")
 
 		validatorLastBlockVoteGauge.With(prometheus.Labels{
-			"valoper": valoper,
-		}).Set(float64(response.AggregatePrevote.SubmitBlock))
-	}()
+			"valoper": c.valoper,
+		}).Set(float64(submitBlock))
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+		return nil
+	})
 
-		sublogger.Debug().
-			Str("valoper", valoper).
-			Msg("Started querying validator aggregate vote")
+	group.Go(func() error {
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Msg("Started querying validator")
 		queryStart := time.Now()
 
-		oracleClient := oracletypes.NewQueryClient(grpcConn)
-		response, err := oracleClient.AggregateVote(
-			context.Background(),
-			&oracletypes.QueryAggregateVote{ValidatorAddr: myAddress.String()},
+		stakingClient := stakingtypes.NewQueryClient(c.grpcConn)
+		validatorResponse, err := stakingClient.Validator(
+			ctx,
+			&stakingtypes.QueryValidatorRequest{ValidatorAddr: addresses.ValAddress(c.myAddress)},
+		)
+		if err != nil {
+			c.logger.Error().
+				Str("valoper", c.valoper).
+				Err(err).
+				Msg("Could not get validator")
+			return nil
+		}
+
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying validator")
+
+		jailed := 0.0
+		if validatorResponse.Validator.Jailed {
+			jailed = 1
+		}
+
+		validatorJailedGauge.With(prometheus.Labels{
+			"valoper": c.valoper,
+		}).Set(jailed)
+
+		consAddress, err := ConsensusAddress(validatorResponse.Validator)
+		if err != nil {
+			c.logger.Error().
+				Str("valoper", c.valoper).
+				Err(err).
+				Msg("Could not get validator consensus address")
+			return nil
+		}
+
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Msg("Started querying validator signing info")
+		queryStart = time.Now()
+
+		slashingClient := slashingtypes.NewQueryClient(c.grpcConn)
+		signingInfoResponse, err := slashingClient.SigningInfo(
+			ctx,
+			&slashingtypes.QuerySigningInfoRequest{ConsAddress: addresses.ConsAddress(consAddress)},
 		)
 		if err != nil {
-			sublogger.Warn().
-				Str("valoper", valoper).
+			c.logger.Error().
+				Str("valoper", c.valoper).
+				Err(err).
+				Msg("Could not get validator signing info")
+			return nil
+		}
+
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying validator signing info")
+
+		tombstoned := 0.0
+		if signingInfoResponse.ValSigningInfo.Tombstoned {
+			tombstoned = 1
+		}
+
+		validatorTombstonedGauge.With(prometheus.Labels{
+			"valoper": c.valoper,
+		}).Set(tombstoned)
+
+		missedBlocks := signingInfoResponse.ValSigningInfo.MissedBlocksCounter
+		validatorMissedBlocksGauge.With(prometheus.Labels{
+			"valoper": c.valoper,
+		}).Set(float64(missedBlocks))
+
+		validatorMissedBlocksWindowGauge.With(prometheus.Labels{
+			"valoper": c.valoper,
+		}).Set(float64(missedBlocks))
+
+		// streaks are tracked between live scrapes of a known height only
+		if height > 0 && !IsHistorical(ctx) {
+			streak := c.missedBlocksStreak(ctx, consAddress, height)
+			validatorConsecutiveMissedBlocksGauge.With(prometheus.Labels{
+				"valoper": c.valoper,
+			}).Set(float64(streak))
+		}
+
+		c.logger.Debug().Msg("Started querying slashing params")
+		queryStart = time.Now()
+
+		slashingParamsResponse, err := slashingClient.Params(ctx, &slashingtypes.QueryParamsRequest{})
+		if err != nil {
+			c.logger.Error().
+				Err(err).
+				Msg("Could not get slashing params")
+			return nil
+		}
+
+		c.logger.Debug().
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying slashing params")
+
+		window := slashingParamsResponse.Params.SignedBlocksWindow
+		validatorSignedBlocksWindowGauge.Set(float64(window))
+		if window > 0 {
+			validatorUptimePercentGauge.With(prometheus.Labels{
+				"valoper": c.valoper,
+			}).Set(float64(window-missedBlocks) / float64(window) * 100)
+		}
+
+		return nil
+	})
+
+	group.Go(func() error {
+		c.logger.Debug().Msg("Started querying oracle exchange rates")
+		queryStart := time.Now()
+
+		exchangeRates, err := c.oracle.ExchangeRates(ctx)
+		if err != nil {
+			c.logger.Error().
+				Err(err).
+				Msg("Could not get oracle exchange rates")
+			return nil
+		}
+
+		c.logger.Debug().
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying oracle exchange rates")
+
+		for denom, exchangeRate := range exchangeRates {
+			oracleExchangeRateGauge.With(prometheus.Labels{
+				"denom": denom,
+			}).Set(exchangeRate.MustFloat64())
+		}
+
+		// reference prices are current, they can't be compared to past rates
+		if c.priceReference == nil || IsHistorical(ctx) {
+			return nil
+		}
+
+		symbols := make([]string, 0, len(exchangeRates))
+		for denom := range exchangeRates {
+			symbols = append(symbols, denom)
+		}
+
+		referencePrices := c.priceReference.Prices(ctx, symbols)
+		for denom, exchangeRate := range exchangeRates {
+			reference, ok := referencePrices[strings.ToUpper(denom)]
+			if !ok || reference.Price == 0 {
+				continue
+			}
+
+			oracleReferencePriceGauge.With(prometheus.Labels{
+				"denom":    denom,
+				"provider": reference.Provider,
+			}).Set(reference.Price)
+
+			deviation := (exchangeRate.MustFloat64() - reference.Price) / reference.Price * 100
+			oraclePriceDeviationGauge.With(prometheus.Labels{
+				"denom": denom,
+			}).Set(deviation)
+		}
+
+		for provider, status := range c.priceReference.Availability() {
+			var up float64
+			if status.Up {
+				up = 1
+			}
+
+			oracleReferenceProviderUpGauge.With(prometheus.Labels{
+				"provider": provider,
+			}).Set(up)
+
+			if !status.LastSuccess.IsZero() {
+				oracleReferenceProviderLastSuccessGauge.With(prometheus.Labels{
+					"provider": provider,
+				}).Set(float64(status.LastSuccess.UTC().UnixMilli()))
+			}
+		}
+
+		return nil
+	})
+
+	// doing this not in goroutine as we'll need params from c.oracle params response for calculation,
+	// the queries above that don't depend on them are already running meanwhile
+	c.logger.Debug().Msg("Started querying oracle params")
+	queryStart := time.Now()
+
+	oracleParams, err := c.oracle.Params(ctx)
+	if err != nil {
+		c.logger.Error().
+			Err(err).
+			Msg("Could not get oracle params")
+		_ = group.Wait()
+		return
+	}
+
+	c.logger.Debug().
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying oracle params")
+
+	var windowSize uint64
+	if oracleParams.VotePeriod > 0 {
+		windowSize = oracleParams.SlashWindow / oracleParams.VotePeriod
+	}
+
+	generalWindowSizeGauge.Set(float64(windowSize))
+	paramsSlashWindowGauge.Set(float64(oracleParams.SlashWindow))
+	paramsMinValidPerWindowGauge.Set(oracleParams.MinValidPerWindow.MustFloat64())
+	paramsSlashFractionGauge.Set(oracleParams.SlashFraction.MustFloat64())
+	paramsVotePeriodGauge.Set(float64(oracleParams.VotePeriod))
+	if !oracleParams.RewardBand.IsNil() {
+		paramsRewardBandGauge.Set(oracleParams.RewardBand.MustFloat64())
+		metrics = append(metrics, paramsRewardBandGauge)
+	}
+	paramsSymbolsCountGauge.Set(float64(len(oracleParams.Symbols)))
+
+	oracleWhitelistedAssetsGauge.Set(float64(len(oracleParams.Symbols)))
+	for _, symbol := range oracleParams.Symbols {
+		oracleWhitelistedAssetGauge.With(prometheus.Labels{"denom": symbol}).Set(1)
+	}
+
+	if !IsHistorical(ctx) {
+		for _, change := range c.whitelist.Observe(oracleParams.Symbols) {
+			c.logger.Warn().
+				Str("denom", change.Denom).
+				Str("change", change.Change).
+				Msg("Oracle whitelist changed, check the price feeder config")
+		}
+	}
+
+	for denom, counts := range c.whitelist.Changes() {
+		for change, count := range counts {
+			oracleWhitelistChangesCounter.With(prometheus.Labels{"denom": denom, "change": change}).Add(count)
+		}
+	}
+
+	// doing this not in goroutine as we'll need slash window value later
+	c.logger.Debug().Msg("Started querying current slash window progress")
+	slashWindowQueryStart := time.Now()
+
+	windowProgress, err := c.oracle.SlashWindowProgress(ctx, oracleParams)
+	if errors.Is(err, ErrNotSupported) {
+		c.logger.Debug().Msg("Slash window progress is not supported by the chain")
+	} else if err != nil {
+		c.logger.Error().Err(err).Msg("Could not get current slash window progress")
+		_ = group.Wait()
+		return
+	} else {
+		c.logger.Debug().
+			Float64("request-time", time.Since(slashWindowQueryStart).Seconds()).
+			Msg("Finished querying current slash window progress")
+
+		generalWindowProgressGauge.Set(float64(windowProgress))
+	}
+
+	group.Go(func() error {
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Msg("Started querying validator current miss counter")
+		queryStart := time.Now()
+
+		missCounter, err := c.oracle.MissCounter(ctx, addresses.ValAddress(c.myAddress))
+		if errors.Is(err, ErrNotSupported) {
+			return nil
+		} else if err != nil {
+			c.logger.Error().
+				Str("valoper", c.valoper).
+				Err(err).
+				Msg("Could not get validator current miss counter")
+			return nil
+		}
+
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying validator current miss counter")
+
+		validatorMissCounterGauge.With(prometheus.Labels{
+			"valoper": c.valoper,
+		}).Set(float64(missCounter))
+
+		if height > 0 && !IsHistorical(ctx) {
+			streak := c.streaks.Observe(StreakMissedVotes, c.valoper, height, missCounter, oracleParams.VotePeriod)
+			oracleConsecutiveMissedVotesGauge.With(prometheus.Labels{
+				"valoper": c.valoper,
+			}).Set(float64(streak))
+		}
+
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Msg("Started calculate the miss rate")
+		missRateStart := time.Now()
+
+		// nothing can be missed yet at the start of a slash window
+		missRate := 0.0
+		if windowProgress > 0 {
+			missRate = float64(missCounter) / float64(windowProgress)
+		}
+
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Float64("request-time", time.Since(missRateStart).Seconds()).
+			Msg("Finished calculate the miss rate")
+
+		validatorMissRateGauge.With(prometheus.Labels{
+			"valoper": c.valoper,
+		}).Set(missRate)
+
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Msg("Started calculate calculate the estimated windows start")
+		windowStart := time.Now()
+
+		seconds := (windowSize - windowProgress + 1) * c.blockTime * oracleParams.VotePeriod
+
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Float64("request-time", time.Since(windowStart).Seconds()).
+			Msg("Finished calculate the estimated windows start")
+
+		validatorNextWindowStartGauge.With(prometheus.Labels{
+			"valoper": c.valoper,
+		}).Set(float64(time.Now().Add(time.Duration(seconds) * time.Second).UTC().UnixMilli()))
+
+		return nil
+	})
+
+	group.Go(func() error {
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Msg("Started querying validator vote penalty counter")
+		queryStart := time.Now()
+
+		counter, err := c.oracle.VotePenaltyCounter(ctx, addresses.ValAddress(c.myAddress))
+		if errors.Is(err, ErrNotSupported) {
+			return nil
+		} else if err != nil {
+			c.logger.Error().
+				Str("valoper", c.valoper).
+				Err(err).
+				Msg("Could not get validator vote penalty counter")
+			return nil
+		}
+
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying validator vote penalty counter")
+
+		labels := prometheus.Labels{"valoper": c.valoper}
+		votePenaltyMissGauge.With(labels).Set(float64(counter.Misses))
+		votePenaltyAbstainGauge.With(labels).Set(float64(counter.Abstains))
+		votePenaltySuccessGauge.With(labels).Set(float64(counter.Successes))
+
+		return nil
+	})
+
+	group.Go(func() error {
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Msg("Started querying validator performance window")
+		queryStart := time.Now()
+
+		window, err := c.oracle.PerformanceWindow(ctx, addresses.ValAddress(c.myAddress), oracleParams)
+		if errors.Is(err, ErrNotSupported) {
+			return nil
+		} else if err != nil {
+			c.logger.Error().
+				Str("valoper", c.valoper).
+				Err(err).
+				Msg("Could not get validator performance window")
+			return nil
+		}
+
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying validator performance window")
+
+		validRatio := 1.0
+		if window.Elapsed > 0 {
+			validRatio = math.Max(0, 1-float64(window.Misses)/float64(window.Elapsed))
+		}
+
+		var projectedValidRatio float64
+		if window.Size > 0 {
+			projectedValidRatio = math.Max(0, 1-float64(window.Misses)/float64(window.Size))
+		}
+
+		labels := prometheus.Labels{"valoper": c.valoper}
+		performanceWindowSizeGauge.With(labels).Set(float64(window.Size))
+		performanceWindowElapsedGauge.With(labels).Set(float64(window.Elapsed))
+		performanceWindowMissesGauge.With(labels).Set(float64(window.Misses))
+		performanceWindowValidRatioGauge.With(labels).Set(validRatio)
+		performanceWindowProjectedValidRatioGauge.With(labels).Set(projectedValidRatio)
+		performanceWindowMissesRemainingGauge.With(labels).Set(float64(window.AllowedMisses) - float64(window.Misses))
+
+		return nil
+	})
+
+	group.Go(func() error {
+		c.logger.Debug().Msg("Started querying price feeds")
+		queryStart := time.Now()
+
+		feeds, err := c.oracle.PriceFeeds(ctx)
+		if errors.Is(err, ErrNotSupported) {
+			return nil
+		} else if err != nil {
+			c.logger.Error().
+				Err(err).
+				Msg("Could not get price feeds")
+			return nil
+		}
+
+		c.logger.Debug().
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying price feeds")
+
+		for _, feed := range feeds {
+			oraclePriceAgeGauge.With(prometheus.Labels{
+				"market": feed.Market,
+				"source": feed.Source,
+			}).Set(time.Since(feed.Timestamp).Seconds())
+
+			for _, relayer := range feed.Relayers {
+				oraclePriceRelayerGauge.With(prometheus.Labels{
+					"market":  feed.Market,
+					"source":  feed.Source,
+					"relayer": relayer,
+				}).Set(1)
+			}
+		}
+
+		return nil
+	})
+
+	group.Go(func() error {
+		c.logger.Debug().
+			Str("valoper", c.valoper).
+			Msg("Started querying validator aggregate vote")
+		queryStart := time.Now()
+
+		votedDenoms, err := c.oracle.VotedDenoms(ctx, addresses.ValAddress(c.myAddress))
+		if errors.Is(err, ErrNotSupported) {
+			return nil
+		} else if err != nil {
+			c.logger.Warn().
+				Str("valoper", c.valoper).
 				Err(err).
 				Msg("Could not get validator aggregate vote")
-			return
+			return nil
 		}
 
-		sublogger.Debug().
-			Str("valoper", valoper).
+		c.logger.Debug().
+			Str("valoper", c.valoper).
 			Float64("request-time", time.Since(queryStart).Seconds()).
 			Msg("Finished querying validator aggregate vote")
 
-		for _, asset := range oracleParamsResponse.Params.AcceptList {
+		for _, asset := range oracleParams.Symbols {
 			var isContains float64 = 1 // expected that is missed by default
 
-			for _, exchangeTuple := range response.AggregateVote.ExchangeRateTuples {
-				if strings.EqualFold(asset.SymbolDenom, exchangeTuple.Denom) {
+			for _, votedDenom := range votedDenoms {
+				if strings.EqualFold(asset, votedDenom) {
 					isContains = 0 // no misses
 					break
 				}
 			}
 
 			validatorAggregateVoteGauge.With(prometheus.Labels{
-				"asset": asset.SymbolDenom,
+				"asset": asset,
 			}).Set(isContains)
+
+			oracleVoteMissingDenomGauge.With(prometheus.Labels{
+				"denom": asset,
+			}).Set(isContains)
+
+			if isContains == 1 {
+				c.logger.Debug().
+					Str("valoper", c.valoper).
+					Str("denom", asset).
+					Msg("Denom is missing from the aggregate vote")
+			}
 		}
-	}()
 
-	wg.Wait()
+		return nil
+	})
+
+	_ = group.Wait()
+
+	collectMetrics(ch, metrics)
+}
+
+// GeneralHandler serves the metrics of the validator given with ?valoper=, at
+// the height of ?height= if set.
+func GeneralHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	grpcConn *grpc.ClientConn,
+	oracle OracleProvider,
+	blockTime uint64,
+	priceReference *PriceReference,
+	denoms *DenomResolver,
+	balances *BalanceTracker,
+	streaks *StreakTracker,
+	exportRawAmounts bool,
+	expectedFeeders map[string]string,
+	whitelist *WhitelistTracker,
+) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	valoper := r.URL.Query().Get("valoper")
+	myAddress, err := ValAddressFromBech32(valoper)
+
+	if err != nil {
+		sublogger.Error().
+			Str("valoper", valoper).
+			Err(err).
+			Msg("Could not get validator address")
+		return
+	}
 
-	h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	denomOverride, err := ParseDenomOverride(r.URL.Query())
+	if err != nil {
+		sublogger.Error().
+			Err(err).
+			Msg("Could not get denom override")
+		return
+	}
+
+	requestedHeight, err := RequestedHeight(r)
+	if err != nil {
+		sublogger.Error().
+			Err(err).
+			Msg("Could not get requested height")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	registry := collectorRegistry(NewGeneralCollector(
+		r.Context(),
+		grpcConn,
+		oracle,
+		blockTime,
+		priceReference,
+		denoms,
+		balances,
+		streaks,
+		exportRawAmounts,
+		expectedFeeders,
+		whitelist,
+		valoper,
+		myAddress,
+		denomOverride,
+		requestedHeight,
+		sublogger,
+	))
+
+	gatherer := ExportTargetGatherer(registry, valoper)
+	if requestedHeight > 0 {
+		gatherer = HistoricalGatherer(registry, valoper)
+	}
+
+	h := promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
 	h.ServeHTTP(w, r)
 	sublogger.Info().
 		Str("method", "GET").
@@ -390,3 +1730,41 @@ func GeneralHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.Clien
 		Float64("request-time", time.Since(requestStart).Seconds()).
 		Msg("Request processed")
 }
+
+// missedBlocksStreak checks the commits of the blocks since the previous
+// scrape for the signature of the validator, at most blockStreakMaxBlocks of
+// them, and returns the streak of blocks it missed.
+func (c *GeneralCollector) missedBlocksStreak(ctx context.Context, consAddress sdk.ConsAddress, height int64) uint64 {
+	from, to := c.streaks.BlocksToCheck(c.valoper, height, blockStreakMaxBlocks)
+
+	serviceClient := tmservice.NewServiceClient(c.grpcConn)
+	var missed []bool
+	for blockHeight := from; blockHeight <= to; blockHeight++ {
+		blockResponse, err := serviceClient.GetBlockByHeight(ctx, &tmservice.GetBlockByHeightRequest{Height: blockHeight})
+		if err != nil {
+			c.logger.Error().Int64("height", blockHeight).Err(err).Msg("Could not get block")
+			break
+		}
+
+		if blockResponse.Block == nil || blockResponse.Block.LastCommit == nil {
+			c.logger.Warn().Int64("height", blockHeight).Msg("Block has no last commit")
+			break
+		}
+
+		missed = append(missed, missedCommit(blockResponse.Block.LastCommit.Signatures, consAddress))
+	}
+
+	return c.streaks.ObserveBlocks(c.valoper, height, from, missed)
+}
+
+// missedCommit tells whether the validator is in the commit without a
+// signature, validators out of the set don't miss blocks.
+func missedCommit(signatures []tmproto.CommitSig, consAddress sdk.ConsAddress) bool {
+	for _, signature := range signatures {
+		if bytes.Equal(signature.ValidatorAddress, consAddress) {
+			return signature.BlockIdFlag != tmproto.BlockIDFlagCommit
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	querytypes "github.com/cosmos/cosmos-sdk/types/query"
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types/v1beta1"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GovernanceCollector exports the proposals in voting period, their quorum
+// progress and, with a voter, whether the validator has voted on them.
+type GovernanceCollector struct {
+	uncheckedCollector
+
+	ctx      context.Context
+	grpcConn *grpc.ClientConn
+	valoper  string
+	voter    string
+	logger   zerolog.Logger
+}
+
+func NewGovernanceCollector(
+	ctx context.Context,
+	grpcConn *grpc.ClientConn,
+	valoper string,
+	voter string,
+	logger zerolog.Logger,
+) *GovernanceCollector {
+	return &GovernanceCollector{
+		ctx:      ctx,
+		grpcConn: grpcConn,
+		valoper:  valoper,
+		voter:    voter,
+		logger:   logger,
+	}
+}
+
+func (c *GovernanceCollector) Collect(ch chan<- prometheus.Metric) {
+	govProposalActiveGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "gov_proposal_active",
+			Help:        "Proposals in voting period",
+			ConstLabels: constLabels(),
+		},
+		[]string{"id", "title"},
+	)
+
+	govProposalVotingEndGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "gov_proposal_voting_end_time",
+			Help:        "Unix time the voting period of a given proposal ends at",
+			ConstLabels: constLabels(),
+		},
+		[]string{"id"},
+	)
+
+	govProposalVotedGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "gov_proposal_voted",
+			Help:        "Whether a given validator has voted on a given proposal",
+			ConstLabels: constLabels(),
+		},
+		[]string{"id", "valoper"},
+	)
+
+	govProposalTurnoutGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "gov_proposal_turnout",
+			Help:        "Share of the bonded tokens that voted on a given proposal",
+			ConstLabels: constLabels(),
+		},
+		[]string{"id"},
+	)
+
+	govProposalQuorumProgressGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "gov_proposal_quorum_progress",
+			Help:        "Turnout of a given proposal relative to the quorum, 1 when the quorum is reached",
+			ConstLabels: constLabels(),
+		},
+		[]string{"id"},
+	)
+
+	govQuorumGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "gov_quorum",
+			Help:        "Share of the bonded tokens that has to vote for a proposal to be valid",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	metrics := []prometheus.Collector{
+		govProposalActiveGauge,
+		govProposalVotingEndGauge,
+		govProposalTurnoutGauge,
+		govProposalQuorumProgressGauge,
+		govQuorumGauge,
+	}
+	if c.voter != "" {
+		metrics = append(metrics, govProposalVotedGauge)
+	}
+
+	govClient := govtypes.NewQueryClient(c.grpcConn)
+	stakingClient := stakingtypes.NewQueryClient(c.grpcConn)
+
+	c.logger.Debug().Msg("Started querying proposals in voting period")
+	queryStart := time.Now()
+
+	var proposals []govtypes.Proposal
+
+	err := paginate("proposals", func(key []byte, limit uint64) ([]byte, error) {
+		response, err := govClient.Proposals(c.ctx, &govtypes.QueryProposalsRequest{
+			ProposalStatus: govtypes.StatusVotingPeriod,
+			Pagination:     &querytypes.PageRequest{Key: key, Limit: limit},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		proposals = append(proposals, response.Proposals...)
+		return nextPageKey(response.Pagination), nil
+	})
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Could not get proposals")
+		return
+	}
+
+	c.logger.Debug().
+		Int("proposals", len(proposals)).
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying proposals in voting period")
+
+	c.logger.Debug().Msg("Started querying tally params and bonded tokens")
+	queryStart = time.Now()
+
+	paramsResponse, err := govClient.Params(c.ctx, &govtypes.QueryParamsRequest{ParamsType: govtypes.ParamTallying})
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Could not get tally params")
+		return
+	}
+
+	poolResponse, err := stakingClient.Pool(c.ctx, &stakingtypes.QueryPoolRequest{})
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Could not get staking pool")
+		return
+	}
+
+	c.logger.Debug().
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying tally params and bonded tokens")
+
+	quorum := paramsResponse.TallyParams.Quorum.MustFloat64()
+	bonded := RawAmount(poolResponse.Pool.BondedTokens)
+	govQuorumGauge.Set(quorum)
+
+	group, ctx := errgroup.WithContext(c.ctx)
+
+	for _, proposal := range proposals {
+		proposal := proposal
+		id := strconv.FormatUint(proposal.ProposalId, 10)
+
+		govProposalActiveGauge.With(prometheus.Labels{
+			"id":    id,
+			"title": proposalTitle(proposal.Content),
+		}).Set(1)
+		govProposalVotingEndGauge.With(prometheus.Labels{"id": id}).Set(float64(proposal.VotingEndTime.Unix()))
+
+		group.Go(func() error {
+			tallyResponse, err := govClient.TallyResult(ctx, &govtypes.QueryTallyResultRequest{ProposalId: proposal.ProposalId})
+			if err != nil {
+				c.logger.Error().
+					Str("proposal", id).
+					Err(err).
+					Msg("Could not get proposal tally")
+				return nil
+			}
+
+			tally := tallyResponse.Tally
+			voted := RawAmount(tally.Yes.Add(tally.No).Add(tally.Abstain).Add(tally.NoWithVeto))
+			if bonded > 0 {
+				turnout := voted / bonded
+				govProposalTurnoutGauge.With(prometheus.Labels{"id": id}).Set(turnout)
+				if quorum > 0 {
+					govProposalQuorumProgressGauge.With(prometheus.Labels{"id": id}).Set(turnout / quorum)
+				}
+			}
+
+			return nil
+		})
+
+		if c.voter == "" {
+			continue
+		}
+
+		group.Go(func() error {
+			labels := prometheus.Labels{"id": id, "valoper": c.valoper}
+
+			_, err := govClient.Vote(ctx, &govtypes.QueryVoteRequest{ProposalId: proposal.ProposalId, Voter: c.voter})
+			if code := status.Code(err); code == codes.InvalidArgument || code == codes.NotFound {
+				// the node answers with an error if there's no vote yet
+				govProposalVotedGauge.With(labels).Set(0)
+			} else if err != nil {
+				c.logger.Error().
+					Str("proposal", id).
+					Err(err).
+					Msg("Could not get validator vote")
+			} else {
+				govProposalVotedGauge.With(labels).Set(1)
+			}
+
+			return nil
+		})
+	}
+
+	_ = group.Wait()
+
+	collectMetrics(ch, metrics)
+}
+
+// GovernanceHandler serves the proposals in voting period and, with ?valoper=,
+// the votes of the validator.
+func GovernanceHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	valoper := r.URL.Query().Get("valoper")
+	var voter string
+	if valoper != "" {
+		// validators vote with their self-delegation account
+		var err error
+		if voter, err = OperatorAccount(valoper); err != nil {
+			sublogger.Error().
+				Str("valoper", valoper).
+				Err(err).
+				Msg("Could not get validator address")
+			return
+		}
+	}
+
+	registry := collectorRegistry(NewGovernanceCollector(r.Context(), grpcConn, valoper, voter, sublogger))
+
+	h := promhttp.HandlerFor(ExportTargetGatherer(registry, valoper), promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/gov?valoper="+valoper).
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
+
+// proposalTitle reads the title of the proposal content. Content types of
+// other modules aren't registered here, but all of them start with the same
+// title and description fields as the text proposal.
+func proposalTitle(content *codectypes.Any) string {
+	if content == nil {
+		return ""
+	}
+
+	var text govtypes.TextProposal
+	if err := text.Unmarshal(content.Value); err != nil {
+		return ""
+	}
+
+	return text.Title
+}
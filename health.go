@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/connectivity"
+)
+
+// HealthzHandler only tells the process is alive and serving HTTP.
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+// ReadyzHandler reports ready once the node answers gRPC queries and the
+// staking denom metadata is resolved, so converted amounts are correct.
+func ReadyzHandler(w http.ResponseWriter, r *http.Request, node *NodeConnection, denoms *DenomResolver) {
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	grpcConn := node.Get()
+	if state := grpcConn.GetState(); state == connectivity.TransientFailure || state == connectivity.Shutdown {
+		sublogger.Debug().Str("state", state.String()).Msg("gRPC connection is not ready")
+		http.Error(w, "gRPC connection is "+state.String(), http.StatusServiceUnavailable)
+		return
+	}
+
+	stakingClient := stakingtypes.NewQueryClient(grpcConn)
+	response, err := stakingClient.Params(ctx, &stakingtypes.QueryParamsRequest{})
+	if err != nil {
+		sublogger.Debug().Err(err).Msg("Could not get staking params")
+		http.Error(w, "gRPC node is not reachable", http.StatusServiceUnavailable)
+		return
+	}
+
+	bondDenom := response.Params.BondDenom
+	if !denoms.Resolved(bondDenom) {
+		denoms.Resolve(ctx, grpcConn, bondDenom)
+	}
+
+	if !denoms.Resolved(bondDenom) {
+		http.Error(w, "denom "+bondDenom+" is not resolved", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+var (
+	checkURL     string
+	checkReady   bool
+	checkGRPC    bool
+	checkTimeout time.Duration
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Exit with an error if the running exporter or the node is unhealthy",
+	Long: "Request /healthz of the exporter listening on --listen-address, /readyz with --ready, " +
+		"or query --node directly with --grpc, for Docker HEALTHCHECK and systemd.",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if checkGRPC {
+			if err := SetupExporter(cmd.Flags()); err != nil {
+				return err
+			}
+			return checkNode(cmd.OutOrStdout())
+		}
+
+		url := checkURL
+		if url == "" {
+			path := "/healthz"
+			if checkReady {
+				path = "/readyz"
+			}
+			endpoints := CurrentConfig().Endpoints
+			url = localURL(endpoints.Listen, endpoints.TLSCert != "") + path
+		}
+
+		return checkHTTP(cmd.OutOrStdout(), url)
+	},
+}
+
+// localURL returns the URL the exporter listening on address is reached at
+// from the same host.
+func localURL(address string, https bool) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = address, "9300"
+	}
+
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+
+	scheme := "http"
+	if https {
+		scheme = "https"
+	}
+
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+func checkHTTP(w io.Writer, url string) error {
+	client := &http.Client{
+		Timeout: checkTimeout,
+		// the certificate is issued for the public name, not for localhost
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+
+	response, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s: %s", url, response.Status, strings.TrimSpace(string(body)))
+	}
+
+	fmt.Fprintln(w, strings.TrimSpace(string(body)))
+	return nil
+}
+
+// checkNode queries the latest block of the node, as the endpoint checks do.
+func checkNode(w io.Writer) error {
+	endpoints := CurrentConfig().Endpoints
+
+	conn, err := DialNode(endpoints.Node, endpoints.IPFamily, 0)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	response, err := tmservice.NewServiceClient(conn).GetLatestBlock(ctx, &tmservice.GetLatestBlockRequest{})
+	if err != nil {
+		return fmt.Errorf("node %s is not reachable: %w", endpoints.Node, err)
+	}
+	if response.Block == nil {
+		return fmt.Errorf("node %s answered without the latest block", endpoints.Node)
+	}
+
+	fmt.Fprintf(w, "ok, height %d in %s\n", response.Block.Header.Height, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func init() {
+	checkCmd.Flags().StringVar(&checkURL, "url", "", "URL to check instead of /healthz on --listen-address")
+	checkCmd.Flags().BoolVar(&checkReady, "ready", false, "Check /readyz, connected to the node, instead of /healthz")
+	checkCmd.Flags().BoolVar(&checkGRPC, "grpc", false, "Query --node directly instead of the exporter")
+	checkCmd.Flags().DurationVar(&checkTimeout, "timeout", 5*time.Second, "Timeout of the check")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type historicalHeightKey struct{}
+
+// PinHeight returns a context whose queries are all answered at the latest
+// block height, so the results of one scrape come from the same block.
+func PinHeight(ctx context.Context, grpcConn *grpc.ClientConn) (context.Context, int64, error) {
+	serviceClient := tmservice.NewServiceClient(grpcConn)
+	response, err := serviceClient.GetLatestBlock(ctx, &tmservice.GetLatestBlockRequest{})
+	if err != nil {
+		return ctx, 0, err
+	}
+
+	height := response.Block.Header.Height
+	return metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatInt(height, 10)), height, nil
+}
+
+// AtHeight returns a context whose queries are answered at a past height.
+// The state trackers and caches of the exporter skip queries made with it.
+func AtHeight(ctx context.Context, height int64) context.Context {
+	ctx = context.WithValue(ctx, historicalHeightKey{}, height)
+	return metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatInt(height, 10))
+}
+
+// IsHistorical tells whether the queries of ctx are made at a past height
+// requested with ?height=.
+func IsHistorical(ctx context.Context) bool {
+	_, ok := ctx.Value(historicalHeightKey{}).(int64)
+	return ok
+}
+
+// PinnedHeight returns the height the queries of ctx are made at, if pinned.
+func PinnedHeight(ctx context.Context) (int64, bool) {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		return 0, false
+	}
+
+	values := md.Get(grpctypes.GRPCBlockHeightHeader)
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	height, err := strconv.ParseInt(values[len(values)-1], 10, 64)
+	return height, err == nil
+}
+
+// RequestedHeight parses the ?height= parameter of a metrics request, 0 if
+// it's not set. It's only accepted with --historical-queries, as historical
+// queries need an archive node and are expensive for it.
+func RequestedHeight(r *http.Request) (int64, error) {
+	value := r.URL.Query().Get("height")
+	if value == "" {
+		return 0, nil
+	}
+
+	if !CurrentConfig().Queries.Historical {
+		return 0, fmt.Errorf("historical queries are disabled, see --historical-queries")
+	}
+
+	height, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || height <= 0 {
+		return 0, fmt.Errorf("invalid height %q", value)
+	}
+
+	return height, nil
+}
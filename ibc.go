@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+const ibcTransferService = "/ibc.applications.transfer.v1.Query/"
+
+type ibcDenomTraceRequest struct {
+	// the hash of ibc/<hash>, or the whole denom on newer ibc-go versions
+	Hash string `protobuf:"bytes,1,opt,name=hash,proto3"`
+}
+
+func (m *ibcDenomTraceRequest) Reset()         { *m = ibcDenomTraceRequest{} }
+func (m *ibcDenomTraceRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*ibcDenomTraceRequest) ProtoMessage()    {}
+
+type ibcDenomTrace struct {
+	// the ports and channels the denom went through, e.g. transfer/channel-0
+	Path      string `protobuf:"bytes,1,opt,name=path,proto3"`
+	BaseDenom string `protobuf:"bytes,2,opt,name=base_denom,json=baseDenom,proto3"`
+}
+
+func (m *ibcDenomTrace) Reset()         { *m = ibcDenomTrace{} }
+func (m *ibcDenomTrace) String() string { return fmt.Sprintf("%+v", *m) }
+func (*ibcDenomTrace) ProtoMessage()    {}
+
+type ibcDenomTraceResponse struct {
+	DenomTrace *ibcDenomTrace `protobuf:"bytes,1,opt,name=denom_trace,json=denomTrace,proto3"`
+}
+
+func (m *ibcDenomTraceResponse) Reset()         { *m = ibcDenomTraceResponse{} }
+func (m *ibcDenomTraceResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*ibcDenomTraceResponse) ProtoMessage()    {}
+
+// IsIBCDenom tells whether the denom is a voucher of the transfer module.
+func IsIBCDenom(denom string) bool {
+	return strings.HasPrefix(denom, "ibc/")
+}
+
+// DenomTrace resolves an ibc/<hash> denom to the denom on its origin chain and
+// the path it was transferred through.
+func DenomTrace(ctx context.Context, grpcConn *grpc.ClientConn, denom string) (*ibcDenomTrace, error) {
+	response := &ibcDenomTraceResponse{}
+	request := &ibcDenomTraceRequest{Hash: strings.TrimPrefix(denom, "ibc/")}
+	if err := grpcConn.Invoke(ctx, ibcTransferService+"DenomTrace", request, response); err != nil {
+		return nil, err
+	}
+
+	if response.DenomTrace == nil || response.DenomTrace.BaseDenom == "" {
+		return nil, fmt.Errorf("no denom trace for %s", denom)
+	}
+
+	return response.DenomTrace, nil
+}
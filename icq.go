@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+)
+
+const neutronICQService = "/neutron.interchainqueries.Query/"
+
+// Hand-written messages of the Neutron interchainqueries module, only the
+// fields the exporter reads are declared.
+
+type icqPageRequest struct {
+	Key   []byte `protobuf:"bytes,1,opt,name=key,proto3"`
+	Limit uint64 `protobuf:"varint,2,opt,name=limit,proto3"`
+}
+
+func (m *icqPageRequest) Reset()         { *m = icqPageRequest{} }
+func (m *icqPageRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*icqPageRequest) ProtoMessage()    {}
+
+type icqPageResponse struct {
+	NextKey []byte `protobuf:"bytes,1,opt,name=next_key,json=nextKey,proto3"`
+}
+
+func (m *icqPageResponse) Reset()         { *m = icqPageResponse{} }
+func (m *icqPageResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*icqPageResponse) ProtoMessage()    {}
+
+type icqRegisteredQueriesRequest struct {
+	Owners       []string        `protobuf:"bytes,1,rep,name=owners,proto3"`
+	ConnectionID string          `protobuf:"bytes,2,opt,name=connection_id,json=connectionId,proto3"`
+	Pagination   *icqPageRequest `protobuf:"bytes,3,opt,name=pagination,proto3"`
+}
+
+func (m *icqRegisteredQueriesRequest) Reset()         { *m = icqRegisteredQueriesRequest{} }
+func (m *icqRegisteredQueriesRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*icqRegisteredQueriesRequest) ProtoMessage()    {}
+
+type icqRegisteredQuery struct {
+	ID                             uint64 `protobuf:"varint,1,opt,name=id,proto3"`
+	Owner                          string `protobuf:"bytes,2,opt,name=owner,proto3"`
+	QueryType                      string `protobuf:"bytes,3,opt,name=query_type,json=queryType,proto3"`
+	ConnectionID                   string `protobuf:"bytes,6,opt,name=connection_id,json=connectionId,proto3"`
+	UpdatePeriod                   uint64 `protobuf:"varint,7,opt,name=update_period,json=updatePeriod,proto3"`
+	LastSubmittedResultLocalHeight uint64 `protobuf:"varint,8,opt,name=last_submitted_result_local_height,json=lastSubmittedResultLocalHeight,proto3"`
+	SubmitTimeout                  uint64 `protobuf:"varint,11,opt,name=submit_timeout,json=submitTimeout,proto3"`
+	RegisteredAtHeight             uint64 `protobuf:"varint,12,opt,name=registered_at_height,json=registeredAtHeight,proto3"`
+}
+
+func (m *icqRegisteredQuery) Reset()         { *m = icqRegisteredQuery{} }
+func (m *icqRegisteredQuery) String() string { return fmt.Sprintf("%+v", *m) }
+func (*icqRegisteredQuery) ProtoMessage()    {}
+
+type icqRegisteredQueriesResponse struct {
+	RegisteredQueries []*icqRegisteredQuery `protobuf:"bytes,1,rep,name=registered_queries,json=registeredQueries,proto3"`
+	Pagination        *icqPageResponse      `protobuf:"bytes,2,opt,name=pagination,proto3"`
+}
+
+func (m *icqRegisteredQueriesResponse) Reset()         { *m = icqRegisteredQueriesResponse{} }
+func (m *icqRegisteredQueriesResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*icqRegisteredQueriesResponse) ProtoMessage()    {}
+
+// ICQCollector exports the state of the interchain queries registered on a
+// Neutron-style interchainqueries module, optionally filtered by owners,
+// and the balances of the ICQ relayers. Stalled relayers leave queries
+// without results, breaking the price and stake data built on them.
+type ICQCollector struct {
+	uncheckedCollector
+
+	ctx             context.Context
+	grpcConn        *grpc.ClientConn
+	denoms          *DenomResolver
+	relayers        []string
+	owners          []string
+	requestedHeight int64
+	logger          zerolog.Logger
+}
+
+func NewICQCollector(
+	ctx context.Context,
+	grpcConn *grpc.ClientConn,
+	denoms *DenomResolver,
+	relayers []string,
+	owners []string,
+	requestedHeight int64,
+	logger zerolog.Logger,
+) *ICQCollector {
+	return &ICQCollector{
+		ctx:             ctx,
+		grpcConn:        grpcConn,
+		denoms:          denoms,
+		relayers:        relayers,
+		owners:          owners,
+		requestedHeight: requestedHeight,
+		logger:          logger,
+	}
+}
+
+func (c *ICQCollector) Collect(ch chan<- prometheus.Metric) {
+	icqRegisteredQueriesGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "icq_registered_queries",
+			Help:        "Number of registered interchain queries of a given connection",
+			ConstLabels: constLabels(),
+		},
+		[]string{"connection_id", "query_type"},
+	)
+
+	icqPendingQueriesGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "icq_pending_queries",
+			Help:        "Number of KV interchain queries of a given connection whose result is older than their update period",
+			ConstLabels: constLabels(),
+		},
+		[]string{"connection_id"},
+	)
+
+	icqMaxResultAgeGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "icq_max_result_age_blocks",
+			Help:        "Blocks since the oldest result of the interchain queries of a given connection was submitted",
+			ConstLabels: constLabels(),
+		},
+		[]string{"connection_id"},
+	)
+
+	icqMinBlocksToTimeoutGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "icq_min_blocks_to_timeout",
+			Help:        "Blocks until the first interchain query of a given connection times out and can be removed, negative if already timed out",
+			ConstLabels: constLabels(),
+		},
+		[]string{"connection_id"},
+	)
+
+	icqRelayerBalanceGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "icq_relayer_balance",
+			Help:        "Balance of a given ICQ relayer in display denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"address", "denom"},
+	)
+
+	metrics := []prometheus.Collector{
+		icqRegisteredQueriesGauge,
+		icqPendingQueriesGauge,
+		icqMaxResultAgeGauge,
+		icqMinBlocksToTimeoutGauge,
+		icqRelayerBalanceGauge,
+	}
+
+	var ctx context.Context
+	var err error
+	height := c.requestedHeight
+	if c.requestedHeight > 0 {
+		ctx = AtHeight(c.ctx, c.requestedHeight)
+	} else if ctx, height, err = PinHeight(c.ctx, c.grpcConn); err != nil {
+		c.logger.Error().Err(err).Msg("Could not get latest block height")
+		return
+	}
+
+	c.logger.Debug().Msg("Started querying registered interchain queries")
+	queryStart := time.Now()
+
+	var queries []*icqRegisteredQuery
+
+	err = paginate("registered_queries", func(key []byte, limit uint64) ([]byte, error) {
+		response := &icqRegisteredQueriesResponse{}
+		err := c.grpcConn.Invoke(ctx, neutronICQService+"RegisteredQueries", &icqRegisteredQueriesRequest{
+			Owners:     c.owners,
+			Pagination: &icqPageRequest{Key: key, Limit: limit},
+		}, response)
+		if err != nil {
+			return nil, err
+		}
+
+		queries = append(queries, response.RegisteredQueries...)
+		if response.Pagination == nil {
+			return nil, nil
+		}
+		return response.Pagination.NextKey, nil
+	})
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Could not get registered interchain queries")
+		return
+	}
+
+	c.logger.Debug().
+		Int("queries", len(queries)).
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying registered interchain queries")
+
+	maxResultAge := make(map[string]int64)
+	minBlocksToTimeout := make(map[string]int64)
+
+	for _, query := range queries {
+		icqRegisteredQueriesGauge.With(prometheus.Labels{
+			"connection_id": query.ConnectionID,
+			"query_type":    query.QueryType,
+		}).Inc()
+
+		// queries without a result yet count from their registration
+		lastHeight := int64(query.LastSubmittedResultLocalHeight)
+		if lastHeight == 0 {
+			lastHeight = int64(query.RegisteredAtHeight)
+		}
+
+		age := height - lastHeight
+		if age > maxResultAge[query.ConnectionID] {
+			maxResultAge[query.ConnectionID] = age
+		}
+
+		pending := icqPendingQueriesGauge.With(prometheus.Labels{"connection_id": query.ConnectionID})
+		if query.QueryType == "kv" && age > int64(query.UpdatePeriod) {
+			pending.Inc()
+		}
+
+		if query.SubmitTimeout == 0 {
+			continue
+		}
+
+		toTimeout := lastHeight + int64(query.SubmitTimeout) - height
+		if current, ok := minBlocksToTimeout[query.ConnectionID]; !ok || toTimeout < current {
+			minBlocksToTimeout[query.ConnectionID] = toTimeout
+		}
+	}
+
+	for connectionID, age := range maxResultAge {
+		icqMaxResultAgeGauge.With(prometheus.Labels{"connection_id": connectionID}).Set(float64(age))
+	}
+
+	for connectionID, blocks := range minBlocksToTimeout {
+		icqMinBlocksToTimeoutGauge.With(prometheus.Labels{"connection_id": connectionID}).Set(float64(blocks))
+	}
+
+	bankClient := banktypes.NewQueryClient(c.grpcConn)
+	for _, relayer := range c.relayers {
+		balancesResponse, err := bankClient.AllBalances(ctx, &banktypes.QueryAllBalancesRequest{Address: relayer})
+		if err != nil {
+			c.logger.Error().
+				Str("address", relayer).
+				Err(err).
+				Msg("Could not get ICQ relayer balance")
+			continue
+		}
+
+		for _, balance := range balancesResponse.Balances {
+			denom := c.denoms.Resolve(ctx, c.grpcConn, balance.Denom)
+			if !c.denoms.Allowed(denom) {
+				continue
+			}
+			icqRelayerBalanceGauge.With(prometheus.Labels{
+				"address": relayer,
+				"denom":   denom.Display,
+			}).Set(c.denoms.Convert(denom, balance.Amount))
+		}
+	}
+
+	collectMetrics(ch, metrics)
+}
+
+// ICQHandler serves the interchain queries, filtered by the owners given with
+// ?owner=, repeated or comma separated, and at the height of ?height= if set.
+func ICQHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	grpcConn *grpc.ClientConn,
+	denoms *DenomResolver,
+	relayers []string,
+) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	var owners []string
+	for _, value := range r.URL.Query()["owner"] {
+		for _, owner := range strings.Split(value, ",") {
+			if owner = strings.TrimSpace(owner); owner != "" {
+				owners = append(owners, owner)
+			}
+		}
+	}
+
+	requestedHeight, err := RequestedHeight(r)
+	if err != nil {
+		sublogger.Error().Err(err).Msg("Could not get requested height")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	registry := collectorRegistry(NewICQCollector(r.Context(), grpcConn, denoms, relayers, owners, requestedHeight, sublogger))
+
+	gatherer := ExportGatherer(registry)
+	if requestedHeight > 0 {
+		gatherer = HistoricalGatherer(registry, "")
+	}
+
+	h := promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/icq").
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SignalOracleMisses     = "oracle-misses"
+	SignalMissedBlocks     = "missed-blocks"
+	SignalNodeLag          = "node-lag"
+	SignalFeederBalance    = "feeder-balance"
+	SignalConsensusRound   = "consensus-round"
+	SignalFeederTxFailures = "feeder-tx-failures"
+)
+
+const (
+	CauseNode          = "node"
+	CauseNetwork       = "network"
+	CauseValidatorNode = "validator-node"
+	CauseFeederFunds   = "feeder-funds"
+	CauseFeeder        = "feeder"
+	CauseUnknown       = "unknown"
+)
+
+// Incident is a set of anomaly signals of one validator that fired within
+// the correlation window, with a guess of what caused them.
+type Incident struct {
+	Valoper  string
+	Cause    string
+	Signals  []string
+	OpenedAt time.Time
+}
+
+// IncidentCorrelator groups the signals observed by the alerter, so a node
+// outage that makes the validator miss blocks and oracle votes at the same
+// time results in a single incident instead of an alert per signal.
+type IncidentCorrelator struct {
+	window time.Duration
+
+	mutex sync.Mutex
+	// valoper -> signal -> last time it fired
+	signals   map[string]map[string]time.Time
+	incidents map[string]*Incident
+}
+
+func NewIncidentCorrelator(window time.Duration) *IncidentCorrelator {
+	return &IncidentCorrelator{
+		window:    window,
+		signals:   make(map[string]map[string]time.Time),
+		incidents: make(map[string]*Incident),
+	}
+}
+
+func (c *IncidentCorrelator) Observe(valoper string, signal string, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.signals[valoper] == nil {
+		c.signals[valoper] = make(map[string]time.Time)
+	}
+
+	c.signals[valoper][signal] = now
+}
+
+// Evaluate drops the signals older than the window and returns the open
+// incident of the validator, if any. An incident opens once two different
+// signals fired within the window and closes when none is left, opened and
+// closed tell which of the two happened on this call.
+func (c *IncidentCorrelator) Evaluate(valoper string, now time.Time) (incident *Incident, opened bool, closed *Incident) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var active []string
+	for signal, firedAt := range c.signals[valoper] {
+		if now.Sub(firedAt) > c.window {
+			delete(c.signals[valoper], signal)
+			continue
+		}
+
+		active = append(active, signal)
+	}
+	sort.Strings(active)
+
+	incident = c.incidents[valoper]
+	switch {
+	case incident == nil && len(active) >= 2:
+		incident = &Incident{
+			Valoper:  valoper,
+			Cause:    guessIncidentCause(active),
+			Signals:  active,
+			OpenedAt: now,
+		}
+		c.incidents[valoper] = incident
+		return incident, true, nil
+	case incident != nil && len(active) == 0:
+		delete(c.incidents, valoper)
+		return nil, false, incident
+	case incident != nil:
+		// the cause stays the first guess, the exported series keep their labels
+		incident.Signals = mergeSignals(incident.Signals, active)
+	}
+
+	return incident, false, nil
+}
+
+// Forget drops the signals and the incident of a validator no longer watched.
+func (c *IncidentCorrelator) Forget(valoper string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.signals, valoper)
+	delete(c.incidents, valoper)
+}
+
+func guessIncidentCause(signals []string) string {
+	has := func(signal string) bool {
+		return containsString(signals, signal)
+	}
+
+	switch {
+	case has(SignalNodeLag):
+		return CauseNode
+	case has(SignalConsensusRound) && has(SignalMissedBlocks):
+		// blocks taking several rounds are a trouble of the whole chain
+		return CauseNetwork
+	case has(SignalMissedBlocks) && has(SignalOracleMisses):
+		// the oracle votes stop along with the blocks when the validator node is down
+		return CauseValidatorNode
+	case has(SignalFeederBalance) && (has(SignalOracleMisses) || has(SignalFeederTxFailures)):
+		return CauseFeederFunds
+	case has(SignalFeederTxFailures):
+		// the votes are sent but rejected, e.g. out of gas or a wrong feeder
+		return CauseFeeder
+	}
+
+	return CauseUnknown
+}
+
+func mergeSignals(signals []string, more []string) []string {
+	merged := append([]string{}, signals...)
+	for _, signal := range more {
+		if !containsString(merged, signal) {
+			merged = append(merged, signal)
+		}
+	}
+	sort.Strings(merged)
+
+	return merged
+}
+
+func formatSignals(signals []string) string {
+	return strings.Join(signals, ", ")
+}
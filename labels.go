@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// LabelNormalizer rewrites label values so label sets stay stable and Grafana
+// legends readable, e.g. monikers with emoji or ibc/ denoms.
+type LabelNormalizer struct {
+	// label names whose values are lowercased
+	Lowercase map[string]bool
+	// strip emoji, symbols and control characters from every label value
+	StripSymbols bool
+	// label name -> maximum value length in runes
+	MaxLength map[string]int64
+}
+
+func NewLabelNormalizer(lowercase []string, stripSymbols bool, maxLength map[string]int64) *LabelNormalizer {
+	names := make(map[string]bool, len(lowercase))
+	for _, name := range lowercase {
+		names[name] = true
+	}
+
+	return &LabelNormalizer{
+		Lowercase:    names,
+		StripSymbols: stripSymbols,
+		MaxLength:    maxLength,
+	}
+}
+
+func (n *LabelNormalizer) enabled() bool {
+	return len(n.Lowercase) > 0 || n.StripSymbols || len(n.MaxLength) > 0
+}
+
+func (n *LabelNormalizer) Normalize(name string, value string) string {
+	if n.StripSymbols {
+		value = strings.Join(strings.Fields(strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) || unicode.In(r, unicode.So, unicode.Sk, unicode.Cf, unicode.Cs, unicode.Co, unicode.Mn) {
+				return -1
+			}
+			return r
+		}, value)), " ")
+	}
+
+	if n.Lowercase[name] {
+		value = strings.ToLower(value)
+	}
+
+	if maxLength, ok := n.MaxLength[name]; ok && maxLength > 0 {
+		if runes := []rune(value); int64(len(runes)) > maxLength {
+			value = string(runes[:maxLength])
+		}
+	}
+
+	return value
+}
+
+// Gatherer normalizes the label values of the metrics of gatherer. Series that
+// become equal after normalization are exported once.
+func (n *LabelNormalizer) Gatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	if !n.enabled() {
+		return gatherer
+	}
+
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+		if err != nil {
+			return families, err
+		}
+
+		// the families may share metrics with each other when several
+		// schemas are emitted, so they are copied instead of changed in place
+		for _, family := range families {
+			seen := make(map[string]bool, len(family.Metric))
+			metrics := make([]*dto.Metric, 0, len(family.Metric))
+
+			for _, metric := range family.Metric {
+				normalized := &dto.Metric{
+					Label:       make([]*dto.LabelPair, len(metric.Label)),
+					Gauge:       metric.Gauge,
+					Counter:     metric.Counter,
+					Summary:     metric.Summary,
+					Untyped:     metric.Untyped,
+					Histogram:   metric.Histogram,
+					TimestampMs: metric.TimestampMs,
+				}
+
+				var signature strings.Builder
+				for index, label := range metric.Label {
+					value := n.Normalize(label.GetName(), label.GetValue())
+					normalized.Label[index] = &dto.LabelPair{Name: label.Name, Value: &value}
+
+					signature.WriteString(label.GetName())
+					signature.WriteByte(0)
+					signature.WriteString(value)
+					signature.WriteByte(0)
+				}
+
+				if seen[signature.String()] {
+					continue
+				}
+
+				seen[signature.String()] = true
+				metrics = append(metrics, normalized)
+			}
+
+			family.Metric = metrics
+		}
+
+		return families, nil
+	})
+}
+
+// labelNormalizer is configured with the --label-* flags, guarded by
+// configMutex as it's rebuilt on reload.
+var labelNormalizer = NewLabelNormalizer(nil, false, nil)
+
+func CurrentLabelNormalizer() *LabelNormalizer {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	return labelNormalizer
+}
+
+func SetLabelNormalizer(normalizer *LabelNormalizer) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	labelNormalizer = normalizer
+}
+
+// constLabels returns the --const-labels in effect. A reload replaces them,
+// so the collectors don't read the flag variable.
+func constLabels() map[string]string {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	return exporterConfig.Metrics.ConstLabels
+}
+
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// TargetLabels are the extra labels of validators and wallets, from the
+// validator-labels and wallet-labels blocks of the config file, e.g. the team
+// running a validator.
+type TargetLabels struct {
+	mutex sync.RWMutex
+	// valoper -> label -> value
+	validators map[string]map[string]string
+	// address -> label -> value
+	wallets map[string]map[string]string
+}
+
+// targetLabels is loaded from the config file and updated on reload.
+var targetLabels = &TargetLabels{}
+
+// ValidateTargetLabels checks the label names of the validator-labels and
+// wallet-labels blocks of the config file.
+func ValidateTargetLabels(validators map[string]map[string]string, wallets map[string]map[string]string) error {
+	for _, targets := range []map[string]map[string]string{validators, wallets} {
+		for target, labels := range targets {
+			for name := range labels {
+				if !labelNameRegexp.MatchString(name) {
+					return fmt.Errorf("invalid label name %q of %s", name, target)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (t *TargetLabels) Set(validators map[string]map[string]string, wallets map[string]map[string]string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.validators = validators
+	t.wallets = wallets
+}
+
+// labels returns the extra labels of a series, those of the validator of the
+// request and those of the validator or wallet the series is about.
+func (t *TargetLabels) labels(valoper string, metric *dto.Metric) map[string]string {
+	extra := make(map[string]string)
+	add := func(labels map[string]string) {
+		for name, value := range labels {
+			extra[name] = value
+		}
+	}
+
+	add(t.validators[valoper])
+	for _, label := range metric.Label {
+		switch label.GetName() {
+		case "valoper":
+			add(t.validators[label.GetValue()])
+		case "address", "feeder":
+			add(t.wallets[label.GetValue()])
+		}
+	}
+
+	// the labels of the series take precedence
+	for _, label := range metric.Label {
+		delete(extra, label.GetName())
+	}
+
+	return extra
+}
+
+// Gatherer adds the extra labels to the metrics of gatherer, valoper is the
+// validator the request is for if any.
+func (t *TargetLabels) Gatherer(gatherer prometheus.Gatherer, valoper string) prometheus.Gatherer {
+	t.mutex.RLock()
+	enabled := len(t.validators) > 0 || len(t.wallets) > 0
+	t.mutex.RUnlock()
+
+	if !enabled {
+		return gatherer
+	}
+
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+		if err != nil {
+			return families, err
+		}
+
+		t.mutex.RLock()
+		defer t.mutex.RUnlock()
+
+		// copied like in LabelNormalizer, the metrics may be shared
+		for _, family := range families {
+			metrics := make([]*dto.Metric, 0, len(family.Metric))
+			for _, metric := range family.Metric {
+				extra := t.labels(valoper, metric)
+				if len(extra) == 0 {
+					metrics = append(metrics, metric)
+					continue
+				}
+
+				labeled := &dto.Metric{
+					Label:       append([]*dto.LabelPair{}, metric.Label...),
+					Gauge:       metric.Gauge,
+					Counter:     metric.Counter,
+					Summary:     metric.Summary,
+					Untyped:     metric.Untyped,
+					Histogram:   metric.Histogram,
+					TimestampMs: metric.TimestampMs,
+				}
+
+				for name, value := range extra {
+					name, value := name, value
+					labeled.Label = append(labeled.Label, &dto.LabelPair{Name: &name, Value: &value})
+				}
+
+				sort.Slice(labeled.Label, func(i, j int) bool {
+					return labeled.Label[i].GetName() < labeled.Label[j].GetName()
+				})
+
+				metrics = append(metrics, labeled)
+			}
+
+			family.Metric = metrics
+		}
+
+		return families, nil
+	})
+}
+
+// ExportGatherer is what the metrics handlers serve: the registry in the
+// enabled schemas with normalized label values and the extra labels of the
+// targets, recorded to the time series store if enabled.
+func ExportGatherer(registry *prometheus.Registry) prometheus.Gatherer {
+	return ExportTargetGatherer(registry, "")
+}
+
+// ExportTargetGatherer is ExportGatherer for the requests of a validator,
+// whose extra labels are added to all series.
+func ExportTargetGatherer(registry *prometheus.Registry, valoper string) prometheus.Gatherer {
+	return timeSeriesStore.Gatherer(HistoricalGatherer(registry, valoper))
+}
+
+// HistoricalGatherer is ExportTargetGatherer for requests at a past height,
+// which aren't recorded to the time series store.
+func HistoricalGatherer(registry *prometheus.Registry, valoper string) prometheus.Gatherer {
+	return targetLabels.Gatherer(CurrentLabelNormalizer().Gatherer(CurrentMetricNamer().Gatherer(SchemaGatherer(CurrentMetricFilter().Gatherer(registry), CurrentConfig().Metrics.Schemas))), valoper)
+}
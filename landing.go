@@ -0,0 +1,112 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+)
+
+type LandingEndpoint struct {
+	Path        string
+	Description string
+}
+
+var landingTemplate = template.Must(template.New("landing").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Oracle exporter</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+td { padding: 0.2em 1em 0.2em 0; }
+</style>
+</head>
+<body>
+<h1>Oracle exporter</h1>
+<table>
+<tr><td>Chain type</td><td>{{ .ChainType }}</td></tr>
+{{- range $name, $value := .ConstLabels }}
+<tr><td>{{ $name }}</td><td>{{ $value }}</td></tr>
+{{- end }}
+</table>
+<h2>Endpoints</h2>
+<table>
+{{- range .Endpoints }}
+<tr><td><a href="{{ .Path }}">{{ .Path }}</a></td><td>{{ .Description }}</td></tr>
+{{- end }}
+</table>
+</body>
+</html>
+`))
+
+// LandingEndpoints returns the endpoints served with the current flags.
+func LandingEndpoints() []LandingEndpoint {
+	endpoints := []LandingEndpoint{
+		{"/metrics", "Metrics of the exporter itself"},
+		{"/metrics/general?valoper=", "Oracle, signing and feeder metrics of a validator"},
+		{"/metrics/oracle/{address}", "Same as /metrics/general with the validator in the path"},
+		{"/metrics/validators?valoper=", "Validator set and rank of a validator"},
+		{"/metrics/validator/{address}", "Same as /metrics/validators with the validator in the path"},
+		{"/metrics/gov?valoper=", "Proposals in voting period and votes of a validator"},
+		{"/metrics/upgrade", "Upgrade plan and time to upgrade"},
+		{"/metrics/marketmap", "Slinky market map"},
+		{"/metrics/slinky?valoper=", "Slinky prices and vote extensions of a validator"},
+		{"/metrics/band?valoper=", "BandChain oracle requests of a validator"},
+		{"/metrics/icq", "Interchain queries"},
+		{"/metrics/wallet?address=", "Balances of wallets"},
+		{"/metrics/wallet/{address}", "Balances of a wallet"},
+		{"/healthz", "Liveness"},
+		{"/readyz", "Readiness, connected to the node"},
+	}
+
+	config := CurrentConfig()
+	if config.Alerts.SLOTarget > 0 {
+		endpoints = append(endpoints, LandingEndpoint{"/metrics/slo?valoper=", "Participation SLO of a validator"})
+	}
+	if len(config.BlockValidators) > 0 {
+		endpoints = append(endpoints, LandingEndpoint{"/metrics/blocks", "Votes checked at every block"})
+	}
+	if config.Endpoints.TendermintRPC != "" {
+		endpoints = append(endpoints, LandingEndpoint{"/metrics/node?valoper=", "Node sync state and peers"})
+	}
+	if Probe {
+		endpoints = append(endpoints, LandingEndpoint{"/probe?target=&validator=", "Metrics of a node dialed on demand"})
+	}
+	if NetworkScan {
+		endpoints = append(endpoints, LandingEndpoint{"/metrics/network", "Oracle metrics of the whole active set"})
+	}
+	if TimeSeriesRetention > 0 {
+		endpoints = append(endpoints, LandingEndpoint{"/api/query?metric=", "Short-term history of metrics"})
+	}
+	if TelegramToken != "" || DiscordWebhookURL != "" || SlackWebhookURL != "" {
+		endpoints = append(endpoints, LandingEndpoint{"/mute?valoper=&duration=", "Mute the alerts of a validator"})
+	}
+	if DebugQueryInterval > 0 {
+		endpoints = append(endpoints, LandingEndpoint{"/debug/query?collector=", "Raw node responses"})
+	}
+
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return endpoints[i].Path < endpoints[j].Path
+	})
+
+	return endpoints
+}
+
+// LandingHandler serves an index of the endpoints on /, so an exporter can be
+// told apart from others without knowing its paths.
+func LandingHandler(w http.ResponseWriter, r *http.Request, endpoints []LandingEndpoint) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	config := CurrentConfig()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := landingTemplate.Execute(w, map[string]interface{}{
+		"ChainType":   config.Chain.Type,
+		"ConstLabels": config.Metrics.ConstLabels,
+		"Endpoints":   endpoints,
+	}); err != nil {
+		log.Error().Err(err).Msg("Could not render landing page")
+	}
+}
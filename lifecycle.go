@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+)
+
+const (
+	TargetAdded           = "target_added"
+	TargetRemoved         = "target_removed"
+	TargetPreflightFailed = "target_preflight_failed"
+)
+
+// TargetEvent is sent to the lifecycle webhook when the watched validators
+// change, so inventory systems know what is actually monitored.
+type TargetEvent struct {
+	Type   string    `json:"type"`
+	Target string    `json:"target"`
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+type LifecycleWebhook struct {
+	url    string
+	client *http.Client
+	logger zerolog.Logger
+}
+
+func NewLifecycleWebhook(url string) *LifecycleWebhook {
+	return &LifecycleWebhook{
+		url:    url,
+		client: newHTTPClient(10 * time.Second),
+		logger: log.With().Str("component", "lifecycle-webhook").Logger(),
+	}
+}
+
+func (w *LifecycleWebhook) Emit(ctx context.Context, event TargetEvent) {
+	event.Time = time.Now().UTC()
+
+	if err := w.post(ctx, event); err != nil {
+		w.logger.Error().
+			Str("type", event.Type).
+			Str("target", event.Target).
+			Err(err).
+			Msg("Could not send lifecycle event")
+		return
+	}
+
+	w.logger.Debug().
+		Str("type", event.Type).
+		Str("target", event.Target).
+		Msg("Lifecycle event sent")
+}
+
+func (w *LifecycleWebhook) post(ctx context.Context, event TargetEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %s", resp.Status)
+	}
+
+	return nil
+}
+
+// SyncTargets emits the events for going from the previous to the current
+// list of watched validators, running the preflight check on the added ones.
+func (w *LifecycleWebhook) SyncTargets(ctx context.Context, grpcConn *grpc.ClientConn, previous []string, current []string) {
+	known := make(map[string]bool, len(previous))
+	for _, target := range previous {
+		known[target] = true
+	}
+
+	watched := make(map[string]bool, len(current))
+	for _, target := range current {
+		watched[target] = true
+
+		if known[target] {
+			continue
+		}
+
+		if err := PreflightTarget(ctx, grpcConn, target); err != nil {
+			w.Emit(ctx, TargetEvent{Type: TargetPreflightFailed, Target: target, Reason: err.Error()})
+			continue
+		}
+
+		w.Emit(ctx, TargetEvent{Type: TargetAdded, Target: target})
+	}
+
+	for _, target := range previous {
+		if !watched[target] {
+			w.Emit(ctx, TargetEvent{Type: TargetRemoved, Target: target})
+		}
+	}
+}
+
+// PreflightTarget checks the validator address is valid and known to the chain.
+func PreflightTarget(ctx context.Context, grpcConn *grpc.ClientConn, valoper string) error {
+	if _, err := ValAddressFromBech32(valoper); err != nil {
+		return err
+	}
+
+	stakingClient := stakingtypes.NewQueryClient(grpcConn)
+	_, err := stakingClient.Validator(ctx, &stakingtypes.QueryValidatorRequest{ValidatorAddr: valoper})
+	return err
+}
@@ -1,32 +1,193 @@
 package main
 
 import (
-	"crypto/tls"
+	"context"
 	"fmt"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/credentials/insecure"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
-	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
-	"github.com/spf13/viper"
-	"google.golang.org/grpc"
 )
 
 var (
 	ConfigPath string
 
-	ListenAddress string
-	NodeAddress   string
-	BlockTime     uint64
+	ListenAddress      string
+	ChainListenAddress string
+	NodeAddress        string
+	NodeFallbacks      []string
+	IPFamily           string
+	ChainType          string
+	Bech32Prefix       string
+	BlockTime          uint64
 
-	LogLevel string
+	Chain              string
+	ChainRegistryURL   string
+	ChainRegistryCache string
+
+	DNSRefreshInterval time.Duration
+
+	Proxy           string
+	EndpointProxies map[string]string
+
+	EndpointHeaders   map[string]string
+	EndpointBasicAuth map[string]string
+
+	EndpointCheckInterval time.Duration
+	EndpointMaxLag        int64
+
+	CacheTTLs    map[string]string
+	DedupQueries bool
+
+	RetryAttempts   int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	RetryJitter     float64
+	RetryCodes      []string
+	GRPCTimeout     time.Duration
+
+	GRPCKeepaliveTime    time.Duration
+	GRPCKeepaliveTimeout time.Duration
+	GRPCMaxRecvMsgSize   int
+
+	QueryRate          float64
+	QueryBurst         int
+	EndpointQueryRates map[string]string
+
+	ScrapeTimeout       time.Duration
+	ScrapeTimeoutOffset time.Duration
+	RouteTimeouts       map[string]string
+
+	NetworkScan         bool
+	NetworkScanInterval time.Duration
+	NetworkFullRefresh  int
+
+	PriceReferenceProviders []string
+	PriceReferenceTTL       time.Duration
+	PriceReferenceQuorum    int
+	PriceReferenceMaxAge    time.Duration
+	CoinGeckoIDs            map[string]string
+	BinanceQuote            string
+	PythIDs                 map[string]string
+	PriceProviderAPIKeys    map[string]string
+	PriceProviderBudgets    map[string]int64
+	PriceProviderBudgetTime time.Duration
+	PriceProviderCacheTTLs  map[string]string
+
+	ShutdownTimeout time.Duration
+
+	TLSCert string
+	TLSKey  string
+
+	AuthUser     string
+	AuthPassword string
+	AuthToken    string
+
+	LogLevel      string
+	StartupBanner bool
+
+	MetricsSchemas   []string
+	MetricsNamespace string
+	MetricRenames    map[string]string
+
+	DisabledCollectors []string
+	MetricsInclude     []string
+	MetricsExclude     []string
+
+	LabelLowercase    []string
+	LabelStripSymbols bool
+	LabelMaxLength    map[string]int64
 
 	ConstLabels map[string]string
+
+	TelegramToken         string
+	TelegramChatID        string
+	DiscordWebhookURL     string
+	SlackWebhookURL       string
+	AlertRoutes           map[string]string
+	AlertMutes            map[string]string
+	AlertValopers         []string
+	ExpectedFeeders       map[string]string
+	AlertInterval         time.Duration
+	AlertCooldown         time.Duration
+	AlertFeederMinBalance uint64
+	AlertFeederDenom      string
+	IncidentWindow        time.Duration
+
+	LifecycleWebhookURL string
+
+	RecordDir string
+	ReplayDir string
+
+	DebugQueryInterval time.Duration
+	EnablePprof        bool
+	PprofListenAddress string
+
+	TimeSeriesRetention      time.Duration
+	TimeSeriesMetrics        []string
+	TimeSeriesSamplePaths    []string
+	TimeSeriesSampleInterval time.Duration
+
+	TendermintRPC  string
+	BlockValopers  []string
+	BatchRPC       string
+	BatchRPCWindow time.Duration
+	BatchRPCSize   int
+
+	QueryPageSize uint64
+	QueryMaxPages int
+
+	HistoricalQueries bool
+
+	Probe               bool
+	ProbeAllowedTargets []string
+	ProbeIdleTimeout    time.Duration
+
+	PushURL      string
+	PushMode     string
+	PushJob      string
+	PushPaths    []string
+	PushHeaders  map[string]string
+	PushInterval time.Duration
+
+	OTLPEndpoint    string
+	OTLPHeaders     map[string]string
+	OTLPServiceName string
+
+	MockChainEnabled bool
+	MockChainFaults  []string
+
+	Wallets     []string
+	ICQRelayers []string
+
+	StateFile        string
+	StateDB          string
+	SLOTarget        float64
+	SLOWindow        time.Duration
+	HistoryInterval  time.Duration
+	HistoryRetention time.Duration
+	ReportDir        string
+
+	RateHistoryDir       string
+	RateHistoryInterval  time.Duration
+	RateHistoryRetention time.Duration
+	RateHistoryRemote    string
+	RateHistoryHeaders   map[string]string
+
+	DenomDisplay     map[string]string
+	DenomExponent    map[string]int64
+	DenomPrecision   int
+	BalanceDenoms    []string
+	DenomPack        string
+	ExportRawAmounts bool
 )
 
 var log = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout}).With().Timestamp().Logger()
@@ -35,29 +196,19 @@ var rootCmd = &cobra.Command{
 	Use:  "oracle-exporter",
 	Long: "Scrape the data about the validators set, specific validators or wallets in the Cosmos network.",
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		viper.SetConfigFile(ConfigPath)
-		if err := viper.ReadInConfig(); err != nil {
-			if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-				log.Info().Err(err).Msg("Error reading config file")
-				return err
-			}
-		}
-
-		// Credits to https://carolynvanslyck.com/blog/2020/08/sting-of-the-viper/
-		cmd.Flags().VisitAll(func(f *pflag.Flag) {
-			if !f.Changed && viper.IsSet(f.Name) {
-				val := viper.Get(f.Name)
-				if err := cmd.Flags().Set(f.Name, fmt.Sprintf("%v", val)); err != nil {
-					log.Fatal().Err(err).Msg("Could not set flag")
-				}
-			}
-		})
-
-		return nil
+		return LoadConfig(cmd.Flags())
 	},
+	// serves without a subcommand as before serve existed
 	Run: Execute,
 }
 
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the metrics over HTTP",
+	Args:  cobra.NoArgs,
+	Run:   Execute,
+}
+
 func Execute(cmd *cobra.Command, args []string) {
 	logLevel, err := zerolog.ParseLevel(LogLevel)
 	if err != nil {
@@ -66,53 +217,883 @@ func Execute(cmd *cobra.Command, args []string) {
 
 	zerolog.SetGlobalLevel(logLevel)
 
+	if err := SetupExporter(cmd.Flags()); err != nil {
+		log.Fatal().Err(err).Msg("Could not set up exporter")
+	}
+
+	config := CurrentConfig()
+
+	version, commit := BuildVersion()
 	log.Info().
-		Str("--listen-address", ListenAddress).
-		Str("--node", NodeAddress).
-		Uint64("--block-time", BlockTime).
+		Str("version", version).
+		Str("commit", commit).
+		Str("--listen-address", config.Endpoints.Listen).
+		Str("--node", config.Endpoints.Node).
+		Strs("--node-fallbacks", config.Endpoints.NodeFallbacks).
+		Str("--ip-family", config.Endpoints.IPFamily).
+		Str("--chain-type", config.Chain.Type).
+		Str("--bech32-prefix", config.Chain.Bech32Prefix).
+		Str("--chain", Chain).
+		Dur("--dns-refresh-interval", DNSRefreshInterval).
+		Uint64("--block-time", config.Chain.BlockTime).
 		Str("--log-level", LogLevel).
 		Msg("Started with following parameters")
 
-	config := sdk.GetConfig()
-	config.Seal()
+	if RecordDir != "" && ReplayDir != "" {
+		log.Fatal().Msg("--record-dir and --replay-dir can't be used together")
+	} else if RecordDir != "" {
+		upstreamRecording, err = NewRecorder(RecordDir)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Could not start recording")
+		}
 
-	var grpcConn *grpc.ClientConn
+		log.Info().Str("dir", RecordDir).Msg("Recording node responses")
+	} else if ReplayDir != "" {
+		upstreamRecording, err = NewReplayer(ReplayDir)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Could not load recording")
+		}
 
-	if strings.EqualFold(strings.Split(NodeAddress, ":")[1], "443") {
-		creds := credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})
-		grpcConn, err = grpc.Dial(
-			NodeAddress,
-			grpc.WithTransportCredentials(creds),
-		)
-	} else {
-		grpcConn, err = grpc.Dial(
-			NodeAddress,
-			grpc.WithTransportCredentials(insecure.NewCredentials()),
-		)
+		log.Warn().Str("dir", ReplayDir).Msg("Replaying recorded node responses, the node is not queried")
+	}
+
+	if DebugQueryInterval > 0 {
+		if config.Endpoints.AuthUser == "" && config.Endpoints.AuthToken == "" {
+			log.Fatal().Msg("--debug-query-interval needs --auth-user or --auth-token, the raw responses must not be public")
+		}
+
+		debugResponses = NewDebugResponses(DebugQueryInterval)
+	}
+
+	if TimeSeriesRetention > 0 {
+		timeSeriesStore = NewTimeSeriesStore(TimeSeriesMetrics, TimeSeriesRetention)
 	}
 
+	if config.Endpoints.TendermintRPC != "" {
+		tendermintClient = NewTendermintClient(config.Endpoints.TendermintRPC)
+	}
+
+	if config.Endpoints.BatchRPC != "" {
+		queryBatcher = NewQueryBatcher(config.Endpoints.BatchRPC, BatchRPCWindow, config.Queries.BatchSize)
+		log.Info().Str("rpc", config.Endpoints.BatchRPC).Msg("Batching module queries over the Tendermint RPC")
+	}
+
+	if OTLPEndpoint != "" {
+		tracer = NewTracer(OTLPEndpoint, OTLPHeaders, OTLPServiceName, 5*time.Second)
+		go tracer.Start()
+	}
+
+	if MockChainEnabled {
+		mockChain, err := NewMockChain(MockChainFaults)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Could not set up mock chain")
+		}
+
+		address, err := mockChain.Start()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Could not start mock chain")
+		}
+
+		NodeAddress, ChainType = address, ChainTypeUmee
+		if err := refreshConfig(); err != nil {
+			log.Fatal().Err(err).Msg("Could not set up mock chain")
+		}
+		config = CurrentConfig()
+
+		log.Warn().
+			Str("node", address).
+			Strs("faults", MockChainFaults).
+			Msg("Using mock chain, the data is fake")
+	}
+
+	node, err := NewNodeConnection(config.Endpoints.Node, config.Endpoints.NodeFallbacks, config.Endpoints.IPFamily, DNSRefreshInterval)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Could not connect to gRPC node")
 	}
 
-	http.HandleFunc("/metrics/general", func(w http.ResponseWriter, r *http.Request) {
-		GeneralHandler(w, r, grpcConn, BlockTime)
+	if EndpointCheckInterval > 0 {
+		go node.CheckHealth(EndpointCheckInterval, config.Endpoints.MaxLag)
+	}
+
+	routeTimeouts, err := ParseRouteTimeouts(config.Queries.RouteTimeouts)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not parse route timeouts")
+	}
+
+	router := NewRouter(routeTimeouts)
+	router.Use(router.LoggingMiddleware)
+	router.Use(func(next http.Handler) http.Handler {
+		return AuthMiddleware(next, config.Endpoints.AuthUser, config.Endpoints.AuthPassword, config.Endpoints.AuthToken)
 	})
 
-	log.Info().Str("address", ListenAddress).Msg("Listening")
-	err = http.ListenAndServe(ListenAddress, nil)
+	go node.WatchState(time.Minute)
+
+	// the endpoints of the chain are also served under its own path, so scrape
+	// configs organized per network don't depend on the exporter setup
+	registerChainPrefix := func(chainType string) {
+		chainPrefix := "/chains/" + strings.ToLower(chainType)
+		router.Handle(chainPrefix+"/", http.StripPrefix(chainPrefix, router))
+	}
+
+	// the node may be restarting, the exporter starts anyway and the oracle
+	// metrics appear once the chain type is known
+	if strings.EqualFold(config.Chain.Type, ChainTypeAuto) {
+		go func() {
+			chainType := WaitForChainType(node)
+			SetChainType(chainType)
+
+			registerChainPrefix(chainType)
+			log.Info().Str("chain-type", chainType).Msg("Detected chain type")
+		}()
+	} else if _, err := NewOracleProvider(config.Chain.Type, node.Get()); err != nil {
+		log.Fatal().Err(err).Msg("Could not create oracle provider")
+	} else {
+		registerChainPrefix(config.Chain.Type)
+	}
+
+	var alerter *Alerter
+	var notifiers []Notifier
+	if TelegramToken != "" {
+		notifiers = append(notifiers, NewTelegramNotifier(TelegramToken, TelegramChatID))
+	}
+	if DiscordWebhookURL != "" {
+		notifiers = append(notifiers, NewDiscordNotifier(DiscordWebhookURL))
+	}
+	if SlackWebhookURL != "" {
+		notifiers = append(notifiers, NewSlackNotifier(SlackWebhookURL))
+	}
+
+	alertRoutes, err := ParseAlertRoutes(config.Alerts.Routes)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not parse alert routes")
+	}
+
+	alertMutes, err := ParseAlertMutes(config.Alerts.Mutes)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not parse alert mutes")
+	}
+
+	dispatcher := NewAlertDispatcher(notifiers, config.Alerts.Cooldown)
+	dispatcher.SetConfigMutes(alertMutes)
+
+	// without notifiers the alerter only records the history of the validators
+	if len(notifiers) > 0 || config.Endpoints.StateFile != "" {
+		alerter = NewAlerter(
+			node,
+			dispatcher,
+			alertRoutes,
+			config.Validators,
+			config.Alerts.Interval,
+			config.Alerts.FeederMinBalance,
+			config.Alerts.FeederDenom,
+			config.Endpoints.StateFile,
+			HistoryInterval,
+			HistoryRetention,
+			ReportDir,
+			config.Alerts.IncidentWindow,
+		)
+		go alerter.Start()
+	}
+
+	alertRules, err := ParseAlertRules(config.Alerts.Rules)
 	if err != nil {
+		log.Fatal().Err(err).Msg("Could not parse alert rules")
+	}
+
+	var ruleEngine *RuleEngine
+	if len(notifiers) > 0 {
+		ruleEngine = NewRuleEngine(node, dispatcher, alertRules, config.Validators, config.Alerts.Interval)
+		go ruleEngine.Start()
+	}
+
+	if RateHistoryDir != "" || RateHistoryRemote != "" {
+		go NewRateRecorder(node, RateHistoryInterval, RateHistoryDir, RateHistoryRetention, RateHistoryRemote, RateHistoryHeaders).Start()
+	}
+
+	var priceReference *PriceReference
+	if len(config.Prices.Providers) > 0 {
+		providers, err := NewPriceProviders(
+			config.Prices.Providers,
+			config.Prices.CoinGeckoIDs,
+			config.Prices.BinanceQuote,
+			config.Prices.PythIDs,
+			config.Prices.APIKeys,
+			config.Prices.Budgets,
+			config.Prices.BudgetPeriod,
+			config.Prices.CacheTTLs,
+		)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Could not set up price reference")
+		}
+
+		priceReference = NewPriceReference(providers, PriceReferenceTTL, PriceReferenceQuorum, PriceReferenceMaxAge)
+	}
+
+	denoms := NewDenomResolver(config.Denoms.Display, config.Denoms.Exponent, config.Denoms.Precision, config.Denoms.Pack, config.Denoms.Balance)
+	var store *StateStore
+	if config.Endpoints.StateDB != "" {
+		store, err = OpenStateStore(config.Endpoints.StateDB)
+		if err != nil {
+			log.Fatal().Err(err).Str("file", config.Endpoints.StateDB).Msg("Could not open state database")
+		}
+	}
+
+	balances := NewBalanceTracker(store)
+	streaks := NewStreakTracker(store)
+	marketMap := NewMarketMapTracker()
+	slinkyReports := NewSlinkyReportTracker()
+	bandReports := NewBandReportTracker()
+	whitelist := NewWhitelistTracker()
+
+	collectorStatus := NewCollectorStatus(node, StartupBanner)
+	// the collectors turned off with --disable-collectors, again on reload
+	configureFilteredCollectors := func(config ExporterConfig) {
+		filter := CurrentMetricFilter()
+		for name, configured := range map[string]bool{
+			"general":    filter.CollectorEnabled("general"),
+			"validators": filter.CollectorEnabled("validators"),
+			"gov":        filter.CollectorEnabled("gov"),
+			"upgrade":    filter.CollectorEnabled("upgrade"),
+			"marketmap":  filter.CollectorEnabled("marketmap"),
+			"slinky":     filter.CollectorEnabled("slinky"),
+			"band":       filter.CollectorEnabled("band"),
+			"icq":        filter.CollectorEnabled("icq"),
+			"wallet":     filter.CollectorEnabled("wallet"),
+			"slo":        config.Alerts.SLOTarget > 0 && filter.CollectorEnabled("slo"),
+			"network":    NetworkScan && filter.CollectorEnabled("network"),
+		} {
+			collectorStatus.Configure(name, configured)
+		}
+	}
+	configureFilteredCollectors(config)
+	for name, configured := range map[string]bool{
+		"alerting":        alerter != nil && len(notifiers) > 0,
+		"alert-rules":     ruleEngine != nil && len(alertRules) > 0,
+		"rate-history":    RateHistoryDir != "" || RateHistoryRemote != "",
+		"price-reference": priceReference != nil,
+		"debug-query":     debugResponses != nil,
+	} {
+		collectorStatus.Configure(name, configured)
+	}
+	go collectorStatus.Start()
+
+	var lifecycleWebhook *LifecycleWebhook
+	// the targets last synced, the syncs run one at a time and each one
+	// syncs the validators of the config in effect against the previous one
+	var (
+		targets      []string
+		targetsMutex sync.Mutex
+	)
+	syncTargets := func() {
+		targetsMutex.Lock()
+		defer targetsMutex.Unlock()
+
+		previous := targets
+		targets = append([]string{}, CurrentConfig().Validators...)
+		lifecycleWebhook.SyncTargets(context.Background(), node.Get(), previous, targets)
+	}
+	if LifecycleWebhookURL != "" {
+		lifecycleWebhook = NewLifecycleWebhook(LifecycleWebhookURL)
+		go syncTargets()
+	}
+
+	router.Handle("/metrics", promhttp.HandlerFor(SelfGatherer(), promhttp.HandlerOpts{}))
+
+	generalHandler := func(w http.ResponseWriter, r *http.Request) {
+		config := CurrentConfig()
+
+		grpcConn := node.Get()
+		oracle, err := NewOracleProvider(config.Chain.Type, grpcConn)
+		if err != nil {
+			// e.g. the chain type wasn't detected yet
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		GeneralHandler(w, r, grpcConn, oracle, config.Chain.BlockTime, priceReference, denoms, balances, streaks, config.Metrics.ExportRawAmounts, config.Feeders, whitelist)
+	}
+	router.Scrape("/metrics/general", "general", generalHandler)
+	router.ScrapeAddress("/metrics/oracle", "valoper", "general", generalHandler)
+
+	validatorSetHandler := func(w http.ResponseWriter, r *http.Request) {
+		ValidatorSetHandler(w, r, node.Get())
+	}
+	router.Scrape("/metrics/validators", "validators", validatorSetHandler)
+	router.ScrapeAddress("/metrics/validator", "valoper", "validators", validatorSetHandler)
+
+	router.Scrape("/metrics/gov", "gov", func(w http.ResponseWriter, r *http.Request) {
+		GovernanceHandler(w, r, node.Get())
+	})
+
+	router.Scrape("/metrics/upgrade", "upgrade", func(w http.ResponseWriter, r *http.Request) {
+		UpgradeHandler(w, r, node.Get(), CurrentConfig().Chain.BlockTime)
+	})
+
+	router.Scrape("/metrics/marketmap", "marketmap", func(w http.ResponseWriter, r *http.Request) {
+		MarketMapHandler(w, r, node.Get(), marketMap)
+	})
+
+	router.Scrape("/metrics/slinky", "slinky", func(w http.ResponseWriter, r *http.Request) {
+		SlinkyHandler(w, r, node.Get(), slinkyReports)
+	})
+
+	router.Scrape("/metrics/band", "band", func(w http.ResponseWriter, r *http.Request) {
+		BandHandler(w, r, node.Get(), bandReports)
+	})
+
+	router.Scrape("/metrics/icq", "icq", func(w http.ResponseWriter, r *http.Request) {
+		ICQHandler(w, r, node.Get(), denoms, CurrentConfig().ICQRelayers)
+	})
+
+	walletHandler := func(w http.ResponseWriter, r *http.Request) {
+		config := CurrentConfig()
+		WalletHandler(w, r, node.Get(), denoms, config.Wallets, config.Metrics.ExportRawAmounts)
+	}
+	router.Scrape("/metrics/wallet", "wallet", walletHandler)
+	router.ScrapeAddress("/metrics/wallet", "address", "wallet", walletHandler)
+
+	if len(notifiers) > 0 {
+		authenticated := config.Endpoints.AuthUser != "" || config.Endpoints.AuthToken != ""
+		router.HandleFunc("/mute", func(w http.ResponseWriter, r *http.Request) {
+			MuteHandler(w, r, dispatcher, authenticated)
+		})
+	}
+
+	if config.Alerts.SLOTarget > 0 {
+		if config.Alerts.SLOTarget >= 1 || config.Endpoints.StateFile == "" {
+			log.Fatal().Float64("target", config.Alerts.SLOTarget).Msg("--slo-target has to be below 1 and needs --state-file for the history")
+		}
+
+		router.Scrape("/metrics/slo", "slo", func(w http.ResponseWriter, r *http.Request) {
+			config := CurrentConfig()
+			oracle, err := NewOracleProvider(config.Chain.Type, node.Get())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			SLOHandler(w, r, oracle, alerter, config.Alerts.SLOTarget, config.Alerts.SLOWindow)
+		})
+	}
+
+	if len(config.BlockValidators) > 0 {
+		if config.Endpoints.TendermintRPC == "" {
+			log.Fatal().Msg("--block-valopers requires --tendermint-rpc")
+		}
+
+		watcher := NewBlockWatcher(node, config.Endpoints.TendermintRPC, config.BlockValidators)
+		go watcher.Start()
+
+		router.Scrape("/metrics/blocks", "blocks", func(w http.ResponseWriter, r *http.Request) {
+			BlocksHandler(w, r, watcher)
+		})
+	}
+
+	if tendermintClient != nil {
+		router.Scrape("/metrics/node", "node", func(w http.ResponseWriter, r *http.Request) {
+			NodeHealthHandler(w, r, node.Get(), tendermintClient)
+		})
+	}
+
+	if NetworkScan {
+		scanner := NewNetworkScanner(node, NetworkScanInterval, NetworkFullRefresh)
+		go scanner.Start()
+
+		router.Scrape("/metrics/network", "network", func(w http.ResponseWriter, r *http.Request) {
+			NetworkHandler(w, r, scanner)
+		})
+	}
+
+	if TimeSeriesRetention > 0 {
+		router.HandleFunc("/api/query", func(w http.ResponseWriter, r *http.Request) {
+			TimeSeriesHandler(w, r, timeSeriesStore)
+		})
+
+		if len(TimeSeriesSamplePaths) > 0 {
+			go timeSeriesStore.StartSampling(router, TimeSeriesSamplePaths, TimeSeriesSampleInterval)
+		}
+	}
+
+	if Probe {
+		if len(config.Probe.AllowedTargets) == 0 {
+			log.Warn().Msg("No --probe-allowed-targets set, /probe denies every target")
+		}
+
+		probes := NewProbeTargets(config.Endpoints.IPFamily, config.Probe.AllowedTargets, config.Probe.IdleTimeout)
+		go probes.Start()
+
+		router.Scrape("/probe", "probe", func(w http.ResponseWriter, r *http.Request) {
+			ProbeHandler(w, r, probes, CurrentConfig().Feeders)
+		})
+	}
+
+	if config.Push.URL != "" {
+		pusher, err := NewPusher(config.Push.URL, config.Push.Mode, config.Push.Job, config.Push.Paths, config.Push.Headers)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Could not create pusher")
+		}
+
+		go pusher.Start(router, PushInterval)
+	}
+
+	if debugResponses != nil {
+		router.HandleFunc("/debug/query", func(w http.ResponseWriter, r *http.Request) {
+			DebugQueryHandler(w, r, debugResponses)
+		})
+	}
+
+	router.HandleFunc("/healthz", HealthzHandler)
+	router.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ReadyzHandler(w, r, node, denoms)
+	})
+
+	landingEndpoints := LandingEndpoints()
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		LandingHandler(w, r, landingEndpoints)
+	})
+
+	if EnablePprof {
+		server := &http.Server{Addr: PprofListenAddress, Handler: PprofMux()}
+		go func() {
+			log.Info().Str("address", server.Addr).Msg("Serving runtime profiles")
+
+			// the profiles are served without authentication, only on an internal address
+			if err := server.ListenAndServe(); err != nil {
+				log.Error().Err(err).Msg("Could not serve runtime profiles")
+			}
+		}()
+	}
+
+	// a reload writes the flag variables, so from here on they're only read
+	// through CurrentConfig or in the reload itself
+	shutdownTimeout := ShutdownTimeout
+
+	WatchConfig(cmd.Flags(), func() {
+		config := CurrentConfig()
+
+		if logLevel, err := zerolog.ParseLevel(LogLevel); err != nil {
+			log.Error().Err(err).Msg("Could not parse log level")
+		} else {
+			zerolog.SetGlobalLevel(logLevel)
+		}
+
+		if err := queryCache.SetTTLs(config.Queries.CacheTTLs); err != nil {
+			log.Error().Err(err).Msg("Could not update query cache")
+		}
+
+		if err := retryPolicy.Set(config.Queries.RetryAttempts, config.Queries.RetryBackoff, config.Queries.RetryMaxBackoff, config.Queries.RetryJitter, config.Queries.RetryCodes, config.Queries.Timeout); err != nil {
+			log.Error().Err(err).Msg("Could not update gRPC retries")
+		}
+
+		if err := ValidateMetricsSchemas(config.Metrics.Schemas); err != nil {
+			log.Error().Err(err).Msg("Could not update metrics schemas")
+		} else {
+			SetMetricsSchemas(config.Metrics.Schemas)
+		}
+
+		if namer, err := NewMetricNamer(config.Metrics.Namespace, config.Metrics.Renames); err != nil {
+			log.Error().Err(err).Msg("Could not update metric names")
+		} else {
+			SetMetricNamer(namer)
+		}
+
+		SetLabelNormalizer(NewLabelNormalizer(config.Metrics.LabelLowercase, config.Metrics.LabelStripSymbols, config.Metrics.LabelMaxLength))
+
+		if filter, err := NewMetricFilter(config.Metrics.DisabledCollectors, config.Metrics.Include, config.Metrics.Exclude); err != nil {
+			log.Error().Err(err).Msg("Could not update metric filter")
+		} else {
+			SetMetricFilter(filter)
+			configureFilteredCollectors(config)
+		}
+
+		if config.Endpoints.Node != node.NodeAddress() {
+			if err := node.Redial(config.Endpoints.Node, config.Endpoints.IPFamily, DNSRefreshInterval); err != nil {
+				log.Error().Err(err).Str("node", config.Endpoints.Node).Msg("Could not connect to gRPC node")
+			} else {
+				log.Info().Str("node", config.Endpoints.Node).Msg("Switched to new gRPC node")
+			}
+		}
+
+		dispatcher.SetCooldown(config.Alerts.Cooldown)
+		if mutes, err := ParseAlertMutes(config.Alerts.Mutes); err != nil {
+			log.Error().Err(err).Msg("Could not parse alert mutes")
+		} else {
+			dispatcher.SetConfigMutes(mutes)
+		}
+
+		if alerter != nil {
+			if routes, err := ParseAlertRoutes(config.Alerts.Routes); err != nil {
+				log.Error().Err(err).Msg("Could not parse alert routes")
+			} else {
+				alerter.Update(config.Validators, routes, config.Alerts.Interval, config.Alerts.FeederMinBalance, config.Alerts.FeederDenom)
+			}
+		}
+
+		if err := ValidateTargetLabels(config.ValidatorLabels, config.WalletLabels); err != nil {
+			log.Error().Err(err).Msg("Could not parse target labels")
+		} else {
+			targetLabels.Set(config.ValidatorLabels, config.WalletLabels)
+		}
+
+		if ruleEngine != nil {
+			if rules, err := ParseAlertRules(config.Alerts.Rules); err != nil {
+				log.Error().Err(err).Msg("Could not parse alert rules")
+			} else {
+				ruleEngine.Update(rules, config.Validators, config.Alerts.Interval)
+			}
+		}
+
+		if lifecycleWebhook != nil {
+			go syncTargets()
+		}
+	})
+
+	if (config.Endpoints.TLSCert == "") != (config.Endpoints.TLSKey == "") {
+		log.Fatal().Msg("--tls-cert and --tls-key have to be set together")
+	}
+
+	listenAddresses := []string{config.Endpoints.Listen}
+	if config.Endpoints.ChainListen != "" {
+		listenAddresses = append(listenAddresses, config.Endpoints.ChainListen)
+	}
+
+	servers := make([]*http.Server, 0, len(listenAddresses))
+	serverErrors := make(chan error, len(listenAddresses))
+	// the watchdog requests /healthz on the first listener
+	var healthzURL string
+
+	for _, address := range listenAddresses {
+		listener, err := Listen(address, config.Endpoints.IPFamily)
+		if err != nil {
+			log.Fatal().Err(err).Str("address", address).Msg("Could not listen on address")
+		}
+
+		server := &http.Server{
+			Handler: router.Handler(),
+		}
+		servers = append(servers, server)
+		if healthzURL == "" {
+			healthzURL = localURL(listener.Addr().String(), config.Endpoints.TLSCert != "") + "/healthz"
+		}
+
+		go func() {
+			if config.Endpoints.TLSCert != "" {
+				serverErrors <- server.ServeTLS(listener, config.Endpoints.TLSCert, config.Endpoints.TLSKey)
+			} else {
+				serverErrors <- server.Serve(listener)
+			}
+		}()
+
+		log.Info().
+			Str("address", listener.Addr().String()).
+			Str("chain-type", config.Chain.Type).
+			Bool("tls", config.Endpoints.TLSCert != "").
+			Msg("Listening")
+	}
+
+	if err := SdNotify("READY=1"); err != nil {
+		log.Error().Err(err).Msg("Could not notify systemd")
+	}
+
+	if interval := WatchdogInterval(); interval > 0 {
+		// only the exporter's own liveness, a node outage must not restart it
+		go RunWatchdog(interval, func() error {
+			if err := pingHTTP(healthzURL, interval); err != nil {
+				return err
+			}
+			if alerter != nil {
+				return alerter.Ticking()
+			}
+			return nil
+		})
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serverErrors:
 		log.Fatal().Err(err).Msg("Could not start application")
+	case sig := <-signals:
+		log.Info().Str("signal", sig.String()).Msg("Shutting down")
+	}
+
+	SdNotify("STOPPING=1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Error().Err(err).Msg("Could not finish in-flight requests")
+		}
+	}
+
+	if err := node.Close(); err != nil {
+		log.Error().Err(err).Msg("Could not close gRPC connection")
+	}
+
+	if upstreamRecording != nil {
+		if err := upstreamRecording.Close(); err != nil {
+			log.Error().Err(err).Msg("Could not close recording")
+		}
+	}
+
+	if err := store.Close(); err != nil {
+		log.Error().Err(err).Msg("Could not close state database")
+	}
+
+	log.Info().Msg("Stopped")
+}
+
+// SetupExporter applies the flags that shape the connections to the node and
+// the exported metrics, shared by the server and the scrape command.
+func SetupExporter(flags *pflag.FlagSet) error {
+	config := CurrentConfig()
+
+	var err error
+	proxyConfig, err = NewProxyConfig(config.Endpoints.Proxy, config.Endpoints.Proxies)
+	if err != nil {
+		return fmt.Errorf("could not set up proxies: %w", err)
+	}
+
+	endpointAuth, err = NewEndpointAuth(config.Endpoints.Headers, config.Endpoints.BasicAuth)
+	if err != nil {
+		return fmt.Errorf("could not set up endpoint authentication: %w", err)
+	}
+
+	if Chain != "" {
+		if err := ApplyChainRegistry(flags, Chain); err != nil {
+			return fmt.Errorf("could not configure %s from the chain registry: %w", Chain, err)
+		}
+
+		// the registry sets the flags it has values for
+		if err := refreshConfig(); err != nil {
+			return err
+		}
+		config = CurrentConfig()
+	}
+
+	RegisterSelfMetrics()
+
+	if err := ValidateMetricsSchemas(config.Metrics.Schemas); err != nil {
+		return fmt.Errorf("could not set up metrics schemas: %w", err)
+	}
+	SetMetricsSchemas(config.Metrics.Schemas)
+
+	SetLabelNormalizer(NewLabelNormalizer(config.Metrics.LabelLowercase, config.Metrics.LabelStripSymbols, config.Metrics.LabelMaxLength))
+
+	namer, err := NewMetricNamer(config.Metrics.Namespace, config.Metrics.Renames)
+	if err != nil {
+		return fmt.Errorf("could not set up metric names: %w", err)
+	}
+	SetMetricNamer(namer)
+
+	filter, err := NewMetricFilter(config.Metrics.DisabledCollectors, config.Metrics.Include, config.Metrics.Exclude)
+	if err != nil {
+		return fmt.Errorf("could not set up metric filtering: %w", err)
+	}
+	SetMetricFilter(filter)
+
+	if err := ValidateTargetLabels(config.ValidatorLabels, config.WalletLabels); err != nil {
+		return fmt.Errorf("could not parse target labels: %w", err)
 	}
+	targetLabels.Set(config.ValidatorLabels, config.WalletLabels)
+
+	if DedupQueries {
+		queryDeduplicator = NewQueryDeduplicator()
+	}
+
+	queryRateLimiter, err = NewQueryRateLimiter(config.Queries.Rate, config.Queries.Burst, config.Queries.EndpointRates)
+	if err != nil {
+		return fmt.Errorf("could not set up query rate limits: %w", err)
+	}
+
+	if err := queryCache.SetTTLs(config.Queries.CacheTTLs); err != nil {
+		return fmt.Errorf("could not set up query cache: %w", err)
+	}
+
+	if err := retryPolicy.Set(config.Queries.RetryAttempts, config.Queries.RetryBackoff, config.Queries.RetryMaxBackoff, config.Queries.RetryJitter, config.Queries.RetryCodes, config.Queries.Timeout); err != nil {
+		return fmt.Errorf("could not set up gRPC retries: %w", err)
+	}
+
+	return nil
 }
 
 func main() {
+	// the flags of the connections and the exported metrics are shared by the
+	// commands collecting metrics, the others are only used by the server
+	exporterFlags := pflag.NewFlagSet("exporter", pflag.ContinueOnError)
+	serveFlags := pflag.NewFlagSet("serve", pflag.ContinueOnError)
+
 	rootCmd.PersistentFlags().StringVar(&ConfigPath, "config", "", "Config file path")
-	rootCmd.PersistentFlags().Uint64Var(&BlockTime, "block-time", 5, "Block time in seconds")
-	rootCmd.PersistentFlags().StringVar(&ListenAddress, "listen-address", ":9300", "The address this exporter would listen on")
-	rootCmd.PersistentFlags().StringVar(&NodeAddress, "node", "localhost:9090", "RPC node address")
+	exporterFlags.Uint64Var(&BlockTime, "block-time", 5, "Block time in seconds")
+	serveFlags.StringVar(&ListenAddress, "listen-address", ":9300", "The address this exporter would listen on")
+	serveFlags.StringVar(&ChainListenAddress, "chain-listen-address", "", "Additional address to serve the chain on, e.g. a well-known port per network")
+	exporterFlags.StringVar(&NodeAddress, "node", "localhost:9090", "RPC node address")
+	exporterFlags.StringVar(&Proxy, "proxy", "", "Proxy of the outbound connections, e.g. http://proxy:3128 or socks5://proxy:1080, the HTTPS_PROXY and HTTP_PROXY environment variables if empty")
+	StringMapVar(exporterFlags, &EndpointProxies, "endpoint-proxies", map[string]string{}, "Proxies of given hosts or host:port endpoints, direct to bypass the proxy, e.g. grpc.example.com:443=socks5://proxy:1080")
+	StringMapVar(exporterFlags, &EndpointHeaders, "endpoint-headers", map[string]string{}, "Headers sent to given endpoints, e.g. grpc.example.com:443/x-api-key=env:API_KEY, values may be env:NAME or file:PATH")
+	StringMapVar(exporterFlags, &EndpointBasicAuth, "endpoint-basic-auth", map[string]string{}, "Basic auth of given endpoints as user:password, e.g. rpc.example.com=file:/run/secrets/rpc, values may be env:NAME or file:PATH")
+	exporterFlags.StringSliceVar(&NodeFallbacks, "node-fallbacks", []string{}, "gRPC addresses of other nodes of the chain the queries move to when --node lags or fails, set at startup only")
+	serveFlags.DurationVar(&EndpointCheckInterval, "endpoint-check-interval", 30*time.Second, "Interval the latest height and latency of the node and its fallbacks are checked at, 0 to disable")
+	serveFlags.Int64Var(&EndpointMaxLag, "endpoint-max-lag", 3, "Blocks the selected endpoint may lag behind the freshest one before the queries move")
+	exporterFlags.StringVar(&ChainType, "chain-type", ChainTypeAuto, "Oracle module flavour: auto, umee, ojo, terra, kujira, sei or injective")
+	exporterFlags.StringVar(&Bech32Prefix, "bech32-prefix", "", "Account bech32 prefix of the chain, e.g. umee, empty to take the prefix of each address given")
+	exporterFlags.StringVar(&Chain, "chain", "", "Chain name in the Cosmos chain registry, e.g. osmosis, to take the bech32 prefix, endpoints and denoms from")
+	exporterFlags.StringVar(&ChainRegistryURL, "chain-registry-url", DefaultChainRegistryURL, "Base URL of the Cosmos chain registry or a mirror of it")
+	exporterFlags.StringVar(&ChainRegistryCache, "chain-registry-cache", "", "Directory the chain registry files are cached in, empty for the user cache directory")
+	exporterFlags.StringVar(&IPFamily, "ip-family", "any", "IP family to dial and listen on: any (dual-stack), ipv4 or ipv6")
+	exporterFlags.DurationVar(&DNSRefreshInterval, "dns-refresh-interval", 30*time.Second, "How often to re-resolve the node hostname, 0 to resolve only once, not used through a proxy")
+	StringMapVar(exporterFlags, &CacheTTLs, "cache-ttls", map[string]string{
+		"oracle-params":   "5m",
+		"staking-params":  "10m",
+		"slashing-params": "10m",
+		"validator":       "30s",
+	}, "How long responses of rarely changing queries are cached for: oracle-params, staking-params, slashing-params, validator, denom-metadata")
+	exporterFlags.BoolVar(&DedupQueries, "dedup-queries", true, "Send identical queries of concurrent scrapes to the node once")
+	exporterFlags.IntVar(&RetryAttempts, "grpc-retry-attempts", 3, "Attempts of gRPC queries failing with a retryable code, 1 to disable retries")
+	exporterFlags.DurationVar(&RetryBackoff, "grpc-retry-backoff", 200*time.Millisecond, "Backoff before the first retry, doubled on every further retry")
+	exporterFlags.DurationVar(&RetryMaxBackoff, "grpc-retry-max-backoff", 2*time.Second, "Maximum backoff between retries")
+	exporterFlags.Float64Var(&RetryJitter, "grpc-retry-jitter", 0.2, "Random share the backoff is varied by, between 0 and 1")
+	exporterFlags.StringSliceVar(&RetryCodes, "grpc-retry-codes", []string{"Unavailable", "DeadlineExceeded", "ResourceExhausted"}, "gRPC status codes that are retried")
+	exporterFlags.DurationVar(&GRPCTimeout, "grpc-timeout", 5*time.Second, "Timeout of every gRPC query attempt, 0 to disable")
+	exporterFlags.DurationVar(&GRPCKeepaliveTime, "grpc-keepalive-time", 30*time.Second, "Interval of the keepalive pings on the gRPC connection, also while idle, 0 to disable")
+	exporterFlags.DurationVar(&GRPCKeepaliveTimeout, "grpc-keepalive-timeout", 20*time.Second, "Time a keepalive ping waits for its answer before the connection is closed and redialed")
+	exporterFlags.Float64Var(&QueryRate, "query-rate", 0, "Maximum gRPC queries per second to all nodes together, 0 for no limit")
+	exporterFlags.IntVar(&QueryBurst, "query-burst", 20, "gRPC queries sent at once after a quiet period within the rate limits")
+	StringMapVar(exporterFlags, &EndpointQueryRates, "endpoint-query-rates", map[string]string{}, "Maximum gRPC queries per second to given endpoints, e.g. grpc.example.com:443=10")
+	exporterFlags.IntVar(&GRPCMaxRecvMsgSize, "grpc-max-recv-msg-size", 64<<20, "Maximum size in bytes of a gRPC response")
+	exporterFlags.DurationVar(&ScrapeTimeout, "scrape-timeout", 0, "Deadline of a scrape if Prometheus doesn't send a shorter one, 0 to only use the Prometheus one")
+	serveFlags.DurationVar(&ScrapeTimeoutOffset, "scrape-timeout-offset", 500*time.Millisecond, "Time subtracted from the scrape deadline to leave room for writing the response")
+	StringMapVar(serveFlags, &RouteTimeouts, "route-timeouts", map[string]string{}, "Scrape deadline of given routes instead of --scrape-timeout, e.g. network=30s,general=10s")
+	serveFlags.BoolVar(&NetworkScan, "network-scan", false, "Scan oracle data of the whole active set in background and serve it on /metrics/network")
+	serveFlags.DurationVar(&NetworkScanInterval, "network-scan-interval", 5*time.Minute, "Interval the network scan queries are spread over")
+	serveFlags.IntVar(&NetworkFullRefresh, "network-full-refresh", 12, "Refetch validator details on every Nth network scan even if the set didn't change, 0 to disable")
+	serveFlags.StringVar(&LifecycleWebhookURL, "lifecycle-webhook-url", "", "URL to POST events to when watched validators are added, removed or fail the preflight check")
+	exporterFlags.StringSliceVar(&Wallets, "wallets", []string{}, "Wallet addresses served on /metrics/wallet when no ?address= is given")
+	exporterFlags.StringSliceVar(&ICQRelayers, "icq-relayers", []string{}, "Interchain query relayer addresses whose balances are served on /metrics/icq")
+	serveFlags.StringVar(&RecordDir, "record-dir", "", "Directory to record the node responses to")
+	serveFlags.StringVar(&ReplayDir, "replay-dir", "", "Directory to replay recorded node responses from instead of querying the node")
+	serveFlags.BoolVar(&StartupBanner, "startup-banner", true, "Log which collectors are active and why the others aren't once the node was probed")
+	serveFlags.DurationVar(&DebugQueryInterval, "debug-query-interval", 0, "Serve the last raw node responses on /debug/query, at most once per interval, 0 to disable")
+	serveFlags.BoolVar(&EnablePprof, "enable-pprof", false, "Serve runtime profiles on --pprof-listen-address and export the detailed Go runtime metrics")
+	serveFlags.StringVar(&PprofListenAddress, "pprof-listen-address", "127.0.0.1:6060", "Internal address the runtime profiles are served on")
+	serveFlags.DurationVar(&TimeSeriesRetention, "timeseries-retention", 0, "Keep the history of --timeseries-metrics in memory for this long and serve it on /api/query, 0 to disable")
+	serveFlags.StringSliceVar(&TimeSeriesMetrics, "timeseries-metrics", []string{"miss_counter", "miss_rate", "feeder_balance", "validator_missed_blocks", "validator_uptime_percent", "validator_jailed", "oracle_exchange_rate", "oracle_price_deviation_percent"}, "Metrics whose history is kept in memory")
+	serveFlags.StringSliceVar(&TimeSeriesSamplePaths, "timeseries-sample-paths", []string{}, "Metrics paths requested internally to record the history without scrapes, e.g. /metrics/general?valoper=...")
+	serveFlags.DurationVar(&TimeSeriesSampleInterval, "timeseries-sample-interval", time.Minute, "Interval of the internal requests of --timeseries-sample-paths")
+	serveFlags.StringVar(&TendermintRPC, "tendermint-rpc", "", "Tendermint RPC address of the node for /metrics/node, e.g. http://localhost:26657")
+	serveFlags.StringSliceVar(&BlockValopers, "block-valopers", []string{}, "Validators whose votes are checked at every block over a --tendermint-rpc subscription, served on /metrics/blocks")
+	serveFlags.StringVar(&BatchRPC, "batch-rpc", "", "Tendermint RPC address to send the module queries to in batches, e.g. http://localhost:26657")
+	serveFlags.DurationVar(&BatchRPCWindow, "batch-rpc-window", 5*time.Millisecond, "Time queries are collected for before a batch is sent")
+	serveFlags.IntVar(&BatchRPCSize, "batch-rpc-size", 50, "Maximum number of queries per batch")
+	exporterFlags.Uint64Var(&QueryPageSize, "page-size", 100, "Number of entries per page of the queries listing validators, delegations, proposals or interchain queries")
+	exporterFlags.IntVar(&QueryMaxPages, "max-pages", 0, "Maximum number of pages of a listing query, longer listings are truncated and counted in exporter_listings_truncated_total, 0 for no limit")
+	serveFlags.BoolVar(&HistoricalQueries, "historical-queries", false, "Accept ?height= on /metrics/general and /metrics/icq to query state at a past height, requires an archive node")
+	serveFlags.BoolVar(&Probe, "probe", false, "Serve /probe?target=, dialing the given node on demand like the blackbox exporter")
+	serveFlags.StringSliceVar(&ProbeAllowedTargets, "probe-allowed-targets", []string{}, "Patterns of the targets /probe may dial, e.g. *.example.com:9090 or * for any, none if empty")
+	serveFlags.DurationVar(&ProbeIdleTimeout, "probe-idle-timeout", 10*time.Minute, "How long the connection to a probe target is kept after its last probe")
+	serveFlags.StringVar(&PushURL, "push-url", "", "Pushgateway or remote-write URL to push the metrics to, e.g. http://pushgateway:9091")
+	serveFlags.StringVar(&PushMode, "push-mode", PushModePushgateway, "Protocol of --push-url, pushgateway or remote-write")
+	serveFlags.StringVar(&PushJob, "push-job", "oracle-exporter", "job label of the pushed metrics")
+	serveFlags.StringSliceVar(&PushPaths, "push-paths", []string{"/metrics"}, "Metrics paths to push, e.g. /metrics/general?valoper=...")
+	StringMapVar(serveFlags, &PushHeaders, "push-headers", map[string]string{}, "Headers sent with the pushes, e.g. for authentication")
+	serveFlags.DurationVar(&PushInterval, "push-interval", 30*time.Second, "Interval of the pushes")
+	serveFlags.StringVar(&OTLPEndpoint, "otlp-endpoint", "", "OTLP/HTTP endpoint to send scrape traces to, e.g. http://localhost:4318")
+	StringMapVar(serveFlags, &OTLPHeaders, "otlp-headers", map[string]string{}, "Headers sent with the traces, e.g. for authentication")
+	serveFlags.StringVar(&OTLPServiceName, "otlp-service-name", "oracle-exporter", "service.name of the exported traces")
+	serveFlags.BoolVar(&MockChainEnabled, "mock-chain", false, "Serve fake deterministic chain data instead of connecting to --node, for testing")
+	serveFlags.StringSliceVar(&MockChainFaults, "mock-chain-faults", []string{}, "Faults of the first mock validator: misses, jail, lag")
+	serveFlags.StringVar(&StateFile, "state-file", "", "File to persist the exporter state to between restarts")
+	serveFlags.StringVar(&StateDB, "state-db", "", "bbolt file to persist the miss counters, streaks and feeder balances seen by the scrapes, empty to keep them in memory")
+	serveFlags.DurationVar(&HistoryInterval, "history-interval", time.Hour, "How often the state of the alert validators is recorded to the state file history")
+	serveFlags.DurationVar(&HistoryRetention, "history-retention", 90*24*time.Hour, "How long the history is kept for")
+	serveFlags.Float64Var(&SLOTarget, "slo-target", 0, "Oracle participation objective served on /metrics/slo, e.g. 0.995, 0 to disable")
+	serveFlags.DurationVar(&SLOWindow, "slo-window", 30*24*time.Hour, "Window the oracle participation objective is evaluated over")
+	serveFlags.StringVar(&ReportDir, "report-dir", "", "Directory to render the monthly HTML report to when a month is over")
+	serveFlags.StringVar(&RateHistoryDir, "rate-history-dir", "", "Directory to record the on-chain exchange rates to as daily CSV files")
+	serveFlags.DurationVar(&RateHistoryInterval, "rate-history-interval", 5*time.Minute, "Interval the exchange rates are recorded at")
+	serveFlags.DurationVar(&RateHistoryRetention, "rate-history-retention", 0, "How long the recorded exchange rates are kept for, 0 to keep them forever")
+	serveFlags.StringVar(&RateHistoryRemote, "rate-history-remote-write", "", "Prometheus remote-write URL of a long-term TSDB to record the on-chain exchange rates to, e.g. http://mimir:9009/api/v1/push")
+	StringMapVar(serveFlags, &RateHistoryHeaders, "rate-history-remote-write-headers", map[string]string{}, "Headers sent with the remote writes of the exchange rates, e.g. for authentication")
+	StringMapVar(exporterFlags, &DenomDisplay, "denom-display", map[string]string{}, "Display denom overrides for chains with wrong metadata, e.g. uumee=umee")
+	Int64MapVar(exporterFlags, &DenomExponent, "denom-exponent", map[string]int64{}, "Denom exponent overrides for chains with wrong metadata, e.g. uumee=6")
+	exporterFlags.StringVar(&DenomPack, "denom-pack", DenomPackAuto, "Chain-id of the built-in denom pack for denoms without bank metadata, auto to use the node's, none to disable")
+	exporterFlags.IntVar(&DenomPrecision, "denom-precision", -1, "Decimals to round converted amounts to, -1 to disable rounding")
+	exporterFlags.StringSliceVar(&BalanceDenoms, "balance-denoms", []string{}, "Base or display denoms to export balances and rewards for, all denoms if empty")
+	exporterFlags.BoolVar(&ExportRawAmounts, "export-raw-amounts", false, "Also export amounts in base denom to avoid float precision loss")
+	exporterFlags.StringSliceVar(&PriceReferenceProviders, "price-reference-providers", []string{}, "External price providers to compare oracle rates with, in order of preference: coingecko, binance, pyth")
+	exporterFlags.DurationVar(&PriceReferenceTTL, "price-reference-ttl", time.Minute, "How long external prices are cached for")
+	exporterFlags.IntVar(&PriceReferenceQuorum, "price-reference-quorum", 1, "Number of providers whose median price is used, 1 to use the first provider that knows the symbol")
+	exporterFlags.DurationVar(&PriceReferenceMaxAge, "price-reference-max-age", 10*time.Minute, "How long prices of a failing provider are still used for")
+	StringMapVar(exporterFlags, &CoinGeckoIDs, "coingecko-ids", map[string]string{}, "Oracle symbol to CoinGecko coin id mapping, e.g. ATOM=cosmos,UMEE=umee")
+	exporterFlags.StringVar(&BinanceQuote, "binance-quote", "USDT", "Binance quote asset the oracle symbols are paired with")
+	StringMapVar(exporterFlags, &PythIDs, "pyth-ids", map[string]string{}, "Oracle symbol to Pyth price feed id mapping, e.g. ATOM=b00b60f8...")
+	StringMapVar(exporterFlags, &PriceProviderAPIKeys, "price-provider-api-keys", map[string]string{}, "API keys of the price providers, e.g. coingecko=CG-...")
+	Int64MapVar(exporterFlags, &PriceProviderBudgets, "price-provider-budgets", map[string]int64{}, "Maximum number of requests per budget period of the price providers, e.g. coingecko=300")
+	exporterFlags.DurationVar(&PriceProviderBudgetTime, "price-provider-budget-period", 24*time.Hour, "Period the price provider budgets are reset after")
+	StringMapVar(exporterFlags, &PriceProviderCacheTTLs, "price-provider-cache-ttls", map[string]string{}, "How long responses of the price providers are cached for, e.g. coingecko=5m")
+	serveFlags.StringVar(&TLSCert, "tls-cert", "", "TLS certificate file to serve HTTPS with")
+	serveFlags.StringVar(&TLSKey, "tls-key", "", "TLS private key file to serve HTTPS with")
+	serveFlags.StringVar(&AuthUser, "auth-user", "", "Require basic auth with this user on the HTTP listener")
+	serveFlags.StringVar(&AuthPassword, "auth-password", "", "Password of the basic auth user")
+	serveFlags.StringVar(&AuthToken, "auth-token", "", "Require this bearer token on the HTTP listener, alternatively to basic auth")
+	serveFlags.DurationVar(&ShutdownTimeout, "shutdown-timeout", 30*time.Second, "How long to wait for in-flight requests on shutdown")
+	exporterFlags.StringVar(&MetricsNamespace, "metrics-namespace", "cosmos", "Namespace put in front of the names of the exported metrics, e.g. cosmos for cosmos_miss_counter, empty for none")
+	StringMapVar(exporterFlags, &MetricRenames, "metric-renames", map[string]string{}, "Exported names of metrics for legacy dashboards, e.g. miss_counter=umee_miss_counter, not namespaced")
+	exporterFlags.StringSliceVar(&DisabledCollectors, "disable-collectors", []string{}, "Collectors to turn off, their endpoints answer 404, e.g. gov,network")
+	exporterFlags.StringSliceVar(&MetricsInclude, "metrics-include", []string{}, "Glob patterns of the metrics to export, all of them if empty")
+	exporterFlags.StringSliceVar(&MetricsExclude, "metrics-exclude", []string{}, "Glob patterns of the metrics not to export, e.g. validator_delegators to skip counting the delegators")
+	exporterFlags.StringSliceVar(&MetricsSchemas, "metrics-schemas", []string{MetricsSchemaV1}, "Metrics schema versions to emit, both 1 and 2 during a transition")
+	exporterFlags.StringSliceVar(&LabelLowercase, "label-lowercase", []string{}, "Labels whose values are lowercased, e.g. moniker,denom")
+	exporterFlags.BoolVar(&LabelStripSymbols, "label-strip-symbols", false, "Strip emoji, symbols and control characters from label values")
+	Int64MapVar(exporterFlags, &LabelMaxLength, "label-max-length", map[string]int64{}, "Maximum length of label values by label, e.g. denom=24")
 	rootCmd.PersistentFlags().StringVar(&LogLevel, "log-level", "info", "Logging level")
 
+	serveFlags.StringVar(&TelegramToken, "telegram-token", "", "Telegram bot token to send alerts with")
+	serveFlags.StringVar(&TelegramChatID, "telegram-chat-id", "", "Telegram chat id to send alerts to")
+	serveFlags.StringVar(&DiscordWebhookURL, "discord-webhook-url", "", "Discord webhook URL to send alerts to")
+	serveFlags.StringVar(&SlackWebhookURL, "slack-webhook-url", "", "Slack incoming webhook URL to send alerts to")
+	StringMapVar(serveFlags, &AlertRoutes, "alert-routes", map[string]string{}, "Notifiers of every alert rule joined with +, e.g. ValidatorJailed=telegram+slack, all notifiers by default")
+	StringMapVar(serveFlags, &AlertMutes, "alert-mutes", map[string]string{}, "Validators, or chain for all of them, to send no alerts for until the given RFC 3339 time, e.g. umeevaloper1...=2024-06-01T18:00:00Z")
+	exporterFlags.StringSliceVar(&AlertValopers, "alert-valopers", []string{}, "Validator addresses to send alerts for")
+	StringMapVar(exporterFlags, &ExpectedFeeders, "expected-feeders", map[string]string{}, "Expected feeder address per validator, e.g. umeevaloper1...=umee1..., exported as feeder_mismatch")
+	serveFlags.DurationVar(&AlertInterval, "alert-interval", time.Minute, "Interval between alert checks")
+	serveFlags.DurationVar(&AlertCooldown, "alert-cooldown", 30*time.Minute, "Minimum time before an alert that resolved is notified again for the same validator")
+	serveFlags.Uint64Var(&AlertFeederMinBalance, "alert-feeder-min-balance", 0, "Alert if feeder balance is below this amount in base denom, 0 to disable")
+	serveFlags.StringVar(&AlertFeederDenom, "alert-feeder-denom", "uumee", "Denom of the feeder balance")
+	serveFlags.DurationVar(&IncidentWindow, "incident-window", 0, "Correlate anomaly signals firing within this window into a single incident alert, 0 to disable")
+
+	StringMapVar(exporterFlags, &ConstLabels, "const-labels", map[string]string{}, "Labels added to every exported series, e.g. env=mainnet")
+
+	RegisterFlagAliases(exporterFlags)
+	RegisterFlagAliases(serveFlags)
+
+	// the root command still serves as before serve existed
+	for _, cmd := range []*cobra.Command{rootCmd, serveCmd, validateConfigCmd} {
+		cmd.Flags().AddFlagSet(exporterFlags)
+		cmd.Flags().AddFlagSet(serveFlags)
+	}
+	scrapeCmd.Flags().AddFlagSet(exporterFlags)
+	checkCmd.Flags().AddFlagSet(exporterFlags)
+	addFlags(checkCmd.Flags(), serveFlags, "listen-address", "tls-cert")
+	addFlags(stateCmd.PersistentFlags(), serveFlags, "state-file", "state-db")
+	addFlags(reportCmd.Flags(), serveFlags, "state-file")
+	addFlags(dashboardCmd.Flags(), exporterFlags, "metrics-namespace", "metric-renames", "alert-valopers", "const-labels")
+	addFlags(rulesCmd.Flags(), exporterFlags, "metrics-namespace", "metric-renames", "alert-valopers", "export-raw-amounts")
+	addFlags(rulesCmd.Flags(), serveFlags, "alert-feeder-min-balance", "alert-feeder-denom", "denom")
+
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(stateCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(validateConfigCmd)
+	rootCmd.AddCommand(dashboardCmd)
+	rootCmd.AddCommand(rulesCmd)
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(scrapeCmd)
+	rootCmd.AddCommand(checkCmd)
+
+	RegisterCompletions(rootCmd)
+
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal().Err(err).Msg("Could not start application")
 	}
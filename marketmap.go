@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+)
+
+const slinkyMarketMapService = "/slinky.marketmap.v1.Query/"
+
+const (
+	MarketAdded    = "added"
+	MarketRemoved  = "removed"
+	MarketEnabled  = "enabled"
+	MarketDisabled = "disabled"
+)
+
+// Hand-written messages of the Slinky x/marketmap module, only the fields
+// the exporter reads are declared.
+
+type slinkyCurrencyPair struct {
+	Base  string `protobuf:"bytes,1,opt,name=Base,proto3"`
+	Quote string `protobuf:"bytes,2,opt,name=Quote,proto3"`
+}
+
+func (m *slinkyCurrencyPair) Reset()         { *m = slinkyCurrencyPair{} }
+func (m *slinkyCurrencyPair) String() string { return fmt.Sprintf("%+v", *m) }
+func (*slinkyCurrencyPair) ProtoMessage()    {}
+
+type slinkyTicker struct {
+	CurrencyPair     *slinkyCurrencyPair `protobuf:"bytes,1,opt,name=currency_pair,json=currencyPair,proto3"`
+	Decimals         uint64              `protobuf:"varint,2,opt,name=decimals,proto3"`
+	MinProviderCount uint64              `protobuf:"varint,3,opt,name=min_provider_count,json=minProviderCount,proto3"`
+	Enabled          bool                `protobuf:"varint,14,opt,name=enabled,proto3"`
+}
+
+func (m *slinkyTicker) Reset()         { *m = slinkyTicker{} }
+func (m *slinkyTicker) String() string { return fmt.Sprintf("%+v", *m) }
+func (*slinkyTicker) ProtoMessage()    {}
+
+type slinkyMarket struct {
+	Ticker *slinkyTicker `protobuf:"bytes,1,opt,name=ticker,proto3"`
+}
+
+func (m *slinkyMarket) Reset()         { *m = slinkyMarket{} }
+func (m *slinkyMarket) String() string { return fmt.Sprintf("%+v", *m) }
+func (*slinkyMarket) ProtoMessage()    {}
+
+type slinkyMarketMap struct {
+	Markets map[string]*slinkyMarket `protobuf:"bytes,1,rep,name=markets,proto3" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *slinkyMarketMap) Reset()         { *m = slinkyMarketMap{} }
+func (m *slinkyMarketMap) String() string { return fmt.Sprintf("%+v", *m) }
+func (*slinkyMarketMap) ProtoMessage()    {}
+
+type slinkyMarketMapResponse struct {
+	MarketMap   *slinkyMarketMap `protobuf:"bytes,1,opt,name=market_map,json=marketMap,proto3"`
+	LastUpdated uint64           `protobuf:"varint,2,opt,name=last_updated,json=lastUpdated,proto3"`
+}
+
+func (m *slinkyMarketMapResponse) Reset()         { *m = slinkyMarketMapResponse{} }
+func (m *slinkyMarketMapResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*slinkyMarketMapResponse) ProtoMessage()    {}
+
+// MarketChange is a market that appeared, disappeared or was toggled since
+// the previous scrape.
+type MarketChange struct {
+	Ticker string
+	Change string
+}
+
+// MarketMapTracker remembers the markets between scrapes, market map updates
+// change what the validators have to price from one block to the next.
+type MarketMapTracker struct {
+	mutex   sync.Mutex
+	markets map[string]bool
+	changes map[string]float64
+}
+
+func NewMarketMapTracker() *MarketMapTracker {
+	return &MarketMapTracker{
+		changes: make(map[string]float64),
+	}
+}
+
+// Observe records the markets, ticker -> enabled, and returns the changes
+// since the previous call. The first call only records the markets.
+func (t *MarketMapTracker) Observe(markets map[string]bool) []MarketChange {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	previous := t.markets
+	t.markets = markets
+	if previous == nil {
+		return nil
+	}
+
+	var changes []MarketChange
+	for ticker, enabled := range markets {
+		wasEnabled, known := previous[ticker]
+		switch {
+		case !known:
+			changes = append(changes, MarketChange{Ticker: ticker, Change: MarketAdded})
+		case enabled && !wasEnabled:
+			changes = append(changes, MarketChange{Ticker: ticker, Change: MarketEnabled})
+		case !enabled && wasEnabled:
+			changes = append(changes, MarketChange{Ticker: ticker, Change: MarketDisabled})
+		}
+	}
+
+	for ticker := range previous {
+		if _, ok := markets[ticker]; !ok {
+			changes = append(changes, MarketChange{Ticker: ticker, Change: MarketRemoved})
+		}
+	}
+
+	for _, change := range changes {
+		t.changes[change.Change]++
+	}
+
+	return changes
+}
+
+// Changes returns the cumulative number of changes by kind.
+func (t *MarketMapTracker) Changes() map[string]float64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	changes := make(map[string]float64, 4)
+	for _, change := range []string{MarketAdded, MarketRemoved, MarketEnabled, MarketDisabled} {
+		changes[change] = t.changes[change]
+	}
+
+	return changes
+}
+
+// MarketMapCollector exports the markets of the Slinky market map and counts
+// the markets added, removed, enabled and disabled while the exporter runs.
+type MarketMapCollector struct {
+	uncheckedCollector
+
+	ctx      context.Context
+	grpcConn *grpc.ClientConn
+	tracker  *MarketMapTracker
+	logger   zerolog.Logger
+}
+
+func NewMarketMapCollector(
+	ctx context.Context,
+	grpcConn *grpc.ClientConn,
+	tracker *MarketMapTracker,
+	logger zerolog.Logger,
+) *MarketMapCollector {
+	return &MarketMapCollector{
+		ctx:      ctx,
+		grpcConn: grpcConn,
+		tracker:  tracker,
+		logger:   logger,
+	}
+}
+
+func (c *MarketMapCollector) Collect(ch chan<- prometheus.Metric) {
+	marketMapMarketsGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "marketmap_markets",
+			Help:        "Number of markets in the market map",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	marketMapMarketEnabledGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "marketmap_market_enabled",
+			Help:        "Whether a given market is enabled and has to be priced",
+			ConstLabels: constLabels(),
+		},
+		[]string{"ticker"},
+	)
+
+	marketMapMinProviderCountGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "marketmap_market_min_provider_count",
+			Help:        "Minimum number of providers of a given market",
+			ConstLabels: constLabels(),
+		},
+		[]string{"ticker"},
+	)
+
+	marketMapLastUpdatedGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "marketmap_last_updated_height",
+			Help:        "Height the market map was last updated at",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	marketMapChangesCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "marketmap_market_changes_total",
+			Help:        "Markets added, removed, enabled or disabled since the exporter started",
+			ConstLabels: constLabels(),
+		},
+		[]string{"change"},
+	)
+
+	metrics := []prometheus.Collector{
+		marketMapMarketsGauge,
+		marketMapMarketEnabledGauge,
+		marketMapMinProviderCountGauge,
+		marketMapLastUpdatedGauge,
+		marketMapChangesCounter,
+	}
+
+	c.logger.Debug().Msg("Started querying market map")
+	queryStart := time.Now()
+
+	response := &slinkyMarketMapResponse{}
+	if err := c.grpcConn.Invoke(c.ctx, slinkyMarketMapService+"MarketMap", &emptyRequest{}, response); err != nil {
+		c.logger.Error().Err(err).Msg("Could not get market map")
+		return
+	}
+
+	c.logger.Debug().
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying market map")
+
+	markets := make(map[string]bool)
+	if response.MarketMap != nil {
+		for ticker, market := range response.MarketMap.Markets {
+			if market == nil || market.Ticker == nil {
+				continue
+			}
+
+			markets[ticker] = market.Ticker.Enabled
+
+			enabled := 0.0
+			if market.Ticker.Enabled {
+				enabled = 1
+			}
+
+			labels := prometheus.Labels{"ticker": ticker}
+			marketMapMarketEnabledGauge.With(labels).Set(enabled)
+			marketMapMinProviderCountGauge.With(labels).Set(float64(market.Ticker.MinProviderCount))
+		}
+	}
+
+	marketMapMarketsGauge.Set(float64(len(markets)))
+	marketMapLastUpdatedGauge.Set(float64(response.LastUpdated))
+
+	for _, change := range c.tracker.Observe(markets) {
+		c.logger.Info().
+			Str("ticker", change.Ticker).
+			Str("change", change.Change).
+			Msg("Market map changed")
+	}
+
+	for change, count := range c.tracker.Changes() {
+		marketMapChangesCounter.With(prometheus.Labels{"change": change}).Add(count)
+	}
+
+	collectMetrics(ch, metrics)
+}
+
+// MarketMapHandler serves the markets of the market map, see MarketMapCollector.
+func MarketMapHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn, tracker *MarketMapTracker) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	registry := collectorRegistry(NewMarketMapCollector(r.Context(), grpcConn, tracker, sublogger))
+
+	h := promhttp.HandlerFor(ExportGatherer(registry), promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/marketmap").
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	oracletypes "github.com/umee-network/umee/v6/x/oracle/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	MockFaultMisses = "misses"
+	MockFaultJail   = "jail"
+	MockFaultLag    = "lag"
+
+	mockBlockTime   = 5 * time.Second
+	mockVotePeriod  = 5
+	mockSlashWindow = 100800
+	mockValidators  = 3
+	mockDenom       = "uumee"
+)
+
+var mockSymbols = []string{"UMEE", "ATOM", "USDC"}
+
+var mockAddresses = NewAddressCodec("umee")
+
+// MockChain is a fake Umee node with deterministic data derived from the
+// block height, for testing dashboards, alert rules and notifiers without
+// a live network. Faults are applied to the first mock validator only.
+type MockChain struct {
+	faults map[string]bool
+	start  time.Time
+}
+
+func NewMockChain(faults []string) (*MockChain, error) {
+	enabled := make(map[string]bool, len(faults))
+	for _, fault := range faults {
+		switch fault {
+		case MockFaultMisses, MockFaultJail, MockFaultLag:
+			enabled[fault] = true
+		default:
+			return nil, fmt.Errorf("unsupported mock fault %q, expected misses, jail or lag", fault)
+		}
+	}
+
+	return &MockChain{faults: enabled, start: time.Now()}, nil
+}
+
+// Start serves the mock chain on a random local port and returns its address.
+func (m *MockChain) Start() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+
+	server := grpc.NewServer(grpc.UnaryInterceptor(m.lag))
+	tmservice.RegisterServiceServer(server, &mockTendermintServer{chain: m})
+	stakingtypes.RegisterQueryServer(server, &mockStakingServer{chain: m})
+	slashingtypes.RegisterQueryServer(server, &mockSlashingServer{chain: m})
+	banktypes.RegisterQueryServer(server, &mockBankServer{chain: m})
+	oracletypes.RegisterQueryServer(server, &mockOracleServer{chain: m})
+
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Error().Err(err).Msg("Mock chain stopped")
+		}
+	}()
+
+	for index := 0; index < mockValidators; index++ {
+		log.Info().
+			Str("valoper", mockValoper(index)).
+			Str("feeder", mockFeeder(index)).
+			Bool("faulty", index == 0 && len(m.faults) > 0).
+			Msg("Mock validator")
+	}
+
+	return listener.Addr().String(), nil
+}
+
+// lag slows every query down like an overloaded node would.
+func (m *MockChain) lag(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if m.faults[MockFaultLag] {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return handler(ctx, req)
+}
+
+func (m *MockChain) height() int64 {
+	height := int64(time.Since(m.start)/mockBlockTime) + 1
+	if m.faults[MockFaultLag] {
+		// the node falls behind, the head only moves every 10 blocks
+		height -= height % 10
+	}
+
+	return height
+}
+
+func (m *MockChain) votePeriods() uint64 {
+	return uint64(m.height()) / mockVotePeriod
+}
+
+func mockKey(index int) *ed25519.PrivKey {
+	return ed25519.GenPrivKeyFromSecret([]byte(fmt.Sprintf("mock-validator-%d", index)))
+}
+
+func mockValoper(index int) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("mock-operator-%d", index)))
+	return mockAddresses.ValAddress(hash[:20])
+}
+
+func mockFeeder(index int) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("mock-feeder-%d", index)))
+	return mockAddresses.AccAddress(hash[:20])
+}
+
+func (m *MockChain) validatorIndex(valoper string) (int, error) {
+	for index := 0; index < mockValidators; index++ {
+		if mockValoper(index) == valoper {
+			return index, nil
+		}
+	}
+
+	return 0, status.Errorf(codes.NotFound, "validator %s not found", valoper)
+}
+
+func (m *MockChain) faulty(index int, fault string) bool {
+	return index == 0 && m.faults[fault]
+}
+
+func (m *MockChain) validator(index int) (stakingtypes.Validator, error) {
+	pubkey, err := codectypes.NewAnyWithValue(mockKey(index).PubKey())
+	if err != nil {
+		return stakingtypes.Validator{}, err
+	}
+
+	return stakingtypes.Validator{
+		OperatorAddress: mockValoper(index),
+		ConsensusPubkey: pubkey,
+		Jailed:          m.faulty(index, MockFaultJail),
+		Status:          stakingtypes.Bonded,
+		Tokens:          sdk.NewInt(int64(1000000000000 * (index + 1))),
+		DelegatorShares: sdk.NewDec(int64(1000000000000 * (index + 1))),
+		Description:     stakingtypes.Description{Moniker: fmt.Sprintf("mock-%d", index)},
+	}, nil
+}
+
+func (m *MockChain) missCounter(index int) uint64 {
+	periods := m.votePeriods() % (mockSlashWindow / mockVotePeriod)
+	if m.faulty(index, MockFaultMisses) {
+		// every third vote period is missed
+		return periods / 3
+	}
+
+	return periods / 500
+}
+
+func (m *MockChain) exchangeRate(symbol string) sdk.Dec {
+	base := map[string]int64{"UMEE": 3, "ATOM": 9000, "USDC": 1000}[symbol]
+	// a slow deterministic drift of up to 1% around the base price
+	drift := int64(m.votePeriods()%200) - 100
+	return sdk.NewDecWithPrec(base*10000+base*drift, 7)
+}
+
+type mockTendermintServer struct {
+	tmservice.UnimplementedServiceServer
+	chain *MockChain
+}
+
+func (s *mockTendermintServer) GetLatestBlock(ctx context.Context, req *tmservice.GetLatestBlockRequest) (*tmservice.GetLatestBlockResponse, error) {
+	height := s.chain.height()
+	return &tmservice.GetLatestBlockResponse{
+		Block: &tmproto.Block{
+			Header: tmproto.Header{
+				ChainID: "mock-1",
+				Height:  height,
+				Time:    s.chain.start.Add(time.Duration(height) * mockBlockTime),
+			},
+		},
+	}, nil
+}
+
+type mockStakingServer struct {
+	stakingtypes.UnimplementedQueryServer
+	chain *MockChain
+}
+
+func (s *mockStakingServer) Params(ctx context.Context, req *stakingtypes.QueryParamsRequest) (*stakingtypes.QueryParamsResponse, error) {
+	return &stakingtypes.QueryParamsResponse{
+		Params: stakingtypes.Params{BondDenom: mockDenom, MaxValidators: 100},
+	}, nil
+}
+
+func (s *mockStakingServer) Validator(ctx context.Context, req *stakingtypes.QueryValidatorRequest) (*stakingtypes.QueryValidatorResponse, error) {
+	index, err := s.chain.validatorIndex(req.ValidatorAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	validator, err := s.chain.validator(index)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stakingtypes.QueryValidatorResponse{Validator: validator}, nil
+}
+
+func (s *mockStakingServer) Validators(ctx context.Context, req *stakingtypes.QueryValidatorsRequest) (*stakingtypes.QueryValidatorsResponse, error) {
+	validators := make([]stakingtypes.Validator, 0, mockValidators)
+	for index := 0; index < mockValidators; index++ {
+		validator, err := s.chain.validator(index)
+		if err != nil {
+			return nil, err
+		}
+
+		validators = append(validators, validator)
+	}
+
+	return &stakingtypes.QueryValidatorsResponse{Validators: validators}, nil
+}
+
+type mockSlashingServer struct {
+	slashingtypes.UnimplementedQueryServer
+	chain *MockChain
+}
+
+func (s *mockSlashingServer) Params(ctx context.Context, req *slashingtypes.QueryParamsRequest) (*slashingtypes.QueryParamsResponse, error) {
+	return &slashingtypes.QueryParamsResponse{
+		Params: slashingtypes.Params{
+			SignedBlocksWindow:      10000,
+			MinSignedPerWindow:      sdk.NewDecWithPrec(5, 2),
+			DowntimeJailDuration:    10 * time.Minute,
+			SlashFractionDoubleSign: sdk.NewDecWithPrec(5, 2),
+			SlashFractionDowntime:   sdk.NewDecWithPrec(1, 4),
+		},
+	}, nil
+}
+
+func (s *mockSlashingServer) SigningInfo(ctx context.Context, req *slashingtypes.QuerySigningInfoRequest) (*slashingtypes.QuerySigningInfoResponse, error) {
+	for index := 0; index < mockValidators; index++ {
+		consAddress := sdk.ConsAddress(mockKey(index).PubKey().Address())
+		if mockAddresses.ConsAddress(consAddress) != req.ConsAddress {
+			continue
+		}
+
+		var missed int64
+		if s.chain.faulty(index, MockFaultJail) {
+			missed = 9500
+		}
+
+		return &slashingtypes.QuerySigningInfoResponse{
+			ValSigningInfo: slashingtypes.ValidatorSigningInfo{
+				Address:             req.ConsAddress,
+				IndexOffset:         s.chain.height(),
+				MissedBlocksCounter: missed,
+			},
+		}, nil
+	}
+
+	return nil, status.Errorf(codes.NotFound, "signing info for %s not found", req.ConsAddress)
+}
+
+type mockBankServer struct {
+	banktypes.UnimplementedQueryServer
+	chain *MockChain
+}
+
+// feederBalance decreases with every vote, as feeders pay fees.
+func (s *mockBankServer) feederBalance(address string) sdk.Coin {
+	for index := 0; index < mockValidators; index++ {
+		if mockFeeder(index) == address {
+			spent := int64(s.chain.votePeriods()) * 2000
+			return sdk.NewCoin(mockDenom, sdk.NewInt(10000000000-spent%10000000000))
+		}
+	}
+
+	return sdk.NewCoin(mockDenom, sdk.ZeroInt())
+}
+
+func (s *mockBankServer) Balance(ctx context.Context, req *banktypes.QueryBalanceRequest) (*banktypes.QueryBalanceResponse, error) {
+	balance := s.feederBalance(req.Address)
+	if req.Denom != mockDenom {
+		balance = sdk.NewCoin(req.Denom, sdk.ZeroInt())
+	}
+
+	return &banktypes.QueryBalanceResponse{Balance: &balance}, nil
+}
+
+func (s *mockBankServer) AllBalances(ctx context.Context, req *banktypes.QueryAllBalancesRequest) (*banktypes.QueryAllBalancesResponse, error) {
+	balance := s.feederBalance(req.Address)
+	return &banktypes.QueryAllBalancesResponse{Balances: sdk.NewCoins(balance)}, nil
+}
+
+func (s *mockBankServer) DenomMetadata(ctx context.Context, req *banktypes.QueryDenomMetadataRequest) (*banktypes.QueryDenomMetadataResponse, error) {
+	if req.Denom != mockDenom {
+		return nil, status.Errorf(codes.NotFound, "denom metadata for %s not found", req.Denom)
+	}
+
+	return &banktypes.QueryDenomMetadataResponse{
+		Metadata: banktypes.Metadata{
+			Base:    mockDenom,
+			Display: "umee",
+			DenomUnits: []*banktypes.DenomUnit{
+				{Denom: mockDenom, Exponent: 0},
+				{Denom: "umee", Exponent: 6},
+			},
+		},
+	}, nil
+}
+
+type mockOracleServer struct {
+	oracletypes.UnimplementedQueryServer
+	chain *MockChain
+}
+
+func (s *mockOracleServer) Params(ctx context.Context, req *oracletypes.QueryParams) (*oracletypes.QueryParamsResponse, error) {
+	acceptList := make(oracletypes.DenomList, len(mockSymbols))
+	for index, symbol := range mockSymbols {
+		acceptList[index] = oracletypes.Denom{
+			BaseDenom:   "u" + strings.ToLower(symbol),
+			SymbolDenom: symbol,
+			Exponent:    6,
+		}
+	}
+
+	return &oracletypes.QueryParamsResponse{
+		Params: oracletypes.Params{
+			VotePeriod:        mockVotePeriod,
+			VoteThreshold:     sdk.NewDecWithPrec(5, 1),
+			RewardBand:        sdk.NewDecWithPrec(2, 2),
+			AcceptList:        acceptList,
+			SlashFraction:     sdk.NewDecWithPrec(1, 4),
+			SlashWindow:       mockSlashWindow,
+			MinValidPerWindow: sdk.NewDecWithPrec(5, 2),
+		},
+	}, nil
+}
+
+func (s *mockOracleServer) SlashWindow(ctx context.Context, req *oracletypes.QuerySlashWindow) (*oracletypes.QuerySlashWindowResponse, error) {
+	return &oracletypes.QuerySlashWindowResponse{
+		WindowProgress: s.chain.votePeriods() % (mockSlashWindow / mockVotePeriod),
+	}, nil
+}
+
+func (s *mockOracleServer) MissCounter(ctx context.Context, req *oracletypes.QueryMissCounter) (*oracletypes.QueryMissCounterResponse, error) {
+	index, err := s.chain.validatorIndex(req.ValidatorAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oracletypes.QueryMissCounterResponse{MissCounter: s.chain.missCounter(index)}, nil
+}
+
+func (s *mockOracleServer) FeederDelegation(ctx context.Context, req *oracletypes.QueryFeederDelegation) (*oracletypes.QueryFeederDelegationResponse, error) {
+	index, err := s.chain.validatorIndex(req.ValidatorAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oracletypes.QueryFeederDelegationResponse{FeederAddr: mockFeeder(index)}, nil
+}
+
+func (s *mockOracleServer) AggregatePrevote(ctx context.Context, req *oracletypes.QueryAggregatePrevote) (*oracletypes.QueryAggregatePrevoteResponse, error) {
+	index, err := s.chain.validatorIndex(req.ValidatorAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	submitBlock := s.chain.votePeriods() * mockVotePeriod
+	if s.chain.faulty(index, MockFaultMisses) {
+		// the feeder is a few vote periods behind
+		submitBlock -= 3 * mockVotePeriod
+	}
+
+	return &oracletypes.QueryAggregatePrevoteResponse{
+		AggregatePrevote: oracletypes.AggregateExchangeRatePrevote{
+			Voter:       mockFeeder(index),
+			SubmitBlock: submitBlock,
+		},
+	}, nil
+}
+
+func (s *mockOracleServer) AggregateVote(ctx context.Context, req *oracletypes.QueryAggregateVote) (*oracletypes.QueryAggregateVoteResponse, error) {
+	index, err := s.chain.validatorIndex(req.ValidatorAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := mockSymbols
+	if s.chain.faulty(index, MockFaultMisses) {
+		// the last symbol is never voted for
+		symbols = symbols[:len(symbols)-1]
+	}
+
+	tuples := make(oracletypes.ExchangeRateTuples, len(symbols))
+	for i, symbol := range symbols {
+		tuples[i] = oracletypes.ExchangeRateTuple{Denom: symbol, ExchangeRate: s.chain.exchangeRate(symbol)}
+	}
+
+	return &oracletypes.QueryAggregateVoteResponse{
+		AggregateVote: oracletypes.AggregateExchangeRateVote{
+			ExchangeRateTuples: tuples,
+			Voter:              mockFeeder(index),
+		},
+	}, nil
+}
+
+func (s *mockOracleServer) ExchangeRates(ctx context.Context, req *oracletypes.QueryExchangeRates) (*oracletypes.QueryExchangeRatesResponse, error) {
+	rates := make(sdk.DecCoins, 0, len(mockSymbols))
+	for _, symbol := range mockSymbols {
+		rates = append(rates, sdk.DecCoin{Denom: symbol, Amount: s.chain.exchangeRate(symbol)})
+	}
+
+	return &oracletypes.QueryExchangeRatesResponse{ExchangeRates: rates}, nil
+}
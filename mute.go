@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MuteChain is the mute target that silences every validator of the chain.
+const MuteChain = "chain"
+
+// maxMuteDuration bounds the mutes set through the API, a forgotten mute
+// shouldn't silence a validator for good.
+const maxMuteDuration = 7 * 24 * time.Hour
+
+// AlertMute silences the notifications of a validator, or of all of them
+// for MuteChain, until the given time. Alerts are still evaluated.
+type AlertMute struct {
+	Target string    `json:"target"`
+	Until  time.Time `json:"until"`
+	// config mutes are replaced on every reload, API mutes stay until they expire
+	Source string `json:"source"`
+}
+
+// ParseAlertMutes parses the --alert-mutes map of a validator address or
+// "chain" to the RFC 3339 time the mute ends at.
+func ParseAlertMutes(values map[string]string) (map[string]time.Time, error) {
+	mutes := make(map[string]time.Time, len(values))
+	for target, value := range values {
+		if err := validateMuteTarget(target); err != nil {
+			return nil, err
+		}
+
+		until, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end of the mute of %s: %w", target, err)
+		}
+
+		mutes[target] = until
+	}
+
+	return mutes, nil
+}
+
+func validateMuteTarget(target string) error {
+	if target == MuteChain {
+		return nil
+	}
+
+	if _, err := ValAddressFromBech32(target); err != nil {
+		return fmt.Errorf("invalid mute target %q, expected a validator address or %q: %w", target, MuteChain, err)
+	}
+
+	return nil
+}
+
+// MuteHandler lists the mutes on GET, mutes the validator given with
+// ?valoper= (or the chain with ?chain) for ?duration= on POST and unmutes it
+// on DELETE. Changes need the exporter to be protected by authentication.
+func MuteHandler(w http.ResponseWriter, r *http.Request, dispatcher *AlertDispatcher, authenticated bool) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	if r.Method != http.MethodGet && !authenticated {
+		http.Error(w, "mutes can only be changed with --auth-user or --auth-token set", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	target := query.Get("valoper")
+	if query.Has("chain") {
+		target = MuteChain
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := validateMuteTarget(target); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		duration, err := time.ParseDuration(query.Get("duration"))
+		if err != nil || duration <= 0 || duration > maxMuteDuration {
+			http.Error(w, fmt.Sprintf("invalid duration %q, expected e.g. 4h, at most %s", query.Get("duration"), maxMuteDuration), http.StatusBadRequest)
+			return
+		}
+
+		until := time.Now().Add(duration).UTC()
+		dispatcher.Mute(target, until)
+
+		sublogger.Info().
+			Str("target", target).
+			Time("until", until).
+			Msg("Muted alerts")
+	case http.MethodDelete:
+		if err := validateMuteTarget(target); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !dispatcher.Unmute(target) {
+			http.Error(w, fmt.Sprintf("%s is not muted through the API", target), http.StatusNotFound)
+			return
+		}
+
+		sublogger.Info().
+			Str("target", target).
+			Msg("Unmuted alerts")
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"mutes": dispatcher.Mutes(),
+	}); err != nil {
+		sublogger.Error().Err(err).Msg("Could not write mutes")
+	}
+
+	sublogger.Info().
+		Str("method", r.Method).
+		Str("endpoint", "/mute").
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
+
+// Mute silences the target until the given time, replacing an earlier API mute.
+func (d *AlertDispatcher) Mute(target string, until time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.mutes[target] = until
+}
+
+// Unmute lifts the API mute of the target and reports whether there was one,
+// mutes from the config stay until the config changes.
+func (d *AlertDispatcher) Unmute(target string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	_, ok := d.mutes[target]
+	delete(d.mutes, target)
+	return ok
+}
+
+// SetConfigMutes replaces the mutes of the config file.
+func (d *AlertDispatcher) SetConfigMutes(mutes map[string]time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.configMutes = mutes
+}
+
+// Mutes returns the mutes that didn't end yet.
+func (d *AlertDispatcher) Mutes() []AlertMute {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+	mutes := []AlertMute{}
+	for source, targets := range map[string]map[string]time.Time{"api": d.mutes, "config": d.configMutes} {
+		for target, until := range targets {
+			if until.After(now) {
+				mutes = append(mutes, AlertMute{Target: target, Until: until, Source: source})
+			}
+		}
+	}
+
+	sort.Slice(mutes, func(i, j int) bool {
+		if mutes[i].Target != mutes[j].Target {
+			return mutes[i].Target < mutes[j].Target
+		}
+		return mutes[i].Source < mutes[j].Source
+	})
+
+	return mutes
+}
+
+// APIMutes returns a copy of the API mutes to persist, without the ended ones.
+func (d *AlertDispatcher) APIMutes() map[string]time.Time {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+	mutes := make(map[string]time.Time, len(d.mutes))
+	for target, until := range d.mutes {
+		if until.After(now) {
+			mutes[target] = until
+		}
+	}
+
+	return mutes
+}
+
+func (d *AlertDispatcher) RestoreAPIMutes(mutes map[string]time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.mutes = make(map[string]time.Time, len(mutes))
+	for target, until := range mutes {
+		d.mutes[target] = until
+	}
+}
+
+// muted tells whether the notifications of the validator are silenced, the
+// caller holds the mutex.
+func (d *AlertDispatcher) muted(valoper string) bool {
+	now := time.Now()
+	for _, target := range []string{valoper, MuteChain} {
+		if until, ok := d.mutes[target]; ok && until.After(now) {
+			return true
+		}
+		if until, ok := d.configMutes[target]; ok && until.After(now) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	querytypes "github.com/cosmos/cosmos-sdk/types/query"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+)
+
+type networkValidator struct {
+	OperatorAddress string
+	Moniker         string
+	Feeder          string
+	MissCounter     uint64
+	HasMissCounter  bool
+}
+
+// NetworkScanner periodically collects oracle data for the whole active set
+// in the background. Queries are paced by a token bucket so that one scan is
+// spread evenly over the scan interval instead of bursting at scrape time.
+//
+// Details that rarely change (feeder delegations) are only refetched for
+// validators whose on-chain record changed since the previous scan, with a
+// full refresh every fullRefreshEvery scans to catch changes not reflected there.
+type NetworkScanner struct {
+	uncheckedCollector
+
+	node             *NodeConnection
+	interval         time.Duration
+	fullRefreshEvery int
+	limiter          *TokenBucket
+
+	mutex         sync.RWMutex
+	validators    []networkValidator
+	lastScan      time.Time
+	scanDuration  time.Duration
+	detailQueries int
+
+	// only touched by the scanning goroutine
+	setHash          string
+	fingerprints     map[string]string
+	feeders          map[string]string
+	scansSinceUpdate int
+
+	logger zerolog.Logger
+}
+
+func NewNetworkScanner(node *NodeConnection, interval time.Duration, fullRefreshEvery int) *NetworkScanner {
+	return &NetworkScanner{
+		node:             node,
+		interval:         interval,
+		fullRefreshEvery: fullRefreshEvery,
+		// until the set size is known, assume a typical active set
+		limiter:      NewTokenBucket(float64(CurrentConfig().Queries.PageSize)/interval.Seconds(), 1),
+		fingerprints: make(map[string]string),
+		feeders:      make(map[string]string),
+		logger:       log.With().Str("component", "network-scanner").Logger(),
+	}
+}
+
+// changedValidators returns the operator addresses whose details have to be
+// refetched, comparing the fingerprints of the validators with the previous scan.
+func (s *NetworkScanner) changedValidators(validators []stakingtypes.Validator) map[string]bool {
+	fingerprints := make(map[string]string, len(validators))
+	setHasher := sha256.New()
+
+	for _, validator := range validators {
+		bz, err := validator.Marshal()
+		if err != nil {
+			// unknown fingerprint, always treated as changed
+			bz = []byte(time.Now().String())
+		}
+
+		hash := sha256.Sum256(bz)
+		fingerprints[validator.OperatorAddress] = hex.EncodeToString(hash[:])
+		setHasher.Write(hash[:])
+	}
+
+	setHash := hex.EncodeToString(setHasher.Sum(nil))
+	fullRefresh := s.fullRefreshEvery > 0 && s.scansSinceUpdate >= s.fullRefreshEvery
+
+	changed := make(map[string]bool)
+	if setHash != s.setHash || fullRefresh {
+		for address, fingerprint := range fingerprints {
+			if fullRefresh || s.fingerprints[address] != fingerprint {
+				changed[address] = true
+			}
+		}
+	}
+
+	if fullRefresh {
+		s.scansSinceUpdate = 0
+	} else {
+		s.scansSinceUpdate++
+	}
+
+	for address := range s.feeders {
+		if _, ok := fingerprints[address]; !ok {
+			delete(s.feeders, address)
+		}
+	}
+
+	s.setHash = setHash
+	s.fingerprints = fingerprints
+
+	return changed
+}
+
+func (s *NetworkScanner) Start() {
+	s.logger.Info().Dur("interval", s.interval).Msg("Started network scanning")
+
+	for {
+		scanStart := time.Now()
+		s.scan()
+
+		if elapsed := time.Since(scanStart); elapsed < s.interval {
+			time.Sleep(s.interval - elapsed)
+		}
+	}
+}
+
+func (s *NetworkScanner) scan() {
+	scanStart := time.Now()
+	ctx := context.Background()
+	grpcConn := s.node.Get()
+
+	stakingClient := stakingtypes.NewQueryClient(grpcConn)
+	var validators []stakingtypes.Validator
+
+	err := paginate("validators", func(key []byte, limit uint64) ([]byte, error) {
+		if err := s.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		response, err := stakingClient.Validators(ctx, &stakingtypes.QueryValidatorsRequest{
+			Status:     stakingtypes.BondStatusBonded,
+			Pagination: &querytypes.PageRequest{Key: key, Limit: limit},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		validators = append(validators, response.Validators...)
+		return nextPageKey(response.Pagination), nil
+	})
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Could not get active validators")
+		return
+	}
+
+	changed := s.changedValidators(validators)
+
+	// miss counter for every validator, details for the changed ones and
+	// the listing itself, spread over the whole interval
+	s.limiter.SetRate(float64(len(validators)+len(changed)+1) / s.interval.Seconds())
+
+	oracle, err := NewOracleProvider(CurrentConfig().Chain.Type, grpcConn)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Could not create oracle provider")
+		return
+	}
+
+	results := make([]networkValidator, len(validators))
+	detailQueries := 0
+
+	for index, validator := range validators {
+		results[index] = networkValidator{
+			OperatorAddress: validator.OperatorAddress,
+			Moniker:         validator.Description.Moniker,
+		}
+
+		if changed[validator.OperatorAddress] {
+			if err := s.limiter.Wait(ctx); err != nil {
+				return
+			}
+
+			detailQueries++
+			feeder, err := oracle.FeederDelegation(ctx, validator.OperatorAddress)
+			if err != nil {
+				s.logger.Warn().
+					Str("valoper", validator.OperatorAddress).
+					Err(err).
+					Msg("Could not get feeder account associated with the validator")
+				// refetch on the next scan
+				delete(s.fingerprints, validator.OperatorAddress)
+				s.setHash = ""
+			} else {
+				s.feeders[validator.OperatorAddress] = feeder
+			}
+		}
+
+		results[index].Feeder = s.feeders[validator.OperatorAddress]
+
+		if err := s.limiter.Wait(ctx); err != nil {
+			return
+		}
+
+		missCounter, err := oracle.MissCounter(ctx, validator.OperatorAddress)
+		if err != nil {
+			s.logger.Warn().
+				Str("valoper", validator.OperatorAddress).
+				Err(err).
+				Msg("Could not get validator current miss counter")
+			continue
+		}
+
+		results[index].MissCounter = missCounter
+		results[index].HasMissCounter = true
+	}
+
+	s.mutex.Lock()
+	s.validators = results
+	s.lastScan = time.Now()
+	s.scanDuration = time.Since(scanStart)
+	s.detailQueries = detailQueries
+	s.mutex.Unlock()
+
+	s.logger.Debug().
+		Int("validators", len(results)).
+		Int("detail-queries", detailQueries).
+		Float64("request-time", time.Since(scanStart).Seconds()).
+		Msg("Finished network scan")
+}
+
+// Collect exports the results of the last network scan.
+func (s *NetworkScanner) Collect(ch chan<- prometheus.Metric) {
+	networkValidatorsGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "network_validators",
+			Help:        "Number of validators in the active set seen on the last network scan",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	networkScanTimestampGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "network_scan_timestamp",
+			Help:        "Timestamp of the last finished network scan",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	networkScanDurationGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "network_scan_duration_seconds",
+			Help:        "Duration of the last network scan",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	networkScanDetailQueriesGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "network_scan_detail_queries",
+			Help:        "Number of validator detail queries done on the last network scan",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	networkFeederAccountGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "network_feeder_account",
+			Help:        "Feeder account delegated by every validator in the active set",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "feeder"},
+	)
+
+	networkMissCounterGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "network_miss_counter",
+			Help:        "Current miss counter for every validator in the active set",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "moniker"},
+	)
+
+	metrics := []prometheus.Collector{
+		networkValidatorsGauge,
+		networkScanTimestampGauge,
+		networkScanDurationGauge,
+		networkScanDetailQueriesGauge,
+		networkFeederAccountGauge,
+		networkMissCounterGauge,
+	}
+
+	s.mutex.RLock()
+	if !s.lastScan.IsZero() {
+		networkValidatorsGauge.Set(float64(len(s.validators)))
+		networkScanTimestampGauge.Set(float64(s.lastScan.UTC().UnixMilli()))
+		networkScanDurationGauge.Set(s.scanDuration.Seconds())
+		networkScanDetailQueriesGauge.Set(float64(s.detailQueries))
+	}
+
+	for _, validator := range s.validators {
+		if validator.Feeder != "" {
+			networkFeederAccountGauge.With(prometheus.Labels{
+				"valoper": validator.OperatorAddress,
+				"feeder":  validator.Feeder,
+			}).Set(1)
+		}
+
+		if !validator.HasMissCounter {
+			continue
+		}
+
+		networkMissCounterGauge.With(prometheus.Labels{
+			"valoper": validator.OperatorAddress,
+			"moniker": validator.Moniker,
+		}).Set(float64(validator.MissCounter))
+	}
+	s.mutex.RUnlock()
+
+	collectMetrics(ch, metrics)
+}
+
+// NetworkHandler serves the results of the last network scan.
+func NetworkHandler(w http.ResponseWriter, r *http.Request, scanner *NetworkScanner) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	registry := collectorRegistry(scanner)
+
+	h := promhttp.HandlerFor(ExportGatherer(registry), promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/network").
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNetworkScannerCollect(t *testing.T) {
+	scanner := &NetworkScanner{
+		validators: []networkValidator{
+			{OperatorAddress: "umeevaloper1a", Moniker: "a", Feeder: "umee1feedera", MissCounter: 3, HasMissCounter: true},
+			{OperatorAddress: "umeevaloper1b", Moniker: "b"},
+		},
+		lastScan:      time.UnixMilli(1700000000000),
+		scanDuration:  1500 * time.Millisecond,
+		detailQueries: 2,
+	}
+
+	expected := `
+# HELP network_feeder_account Feeder account delegated by every validator in the active set
+# TYPE network_feeder_account gauge
+network_feeder_account{feeder="umee1feedera",valoper="umeevaloper1a"} 1
+# HELP network_miss_counter Current miss counter for every validator in the active set
+# TYPE network_miss_counter gauge
+network_miss_counter{moniker="a",valoper="umeevaloper1a"} 3
+# HELP network_scan_detail_queries Number of validator detail queries done on the last network scan
+# TYPE network_scan_detail_queries gauge
+network_scan_detail_queries 2
+# HELP network_scan_duration_seconds Duration of the last network scan
+# TYPE network_scan_duration_seconds gauge
+network_scan_duration_seconds 1.5
+# HELP network_scan_timestamp Timestamp of the last finished network scan
+# TYPE network_scan_timestamp gauge
+network_scan_timestamp 1.7e+12
+# HELP network_validators Number of validators in the active set seen on the last network scan
+# TYPE network_validators gauge
+network_validators 2
+`
+
+	if err := testutil.CollectAndCompare(scanner, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNetworkScannerCollectBeforeScan(t *testing.T) {
+	scanner := &NetworkScanner{}
+
+	// the scan gauges are exported at 0 until the first scan finished
+	if count := testutil.CollectAndCount(scanner); count != 4 {
+		t.Errorf("expected 4 metrics before the first scan, got %d", count)
+	}
+}
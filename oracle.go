@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"google.golang.org/grpc"
+)
+
+var ErrNotSupported = errors.New("not supported by the chain oracle module")
+
+const (
+	ChainTypeAuto      = "auto"
+	ChainTypeUmee      = "umee"
+	ChainTypeOjo       = "ojo"
+	ChainTypeTerra     = "terra"
+	ChainTypeKujira    = "kujira"
+	ChainTypeSei       = "sei"
+	ChainTypeInjective = "injective"
+)
+
+// chainTypes is also the order chain types are tried in on auto-detection.
+var chainTypes = []string{
+	ChainTypeUmee,
+	ChainTypeOjo,
+	ChainTypeKujira,
+	ChainTypeTerra,
+	ChainTypeSei,
+	ChainTypeInjective,
+}
+
+type OracleParams struct {
+	VotePeriod        uint64
+	SlashWindow       uint64
+	MinValidPerWindow sdk.Dec
+	SlashFraction     sdk.Dec
+	// RewardBand is the deviation from the median a vote may have to be
+	// valid, nil if the module has none
+	RewardBand sdk.Dec
+	// Symbols are the denoms validators have to vote for
+	Symbols []string
+}
+
+// VotePenaltyCounter counts the vote periods of a validator in the current
+// slash window by outcome, as Sei tracks them instead of a miss counter.
+type VotePenaltyCounter struct {
+	Misses    uint64
+	Abstains  uint64
+	Successes uint64
+}
+
+// PerformanceWindow is the voting performance of a validator in the current
+// slash window, counted in vote periods. Umee and Ojo slash the validators
+// whose share of valid votes is below min_valid_per_window at the end of the
+// window, a vote being valid if it has all the accepted denoms within the
+// reward band of the median.
+type PerformanceWindow struct {
+	Size    uint64
+	Elapsed uint64
+	Misses  uint64
+	// AllowedMisses is the number of misses the validator can have at the
+	// end of the window without being slashed
+	AllowedMisses uint64
+}
+
+// PriceFeed is a market of an oracle fed by relayers instead of validator
+// votes, with the accounts allowed to relay its price.
+type PriceFeed struct {
+	Market    string
+	Source    string
+	Timestamp time.Time
+	Relayers  []string
+}
+
+// OracleProvider hides the differences between the oracle modules of the
+// supported chains, which mostly rename services and fields. Methods the
+// chain has no equivalent for return ErrNotSupported.
+type OracleProvider interface {
+	Name() string
+	Params(ctx context.Context) (*OracleParams, error)
+	SlashWindowProgress(ctx context.Context, params *OracleParams) (uint64, error)
+	MissCounter(ctx context.Context, valoper string) (uint64, error)
+	VotePenaltyCounter(ctx context.Context, valoper string) (*VotePenaltyCounter, error)
+	PerformanceWindow(ctx context.Context, valoper string, params *OracleParams) (*PerformanceWindow, error)
+	FeederDelegation(ctx context.Context, valoper string) (string, error)
+	LastPrevoteBlock(ctx context.Context, valoper string) (uint64, error)
+	VotedDenoms(ctx context.Context, valoper string) ([]string, error)
+	ExchangeRates(ctx context.Context) (map[string]sdk.Dec, error)
+	PriceFeeds(ctx context.Context) ([]PriceFeed, error)
+}
+
+func NewOracleProvider(chainType string, grpcConn *grpc.ClientConn) (OracleProvider, error) {
+	switch strings.ToLower(chainType) {
+	case ChainTypeUmee:
+		return NewUmeeOracleProvider(grpcConn, "umee.oracle.v1"), nil
+	case ChainTypeOjo:
+		return NewUmeeOracleProvider(grpcConn, "ojo.oracle.v1"), nil
+	case ChainTypeTerra:
+		return NewTerraOracleProvider(grpcConn, ChainTypeTerra, "terra.oracle.v1beta1"), nil
+	case ChainTypeKujira:
+		return NewKujiraOracleProvider(grpcConn), nil
+	case ChainTypeSei:
+		return NewSeiOracleProvider(grpcConn), nil
+	case ChainTypeInjective:
+		return NewInjectiveOracleProvider(grpcConn), nil
+	default:
+		return nil, fmt.Errorf("unsupported chain type %q, expected one of %s", chainType, strings.Join(chainTypes, ", "))
+	}
+}
+
+// DetectChainType returns the first chain type whose oracle params query
+// is served by the node.
+func DetectChainType(ctx context.Context, grpcConn *grpc.ClientConn) (string, error) {
+	for _, chainType := range chainTypes {
+		provider, err := NewOracleProvider(chainType, grpcConn)
+		if err != nil {
+			return "", err
+		}
+
+		if _, err := provider.Params(ctx); err == nil {
+			return chainType, nil
+		}
+	}
+
+	return "", errors.New("could not detect the chain type, none of the known oracle modules responded")
+}
+
+// WaitForChainType retries the detection until the node answers, so the
+// exporter can start while the node is down.
+func WaitForChainType(node *NodeConnection) string {
+	backoff := time.Second
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		chainType, err := DetectChainType(ctx, node.Get())
+		cancel()
+
+		if err == nil {
+			return chainType
+		}
+
+		log.Warn().
+			Err(err).
+			Dur("retry-in", backoff).
+			Msg("Could not detect chain type, set it with --chain-type if the node is up")
+
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+}
+
+// windowProgressFromHeight calculates the slash window progress the same way
+// x/oracle does, for chains that don't expose it with a query.
+func windowProgressFromHeight(ctx context.Context, grpcConn *grpc.ClientConn, params *OracleParams) (uint64, error) {
+	if params.SlashWindow == 0 || params.VotePeriod == 0 {
+		return 0, errors.New("slash window and vote period must be positive")
+	}
+
+	// the queries of a scrape are pinned to a height, which may be in the past
+	height, ok := PinnedHeight(ctx)
+	if !ok {
+		serviceClient := tmservice.NewServiceClient(grpcConn)
+		response, err := serviceClient.GetLatestBlock(ctx, &tmservice.GetLatestBlockRequest{})
+		if err != nil {
+			return 0, err
+		}
+
+		height = response.Block.Header.Height
+	}
+
+	return (uint64(height) % params.SlashWindow) / params.VotePeriod, nil
+}
+
+// parseProtoDec parses sdk.Dec as it goes over the wire,
+// an integer with 18 implied decimals.
+func parseProtoDec(value string) (sdk.Dec, error) {
+	if value == "" {
+		return sdk.ZeroDec(), nil
+	}
+
+	integer, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return sdk.Dec{}, fmt.Errorf("invalid decimal %q", value)
+	}
+
+	return sdk.NewDecFromBigIntWithPrec(integer, sdk.Precision), nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"google.golang.org/grpc"
+)
+
+const injectiveOracleService = "/injective.oracle.v1beta1.Query/"
+
+// InjectiveOracleProvider serves Injective. Its oracle is fed by relayers
+// rather than validator votes, so only exchange rates and the price feeds of
+// the relayers are available.
+type InjectiveOracleProvider struct {
+	grpcConn *grpc.ClientConn
+}
+
+func NewInjectiveOracleProvider(grpcConn *grpc.ClientConn) *InjectiveOracleProvider {
+	return &InjectiveOracleProvider{grpcConn: grpcConn}
+}
+
+func (p *InjectiveOracleProvider) Name() string {
+	return ChainTypeInjective
+}
+
+func (p *InjectiveOracleProvider) invoke(ctx context.Context, method string, request interface{}, response interface{}) error {
+	return p.grpcConn.Invoke(ctx, injectiveOracleService+method, request, response)
+}
+
+func (p *InjectiveOracleProvider) Params(ctx context.Context) (*OracleParams, error) {
+	// none of the params are about voting, the query only tells the module is there
+	if err := p.invoke(ctx, "Params", &emptyRequest{}, &emptyRequest{}); err != nil {
+		return nil, err
+	}
+
+	return &OracleParams{
+		MinValidPerWindow: sdk.ZeroDec(),
+		SlashFraction:     sdk.ZeroDec(),
+		Symbols:           []string{},
+	}, nil
+}
+
+func (p *InjectiveOracleProvider) SlashWindowProgress(ctx context.Context, params *OracleParams) (uint64, error) {
+	return 0, ErrNotSupported
+}
+
+func (p *InjectiveOracleProvider) MissCounter(ctx context.Context, valoper string) (uint64, error) {
+	return 0, ErrNotSupported
+}
+
+func (p *InjectiveOracleProvider) VotePenaltyCounter(ctx context.Context, valoper string) (*VotePenaltyCounter, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *InjectiveOracleProvider) PerformanceWindow(ctx context.Context, valoper string, params *OracleParams) (*PerformanceWindow, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *InjectiveOracleProvider) FeederDelegation(ctx context.Context, valoper string) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (p *InjectiveOracleProvider) LastPrevoteBlock(ctx context.Context, valoper string) (uint64, error) {
+	return 0, ErrNotSupported
+}
+
+func (p *InjectiveOracleProvider) VotedDenoms(ctx context.Context, valoper string) ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *InjectiveOracleProvider) ExchangeRates(ctx context.Context) (map[string]sdk.Dec, error) {
+	response := &injectivePriceFeedStatesResponse{}
+	if err := p.invoke(ctx, "PriceFeedPriceStates", &emptyRequest{}, response); err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string]sdk.Dec, len(response.PriceStates))
+	for _, state := range response.PriceStates {
+		if state.PriceState == nil {
+			continue
+		}
+
+		price, err := parseProtoDec(state.PriceState.Price)
+		if err != nil {
+			return nil, err
+		}
+
+		rates[state.Base+"/"+state.Quote] = price
+	}
+
+	return rates, nil
+}
+
+// PriceFeeds returns the markets of the price feeder and Band oracles, the
+// Band relayers relay every Band symbol.
+func (p *InjectiveOracleProvider) PriceFeeds(ctx context.Context) ([]PriceFeed, error) {
+	priceFeedResponse := &injectivePriceFeedStatesResponse{}
+	if err := p.invoke(ctx, "PriceFeedPriceStates", &emptyRequest{}, priceFeedResponse); err != nil {
+		return nil, err
+	}
+
+	bandResponse := &injectiveBandPriceStatesResponse{}
+	if err := p.invoke(ctx, "BandPriceStates", &emptyRequest{}, bandResponse); err != nil {
+		return nil, err
+	}
+
+	bandRelayersResponse := &injectiveBandRelayersResponse{}
+	if err := p.invoke(ctx, "BandRelayers", &emptyRequest{}, bandRelayersResponse); err != nil {
+		return nil, err
+	}
+
+	feeds := make([]PriceFeed, 0, len(priceFeedResponse.PriceStates)+len(bandResponse.PriceStates))
+	for _, state := range priceFeedResponse.PriceStates {
+		if state.PriceState == nil {
+			continue
+		}
+
+		feeds = append(feeds, PriceFeed{
+			Market:    state.Base + "/" + state.Quote,
+			Source:    "pricefeed",
+			Timestamp: time.Unix(state.PriceState.Timestamp, 0),
+			Relayers:  state.Relayers,
+		})
+	}
+
+	for _, state := range bandResponse.PriceStates {
+		feeds = append(feeds, PriceFeed{
+			Market:    state.Symbol,
+			Source:    "band",
+			Timestamp: time.Unix(int64(state.ResolveTime), 0),
+			Relayers:  bandRelayersResponse.Relayers,
+		})
+	}
+
+	return feeds, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"google.golang.org/grpc"
+)
+
+// kujiraVotePeriod is the vote period of Kujira, the validators vote with the
+// vote extensions of every block instead of prevote and vote transactions.
+const kujiraVotePeriod = 1
+
+// KujiraOracleProvider serves the Kujira fork of x/oracle. It has no feeders
+// and no prevotes, the whitelist is replaced by the required denoms and the
+// slash window progress is calculated from the latest block height.
+type KujiraOracleProvider struct {
+	grpcConn *grpc.ClientConn
+}
+
+func NewKujiraOracleProvider(grpcConn *grpc.ClientConn) *KujiraOracleProvider {
+	return &KujiraOracleProvider{grpcConn: grpcConn}
+}
+
+func (p *KujiraOracleProvider) Name() string {
+	return ChainTypeKujira
+}
+
+func (p *KujiraOracleProvider) invoke(ctx context.Context, method string, request interface{}, response interface{}) error {
+	return p.grpcConn.Invoke(ctx, "/kujira.oracle.Query/"+method, request, response)
+}
+
+func (p *KujiraOracleProvider) Params(ctx context.Context) (*OracleParams, error) {
+	response := &kujiraParamsResponse{}
+	if err := p.invoke(ctx, "Params", &emptyRequest{}, response); err != nil {
+		return nil, err
+	}
+
+	if response.Params == nil {
+		return nil, errors.New("empty oracle params")
+	}
+
+	slashFraction, err := parseProtoDec(response.Params.SlashFraction)
+	if err != nil {
+		return nil, err
+	}
+
+	minValidPerWindow, err := parseProtoDec(response.Params.MinValidPerWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OracleParams{
+		VotePeriod:        kujiraVotePeriod,
+		SlashWindow:       response.Params.SlashWindow,
+		MinValidPerWindow: minValidPerWindow,
+		SlashFraction:     slashFraction,
+		Symbols:           response.Params.RequiredDenoms,
+	}, nil
+}
+
+func (p *KujiraOracleProvider) SlashWindowProgress(ctx context.Context, params *OracleParams) (uint64, error) {
+	return windowProgressFromHeight(ctx, p.grpcConn, params)
+}
+
+func (p *KujiraOracleProvider) MissCounter(ctx context.Context, valoper string) (uint64, error) {
+	response := &missCounterResponse{}
+	if err := p.invoke(ctx, "MissCounter", &validatorRequest{ValidatorAddr: valoper}, response); err != nil {
+		return 0, err
+	}
+
+	return response.MissCounter, nil
+}
+
+func (p *KujiraOracleProvider) VotePenaltyCounter(ctx context.Context, valoper string) (*VotePenaltyCounter, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *KujiraOracleProvider) PerformanceWindow(ctx context.Context, valoper string, params *OracleParams) (*PerformanceWindow, error) {
+	return nil, ErrNotSupported
+}
+
+// FeederDelegation isn't supported, the prices are voted with the vote
+// extensions signed by the validator itself.
+func (p *KujiraOracleProvider) FeederDelegation(ctx context.Context, valoper string) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (p *KujiraOracleProvider) LastPrevoteBlock(ctx context.Context, valoper string) (uint64, error) {
+	return 0, ErrNotSupported
+}
+
+func (p *KujiraOracleProvider) VotedDenoms(ctx context.Context, valoper string) ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *KujiraOracleProvider) ExchangeRates(ctx context.Context) (map[string]sdk.Dec, error) {
+	response := &exchangeRatesResponse{}
+	if err := p.invoke(ctx, "ExchangeRates", &emptyRequest{}, response); err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string]sdk.Dec, len(response.ExchangeRates))
+	for _, rate := range response.ExchangeRates {
+		amount, err := parseProtoDec(rate.Amount)
+		if err != nil {
+			return nil, err
+		}
+
+		rates[rate.Denom] = amount
+	}
+
+	return rates, nil
+}
+
+func (p *KujiraOracleProvider) PriceFeeds(ctx context.Context) ([]PriceFeed, error) {
+	return nil, ErrNotSupported
+}
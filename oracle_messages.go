@@ -0,0 +1,274 @@
+package main
+
+import "fmt"
+
+// Hand-written messages for the oracle modules we don't have generated Go
+// types for. Only the fields the exporter reads are declared, the field
+// numbers follow the chains' proto definitions and decimals are kept as the
+// strings they are sent as over the wire (see parseProtoDec).
+
+type emptyRequest struct{}
+
+func (m *emptyRequest) Reset()         { *m = emptyRequest{} }
+func (m *emptyRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*emptyRequest) ProtoMessage()    {}
+
+type validatorRequest struct {
+	ValidatorAddr string `protobuf:"bytes,1,opt,name=validator_addr,json=validatorAddr,proto3"`
+}
+
+func (m *validatorRequest) Reset()         { *m = validatorRequest{} }
+func (m *validatorRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*validatorRequest) ProtoMessage()    {}
+
+type oracleDenom struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3"`
+}
+
+func (m *oracleDenom) Reset()         { *m = oracleDenom{} }
+func (m *oracleDenom) String() string { return fmt.Sprintf("%+v", *m) }
+func (*oracleDenom) ProtoMessage()    {}
+
+type terraParams struct {
+	VotePeriod        uint64         `protobuf:"varint,1,opt,name=vote_period,json=votePeriod,proto3"`
+	Whitelist         []*oracleDenom `protobuf:"bytes,5,rep,name=whitelist,proto3"`
+	SlashFraction     string         `protobuf:"bytes,6,opt,name=slash_fraction,json=slashFraction,proto3"`
+	SlashWindow       uint64         `protobuf:"varint,7,opt,name=slash_window,json=slashWindow,proto3"`
+	MinValidPerWindow string         `protobuf:"bytes,8,opt,name=min_valid_per_window,json=minValidPerWindow,proto3"`
+}
+
+func (m *terraParams) Reset()         { *m = terraParams{} }
+func (m *terraParams) String() string { return fmt.Sprintf("%+v", *m) }
+func (*terraParams) ProtoMessage()    {}
+
+type terraParamsResponse struct {
+	Params *terraParams `protobuf:"bytes,1,opt,name=params,proto3"`
+}
+
+func (m *terraParamsResponse) Reset()         { *m = terraParamsResponse{} }
+func (m *terraParamsResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*terraParamsResponse) ProtoMessage()    {}
+
+type kujiraParams struct {
+	RequiredDenoms    []string `protobuf:"bytes,3,rep,name=required_denoms,json=requiredDenoms,proto3"`
+	SlashFraction     string   `protobuf:"bytes,4,opt,name=slash_fraction,json=slashFraction,proto3"`
+	SlashWindow       uint64   `protobuf:"varint,5,opt,name=slash_window,json=slashWindow,proto3"`
+	MinValidPerWindow string   `protobuf:"bytes,6,opt,name=min_valid_per_window,json=minValidPerWindow,proto3"`
+}
+
+func (m *kujiraParams) Reset()         { *m = kujiraParams{} }
+func (m *kujiraParams) String() string { return fmt.Sprintf("%+v", *m) }
+func (*kujiraParams) ProtoMessage()    {}
+
+type kujiraParamsResponse struct {
+	Params *kujiraParams `protobuf:"bytes,1,opt,name=params,proto3"`
+}
+
+func (m *kujiraParamsResponse) Reset()         { *m = kujiraParamsResponse{} }
+func (m *kujiraParamsResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*kujiraParamsResponse) ProtoMessage()    {}
+
+type seiParams struct {
+	VotePeriod        uint64         `protobuf:"varint,1,opt,name=vote_period,json=votePeriod,proto3"`
+	Whitelist         []*oracleDenom `protobuf:"bytes,4,rep,name=whitelist,proto3"`
+	SlashFraction     string         `protobuf:"bytes,5,opt,name=slash_fraction,json=slashFraction,proto3"`
+	SlashWindow       uint64         `protobuf:"varint,6,opt,name=slash_window,json=slashWindow,proto3"`
+	MinValidPerWindow string         `protobuf:"bytes,7,opt,name=min_valid_per_window,json=minValidPerWindow,proto3"`
+}
+
+func (m *seiParams) Reset()         { *m = seiParams{} }
+func (m *seiParams) String() string { return fmt.Sprintf("%+v", *m) }
+func (*seiParams) ProtoMessage()    {}
+
+type seiParamsResponse struct {
+	Params *seiParams `protobuf:"bytes,1,opt,name=params,proto3"`
+}
+
+func (m *seiParamsResponse) Reset()         { *m = seiParamsResponse{} }
+func (m *seiParamsResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*seiParamsResponse) ProtoMessage()    {}
+
+type missCounterResponse struct {
+	MissCounter uint64 `protobuf:"varint,1,opt,name=miss_counter,json=missCounter,proto3"`
+}
+
+func (m *missCounterResponse) Reset()         { *m = missCounterResponse{} }
+func (m *missCounterResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*missCounterResponse) ProtoMessage()    {}
+
+type slashWindowResponse struct {
+	WindowProgress uint64 `protobuf:"varint,1,opt,name=window_progress,json=windowProgress,proto3"`
+}
+
+func (m *slashWindowResponse) Reset()         { *m = slashWindowResponse{} }
+func (m *slashWindowResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*slashWindowResponse) ProtoMessage()    {}
+
+type feederDelegationResponse struct {
+	FeederAddr string `protobuf:"bytes,1,opt,name=feeder_addr,json=feederAddr,proto3"`
+}
+
+func (m *feederDelegationResponse) Reset()         { *m = feederDelegationResponse{} }
+func (m *feederDelegationResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*feederDelegationResponse) ProtoMessage()    {}
+
+type aggregatePrevote struct {
+	Hash        string `protobuf:"bytes,1,opt,name=hash,proto3"`
+	Voter       string `protobuf:"bytes,2,opt,name=voter,proto3"`
+	SubmitBlock uint64 `protobuf:"varint,3,opt,name=submit_block,json=submitBlock,proto3"`
+}
+
+func (m *aggregatePrevote) Reset()         { *m = aggregatePrevote{} }
+func (m *aggregatePrevote) String() string { return fmt.Sprintf("%+v", *m) }
+func (*aggregatePrevote) ProtoMessage()    {}
+
+type aggregatePrevoteResponse struct {
+	AggregatePrevote *aggregatePrevote `protobuf:"bytes,1,opt,name=aggregate_prevote,json=aggregatePrevote,proto3"`
+}
+
+func (m *aggregatePrevoteResponse) Reset()         { *m = aggregatePrevoteResponse{} }
+func (m *aggregatePrevoteResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*aggregatePrevoteResponse) ProtoMessage()    {}
+
+type exchangeRateTuple struct {
+	Denom        string `protobuf:"bytes,1,opt,name=denom,proto3"`
+	ExchangeRate string `protobuf:"bytes,2,opt,name=exchange_rate,json=exchangeRate,proto3"`
+}
+
+func (m *exchangeRateTuple) Reset()         { *m = exchangeRateTuple{} }
+func (m *exchangeRateTuple) String() string { return fmt.Sprintf("%+v", *m) }
+func (*exchangeRateTuple) ProtoMessage()    {}
+
+type aggregateVote struct {
+	ExchangeRateTuples []*exchangeRateTuple `protobuf:"bytes,1,rep,name=exchange_rate_tuples,json=exchangeRateTuples,proto3"`
+	Voter              string               `protobuf:"bytes,2,opt,name=voter,proto3"`
+}
+
+func (m *aggregateVote) Reset()         { *m = aggregateVote{} }
+func (m *aggregateVote) String() string { return fmt.Sprintf("%+v", *m) }
+func (*aggregateVote) ProtoMessage()    {}
+
+type aggregateVoteResponse struct {
+	AggregateVote *aggregateVote `protobuf:"bytes,1,opt,name=aggregate_vote,json=aggregateVote,proto3"`
+}
+
+func (m *aggregateVoteResponse) Reset()         { *m = aggregateVoteResponse{} }
+func (m *aggregateVoteResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*aggregateVoteResponse) ProtoMessage()    {}
+
+type decCoin struct {
+	Denom  string `protobuf:"bytes,1,opt,name=denom,proto3"`
+	Amount string `protobuf:"bytes,2,opt,name=amount,proto3"`
+}
+
+func (m *decCoin) Reset()         { *m = decCoin{} }
+func (m *decCoin) String() string { return fmt.Sprintf("%+v", *m) }
+func (*decCoin) ProtoMessage()    {}
+
+type exchangeRatesResponse struct {
+	ExchangeRates []*decCoin `protobuf:"bytes,1,rep,name=exchange_rates,json=exchangeRates,proto3"`
+}
+
+func (m *exchangeRatesResponse) Reset()         { *m = exchangeRatesResponse{} }
+func (m *exchangeRatesResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*exchangeRatesResponse) ProtoMessage()    {}
+
+type seiVotePenaltyCounter struct {
+	MissCount    uint64 `protobuf:"varint,1,opt,name=miss_count,json=missCount,proto3"`
+	AbstainCount uint64 `protobuf:"varint,2,opt,name=abstain_count,json=abstainCount,proto3"`
+	SuccessCount uint64 `protobuf:"varint,3,opt,name=success_count,json=successCount,proto3"`
+}
+
+func (m *seiVotePenaltyCounter) Reset()         { *m = seiVotePenaltyCounter{} }
+func (m *seiVotePenaltyCounter) String() string { return fmt.Sprintf("%+v", *m) }
+func (*seiVotePenaltyCounter) ProtoMessage()    {}
+
+type seiVotePenaltyCounterResponse struct {
+	VotePenaltyCounter *seiVotePenaltyCounter `protobuf:"bytes,1,opt,name=vote_penalty_counter,json=votePenaltyCounter,proto3"`
+}
+
+func (m *seiVotePenaltyCounterResponse) Reset()         { *m = seiVotePenaltyCounterResponse{} }
+func (m *seiVotePenaltyCounterResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*seiVotePenaltyCounterResponse) ProtoMessage()    {}
+
+type seiOracleExchangeRate struct {
+	ExchangeRate        string `protobuf:"bytes,1,opt,name=exchange_rate,json=exchangeRate,proto3"`
+	LastUpdate          string `protobuf:"bytes,2,opt,name=last_update,json=lastUpdate,proto3"`
+	LastUpdateTimestamp int64  `protobuf:"varint,3,opt,name=last_update_timestamp,json=lastUpdateTimestamp,proto3"`
+}
+
+func (m *seiOracleExchangeRate) Reset()         { *m = seiOracleExchangeRate{} }
+func (m *seiOracleExchangeRate) String() string { return fmt.Sprintf("%+v", *m) }
+func (*seiOracleExchangeRate) ProtoMessage()    {}
+
+type seiDenomExchangeRatePair struct {
+	Denom              string                 `protobuf:"bytes,1,opt,name=denom,proto3"`
+	OracleExchangeRate *seiOracleExchangeRate `protobuf:"bytes,2,opt,name=oracle_exchange_rate,json=oracleExchangeRate,proto3"`
+}
+
+func (m *seiDenomExchangeRatePair) Reset()         { *m = seiDenomExchangeRatePair{} }
+func (m *seiDenomExchangeRatePair) String() string { return fmt.Sprintf("%+v", *m) }
+func (*seiDenomExchangeRatePair) ProtoMessage()    {}
+
+type seiExchangeRatesResponse struct {
+	DenomOracleExchangeRatePairs []*seiDenomExchangeRatePair `protobuf:"bytes,1,rep,name=denom_oracle_exchange_rate_pairs,json=denomOracleExchangeRatePairs,proto3"`
+}
+
+func (m *seiExchangeRatesResponse) Reset()         { *m = seiExchangeRatesResponse{} }
+func (m *seiExchangeRatesResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*seiExchangeRatesResponse) ProtoMessage()    {}
+
+type injectivePriceState struct {
+	Price           string `protobuf:"bytes,1,opt,name=price,proto3"`
+	CumulativePrice string `protobuf:"bytes,2,opt,name=cumulative_price,json=cumulativePrice,proto3"`
+	Timestamp       int64  `protobuf:"varint,3,opt,name=timestamp,proto3"`
+}
+
+func (m *injectivePriceState) Reset()         { *m = injectivePriceState{} }
+func (m *injectivePriceState) String() string { return fmt.Sprintf("%+v", *m) }
+func (*injectivePriceState) ProtoMessage()    {}
+
+type injectivePriceFeedState struct {
+	Base       string               `protobuf:"bytes,1,opt,name=base,proto3"`
+	Quote      string               `protobuf:"bytes,2,opt,name=quote,proto3"`
+	PriceState *injectivePriceState `protobuf:"bytes,3,opt,name=price_state,json=priceState,proto3"`
+	Relayers   []string             `protobuf:"bytes,4,rep,name=relayers,proto3"`
+}
+
+func (m *injectivePriceFeedState) Reset()         { *m = injectivePriceFeedState{} }
+func (m *injectivePriceFeedState) String() string { return fmt.Sprintf("%+v", *m) }
+func (*injectivePriceFeedState) ProtoMessage()    {}
+
+type injectivePriceFeedStatesResponse struct {
+	PriceStates []*injectivePriceFeedState `protobuf:"bytes,1,rep,name=price_states,json=priceStates,proto3"`
+}
+
+func (m *injectivePriceFeedStatesResponse) Reset()         { *m = injectivePriceFeedStatesResponse{} }
+func (m *injectivePriceFeedStatesResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*injectivePriceFeedStatesResponse) ProtoMessage()    {}
+
+type injectiveBandPriceState struct {
+	Symbol      string `protobuf:"bytes,1,opt,name=symbol,proto3"`
+	Rate        string `protobuf:"bytes,2,opt,name=rate,proto3"`
+	ResolveTime uint64 `protobuf:"varint,3,opt,name=resolve_time,json=resolveTime,proto3"`
+}
+
+func (m *injectiveBandPriceState) Reset()         { *m = injectiveBandPriceState{} }
+func (m *injectiveBandPriceState) String() string { return fmt.Sprintf("%+v", *m) }
+func (*injectiveBandPriceState) ProtoMessage()    {}
+
+type injectiveBandPriceStatesResponse struct {
+	PriceStates []*injectiveBandPriceState `protobuf:"bytes,1,rep,name=price_states,json=priceStates,proto3"`
+}
+
+func (m *injectiveBandPriceStatesResponse) Reset()         { *m = injectiveBandPriceStatesResponse{} }
+func (m *injectiveBandPriceStatesResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*injectiveBandPriceStatesResponse) ProtoMessage()    {}
+
+type injectiveBandRelayersResponse struct {
+	Relayers []string `protobuf:"bytes,1,rep,name=relayers,proto3"`
+}
+
+func (m *injectiveBandRelayersResponse) Reset()         { *m = injectiveBandRelayersResponse{} }
+func (m *injectiveBandRelayersResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*injectiveBandRelayersResponse) ProtoMessage()    {}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"google.golang.org/grpc"
+)
+
+const seiOracleService = "/seiprotocol.seichain.oracle.Query/"
+
+// SeiOracleProvider serves Sei, which votes without prevotes and tracks
+// misses in a vote penalty counter instead of a plain miss counter.
+type SeiOracleProvider struct {
+	grpcConn *grpc.ClientConn
+}
+
+func NewSeiOracleProvider(grpcConn *grpc.ClientConn) *SeiOracleProvider {
+	return &SeiOracleProvider{grpcConn: grpcConn}
+}
+
+func (p *SeiOracleProvider) Name() string {
+	return ChainTypeSei
+}
+
+func (p *SeiOracleProvider) invoke(ctx context.Context, method string, request interface{}, response interface{}) error {
+	return p.grpcConn.Invoke(ctx, seiOracleService+method, request, response)
+}
+
+func (p *SeiOracleProvider) Params(ctx context.Context) (*OracleParams, error) {
+	response := &seiParamsResponse{}
+	if err := p.invoke(ctx, "Params", &emptyRequest{}, response); err != nil {
+		return nil, err
+	}
+
+	if response.Params == nil {
+		return nil, errors.New("empty oracle params")
+	}
+
+	slashFraction, err := parseProtoDec(response.Params.SlashFraction)
+	if err != nil {
+		return nil, err
+	}
+
+	minValidPerWindow, err := parseProtoDec(response.Params.MinValidPerWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make([]string, len(response.Params.Whitelist))
+	for index, denom := range response.Params.Whitelist {
+		symbols[index] = denom.Name
+	}
+
+	return &OracleParams{
+		VotePeriod:        response.Params.VotePeriod,
+		SlashWindow:       response.Params.SlashWindow,
+		MinValidPerWindow: minValidPerWindow,
+		SlashFraction:     slashFraction,
+		Symbols:           symbols,
+	}, nil
+}
+
+func (p *SeiOracleProvider) SlashWindowProgress(ctx context.Context, params *OracleParams) (uint64, error) {
+	response := &slashWindowResponse{}
+	if err := p.invoke(ctx, "SlashWindow", &emptyRequest{}, response); err != nil {
+		return 0, err
+	}
+
+	return response.WindowProgress, nil
+}
+
+func (p *SeiOracleProvider) MissCounter(ctx context.Context, valoper string) (uint64, error) {
+	counter, err := p.VotePenaltyCounter(ctx, valoper)
+	if err != nil {
+		return 0, err
+	}
+
+	return counter.Misses, nil
+}
+
+func (p *SeiOracleProvider) VotePenaltyCounter(ctx context.Context, valoper string) (*VotePenaltyCounter, error) {
+	response := &seiVotePenaltyCounterResponse{}
+	if err := p.invoke(ctx, "VotePenaltyCounter", &validatorRequest{ValidatorAddr: valoper}, response); err != nil {
+		return nil, err
+	}
+
+	if response.VotePenaltyCounter == nil {
+		return &VotePenaltyCounter{}, nil
+	}
+
+	return &VotePenaltyCounter{
+		Misses:    response.VotePenaltyCounter.MissCount,
+		Abstains:  response.VotePenaltyCounter.AbstainCount,
+		Successes: response.VotePenaltyCounter.SuccessCount,
+	}, nil
+}
+
+func (p *SeiOracleProvider) PerformanceWindow(ctx context.Context, valoper string, params *OracleParams) (*PerformanceWindow, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *SeiOracleProvider) FeederDelegation(ctx context.Context, valoper string) (string, error) {
+	response := &feederDelegationResponse{}
+	if err := p.invoke(ctx, "FeederDelegation", &validatorRequest{ValidatorAddr: valoper}, response); err != nil {
+		return "", err
+	}
+
+	return response.FeederAddr, nil
+}
+
+func (p *SeiOracleProvider) LastPrevoteBlock(ctx context.Context, valoper string) (uint64, error) {
+	return 0, ErrNotSupported
+}
+
+func (p *SeiOracleProvider) VotedDenoms(ctx context.Context, valoper string) ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *SeiOracleProvider) ExchangeRates(ctx context.Context) (map[string]sdk.Dec, error) {
+	response := &seiExchangeRatesResponse{}
+	if err := p.invoke(ctx, "ExchangeRates", &emptyRequest{}, response); err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string]sdk.Dec, len(response.DenomOracleExchangeRatePairs))
+	for _, pair := range response.DenomOracleExchangeRatePairs {
+		if pair.OracleExchangeRate == nil {
+			continue
+		}
+
+		amount, err := parseProtoDec(pair.OracleExchangeRate.ExchangeRate)
+		if err != nil {
+			return nil, err
+		}
+
+		rates[pair.Denom] = amount
+	}
+
+	return rates, nil
+}
+
+func (p *SeiOracleProvider) PriceFeeds(ctx context.Context) ([]PriceFeed, error) {
+	return nil, ErrNotSupported
+}
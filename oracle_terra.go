@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"google.golang.org/grpc"
+)
+
+// TerraOracleProvider serves the Terra classic x/oracle and forks keeping its
+// messages. These don't expose the slash window progress, it is
+// calculated from the latest block height instead.
+type TerraOracleProvider struct {
+	grpcConn *grpc.ClientConn
+	name     string
+	service  string
+}
+
+func NewTerraOracleProvider(grpcConn *grpc.ClientConn, name string, protoPackage string) *TerraOracleProvider {
+	return &TerraOracleProvider{
+		grpcConn: grpcConn,
+		name:     name,
+		service:  "/" + protoPackage + ".Query/",
+	}
+}
+
+func (p *TerraOracleProvider) Name() string {
+	return p.name
+}
+
+func (p *TerraOracleProvider) invoke(ctx context.Context, method string, request interface{}, response interface{}) error {
+	return p.grpcConn.Invoke(ctx, p.service+method, request, response)
+}
+
+func (p *TerraOracleProvider) Params(ctx context.Context) (*OracleParams, error) {
+	response := &terraParamsResponse{}
+	if err := p.invoke(ctx, "Params", &emptyRequest{}, response); err != nil {
+		return nil, err
+	}
+
+	if response.Params == nil {
+		return nil, errors.New("empty oracle params")
+	}
+
+	slashFraction, err := parseProtoDec(response.Params.SlashFraction)
+	if err != nil {
+		return nil, err
+	}
+
+	minValidPerWindow, err := parseProtoDec(response.Params.MinValidPerWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make([]string, len(response.Params.Whitelist))
+	for index, denom := range response.Params.Whitelist {
+		symbols[index] = denom.Name
+	}
+
+	return &OracleParams{
+		VotePeriod:        response.Params.VotePeriod,
+		SlashWindow:       response.Params.SlashWindow,
+		MinValidPerWindow: minValidPerWindow,
+		SlashFraction:     slashFraction,
+		Symbols:           symbols,
+	}, nil
+}
+
+func (p *TerraOracleProvider) SlashWindowProgress(ctx context.Context, params *OracleParams) (uint64, error) {
+	return windowProgressFromHeight(ctx, p.grpcConn, params)
+}
+
+func (p *TerraOracleProvider) MissCounter(ctx context.Context, valoper string) (uint64, error) {
+	response := &missCounterResponse{}
+	if err := p.invoke(ctx, "MissCounter", &validatorRequest{ValidatorAddr: valoper}, response); err != nil {
+		return 0, err
+	}
+
+	return response.MissCounter, nil
+}
+
+func (p *TerraOracleProvider) VotePenaltyCounter(ctx context.Context, valoper string) (*VotePenaltyCounter, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *TerraOracleProvider) PerformanceWindow(ctx context.Context, valoper string, params *OracleParams) (*PerformanceWindow, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *TerraOracleProvider) FeederDelegation(ctx context.Context, valoper string) (string, error) {
+	response := &feederDelegationResponse{}
+	if err := p.invoke(ctx, "FeederDelegation", &validatorRequest{ValidatorAddr: valoper}, response); err != nil {
+		return "", err
+	}
+
+	return response.FeederAddr, nil
+}
+
+func (p *TerraOracleProvider) LastPrevoteBlock(ctx context.Context, valoper string) (uint64, error) {
+	response := &aggregatePrevoteResponse{}
+	if err := p.invoke(ctx, "AggregatePrevote", &validatorRequest{ValidatorAddr: valoper}, response); err != nil {
+		return 0, err
+	}
+
+	if response.AggregatePrevote == nil {
+		return 0, errors.New("no aggregate prevote")
+	}
+
+	return response.AggregatePrevote.SubmitBlock, nil
+}
+
+func (p *TerraOracleProvider) VotedDenoms(ctx context.Context, valoper string) ([]string, error) {
+	response := &aggregateVoteResponse{}
+	if err := p.invoke(ctx, "AggregateVote", &validatorRequest{ValidatorAddr: valoper}, response); err != nil {
+		return nil, err
+	}
+
+	if response.AggregateVote == nil {
+		return []string{}, nil
+	}
+
+	denoms := make([]string, len(response.AggregateVote.ExchangeRateTuples))
+	for index, tuple := range response.AggregateVote.ExchangeRateTuples {
+		denoms[index] = tuple.Denom
+	}
+
+	return denoms, nil
+}
+
+func (p *TerraOracleProvider) ExchangeRates(ctx context.Context) (map[string]sdk.Dec, error) {
+	response := &exchangeRatesResponse{}
+	if err := p.invoke(ctx, "ExchangeRates", &emptyRequest{}, response); err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string]sdk.Dec, len(response.ExchangeRates))
+	for _, rate := range response.ExchangeRates {
+		amount, err := parseProtoDec(rate.Amount)
+		if err != nil {
+			return nil, err
+		}
+
+		rates[rate.Denom] = amount
+	}
+
+	return rates, nil
+}
+
+func (p *TerraOracleProvider) PriceFeeds(ctx context.Context) ([]PriceFeed, error) {
+	return nil, ErrNotSupported
+}
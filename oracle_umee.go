@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	oracletypes "github.com/umee-network/umee/v6/x/oracle/types"
+	"google.golang.org/grpc"
+)
+
+// UmeeOracleProvider serves Umee and Ojo, Ojo's oracle is a fork of Umee's
+// with the same messages under a different proto package.
+type UmeeOracleProvider struct {
+	grpcConn *grpc.ClientConn
+	service  string
+}
+
+func NewUmeeOracleProvider(grpcConn *grpc.ClientConn, protoPackage string) *UmeeOracleProvider {
+	return &UmeeOracleProvider{
+		grpcConn: grpcConn,
+		service:  "/" + protoPackage + ".Query/",
+	}
+}
+
+func (p *UmeeOracleProvider) Name() string {
+	if p.service == "/ojo.oracle.v1.Query/" {
+		return ChainTypeOjo
+	}
+
+	return ChainTypeUmee
+}
+
+func (p *UmeeOracleProvider) invoke(ctx context.Context, method string, request interface{}, response interface{}) error {
+	return p.grpcConn.Invoke(ctx, p.service+method, request, response)
+}
+
+func (p *UmeeOracleProvider) Params(ctx context.Context) (*OracleParams, error) {
+	response := &oracletypes.QueryParamsResponse{}
+	if err := p.invoke(ctx, "Params", &oracletypes.QueryParams{}, response); err != nil {
+		return nil, err
+	}
+
+	symbols := make([]string, len(response.Params.AcceptList))
+	for index, denom := range response.Params.AcceptList {
+		symbols[index] = denom.SymbolDenom
+	}
+
+	return &OracleParams{
+		VotePeriod:        response.Params.VotePeriod,
+		SlashWindow:       response.Params.SlashWindow,
+		MinValidPerWindow: response.Params.MinValidPerWindow,
+		SlashFraction:     response.Params.SlashFraction,
+		RewardBand:        response.Params.RewardBand,
+		Symbols:           symbols,
+	}, nil
+}
+
+func (p *UmeeOracleProvider) SlashWindowProgress(ctx context.Context, params *OracleParams) (uint64, error) {
+	response := &oracletypes.QuerySlashWindowResponse{}
+	if err := p.invoke(ctx, "SlashWindow", &oracletypes.QuerySlashWindow{}, response); err != nil {
+		return 0, err
+	}
+
+	return response.WindowProgress, nil
+}
+
+func (p *UmeeOracleProvider) MissCounter(ctx context.Context, valoper string) (uint64, error) {
+	response := &oracletypes.QueryMissCounterResponse{}
+	if err := p.invoke(ctx, "MissCounter", &oracletypes.QueryMissCounter{ValidatorAddr: valoper}, response); err != nil {
+		return 0, err
+	}
+
+	return response.MissCounter, nil
+}
+
+func (p *UmeeOracleProvider) VotePenaltyCounter(ctx context.Context, valoper string) (*VotePenaltyCounter, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *UmeeOracleProvider) PerformanceWindow(ctx context.Context, valoper string, params *OracleParams) (*PerformanceWindow, error) {
+	if params.VotePeriod == 0 {
+		return nil, errors.New("vote period must be positive")
+	}
+
+	elapsed, err := p.SlashWindowProgress(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	misses, err := p.MissCounter(ctx, valoper)
+	if err != nil {
+		return nil, err
+	}
+
+	size := params.SlashWindow / params.VotePeriod
+
+	// the validator is slashed if (size - misses) / size < min_valid_per_window
+	allowed := sdk.OneDec().Sub(params.MinValidPerWindow).MulInt64(int64(size)).TruncateInt64()
+
+	return &PerformanceWindow{
+		Size:          size,
+		Elapsed:       elapsed,
+		Misses:        misses,
+		AllowedMisses: uint64(allowed),
+	}, nil
+}
+
+func (p *UmeeOracleProvider) FeederDelegation(ctx context.Context, valoper string) (string, error) {
+	response := &oracletypes.QueryFeederDelegationResponse{}
+	if err := p.invoke(ctx, "FeederDelegation", &oracletypes.QueryFeederDelegation{ValidatorAddr: valoper}, response); err != nil {
+		return "", err
+	}
+
+	return response.FeederAddr, nil
+}
+
+func (p *UmeeOracleProvider) LastPrevoteBlock(ctx context.Context, valoper string) (uint64, error) {
+	response := &oracletypes.QueryAggregatePrevoteResponse{}
+	if err := p.invoke(ctx, "AggregatePrevote", &oracletypes.QueryAggregatePrevote{ValidatorAddr: valoper}, response); err != nil {
+		return 0, err
+	}
+
+	return response.AggregatePrevote.SubmitBlock, nil
+}
+
+func (p *UmeeOracleProvider) VotedDenoms(ctx context.Context, valoper string) ([]string, error) {
+	response := &oracletypes.QueryAggregateVoteResponse{}
+	if err := p.invoke(ctx, "AggregateVote", &oracletypes.QueryAggregateVote{ValidatorAddr: valoper}, response); err != nil {
+		return nil, err
+	}
+
+	denoms := make([]string, len(response.AggregateVote.ExchangeRateTuples))
+	for index, tuple := range response.AggregateVote.ExchangeRateTuples {
+		denoms[index] = tuple.Denom
+	}
+
+	return denoms, nil
+}
+
+func (p *UmeeOracleProvider) ExchangeRates(ctx context.Context) (map[string]sdk.Dec, error) {
+	response := &oracletypes.QueryExchangeRatesResponse{}
+	if err := p.invoke(ctx, "ExchangeRates", &oracletypes.QueryExchangeRates{}, response); err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string]sdk.Dec, len(response.ExchangeRates))
+	for _, rate := range response.ExchangeRates {
+		rates[rate.Denom] = rate.Amount
+	}
+
+	return rates, nil
+}
+
+func (p *UmeeOracleProvider) PriceFeeds(ctx context.Context) ([]PriceFeed, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	querytypes "github.com/cosmos/cosmos-sdk/types/query"
+)
+
+// paginate walks a listing page by page: fetch gets the key of the page, nil
+// for the first one, and the page size, and returns the key of the next page,
+// empty on the last one. Listings longer than --max-pages are cut there, which
+// is logged and counted rather than silently returning a partial list.
+func paginate(query string, fetch func(key []byte, limit uint64) ([]byte, error)) error {
+	queries := CurrentConfig().Queries
+
+	var key []byte
+	for page := 1; ; page++ {
+		next, err := fetch(key, queries.PageSize)
+		if err != nil {
+			return err
+		}
+
+		if len(next) == 0 {
+			return nil
+		}
+
+		if queries.MaxPages > 0 && page >= queries.MaxPages {
+			log.Warn().
+				Str("query", query).
+				Int("max-pages", queries.MaxPages).
+				Uint64("page-size", queries.PageSize).
+				Msg("Listing truncated at --max-pages")
+			selfListingsTruncated.WithLabelValues(query).Inc()
+			return nil
+		}
+
+		key = next
+	}
+}
+
+// nextPageKey is the key of the page after the response, empty if it was the last one.
+func nextPageKey(pagination *querytypes.PageResponse) []byte {
+	if pagination == nil {
+		return nil
+	}
+
+	return pagination.NextKey
+}
@@ -0,0 +1,570 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	coinGeckoAPIURL = "https://api.coingecko.com/api/v3"
+	binanceAPIURL   = "https://api.binance.com/api/v3"
+	pythAPIURL      = "https://hermes.pyth.network/v2"
+)
+
+// PriceProvider returns USD prices for the given oracle symbols,
+// symbols it doesn't know are left out of the result.
+type PriceProvider interface {
+	Name() string
+	Prices(ctx context.Context, symbols []string) (map[string]float64, error)
+}
+
+type CoinGeckoProvider struct {
+	// oracle symbol -> coingecko coin id
+	ids    map[string]string
+	apiKey string
+	client *http.Client
+}
+
+func NewCoinGeckoProvider(ids map[string]string, apiKey string) *CoinGeckoProvider {
+	normalized := make(map[string]string, len(ids))
+	for symbol, id := range ids {
+		normalized[strings.ToUpper(symbol)] = id
+	}
+
+	return &CoinGeckoProvider{
+		ids:    normalized,
+		apiKey: apiKey,
+		client: newHTTPClient(10 * time.Second),
+	}
+}
+
+func (p *CoinGeckoProvider) Name() string {
+	return "coingecko"
+}
+
+func (p *CoinGeckoProvider) Prices(ctx context.Context, symbols []string) (map[string]float64, error) {
+	symbolsByID := make(map[string]string)
+	for _, symbol := range symbols {
+		if id, ok := p.ids[symbol]; ok {
+			symbolsByID[id] = symbol
+		}
+	}
+
+	prices := make(map[string]float64)
+	if len(symbolsByID) == 0 {
+		return prices, nil
+	}
+
+	ids := make([]string, 0, len(symbolsByID))
+	for id := range symbolsByID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	query := url.Values{}
+	query.Set("ids", strings.Join(ids, ","))
+	query.Set("vs_currencies", "usd")
+
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["x-cg-demo-api-key"] = p.apiKey
+	}
+
+	var response map[string]map[string]float64
+	if err := getJSON(ctx, p.client, coinGeckoAPIURL+"/simple/price?"+query.Encode(), headers, &response); err != nil {
+		return nil, err
+	}
+
+	for id, price := range response {
+		if usd, ok := price["usd"]; ok {
+			prices[symbolsByID[id]] = usd
+		}
+	}
+
+	return prices, nil
+}
+
+type BinanceProvider struct {
+	quote  string
+	apiKey string
+	client *http.Client
+}
+
+func NewBinanceProvider(quote string, apiKey string) *BinanceProvider {
+	return &BinanceProvider{
+		quote:  strings.ToUpper(quote),
+		apiKey: apiKey,
+		client: newHTTPClient(10 * time.Second),
+	}
+}
+
+func (p *BinanceProvider) Name() string {
+	return "binance"
+}
+
+func (p *BinanceProvider) Prices(ctx context.Context, symbols []string) (map[string]float64, error) {
+	// asking for specific pairs fails the whole request if one of them
+	// isn't listed, so all tickers are fetched and filtered instead
+	var response []struct {
+		Symbol string `json:"symbol"`
+		Price  string `json:"price"`
+	}
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["X-MBX-APIKEY"] = p.apiKey
+	}
+
+	if err := getJSON(ctx, p.client, binanceAPIURL+"/ticker/price", headers, &response); err != nil {
+		return nil, err
+	}
+
+	symbolsByPair := make(map[string]string, len(symbols))
+	for _, symbol := range symbols {
+		symbolsByPair[symbol+p.quote] = symbol
+	}
+
+	prices := make(map[string]float64)
+	for _, ticker := range response {
+		symbol, ok := symbolsByPair[ticker.Symbol]
+		if !ok {
+			continue
+		}
+
+		price, err := strconv.ParseFloat(ticker.Price, 64)
+		if err != nil {
+			continue
+		}
+
+		prices[symbol] = price
+	}
+
+	return prices, nil
+}
+
+type PythProvider struct {
+	// oracle symbol -> pyth price feed id
+	ids    map[string]string
+	apiKey string
+	client *http.Client
+}
+
+func NewPythProvider(ids map[string]string, apiKey string) *PythProvider {
+	normalized := make(map[string]string, len(ids))
+	for symbol, id := range ids {
+		normalized[strings.ToUpper(symbol)] = strings.TrimPrefix(strings.ToLower(id), "0x")
+	}
+
+	return &PythProvider{
+		ids:    normalized,
+		apiKey: apiKey,
+		client: newHTTPClient(10 * time.Second),
+	}
+}
+
+func (p *PythProvider) Name() string {
+	return "pyth"
+}
+
+func (p *PythProvider) Prices(ctx context.Context, symbols []string) (map[string]float64, error) {
+	symbolsByID := make(map[string]string)
+	query := url.Values{}
+	for _, symbol := range symbols {
+		if id, ok := p.ids[symbol]; ok {
+			symbolsByID[id] = symbol
+			query.Add("ids[]", id)
+		}
+	}
+
+	prices := make(map[string]float64)
+	if len(symbolsByID) == 0 {
+		return prices, nil
+	}
+
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+
+	var response struct {
+		Parsed []struct {
+			ID    string `json:"id"`
+			Price struct {
+				Price string `json:"price"`
+				Expo  int    `json:"expo"`
+			} `json:"price"`
+		} `json:"parsed"`
+	}
+	if err := getJSON(ctx, p.client, pythAPIURL+"/updates/price/latest?"+query.Encode(), headers, &response); err != nil {
+		return nil, err
+	}
+
+	for _, feed := range response.Parsed {
+		symbol, ok := symbolsByID[strings.TrimPrefix(strings.ToLower(feed.ID), "0x")]
+		if !ok {
+			continue
+		}
+
+		price, err := strconv.ParseFloat(feed.Price.Price, 64)
+		if err != nil {
+			continue
+		}
+
+		prices[symbol] = price * math.Pow10(feed.Price.Expo)
+	}
+
+	return prices, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with status %s", req.URL.Host, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+type ReferencePrice struct {
+	Price float64
+	// Provider is the provider the price came from, or the providers joined
+	// with "+" when it is the median of a quorum
+	Provider string
+}
+
+// ProviderStatus is the availability of a price provider as of the last refresh.
+type ProviderStatus struct {
+	Up          bool
+	LastSuccess time.Time
+}
+
+type providerPrices struct {
+	prices  map[string]float64
+	updated time.Time
+}
+
+// PriceReference fetches market prices from the configured providers and caches
+// them for ttl so external APIs aren't hit on every scrape. With a quorum of 1 the
+// first provider in order of preference that knows a symbol wins, otherwise the
+// price is the median of the first quorum providers that know it. Prices of a
+// provider that fails are reused for up to maxAge so a rate-limited provider
+// doesn't make the deviation signal flap.
+type PriceReference struct {
+	providers []PriceProvider
+	ttl       time.Duration
+	quorum    int
+	maxAge    time.Duration
+
+	mutex     sync.Mutex
+	prices    map[string]ReferencePrice
+	symbols   string
+	updated   time.Time
+	lastGood  map[string]providerPrices
+	available map[string]ProviderStatus
+	logger    zerolog.Logger
+}
+
+func NewPriceReference(providers []PriceProvider, ttl time.Duration, quorum int, maxAge time.Duration) *PriceReference {
+	if quorum < 1 {
+		quorum = 1
+	}
+
+	return &PriceReference{
+		providers: providers,
+		ttl:       ttl,
+		quorum:    quorum,
+		maxAge:    maxAge,
+		prices:    make(map[string]ReferencePrice),
+		lastGood:  make(map[string]providerPrices),
+		available: make(map[string]ProviderStatus),
+		logger:    log.With().Str("component", "price-reference").Logger(),
+	}
+}
+
+func (p *PriceReference) Prices(ctx context.Context, symbols []string) map[string]ReferencePrice {
+	normalized := make([]string, len(symbols))
+	for index, symbol := range symbols {
+		normalized[index] = strings.ToUpper(symbol)
+	}
+	sort.Strings(normalized)
+	key := strings.Join(normalized, ",")
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if key == p.symbols && time.Since(p.updated) < p.ttl {
+		return p.prices
+	}
+
+	// prices of every provider in order of preference, including still fresh
+	// enough prices of the ones that failed this time
+	results := make([]providerPrices, len(p.providers))
+	for index, provider := range p.providers {
+		queryStart := time.Now()
+		status := p.available[provider.Name()]
+
+		fetched, err := provider.Prices(ctx, normalized)
+		if err != nil {
+			p.logger.Warn().
+				Str("provider", provider.Name()).
+				Err(err).
+				Msg("Could not get reference prices")
+
+			status.Up = false
+			p.available[provider.Name()] = status
+			selfEndpointUp.WithLabelValues(provider.Name()).Set(0)
+
+			if lastGood, ok := p.lastGood[provider.Name()]; ok && time.Since(lastGood.updated) < p.maxAge {
+				results[index] = lastGood
+			}
+			continue
+		}
+
+		p.logger.Debug().
+			Str("provider", provider.Name()).
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying reference prices")
+
+		status.Up = true
+		status.LastSuccess = time.Now()
+		p.available[provider.Name()] = status
+		selfEndpointUp.WithLabelValues(provider.Name()).Set(1)
+
+		results[index] = providerPrices{prices: fetched, updated: time.Now()}
+		p.lastGood[provider.Name()] = results[index]
+	}
+
+	prices := make(map[string]ReferencePrice)
+	for _, symbol := range normalized {
+		var values []float64
+		var names []string
+
+		for index, result := range results {
+			price, ok := result.prices[symbol]
+			if !ok {
+				continue
+			}
+
+			values = append(values, price)
+			names = append(names, p.providers[index].Name())
+
+			if len(values) == p.quorum {
+				break
+			}
+		}
+
+		if len(values) < p.quorum {
+			continue
+		}
+
+		prices[symbol] = ReferencePrice{
+			Price:    median(values),
+			Provider: strings.Join(names, "+"),
+		}
+	}
+
+	p.prices = prices
+	p.symbols = key
+	p.updated = time.Now()
+
+	return prices
+}
+
+// Availability returns the status of every provider as of the last refresh.
+func (p *PriceReference) Availability() map[string]ProviderStatus {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	available := make(map[string]ProviderStatus, len(p.available))
+	for name, status := range p.available {
+		available[name] = status
+	}
+
+	return available
+}
+
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+
+	return sorted[middle]
+}
+
+// ProviderBudget limits the number of requests a provider can make per period,
+// e.g. to stay within the free tier of an API.
+type ProviderBudget struct {
+	Limit  int64
+	Period time.Duration
+}
+
+// ManagedPriceProvider wraps a provider with a request budget and a cache, so
+// the provider is asked less often than the price reference refreshes.
+type ManagedPriceProvider struct {
+	provider PriceProvider
+	budget   ProviderBudget
+	cacheTTL time.Duration
+
+	mutex       sync.Mutex
+	used        int64
+	periodStart time.Time
+	cached      map[string]float64
+	cachedKey   string
+	cachedAt    time.Time
+}
+
+func NewManagedPriceProvider(provider PriceProvider, budget ProviderBudget, cacheTTL time.Duration) *ManagedPriceProvider {
+	if budget.Limit > 0 {
+		selfProviderBudgetRemaining.WithLabelValues(provider.Name()).Set(float64(budget.Limit))
+	}
+
+	return &ManagedPriceProvider{
+		provider:    provider,
+		budget:      budget,
+		cacheTTL:    cacheTTL,
+		periodStart: time.Now(),
+	}
+}
+
+func (p *ManagedPriceProvider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *ManagedPriceProvider) Prices(ctx context.Context, symbols []string) (map[string]float64, error) {
+	key := strings.Join(symbols, ",")
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.cached != nil && key == p.cachedKey && time.Since(p.cachedAt) < p.cacheTTL {
+		return p.cached, nil
+	}
+
+	if p.budget.Limit > 0 {
+		if time.Since(p.periodStart) >= p.budget.Period {
+			p.used = 0
+			p.periodStart = time.Now()
+		}
+
+		if p.used >= p.budget.Limit {
+			return nil, fmt.Errorf("request budget of %d per %s is exhausted", p.budget.Limit, p.budget.Period)
+		}
+
+		p.used++
+		selfProviderBudgetRemaining.WithLabelValues(p.Name()).Set(float64(p.budget.Limit - p.used))
+	}
+
+	selfProviderRequests.WithLabelValues(p.Name()).Inc()
+
+	prices, err := p.provider.Prices(ctx, symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	p.cached = prices
+	p.cachedKey = key
+	p.cachedAt = time.Now()
+
+	return prices, nil
+}
+
+var priceProviderNames = []string{"coingecko", "binance", "pyth"}
+
+func NewPriceProviders(
+	names []string,
+	coinGeckoIDs map[string]string,
+	binanceQuote string,
+	pythIDs map[string]string,
+	apiKeys map[string]string,
+	budgets map[string]int64,
+	budgetPeriod time.Duration,
+	cacheTTLs map[string]string,
+) ([]PriceProvider, error) {
+	providers := make([]PriceProvider, 0, len(names))
+
+	for _, name := range names {
+		name = strings.ToLower(name)
+
+		var provider PriceProvider
+		switch name {
+		case "coingecko":
+			provider = NewCoinGeckoProvider(coinGeckoIDs, apiKeys[name])
+		case "binance":
+			provider = NewBinanceProvider(binanceQuote, apiKeys[name])
+		case "pyth":
+			provider = NewPythProvider(pythIDs, apiKeys[name])
+		default:
+			return nil, fmt.Errorf("unsupported price provider %q", name)
+		}
+
+		cacheTTL, err := priceProviderCacheTTL(name, cacheTTLs)
+		if err != nil {
+			return nil, err
+		}
+
+		budget := ProviderBudget{Limit: budgets[name], Period: budgetPeriod}
+		providers = append(providers, NewManagedPriceProvider(provider, budget, cacheTTL))
+	}
+
+	return providers, nil
+}
+
+// ValidatePriceProviders checks the providers and their settings without
+// creating them, which sets their budgets in the self-metrics.
+func ValidatePriceProviders(names []string, cacheTTLs map[string]string) error {
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if !containsString(priceProviderNames, name) {
+			return fmt.Errorf("unsupported price provider %q", name)
+		}
+
+		if _, err := priceProviderCacheTTL(name, cacheTTLs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func priceProviderCacheTTL(name string, cacheTTLs map[string]string) (time.Duration, error) {
+	value, ok := cacheTTLs[name]
+	if !ok {
+		return 0, nil
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cache ttl of price provider %q: %w", name, err)
+	}
+
+	return ttl, nil
+}
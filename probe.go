@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+)
+
+// probeModules are the collectors a probe can run, selected with ?module=.
+var probeModules = []string{"general", "validators", "gov", "upgrade"}
+
+// probeMaxTargets bounds the connections kept to probe targets, the targets
+// come from the requests.
+const probeMaxTargets = 100
+
+// probeMinReapInterval keeps the reaper from spinning with a tiny idle timeout.
+const probeMinReapInterval = time.Second
+
+// probeTarget is the connection to a probed node and the state kept between
+// its probes, like the balance flows of the feeders.
+type probeTarget struct {
+	conn      *grpc.ClientConn
+	chainType string
+	lastUsed  time.Time
+
+	denoms    *DenomResolver
+	balances  *BalanceTracker
+	streaks   *StreakTracker
+	whitelist *WhitelistTracker
+}
+
+// ProbeTargets dials the nodes given to /probe on demand and keeps the
+// connections for further probes, closing those not probed for idleTimeout.
+type ProbeTargets struct {
+	family      string
+	allowed     []string
+	idleTimeout time.Duration
+	logger      zerolog.Logger
+
+	mutex   sync.Mutex
+	targets map[string]*probeTarget
+}
+
+func NewProbeTargets(family string, allowed []string, idleTimeout time.Duration) *ProbeTargets {
+	return &ProbeTargets{
+		family:      family,
+		allowed:     allowed,
+		idleTimeout: idleTimeout,
+		logger:      log.With().Str("component", "probe").Logger(),
+		targets:     make(map[string]*probeTarget),
+	}
+}
+
+// Allowed checks the target against the patterns of --probe-allowed-targets,
+// no target is allowed if none are set.
+func (p *ProbeTargets) Allowed(target string) bool {
+	for _, pattern := range p.allowed {
+		// exact targets like [::1]:9090 aren't valid patterns
+		if pattern == target {
+			return true
+		}
+		if matched, _ := path.Match(pattern, target); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get returns the connection to the target, dialing it on the first probe.
+// The chain type is detected once per target unless given.
+func (p *ProbeTargets) Get(ctx context.Context, target string, chainType string) (*probeTarget, error) {
+	probe, err := p.target(target)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	probe.lastUsed = time.Now()
+	known := probe.chainType
+	p.mutex.Unlock()
+
+	if chainType == "" || strings.EqualFold(chainType, ChainTypeAuto) {
+		if known != "" {
+			return probe, nil
+		}
+
+		detected, err := DetectChainType(ctx, probe.conn)
+		if err != nil {
+			return nil, err
+		}
+		chainType = detected
+	}
+
+	p.mutex.Lock()
+	probe.chainType = strings.ToLower(chainType)
+	p.mutex.Unlock()
+
+	return probe, nil
+}
+
+// target returns the kept target or dials it, without holding the mutex
+// while dialing.
+func (p *ProbeTargets) target(target string) (*probeTarget, error) {
+	p.mutex.Lock()
+	probe, ok := p.targets[target]
+	full := len(p.targets) >= probeMaxTargets
+	p.mutex.Unlock()
+
+	if ok {
+		return probe, nil
+	}
+	if full {
+		return nil, fmt.Errorf("too many probe targets, %d are kept until idle", probeMaxTargets)
+	}
+
+	conn, err := DialNode(target, p.family, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// another probe of the target dialed it meanwhile
+	if probe, ok := p.targets[target]; ok {
+		conn.Close()
+		return probe, nil
+	}
+	if len(p.targets) >= probeMaxTargets {
+		conn.Close()
+		return nil, fmt.Errorf("too many probe targets, %d are kept until idle", probeMaxTargets)
+	}
+
+	p.logger.Info().Str("target", target).Msg("Dialed probe target")
+
+	denoms := CurrentConfig().Denoms
+	probe = &probeTarget{
+		conn:      conn,
+		lastUsed:  time.Now(),
+		denoms:    NewDenomResolver(denoms.Display, denoms.Exponent, denoms.Precision, denoms.Pack, denoms.Balance),
+		balances:  NewBalanceTracker(nil),
+		streaks:   NewStreakTracker(nil),
+		whitelist: NewWhitelistTracker(),
+	}
+	p.targets[target] = probe
+
+	return probe, nil
+}
+
+// Start closes the connections of the targets no longer probed, e.g. after
+// a relabeling change.
+func (p *ProbeTargets) Start() {
+	interval := p.idleTimeout
+	if interval < probeMinReapInterval {
+		interval = probeMinReapInterval
+	}
+
+	for {
+		<-time.After(interval)
+
+		p.mutex.Lock()
+		for target, probe := range p.targets {
+			if time.Since(probe.lastUsed) < p.idleTimeout {
+				continue
+			}
+
+			probe.conn.Close()
+			delete(p.targets, target)
+			p.logger.Info().Str("target", target).Msg("Closed idle probe target")
+		}
+		p.mutex.Unlock()
+	}
+}
+
+// ProbeCollector exports the result of a probe and the metrics of the
+// collector run on the target, nil if the target couldn't be probed. The
+// probe succeeds if no query of the collector failed.
+type ProbeCollector struct {
+	uncheckedCollector
+
+	collector prometheus.Collector
+	result    *CollectResult
+	start     time.Time
+}
+
+func (c *ProbeCollector) Collect(ch chan<- prometheus.Metric) {
+	probeSuccessGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "probe_success",
+			Help:        "Whether all metrics of the probe target could be collected",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	probeDurationGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "probe_duration_seconds",
+			Help:        "Seconds the probe took",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	// the collector queries the target while collecting
+	if c.collector != nil {
+		c.collector.Collect(ch)
+	}
+	if c.result.Failed() == 0 {
+		probeSuccessGauge.Set(1)
+	}
+
+	probeDurationGauge.Set(time.Since(c.start).Seconds())
+
+	collectMetrics(ch, []prometheus.Collector{probeSuccessGauge, probeDurationGauge})
+}
+
+// ProbeHandler serves the metrics of the node given with ?target=, like the
+// blackbox exporter, so a single exporter can monitor many nodes with the
+// targets set by relabeling. ?validator= is the validator to export the
+// metrics of, ?module= the collector, general by default, and ?chain-type=
+// skips the detection of the chain type.
+func ProbeHandler(w http.ResponseWriter, r *http.Request, probes *ProbeTargets, expectedFeeders map[string]string) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	query := r.URL.Query()
+	target := query.Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	if !probes.Allowed(target) {
+		sublogger.Warn().Str("target", target).Msg("Probe target is not allowed")
+		http.Error(w, fmt.Sprintf("target %q is not allowed", target), http.StatusForbidden)
+		return
+	}
+
+	module := query.Get("module")
+	if module == "" {
+		module = "general"
+	}
+
+	if !containsString(probeModules, module) {
+		http.Error(w, fmt.Sprintf("unknown module %q, expected one of %s", module, strings.Join(probeModules, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	valoper := query.Get("validator")
+	var myAddress sdk.ValAddress
+	if valoper != "" {
+		var err error
+		if myAddress, err = ValAddressFromBech32(valoper); err != nil {
+			http.Error(w, fmt.Sprintf("invalid validator %q: %s", valoper, err), http.StatusBadRequest)
+			return
+		}
+	} else if module == "general" || module == "validators" {
+		http.Error(w, "validator parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	requestedHeight, err := RequestedHeight(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, result := WithCollectResult(r.Context())
+	config := CurrentConfig()
+
+	sublogger.Debug().
+		Str("target", target).
+		Msg("Started probing target")
+
+	var collector prometheus.Collector
+	probe, err := probes.Get(r.Context(), target, query.Get("chain-type"))
+	if err != nil {
+		sublogger.Error().
+			Str("target", target).
+			Err(err).
+			Msg("Could not probe target")
+		result.Fail()
+	} else {
+		switch module {
+		case "general":
+			oracle, err := NewOracleProvider(probe.chainType, probe.conn)
+			if err != nil {
+				sublogger.Error().Err(err).Msg("Could not create oracle provider")
+				result.Fail()
+				break
+			}
+
+			collector = NewGeneralCollector(
+				ctx,
+				probe.conn,
+				oracle,
+				config.Chain.BlockTime,
+				nil,
+				probe.denoms,
+				probe.balances,
+				probe.streaks,
+				config.Metrics.ExportRawAmounts,
+				expectedFeeders,
+				probe.whitelist,
+				valoper,
+				myAddress,
+				DenomOverride{},
+				requestedHeight,
+				sublogger,
+			)
+		case "validators":
+			collector = NewValidatorSetCollector(ctx, probe.conn, valoper, myAddress, sublogger)
+		case "gov":
+			var voter string
+			if valoper != "" {
+				// valoper was decoded above
+				voter, _ = OperatorAccount(valoper)
+			}
+			collector = NewGovernanceCollector(ctx, probe.conn, valoper, voter, sublogger)
+		case "upgrade":
+			collector = NewUpgradeCollector(ctx, probe.conn, config.Chain.BlockTime, sublogger)
+		}
+	}
+
+	registry := collectorRegistry(&ProbeCollector{collector: collector, result: result, start: requestStart})
+
+	// the metrics of other nodes are kept out of the short-term history
+	h := promhttp.HandlerFor(HistoricalGatherer(registry, valoper), promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/probe?target="+target+"&module="+module).
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	rulesGroup  string
+	rulesOutput string
+)
+
+type prometheusRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+type prometheusRuleGroup struct {
+	Name  string           `yaml:"name"`
+	Rules []prometheusRule `yaml:"rules"`
+}
+
+// promDuration formats a duration the way PromQL accepts it, e.g. 1h30m.
+func promDuration(duration time.Duration) string {
+	var result strings.Builder
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{{"d", 24 * time.Hour}, {"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}} {
+		if count := duration / unit.size; count > 0 {
+			result.WriteString(strconv.FormatInt(int64(count), 10) + unit.suffix)
+			duration -= count * unit.size
+		}
+	}
+
+	if result.Len() == 0 {
+		return "0s"
+	}
+
+	return result.String()
+}
+
+// promAlertName turns the name of a config alert, which may be its condition,
+// into a valid Prometheus alert name.
+func promAlertName(name string) string {
+	var result strings.Builder
+	upper := true
+	for _, char := range name {
+		if !unicode.IsLetter(char) && !unicode.IsDigit(char) {
+			upper = true
+			continue
+		}
+
+		if upper {
+			char = unicode.ToUpper(char)
+			upper = false
+		}
+		result.WriteRune(char)
+	}
+
+	alert := result.String()
+	if alert == "" || unicode.IsDigit(rune(alert[0])) {
+		alert = "Alert" + alert
+	}
+
+	return alert
+}
+
+// valoperSelector matches the validators to alert for, all if none are set.
+func valoperSelector(valopers []string) string {
+	if len(valopers) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(`{valoper=~"%s"}`, strings.Join(valopers, "|"))
+}
+
+// PrometheusAlertRule translates an alert rule of the built-in alerting to
+// the PromQL expression of the same condition on the exported metrics.
+func PrometheusAlertRule(rule AlertRule, valopers []string) prometheusRule {
+	threshold := strconv.FormatFloat(rule.Threshold, 'f', -1, 64)
+	selector := valoperSelector(valopers)
+
+	var expr, description string
+	switch rule.Metric {
+	case RuleMetricMissCounter:
+		expr = fmt.Sprintf("%s%s %s %s", CurrentMetricNamer().Name("miss_counter"), selector, rule.Operator, threshold)
+		description = "Miss counter of {{ $labels.valoper }} is {{ $value }}"
+	case RuleMetricMissCounterDelta:
+		expr = fmt.Sprintf("delta(%s%s[%s]) %s %s", CurrentMetricNamer().Name("miss_counter"), selector, promDuration(rule.Window), rule.Operator, threshold)
+		description = fmt.Sprintf("Miss counter of {{ $labels.valoper }} changed by {{ $value }} in %s", promDuration(rule.Window))
+	case RuleMetricJailed:
+		expr = fmt.Sprintf("%s%s %s %s", CurrentMetricNamer().Name("validator_jailed"), selector, rule.Operator, threshold)
+		description = "Validator {{ $labels.valoper }} is jailed"
+	case RuleMetricFeederBalance:
+		// the threshold is in base denom, exported with --export-raw-amounts
+		expr = fmt.Sprintf(`%s{denom=%q} %s %s`, CurrentMetricNamer().Name("feeder_balance_raw"), rule.Denom, rule.Operator, threshold)
+		description = fmt.Sprintf("Feeder {{ $labels.feeder }} has {{ $value }}%s left", rule.Denom)
+	}
+
+	return prometheusRule{
+		Alert:       promAlertName(rule.Name),
+		Expr:        expr,
+		For:         "5m",
+		Labels:      map[string]string{"severity": "critical"},
+		Annotations: map[string]string{"summary": rule.Condition, "description": description},
+	}
+}
+
+// PrometheusRules returns the alerting rules of the exporter's metrics with
+// the configured thresholds: the built-in alerts, the alerts: block of the
+// config file, and node health. The feeder balance rules compare base denom
+// amounts, they are left out unless rawAmounts tells the _raw metrics are
+// exported.
+func PrometheusRules(rules []AlertRule, valopers []string, feederMinBalance uint64, feederDenom string, rawAmounts bool) []prometheusRule {
+	defaults := []AlertRule{
+		{Name: "MissCounterGoingUp", Condition: "miss_counter_delta > 8 in 5m", Metric: RuleMetricMissCounterDelta, Operator: ">", Threshold: 8, Window: 5 * time.Minute},
+		{Name: "ValidatorJailed", Condition: "jailed == 1", Metric: RuleMetricJailed, Operator: "==", Threshold: 1},
+	}
+
+	if feederMinBalance > 0 {
+		defaults = append(defaults, AlertRule{
+			Name:      "FeederLowBalance",
+			Condition: fmt.Sprintf("feeder_balance < %d%s", feederMinBalance, feederDenom),
+			Metric:    RuleMetricFeederBalance,
+			Operator:  "<",
+			Threshold: float64(feederMinBalance),
+			Denom:     feederDenom,
+		})
+	}
+
+	result := make([]prometheusRule, 0, len(defaults)+len(rules)+1)
+	for _, rule := range append(defaults, rules...) {
+		if rule.Metric == RuleMetricFeederBalance && !rawAmounts {
+			log.Warn().
+				Str("rule", rule.Name).
+				Msg("Feeder balance rule left out, it needs feeder_balance_raw exported with --export-raw-amounts")
+			continue
+		}
+
+		result = append(result, PrometheusAlertRule(rule, valopers))
+	}
+
+	result = append(result, prometheusRule{
+		Alert:  "NodeCatchingUp",
+		Expr:   CurrentMetricNamer().Name("node_catching_up") + " == 1",
+		For:    "5m",
+		Labels: map[string]string{"severity": "critical"},
+		Annotations: map[string]string{
+			"summary":     "node is catching up",
+			"description": "The node of {{ $labels.instance }} is syncing, the price feeder can't vote until it's caught up",
+		},
+	})
+
+	return result
+}
+
+func WritePrometheusRules(writer io.Writer, group string, rules []prometheusRule) error {
+	encoder := yaml.NewEncoder(writer)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(map[string][]prometheusRuleGroup{
+		"groups": {{Name: group, Rules: rules}},
+	}); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "Print Prometheus alerting rules for the metrics of the exporter with the configured thresholds",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config := CurrentConfig()
+
+		alertRules, err := ParseAlertRules(config.Alerts.Rules)
+		if err != nil {
+			return err
+		}
+
+		// the rules use the metric names the exporter exports
+		namer, err := NewMetricNamer(config.Metrics.Namespace, config.Metrics.Renames)
+		if err != nil {
+			return err
+		}
+		SetMetricNamer(namer)
+
+		rules := PrometheusRules(alertRules, config.Validators, config.Alerts.FeederMinBalance, config.Alerts.FeederDenom, config.Metrics.ExportRawAmounts)
+		if rulesOutput == "" {
+			return WritePrometheusRules(os.Stdout, rulesGroup, rules)
+		}
+
+		file, err := os.Create(rulesOutput)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if err := WritePrometheusRules(file, rulesGroup, rules); err != nil {
+			return err
+		}
+
+		log.Info().
+			Str("file", rulesOutput).
+			Int("rules", len(rules)).
+			Msg("Wrote alerting rules")
+		return nil
+	},
+}
+
+func init() {
+	rulesCmd.Flags().StringVar(&rulesGroup, "group", "oracle-exporter", "Name of the rule group")
+	rulesCmd.Flags().StringVarP(&rulesOutput, "output", "o", "", "File to write the rules to, stdout by default")
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ProxyDirect is the value of --endpoint-proxies that connects to the
+// endpoint without a proxy.
+const ProxyDirect = "direct"
+
+// ProxyConfig picks the proxy of the outbound connections, to gRPC nodes as
+// well as to the HTTP services (price references, webhooks, notifiers): the
+// proxy set for the endpoint in --endpoint-proxies, else --proxy, else the
+// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables. http, https
+// and socks5 proxies are supported.
+type ProxyConfig struct {
+	proxy *url.URL
+	// host:port or host -> proxy, nil to connect directly
+	endpoints map[string]*url.URL
+}
+
+var proxyConfig = &ProxyConfig{}
+
+// proxyTransport is the transport of the HTTP clients, sharing the
+// connections of every client.
+var proxyTransport = func() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(r *http.Request) (*url.URL, error) {
+		return proxyConfig.URL(r.URL.Host, r.URL.Scheme)
+	}
+	return transport
+}()
+
+// newHTTPClient returns an HTTP client going through the configured proxies
+// and sending the headers of the endpoints, see EndpointAuth.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: &authTransport{next: proxyTransport}}
+}
+
+func NewProxyConfig(proxyURL string, endpoints map[string]string) (*ProxyConfig, error) {
+	config := &ProxyConfig{endpoints: make(map[string]*url.URL, len(endpoints))}
+
+	if proxyURL != "" {
+		parsed, err := parseProxyURL(proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		config.proxy = parsed
+	}
+
+	for endpoint, value := range endpoints {
+		if value == ProxyDirect {
+			config.endpoints[endpoint] = nil
+			continue
+		}
+
+		parsed, err := parseProxyURL(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy of %s: %w", endpoint, err)
+		}
+		config.endpoints[endpoint] = parsed
+	}
+
+	return config, nil
+}
+
+func parseProxyURL(value string) (*url.URL, error) {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parsed.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy %q, expected an http, https or socks5 URL", value)
+	}
+
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("proxy %q has no host", value)
+	}
+
+	return parsed, nil
+}
+
+// URL returns the proxy of the endpoint given as host:port or host, nil to
+// connect directly. scheme is the one the environment variables are looked
+// up for.
+func (p *ProxyConfig) URL(address string, scheme string) (*url.URL, error) {
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+
+	for _, key := range []string{address, host} {
+		if proxyURL, ok := p.endpoints[key]; ok {
+			return proxyURL, nil
+		}
+	}
+
+	if p.proxy != nil {
+		return p.proxy, nil
+	}
+
+	return http.ProxyFromEnvironment(&http.Request{URL: &url.URL{Scheme: scheme, Host: address}})
+}
+
+// dialProxy connects to addr through the proxy, or directly if nil.
+func dialProxy(ctx context.Context, dialer *net.Dialer, network string, proxyURL *url.URL, addr string) (net.Conn, error) {
+	if proxyURL == nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	if proxyURL.Scheme == "socks5" || proxyURL.Scheme == "socks5h" {
+		socks, err := proxy.FromURL(proxyURL, dialer)
+		if err != nil {
+			return nil, err
+		}
+
+		return socks.(proxy.ContextDialer).DialContext(ctx, network, addr)
+	}
+
+	return dialConnect(ctx, dialer, network, proxyURL, addr)
+}
+
+// dialConnect opens a tunnel to addr with an HTTP CONNECT request.
+func dialConnect(ctx context.Context, dialer *net.Dialer, network string, proxyURL *url.URL, addr string) (net.Conn, error) {
+	proxyAddress := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddress = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+
+	conn, err := dialer.DialContext(ctx, network, proxyAddress)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to proxy %s: %w", proxyURL.Host, err)
+	}
+
+	if proxyURL.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused the tunnel to %s: %s", proxyURL.Host, addr, response.Status)
+	}
+
+	// the endpoint may have sent data read along with the response
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+
+	return conn, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/rs/zerolog"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	PushModePushgateway = "pushgateway"
+	PushModeRemoteWrite = "remote-write"
+)
+
+// Pusher pushes the metrics of the exporter to a Pushgateway or a Prometheus
+// remote-write endpoint, for exporters running on machines Prometheus can't
+// reach. The metrics paths are requested internally like by a scrape.
+type Pusher struct {
+	url     string
+	mode    string
+	job     string
+	paths   []string
+	headers map[string]string
+	client  *http.Client
+	logger  zerolog.Logger
+}
+
+func NewPusher(url string, mode string, job string, paths []string, headers map[string]string) (*Pusher, error) {
+	if mode != PushModePushgateway && mode != PushModeRemoteWrite {
+		return nil, fmt.Errorf("unknown push mode %q, expected %s or %s", mode, PushModePushgateway, PushModeRemoteWrite)
+	}
+
+	return &Pusher{
+		url:     url,
+		mode:    mode,
+		job:     job,
+		paths:   paths,
+		headers: headers,
+		client:  newHTTPClient(30 * time.Second),
+		logger:  log.With().Str("component", "pusher").Logger(),
+	}, nil
+}
+
+// Do sends the request with the configured headers, it's the HTTP client of
+// the Pushgateway pushes.
+func (p *Pusher) Do(request *http.Request) (*http.Response, error) {
+	for key, value := range p.headers {
+		request.Header.Set(key, value)
+	}
+
+	return p.client.Do(request)
+}
+
+// Start pushes the paths served by routes every interval.
+func (p *Pusher) Start(routes http.Handler, interval time.Duration) {
+	p.logger.Info().
+		Str("url", p.url).
+		Str("mode", p.mode).
+		Strs("paths", p.paths).
+		Dur("interval", interval).
+		Msg("Started pushing metrics")
+
+	for {
+		for _, path := range p.paths {
+			start := time.Now()
+
+			if err := p.push(routes, path); err != nil {
+				p.logger.Warn().Err(err).Str("path", path).Msg("Could not push metrics")
+				continue
+			}
+
+			p.logger.Debug().
+				Str("path", path).
+				Float64("request-time", time.Since(start).Seconds()).
+				Msg("Pushed metrics")
+		}
+
+		<-time.After(interval)
+	}
+}
+
+func (p *Pusher) push(routes http.Handler, path string) error {
+	gatherer := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return gatherPath(routes, path)
+	})
+
+	if p.mode == PushModePushgateway {
+		// every path is a group of its own, so a push replaces only the
+		// metrics of the same path
+		return push.New(p.url, p.job).
+			Grouping("path", path).
+			Gatherer(gatherer).
+			Client(p).
+			Push()
+	}
+
+	families, err := gatherer.Gather()
+	if err != nil {
+		return err
+	}
+
+	return p.remoteWrite(path, families, time.Now())
+}
+
+// gatherPath requests a metrics path of the exporter internally, without
+// passing the authentication, and parses the response.
+func gatherPath(routes http.Handler, path string) ([]*dto.MetricFamily, error) {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	recorder := httptest.NewRecorder()
+	routes.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", recorder.Code)
+	}
+
+	var parser expfmt.TextParser
+	parsed, err := parser.TextToMetricFamilies(recorder.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	families := make([]*dto.MetricFamily, 0, len(parsed))
+	for _, family := range parsed {
+		families = append(families, family)
+	}
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+
+	return families, nil
+}
+
+type remoteWriteSample struct {
+	labels map[string]string
+	value  float64
+}
+
+func (p *Pusher) remoteWrite(path string, families []*dto.MetricFamily, now time.Time) error {
+	var samples []remoteWriteSample
+	for _, family := range families {
+		for _, sample := range flattenFamily(family) {
+			sample.labels["job"] = p.job
+			sample.labels["path"] = path
+			samples = append(samples, sample)
+		}
+	}
+
+	return sendRemoteWrite(p.Do, p.url, samples, now)
+}
+
+// sendRemoteWrite posts the samples to a Prometheus remote-write endpoint,
+// do sends the request, e.g. with the headers of the endpoint.
+func sendRemoteWrite(do func(*http.Request) (*http.Response, error), url string, samples []remoteWriteSample, now time.Time) error {
+	var request []byte
+	for _, sample := range samples {
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, encodeTimeSeries(sample, now))
+	}
+
+	httpRequest, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(snappy.Encode(nil, request)))
+	if err != nil {
+		return err
+	}
+
+	httpRequest.Header.Set("Content-Type", "application/x-protobuf")
+	httpRequest.Header.Set("Content-Encoding", "snappy")
+	httpRequest.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	response, err := do(httpRequest)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+// flattenFamily turns a metric family into the series Prometheus would store
+// for it, e.g. the _bucket, _sum and _count series of a histogram.
+func flattenFamily(family *dto.MetricFamily) []remoteWriteSample {
+	var samples []remoteWriteSample
+
+	add := func(metric *dto.Metric, suffix string, value float64, extra ...string) {
+		labels := map[string]string{"__name__": family.GetName() + suffix}
+		for _, label := range metric.Label {
+			labels[label.GetName()] = label.GetValue()
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			labels[extra[i]] = extra[i+1]
+		}
+
+		samples = append(samples, remoteWriteSample{labels: labels, value: value})
+	}
+
+	for _, metric := range family.Metric {
+		switch {
+		case metric.Gauge != nil:
+			add(metric, "", metric.Gauge.GetValue())
+		case metric.Counter != nil:
+			add(metric, "", metric.Counter.GetValue())
+		case metric.Untyped != nil:
+			add(metric, "", metric.Untyped.GetValue())
+		case metric.Summary != nil:
+			for _, quantile := range metric.Summary.Quantile {
+				add(metric, "", quantile.GetValue(), "quantile", formatFloat(quantile.GetQuantile()))
+			}
+			add(metric, "_sum", metric.Summary.GetSampleSum())
+			add(metric, "_count", float64(metric.Summary.GetSampleCount()))
+		case metric.Histogram != nil:
+			hasInf := false
+			for _, bucket := range metric.Histogram.Bucket {
+				hasInf = hasInf || math.IsInf(bucket.GetUpperBound(), 1)
+				add(metric, "_bucket", float64(bucket.GetCumulativeCount()), "le", formatFloat(bucket.GetUpperBound()))
+			}
+			if !hasInf {
+				add(metric, "_bucket", float64(metric.Histogram.GetSampleCount()), "le", "+Inf")
+			}
+			add(metric, "_sum", metric.Histogram.GetSampleSum())
+			add(metric, "_count", float64(metric.Histogram.GetSampleCount()))
+		}
+	}
+
+	return samples
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// encodeTimeSeries encodes a prometheus.TimeSeries of the remote-write
+// protocol with a single sample. Labels have to be sorted by name.
+func encodeTimeSeries(sample remoteWriteSample, now time.Time) []byte {
+	names := make([]string, 0, len(sample.labels))
+	for name := range sample.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var series []byte
+	for _, name := range names {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, name)
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, sample.labels[name])
+
+		series = protowire.AppendTag(series, 1, protowire.BytesType)
+		series = protowire.AppendBytes(series, label)
+	}
+
+	var point []byte
+	point = protowire.AppendTag(point, 1, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, math.Float64bits(sample.value))
+	point = protowire.AppendTag(point, 2, protowire.VarintType)
+	point = protowire.AppendVarint(point, uint64(now.UnixMilli()))
+
+	series = protowire.AppendTag(series, 2, protowire.BytesType)
+	series = protowire.AppendBytes(series, point)
+
+	return series
+}
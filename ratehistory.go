@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// RateRecorder downsamples the on-chain exchange rates into daily CSV files
+// and to a Prometheus remote-write endpoint, e.g. of a long-term TSDB like
+// Thanos, Mimir or VictoriaMetrics, so prices can be audited long after the
+// Prometheus retention. The files are append-only and can be synced to
+// remote storage as they are.
+type RateRecorder struct {
+	node      *NodeConnection
+	interval  time.Duration
+	dir       string
+	retention time.Duration
+	// remote-write URL, none if empty
+	remoteWrite   string
+	remoteHeaders map[string]string
+	client        *http.Client
+	logger        zerolog.Logger
+}
+
+func NewRateRecorder(node *NodeConnection, interval time.Duration, dir string, retention time.Duration, remoteWrite string, remoteHeaders map[string]string) *RateRecorder {
+	return &RateRecorder{
+		node:          node,
+		interval:      interval,
+		dir:           dir,
+		retention:     retention,
+		remoteWrite:   remoteWrite,
+		remoteHeaders: remoteHeaders,
+		client:        newHTTPClient(30 * time.Second),
+		logger:        log.With().Str("component", "rate-recorder").Logger(),
+	}
+}
+
+func (r *RateRecorder) Start() {
+	r.logger.Info().
+		Str("dir", r.dir).
+		Str("remote-write", r.remoteWrite).
+		Dur("interval", r.interval).
+		Msg("Started recording exchange rates")
+
+	for {
+		r.record()
+		r.prune()
+
+		// records land on multiples of the interval, e.g. every full 5 minutes
+		now := time.Now()
+		<-time.After(now.Truncate(r.interval).Add(r.interval).Sub(now))
+	}
+}
+
+func (r *RateRecorder) record() {
+	ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+	defer cancel()
+
+	grpcConn := r.node.Get()
+	ctx, height, err := PinHeight(ctx, grpcConn)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("Could not get latest block height")
+		return
+	}
+
+	oracle, err := NewOracleProvider(CurrentConfig().Chain.Type, grpcConn)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("Could not create oracle provider")
+		return
+	}
+
+	exchangeRates, err := oracle.ExchangeRates(ctx)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("Could not get oracle exchange rates")
+		return
+	}
+
+	now := time.Now().UTC()
+	denoms := make([]string, 0, len(exchangeRates))
+	for denom := range exchangeRates {
+		denoms = append(denoms, denom)
+	}
+	sort.Strings(denoms)
+
+	if r.dir != "" {
+		rows := make([][]string, 0, len(denoms))
+		for _, denom := range denoms {
+			rows = append(rows, []string{
+				now.Format(time.RFC3339),
+				strconv.FormatInt(height, 10),
+				denom,
+				exchangeRates[denom].String(),
+			})
+		}
+
+		path := filepath.Join(r.dir, "rates-"+now.Format("2006-01-02")+".csv")
+		if err := appendCSV(path, []string{"time", "height", "denom", "rate"}, rows); err != nil {
+			r.logger.Error().Err(err).Str("file", path).Msg("Could not record exchange rates")
+			return
+		}
+	}
+
+	if r.remoteWrite != "" {
+		// a series of its own, the scraped oracle_exchange_rate may land in
+		// the same TSDB
+		name := CurrentMetricNamer().Name("oracle_exchange_rate_history")
+		samples := make([]remoteWriteSample, 0, len(denoms))
+		for _, denom := range denoms {
+			labels := map[string]string{"__name__": name, "job": "oracle-exporter", "denom": denom}
+			for key, value := range constLabels() {
+				labels[key] = value
+			}
+
+			value, err := exchangeRates[denom].Float64()
+			if err != nil {
+				continue
+			}
+			samples = append(samples, remoteWriteSample{labels: labels, value: value})
+		}
+
+		if err := sendRemoteWrite(r.do, r.remoteWrite, samples, now); err != nil {
+			r.logger.Error().Err(err).Str("url", r.remoteWrite).Msg("Could not write exchange rates to remote storage")
+			return
+		}
+	}
+
+	r.logger.Debug().
+		Int64("height", height).
+		Int("denoms", len(denoms)).
+		Msg("Recorded exchange rates")
+}
+
+// do sends a remote-write request with the configured headers.
+func (r *RateRecorder) do(request *http.Request) (*http.Response, error) {
+	for key, value := range r.remoteHeaders {
+		request.Header.Set(key, value)
+	}
+
+	return r.client.Do(request)
+}
+
+// prune removes the daily files older than the retention.
+func (r *RateRecorder) prune() {
+	if r.dir == "" || r.retention <= 0 {
+		return
+	}
+
+	paths, err := filepath.Glob(filepath.Join(r.dir, "rates-*.csv"))
+	if err != nil {
+		return
+	}
+
+	for _, path := range paths {
+		day, err := time.Parse("2006-01-02", strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "rates-"), ".csv"))
+		if err != nil || time.Since(day.Add(24*time.Hour)) < r.retention {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			r.logger.Error().Err(err).Str("file", path).Msg("Could not remove old exchange rates")
+		}
+	}
+}
+
+// appendCSV appends the rows to the file, writing the header first if the
+// file is new.
+func appendCSV(path string, header []string, rows [][]string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	if info.Size() == 0 {
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// QueryRateLimiter bounds the rate of the gRPC queries sent to the nodes, in
+// total and per endpoint, so an aggressive scrape config can't get the API
+// keys of a managed provider banned. Queries wait for their turn within the
+// scrape deadline, answers of the cache and of identical queries in flight
+// don't count, every retry and every query sent in a --batch-rpc batch does.
+type QueryRateLimiter struct {
+	global    *TokenBucket
+	endpoints map[string]*TokenBucket
+}
+
+var queryRateLimiter = &QueryRateLimiter{global: NewTokenBucket(0, 1)}
+
+// NewQueryRateLimiter limits all queries to rate per second and the queries
+// of the endpoints to theirs, a non-positive rate means no limit. Up to burst
+// queries are sent at once after a quiet period.
+func NewQueryRateLimiter(rate float64, burst int, endpointRates map[string]string) (*QueryRateLimiter, error) {
+	if burst < 1 {
+		return nil, fmt.Errorf("burst has to be at least 1, got %d", burst)
+	}
+
+	limiter := &QueryRateLimiter{
+		global:    NewTokenBucket(rate, burst),
+		endpoints: make(map[string]*TokenBucket, len(endpointRates)),
+	}
+
+	for endpoint, value := range endpointRates {
+		endpointRate, err := strconv.ParseFloat(value, 64)
+		if err != nil || endpointRate <= 0 {
+			return nil, fmt.Errorf("invalid rate %q of %s, expected queries per second like 10", value, endpoint)
+		}
+
+		limiter.endpoints[endpoint] = NewTokenBucket(endpointRate, burst)
+	}
+
+	return limiter, nil
+}
+
+// Interceptor waits for the global and the endpoint limits before a query is sent.
+func (l *QueryRateLimiter) Interceptor(endpoint string) grpc.UnaryClientInterceptor {
+	buckets := []*TokenBucket{l.global}
+	if bucket, ok := l.endpoints[endpoint]; ok {
+		buckets = append(buckets, bucket)
+	}
+
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		for index, bucket := range buckets {
+			if bucket.Allow() {
+				continue
+			}
+
+			selfQueriesRateLimited.WithLabelValues(endpoint).Inc()
+			if err := bucket.Wait(ctx); err != nil {
+				// the query isn't sent, the tokens already taken for it go back
+				for _, taken := range buckets[:index] {
+					taken.Release()
+				}
+				return status.Errorf(codes.DeadlineExceeded, "rate limit of %s: %v", endpoint, err)
+			}
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
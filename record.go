@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+const recordingFile = "responses.jsonl"
+
+// recordedResponse is one line of the recording, the messages are kept in
+// their wire format so any message type can be replayed.
+type recordedResponse struct {
+	Method     string    `json:"method"`
+	Request    string    `json:"request"`
+	Response   string    `json:"response"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Recording captures the gRPC responses of the node to a directory, or
+// serves them back from it instead of querying the node, so a problem seen
+// on a chain at some height can be reproduced later.
+type Recording struct {
+	replay bool
+
+	mutex     sync.Mutex
+	file      *os.File
+	responses map[string]string
+}
+
+// upstreamRecording is set with --record-dir or --replay-dir.
+var upstreamRecording *Recording
+
+func NewRecorder(dir string) (*Recording, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, recordingFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recording{file: file}, nil
+}
+
+func NewReplayer(dir string) (*Recording, error) {
+	file, err := os.Open(filepath.Join(dir, recordingFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	responses := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		var response recordedResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			return nil, err
+		}
+
+		// the latest recording of the same request wins
+		responses[response.Method+" "+response.Request] = response.Response
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(responses) == 0 {
+		return nil, errors.New("the recording is empty")
+	}
+
+	return &Recording{replay: true, responses: responses}, nil
+}
+
+func (rec *Recording) Interceptor() grpc.UnaryClientInterceptor {
+	codec := encoding.GetCodec("proto")
+
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		requestBytes, err := codec.Marshal(req)
+		if err != nil {
+			return err
+		}
+		request := base64.StdEncoding.EncodeToString(requestBytes)
+
+		if rec.replay {
+			rec.mutex.Lock()
+			response, ok := rec.responses[method+" "+request]
+			rec.mutex.Unlock()
+
+			if !ok {
+				return status.Errorf(codes.NotFound, "no recorded response for %s", method)
+			}
+
+			responseBytes, err := base64.StdEncoding.DecodeString(response)
+			if err != nil {
+				return err
+			}
+
+			return codec.Unmarshal(responseBytes, reply)
+		}
+
+		if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+			return err
+		}
+
+		responseBytes, err := codec.Marshal(reply)
+		if err != nil {
+			return err
+		}
+
+		line, err := json.Marshal(recordedResponse{
+			Method:     method,
+			Request:    request,
+			Response:   base64.StdEncoding.EncodeToString(responseBytes),
+			RecordedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+
+		rec.mutex.Lock()
+		defer rec.mutex.Unlock()
+
+		if _, err := rec.file.Write(append(line, '\n')); err != nil {
+			log.Error().Err(err).Str("method", method).Msg("Could not record response")
+		}
+
+		return nil
+	}
+}
+
+func (rec *Recording) Close() error {
+	if rec.file == nil {
+		return nil
+	}
+
+	return rec.file.Close()
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type ValidatorReport struct {
+	Valoper string
+	Samples int
+	// Misses is the sum of the miss counter increases, the counter
+	// starts over at every slash window
+	Misses        uint64
+	JailedPercent float64
+	First         time.Time
+	Last          time.Time
+}
+
+// Report is the performance of the watched validators over one month,
+// calculated from the history in the state file.
+type Report struct {
+	Month       time.Time
+	GeneratedAt time.Time
+	Validators  []ValidatorReport
+}
+
+func NewReport(history map[string][]HistorySample, month time.Time) *Report {
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	report := &Report{
+		Month:       start,
+		GeneratedAt: time.Now().UTC(),
+		Validators:  make([]ValidatorReport, 0, len(history)),
+	}
+
+	for valoper, samples := range history {
+		validator := ValidatorReport{Valoper: valoper}
+
+		var previous *HistorySample
+		var jailed int
+		for index := range samples {
+			sample := samples[index]
+			if sample.Time.Before(start) || !sample.Time.Before(end) {
+				continue
+			}
+
+			if validator.Samples == 0 {
+				validator.First = sample.Time
+			}
+			validator.Last = sample.Time
+			validator.Samples++
+
+			if sample.Jailed {
+				jailed++
+			}
+
+			if previous != nil {
+				validator.Misses += missCounterIncrease(previous.MissCounter, sample.MissCounter)
+			}
+			previous = &samples[index]
+		}
+
+		if validator.Samples == 0 {
+			continue
+		}
+
+		validator.JailedPercent = float64(jailed) / float64(validator.Samples) * 100
+		report.Validators = append(report.Validators, validator)
+	}
+
+	sort.Slice(report.Validators, func(i, j int) bool {
+		return report.Validators[i].Valoper < report.Validators[j].Valoper
+	})
+
+	return report
+}
+
+// missCounterIncrease returns the misses between two samples, a lower
+// counter means a new slash window has started in between.
+func missCounterIncrease(previous uint64, current uint64) uint64 {
+	if current >= previous {
+		return current - previous
+	}
+
+	return current
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Oracle performance report {{ .Month.Format "January 2006" }}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 0.4em 0.8em; text-align: left; }
+th { background: #f2f2f2; }
+td.number { text-align: right; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>Oracle performance report {{ .Month.Format "January 2006" }}</h1>
+<p>Generated {{ .GeneratedAt.Format "2006-01-02 15:04 MST" }}</p>
+{{ if .Validators }}
+<table>
+<tr><th>Validator</th><th>Missed votes</th><th>Time jailed</th><th>Samples</th><th>Period covered</th></tr>
+{{ range .Validators }}
+<tr>
+<td>{{ .Valoper }}</td>
+<td class="number">{{ .Misses }}</td>
+<td class="number">{{ printf "%.2f" .JailedPercent }}%</td>
+<td class="number">{{ .Samples }}</td>
+<td>{{ .First.Format "2006-01-02 15:04" }} – {{ .Last.Format "2006-01-02 15:04" }}</td>
+</tr>
+{{ end }}
+</table>
+{{ else }}
+<p>No history was recorded for this month.</p>
+{{ end }}
+</body>
+</html>
+`))
+
+func (r *Report) Render(writer io.Writer) error {
+	return reportTemplate.Execute(writer, r)
+}
+
+func WriteReportFile(path string, report *Report) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := report.Render(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+var (
+	reportMonth  string
+	reportOutput string
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Render the monthly HTML performance report from the state file history",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stateFile := CurrentConfig().Endpoints.StateFile
+		if stateFile == "" {
+			return errors.New("--state-file is not set")
+		}
+
+		month := time.Now().UTC().AddDate(0, -1, 0)
+		if reportMonth != "" {
+			parsed, err := time.Parse("2006-01", reportMonth)
+			if err != nil {
+				return err
+			}
+			month = parsed
+		}
+
+		snapshot, err := LoadState(stateFile)
+		if err != nil {
+			return err
+		}
+
+		report := NewReport(snapshot.History, month)
+		if reportOutput == "" {
+			return report.Render(os.Stdout)
+		}
+
+		if err := WriteReportFile(reportOutput, report); err != nil {
+			return err
+		}
+
+		log.Info().
+			Str("file", reportOutput).
+			Int("validators", len(report.Validators)).
+			Msg("Rendered report")
+		return nil
+	},
+}
+
+func init() {
+	reportCmd.Flags().StringVar(&reportMonth, "month", "", "Month to report on as YYYY-MM, the previous month by default")
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "", "File to write the report to, stdout by default")
+}
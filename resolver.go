@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/resolver"
+)
+
+const reresolveScheme = "reresolve"
+
+// reresolveBuilder builds resolvers that look the endpoint hostname up again
+// every interval and whenever gRPC reports a connection failure, so DNS-based
+// failover between RPC providers is picked up without a restart.
+type reresolveBuilder struct {
+	interval time.Duration
+	network  string
+}
+
+func newReresolveBuilder(interval time.Duration, family string) (*reresolveBuilder, error) {
+	network, err := networkForFamily(family)
+	if err != nil {
+		return nil, err
+	}
+
+	// tcp/tcp4/tcp6 -> ip/ip4/ip6 for net.Resolver.LookupIP
+	return &reresolveBuilder{interval: interval, network: "ip" + network[3:]}, nil
+}
+
+func (b *reresolveBuilder) Scheme() string {
+	return reresolveScheme
+}
+
+func (b *reresolveBuilder) Build(
+	target resolver.Target,
+	cc resolver.ClientConn,
+	opts resolver.BuildOptions,
+) (resolver.Resolver, error) {
+	host, port, err := net.SplitHostPort(target.Endpoint())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &reresolveResolver{
+		host:       host,
+		port:       port,
+		network:    b.network,
+		interval:   b.interval,
+		cc:         cc,
+		resolveNow: make(chan struct{}, 1),
+		ctx:        ctx,
+		cancel:     cancel,
+		logger:     log.With().Str("component", "resolver").Str("host", host).Logger(),
+	}
+
+	r.wg.Add(1)
+	go r.watch()
+
+	return r, nil
+}
+
+type reresolveResolver struct {
+	host     string
+	port     string
+	network  string
+	interval time.Duration
+
+	cc         resolver.ClientConn
+	resolveNow chan struct{}
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	logger     zerolog.Logger
+}
+
+func (r *reresolveResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolveNow <- struct{}{}:
+	default:
+	}
+}
+
+func (r *reresolveResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *reresolveResolver) watch() {
+	defer r.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-timer.C:
+		case <-r.resolveNow:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+
+		r.resolve()
+		timer.Reset(r.interval)
+	}
+}
+
+func (r *reresolveResolver) resolve() {
+	ips, err := net.DefaultResolver.LookupIP(r.ctx, r.network, r.host)
+	if err != nil {
+		r.logger.Warn().Err(err).Msg("Could not resolve endpoint hostname")
+		r.cc.ReportError(err)
+		return
+	}
+
+	addresses := make([]resolver.Address, len(ips))
+	for index, ip := range ips {
+		addresses[index] = resolver.Address{Addr: net.JoinHostPort(ip.String(), r.port)}
+	}
+
+	r.logger.Trace().Int("addresses", len(addresses)).Msg("Resolved endpoint hostname")
+
+	if err := r.cc.UpdateState(resolver.State{Addresses: addresses}); err != nil {
+		r.logger.Debug().Err(err).Msg("Could not update resolver state")
+	}
+}
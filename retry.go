@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy retries gRPC queries failing with transient errors, so a
+// hiccup of a public endpoint doesn't turn into an empty scrape.
+type RetryPolicy struct {
+	mutex      sync.Mutex
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	// random share the backoff is shortened or lengthened by
+	jitter float64
+	codes  map[codes.Code]bool
+	// timeout of every attempt, 0 for none
+	timeout time.Duration
+}
+
+// retryPolicy is shared by every connection to the node, like the query cache.
+var retryPolicy = &RetryPolicy{attempts: 1}
+
+// Set replaces the policy, the codes are given by name, e.g. Unavailable.
+func (p *RetryPolicy) Set(
+	attempts int,
+	backoff time.Duration,
+	maxBackoff time.Duration,
+	jitter float64,
+	codeNames []string,
+	timeout time.Duration,
+) error {
+	if attempts < 1 {
+		return fmt.Errorf("retry attempts has to be at least 1, got %d", attempts)
+	}
+
+	if jitter < 0 || jitter > 1 {
+		return fmt.Errorf("retry jitter has to be between 0 and 1, got %v", jitter)
+	}
+
+	retryable := make(map[codes.Code]bool, len(codeNames))
+	for _, name := range codeNames {
+		code, ok := parseCode(name)
+		if !ok {
+			return fmt.Errorf("unknown gRPC code %q", name)
+		}
+
+		retryable[code] = true
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.attempts = attempts
+	p.backoff = backoff
+	p.maxBackoff = maxBackoff
+	p.jitter = jitter
+	p.codes = retryable
+	p.timeout = timeout
+
+	return nil
+}
+
+func parseCode(name string) (codes.Code, bool) {
+	name = strings.ReplaceAll(name, "_", "")
+	for code := codes.OK; code <= codes.Unauthenticated; code++ {
+		if strings.EqualFold(code.String(), name) {
+			return code, true
+		}
+	}
+
+	return 0, false
+}
+
+// delay returns the backoff before the given retry, starting at 1.
+func (p *RetryPolicy) delay(retry int) time.Duration {
+	delay := p.backoff << (retry - 1)
+	if delay > p.maxBackoff || delay <= 0 {
+		delay = p.maxBackoff
+	}
+
+	if p.jitter > 0 {
+		delay = time.Duration(float64(delay) * (1 + p.jitter*(2*rand.Float64()-1)))
+	}
+
+	return delay
+}
+
+func (p *RetryPolicy) Interceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		p.mutex.Lock()
+		attempts := p.attempts
+		retryable := p.codes
+		timeout := p.timeout
+		p.mutex.Unlock()
+
+		var err error
+		for attempt := 1; ; attempt++ {
+			err = invokeWithTimeout(ctx, timeout, method, req, reply, cc, invoker, opts...)
+			if err == nil || attempt >= attempts || !retryable[status.Code(err)] {
+				return err
+			}
+
+			p.mutex.Lock()
+			delay := p.delay(attempt)
+			p.mutex.Unlock()
+
+			log.Debug().
+				Str("method", method).
+				Int("attempt", attempt).
+				Dur("backoff", delay).
+				Err(err).
+				Msg("Retrying gRPC query")
+
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(delay):
+			}
+		}
+	}
+}
+
+// invokeWithTimeout bounds a single attempt, a hung endpoint then fails the
+// query with DeadlineExceeded, which is retried like other transient errors.
+func invokeWithTimeout(
+	ctx context.Context,
+	timeout time.Duration,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	if timeout <= 0 {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Router holds the routes of the exporter. Scrape routes are named after
+// their collector, which keys their self-metrics and their timeout, and
+// address routes like /metrics/oracle/{address} take the address from the
+// path instead of the query. The internal requests of push mode and of the
+// sampling go to the Router itself, without the middlewares.
+type Router struct {
+	mux         *http.ServeMux
+	timeouts    map[string]time.Duration
+	middlewares []func(http.Handler) http.Handler
+}
+
+func NewRouter(timeouts map[string]time.Duration) *Router {
+	return &Router{
+		mux:      http.NewServeMux(),
+		timeouts: timeouts,
+	}
+}
+
+// ParseRouteTimeouts parses the --route-timeouts map of a scrape route name
+// to its timeout.
+func ParseRouteTimeouts(values map[string]string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration, len(values))
+	for name, value := range values {
+		if name != "probe" && !containsString(collectorNames, name) {
+			return nil, fmt.Errorf("unknown route %q, expected probe or one of %v", name, collectorNames)
+		}
+
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q of route %s, expected e.g. 10s", value, name)
+		}
+
+		timeouts[name] = timeout
+	}
+
+	return timeouts, nil
+}
+
+// Use adds a middleware around the routes, the first one added is the outermost.
+func (r *Router) Use(middleware func(http.Handler) http.Handler) {
+	r.middlewares = append(r.middlewares, middleware)
+}
+
+// Handler returns the routes wrapped in the middlewares, for the servers.
+func (r *Router) Handler() http.Handler {
+	var handler http.Handler = r.mux
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// ServeHTTP serves the routes without the middlewares.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.ServeHTTP(w, req)
+}
+
+func (r *Router) Handle(pattern string, handler http.Handler) {
+	r.mux.Handle(pattern, handler)
+}
+
+func (r *Router) HandleFunc(pattern string, handler http.HandlerFunc) {
+	r.mux.HandleFunc(pattern, handler)
+}
+
+// Scrape registers the metrics handler of the named collector, bounded by
+// the timeout of the route or --scrape-timeout.
+func (r *Router) Scrape(pattern string, name string, handler http.HandlerFunc) {
+	r.mux.HandleFunc(pattern, instrumentHandler(name, r.timeout(name), handler))
+}
+
+// ScrapeAddress registers prefix/{address} for the metrics handler of the
+// named collector, which gets the address as the query parameter param as if
+// it was requested as ?param=address.
+func (r *Router) ScrapeAddress(prefix string, param string, name string, handler http.HandlerFunc) {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+
+	r.Scrape(prefix, name, func(w http.ResponseWriter, req *http.Request) {
+		address := strings.TrimPrefix(req.URL.Path, prefix)
+		if address == "" || strings.Contains(address, "/") {
+			http.NotFound(w, req)
+			return
+		}
+
+		query := req.URL.Query()
+		query.Set(param, address)
+
+		req = req.Clone(req.Context())
+		req.URL.RawQuery = query.Encode()
+
+		handler(w, req)
+	})
+}
+
+func (r *Router) timeout(name string) time.Duration {
+	if timeout, ok := r.timeouts[name]; ok {
+		return timeout
+	}
+
+	return CurrentConfig().Queries.ScrapeTimeout
+}
+
+// statusRecorder keeps the status code the handler answered with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// LoggingMiddleware logs every request served to a client at debug level and
+// counts it in http_requests_total. The path label is the route pattern, so
+// address routes don't create a series per address.
+func (r *Router) LoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, req)
+
+		_, pattern := r.mux.Handler(req)
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		selfHTTPRequests.WithLabelValues(pattern, strconv.Itoa(recorder.status)).Inc()
+
+		log.Debug().
+			Str("remote-address", req.RemoteAddr).
+			Str("method", req.Method).
+			Str("endpoint", req.URL.Path).
+			Int("status", recorder.status).
+			Float64("request-time", time.Since(start).Seconds()).
+			Msg("Request served")
+	})
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/rs/zerolog"
+)
+
+const (
+	RuleMetricMissCounter      = "miss_counter"
+	RuleMetricMissCounterDelta = "miss_counter_delta"
+	RuleMetricJailed           = "jailed"
+	RuleMetricFeederBalance    = "feeder_balance"
+)
+
+// AlertRuleConfig is an entry of the alerts: block in the config file.
+type AlertRuleConfig struct {
+	Name      string   `mapstructure:"name"`
+	Condition string   `mapstructure:"condition"`
+	Notifiers []string `mapstructure:"notifiers"`
+}
+
+// AlertRule is a parsed condition like "miss_counter_delta > 5 in 1h"
+// or "feeder_balance < 1000000uumee".
+type AlertRule struct {
+	Name      string
+	Condition string
+	Metric    string
+	Operator  string
+	Threshold float64
+	// base denom of the threshold, only for feeder_balance
+	Denom string
+	// only for miss_counter_delta
+	Window    time.Duration
+	Notifiers []string
+}
+
+var ruleConditionRegexp = regexp.MustCompile(
+	`^([a-z_]+)\s*(>=|<=|==|!=|>|<)\s*([0-9]+(?:\.[0-9]+)?)([a-zA-Z][a-zA-Z0-9/]*)?(?:\s+in\s+(\S+))?$`,
+)
+
+func ParseAlertRule(config AlertRuleConfig) (AlertRule, error) {
+	condition := strings.TrimSpace(config.Condition)
+	match := ruleConditionRegexp.FindStringSubmatch(condition)
+	if match == nil {
+		return AlertRule{}, fmt.Errorf("alert %q: could not parse condition %q", config.Name, config.Condition)
+	}
+
+	threshold, err := strconv.ParseFloat(match[3], 64)
+	if err != nil {
+		return AlertRule{}, fmt.Errorf("alert %q: invalid threshold: %w", config.Name, err)
+	}
+
+	rule := AlertRule{
+		Name:      config.Name,
+		Condition: condition,
+		Metric:    match[1],
+		Operator:  match[2],
+		Threshold: threshold,
+		Denom:     match[4],
+	}
+
+	if rule.Name == "" {
+		rule.Name = condition
+	}
+
+	for _, notifier := range config.Notifiers {
+		rule.Notifiers = append(rule.Notifiers, strings.ToLower(notifier))
+	}
+
+	if match[5] != "" {
+		rule.Window, err = time.ParseDuration(match[5])
+		if err != nil {
+			return AlertRule{}, fmt.Errorf("alert %q: invalid window: %w", config.Name, err)
+		}
+	}
+
+	switch rule.Metric {
+	case RuleMetricMissCounter, RuleMetricJailed:
+	case RuleMetricMissCounterDelta:
+		if rule.Window <= 0 {
+			return AlertRule{}, fmt.Errorf("alert %q: %s needs a window, e.g. \"in 1h\"", config.Name, rule.Metric)
+		}
+	case RuleMetricFeederBalance:
+		if rule.Denom == "" {
+			return AlertRule{}, fmt.Errorf("alert %q: %s needs a denom, e.g. 1000000uumee", config.Name, rule.Metric)
+		}
+	default:
+		return AlertRule{}, fmt.Errorf("alert %q: unknown metric %q", config.Name, rule.Metric)
+	}
+
+	if rule.Denom != "" && rule.Metric != RuleMetricFeederBalance {
+		return AlertRule{}, fmt.Errorf("alert %q: %s takes no denom", config.Name, rule.Metric)
+	}
+
+	return rule, nil
+}
+
+// ParseAlertRules parses the rules of the alerts: block of the config file.
+func ParseAlertRules(configs []AlertRuleConfig) ([]AlertRule, error) {
+	rules := make([]AlertRule, 0, len(configs))
+	for _, config := range configs {
+		rule, err := ParseAlertRule(config)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func (r AlertRule) Matches(value float64) bool {
+	switch r.Operator {
+	case ">":
+		return value > r.Threshold
+	case ">=":
+		return value >= r.Threshold
+	case "<":
+		return value < r.Threshold
+	case "<=":
+		return value <= r.Threshold
+	case "==":
+		return value == r.Threshold
+	case "!=":
+		return value != r.Threshold
+	}
+
+	return false
+}
+
+type missCounterSample struct {
+	time  time.Time
+	value uint64
+}
+
+// RuleEngine evaluates the configured alert rules for the watched validators
+// on its own ticker, independent of Prometheus scrapes.
+type RuleEngine struct {
+	node       *NodeConnection
+	dispatcher *AlertDispatcher
+
+	// settings below can be changed on config reload
+	rules    []AlertRule
+	valopers []string
+	interval time.Duration
+
+	mutex sync.Mutex
+	// miss counters of the longest window of the rules, per valoper
+	samples map[string][]missCounterSample
+	logger  zerolog.Logger
+}
+
+func NewRuleEngine(
+	node *NodeConnection,
+	dispatcher *AlertDispatcher,
+	rules []AlertRule,
+	valopers []string,
+	interval time.Duration,
+) *RuleEngine {
+	return &RuleEngine{
+		node:       node,
+		dispatcher: dispatcher,
+		rules:      rules,
+		valopers:   valopers,
+		interval:   interval,
+		samples:    make(map[string][]missCounterSample),
+		logger:     log.With().Str("component", "rule-engine").Logger(),
+	}
+}
+
+func (e *RuleEngine) Start() {
+	e.logger.Info().
+		Int("rules", len(e.rules)).
+		Dur("interval", e.interval).
+		Msg("Started alert rule engine")
+
+	for {
+		e.check()
+
+		e.mutex.Lock()
+		interval := e.interval
+		e.mutex.Unlock()
+
+		<-time.After(interval)
+	}
+}
+
+// Update applies rules and settings from a reloaded config, rules that keep
+// their name keep firing state so no duplicate alerts are sent.
+func (e *RuleEngine) Update(rules []AlertRule, valopers []string, interval time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.rules = rules
+	e.valopers = valopers
+	e.interval = interval
+
+	watched := make(map[string]bool, len(valopers))
+	for _, valoper := range valopers {
+		watched[valoper] = true
+	}
+
+	for valoper := range e.samples {
+		if !watched[valoper] {
+			delete(e.samples, valoper)
+			e.dispatcher.Forget(valoper)
+		}
+	}
+
+	e.logger.Info().
+		Int("rules", len(e.rules)).
+		Dur("interval", e.interval).
+		Msg("Updated alert rules")
+}
+
+func (e *RuleEngine) check() {
+	e.mutex.Lock()
+	rules := e.rules
+	valopers := e.valopers
+	e.mutex.Unlock()
+
+	if len(rules) == 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, valoper := range valopers {
+		wg.Add(1)
+		go func(valoper string) {
+			defer wg.Done()
+			e.checkValidator(valoper, rules)
+		}(valoper)
+	}
+
+	wg.Wait()
+
+	e.dispatcher.Flush()
+}
+
+func (e *RuleEngine) checkValidator(valoper string, rules []AlertRule) {
+	if _, err := ValAddressFromBech32(valoper); err != nil {
+		e.logger.Error().
+			Str("valoper", valoper).
+			Err(err).
+			Msg("Could not get validator address")
+		return
+	}
+
+	// every metric is queried once per check, however many rules use it
+	values := make(map[string]float64)
+	recorded := false
+	for _, rule := range rules {
+		metric := rule.Metric
+		if metric == RuleMetricMissCounterDelta {
+			metric = RuleMetricMissCounter
+		}
+
+		key := metric + "/" + rule.Denom
+		value, ok := values[key]
+		if !ok {
+			var err error
+			value, err = e.value(valoper, metric, rule.Denom)
+			if err != nil {
+				e.logger.Error().
+					Str("valoper", valoper).
+					Str("rule", rule.Name).
+					Err(err).
+					Msg("Could not evaluate alert rule")
+				continue
+			}
+
+			values[key] = value
+		}
+
+		if rule.Metric == RuleMetricMissCounterDelta {
+			if !recorded {
+				e.recordMissCounter(valoper, uint64(value))
+				recorded = true
+			}
+
+			value = float64(e.missCounterDelta(valoper, rule.Window))
+		}
+
+		e.dispatcher.Set(rule.Name, valoper, rule.Matches(value), fmt.Sprintf(
+			"🔥 <b>%s</b>\nValidator: %s\nCondition: %s\nValue: %s",
+			rule.Name, valoper, rule.Condition, strconv.FormatFloat(value, 'f', -1, 64),
+		), rule.Notifiers)
+	}
+}
+
+func (e *RuleEngine) value(valoper string, metric string, denom string) (float64, error) {
+	ctx := context.Background()
+	grpcConn := e.node.Get()
+
+	switch metric {
+	case RuleMetricJailed:
+		stakingClient := stakingtypes.NewQueryClient(grpcConn)
+		response, err := stakingClient.Validator(ctx, &stakingtypes.QueryValidatorRequest{ValidatorAddr: valoper})
+		if err != nil {
+			return 0, err
+		}
+
+		if response.Validator.Jailed {
+			return 1, nil
+		}
+		return 0, nil
+	case RuleMetricFeederBalance:
+		oracle, err := NewOracleProvider(CurrentConfig().Chain.Type, grpcConn)
+		if err != nil {
+			return 0, err
+		}
+
+		feeder, err := oracle.FeederDelegation(ctx, valoper)
+		if err != nil {
+			return 0, err
+		}
+
+		bankClient := banktypes.NewQueryClient(grpcConn)
+		response, err := bankClient.Balance(ctx, &banktypes.QueryBalanceRequest{Address: feeder, Denom: denom})
+		if err != nil {
+			return 0, err
+		}
+
+		return RawAmount(response.Balance.Amount), nil
+	}
+
+	oracle, err := NewOracleProvider(CurrentConfig().Chain.Type, grpcConn)
+	if err != nil {
+		return 0, err
+	}
+
+	missCounter, err := oracle.MissCounter(ctx, valoper)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(missCounter), nil
+}
+
+// recordMissCounter keeps the miss counter samples of the longest window
+// of the rules.
+func (e *RuleEngine) recordMissCounter(valoper string, missCounter uint64) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var retention time.Duration
+	for _, rule := range e.rules {
+		if rule.Window > retention {
+			retention = rule.Window
+		}
+	}
+
+	now := time.Now()
+	samples := append(e.samples[valoper], missCounterSample{time: now, value: missCounter})
+	for len(samples) > 0 && now.Sub(samples[0].time) > retention {
+		samples = samples[1:]
+	}
+
+	e.samples[valoper] = samples
+}
+
+// missCounterDelta returns how much the miss counter increased within the
+// window. The counter resets on every slash window, so only the increments
+// between samples are summed up.
+func (e *RuleEngine) missCounterDelta(valoper string, window time.Duration) uint64 {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	now := time.Now()
+	samples := e.samples[valoper]
+
+	var delta uint64
+	for i := 1; i < len(samples); i++ {
+		if now.Sub(samples[i-1].time) > window {
+			continue
+		}
+
+		if samples[i].value > samples[i-1].value {
+			delta += samples[i].value - samples[i-1].value
+		}
+	}
+
+	return delta
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	MetricsSchemaV1 = "1"
+	MetricsSchemaV2 = "2"
+)
+
+type metricRename struct {
+	Name   string
+	Labels map[string]string
+}
+
+// metricsSchemaV2 renames the v1 metrics that were exported without a prefix,
+// metrics that are not listed keep their name in v2.
+var metricsSchemaV2 = func() map[string]metricRename {
+	renames := make(map[string]metricRename)
+	for _, name := range []string{
+		"window_progress",
+		"window_size",
+		"slash_window",
+		"min_valid_per_window",
+		"slash_fraction",
+		"vote_period",
+		"symbols_count",
+		"miss_counter",
+		"miss_rate",
+		"next_window_start",
+		"last_block_vote",
+		"feeder_account",
+		"feeder_balance",
+		"feeder_balance_raw",
+		"feeder_balance_change",
+		"feeder_balance_inflow_total",
+		"feeder_balance_outflow_total",
+		"feeder_balance_inflow_raw_total",
+		"feeder_balance_outflow_raw_total",
+	} {
+		renames[name] = metricRename{Name: "oracle_" + name}
+	}
+
+	renames["aggregated_votes"] = metricRename{
+		Name:   "oracle_aggregated_votes",
+		Labels: map[string]string{"asset": "denom"},
+	}
+
+	return renames
+}()
+
+func ValidateMetricsSchemas(schemas []string) error {
+	if len(schemas) == 0 {
+		return fmt.Errorf("at least one metrics schema has to be enabled")
+	}
+
+	for _, schema := range schemas {
+		if schema != MetricsSchemaV1 && schema != MetricsSchemaV2 {
+			return fmt.Errorf("unsupported metrics schema %q, expected %s or %s", schema, MetricsSchemaV1, MetricsSchemaV2)
+		}
+	}
+
+	return nil
+}
+
+// SchemaGatherer exports the metrics of gatherer in every enabled schema,
+// with both enabled during a transition the renamed metrics are emitted twice.
+func SchemaGatherer(gatherer prometheus.Gatherer, schemas []string) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+		if err != nil {
+			return families, err
+		}
+
+		var result []*dto.MetricFamily
+		seen := make(map[string]bool)
+
+		for _, schema := range schemas {
+			for _, family := range families {
+				converted := family
+				if schema == MetricsSchemaV2 {
+					if rename, ok := metricsSchemaV2[family.GetName()]; ok {
+						converted = renameFamily(family, rename)
+					}
+				}
+
+				// unrenamed metrics are the same in every schema
+				if seen[converted.GetName()] {
+					continue
+				}
+
+				seen[converted.GetName()] = true
+				result = append(result, converted)
+			}
+		}
+
+		return result, nil
+	})
+}
+
+func renameFamily(family *dto.MetricFamily, rename metricRename) *dto.MetricFamily {
+	name := rename.Name
+
+	renamed := &dto.MetricFamily{
+		Name:   &name,
+		Help:   family.Help,
+		Type:   family.Type,
+		Metric: family.Metric,
+	}
+
+	if len(rename.Labels) == 0 {
+		return renamed
+	}
+
+	renamed.Metric = make([]*dto.Metric, len(family.Metric))
+	for index, metric := range family.Metric {
+		copied := &dto.Metric{
+			Label:       make([]*dto.LabelPair, len(metric.Label)),
+			Gauge:       metric.Gauge,
+			Counter:     metric.Counter,
+			Summary:     metric.Summary,
+			Untyped:     metric.Untyped,
+			Histogram:   metric.Histogram,
+			TimestampMs: metric.TimestampMs,
+		}
+
+		for labelIndex, label := range metric.Label {
+			labelName := label.GetName()
+			if newName, ok := rename.Labels[labelName]; ok {
+				labelName = newName
+			}
+
+			copied.Label[labelIndex] = &dto.LabelPair{Name: &labelName, Value: label.Value}
+		}
+
+		renamed.Metric[index] = copied
+	}
+
+	return renamed
+}
+
+var metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// MetricNamer puts the namespace in front of the names of the exported
+// metrics, or renames them to the names legacy dashboards expect. It is
+// applied after the schema conversion, so renames use the names of the
+// enabled schema.
+type MetricNamer struct {
+	prefix  string
+	renames map[string]string
+}
+
+var metricNamer = &MetricNamer{}
+
+// CurrentMetricNamer returns the namer in effect, a reload replaces it.
+func CurrentMetricNamer() *MetricNamer {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	return metricNamer
+}
+
+func SetMetricNamer(namer *MetricNamer) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	metricNamer = namer
+}
+
+func NewMetricNamer(namespace string, renames map[string]string) (*MetricNamer, error) {
+	namespace = strings.TrimSuffix(namespace, "_")
+	if namespace != "" && (!metricNameRegexp.MatchString(namespace) || strings.Contains(namespace, ":")) {
+		return nil, fmt.Errorf("invalid metrics namespace %q", namespace)
+	}
+
+	for from, to := range renames {
+		if !metricNameRegexp.MatchString(from) || !metricNameRegexp.MatchString(to) {
+			return nil, fmt.Errorf("invalid metric rename %s=%s", from, to)
+		}
+	}
+
+	namer := &MetricNamer{renames: renames}
+	if namespace != "" {
+		namer.prefix = namespace + "_"
+	}
+
+	return namer, nil
+}
+
+// Name returns the exported name of the metric, renamed metrics don't get
+// the namespace.
+func (n *MetricNamer) Name(name string) string {
+	if renamed, ok := n.renames[name]; ok {
+		return renamed
+	}
+
+	return n.prefix + name
+}
+
+func (n *MetricNamer) Gatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	if n.prefix == "" && len(n.renames) == 0 {
+		return gatherer
+	}
+
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+
+		// copied, the families may be shared when several schemas are emitted
+		named := make([]*dto.MetricFamily, 0, len(families))
+		for _, family := range families {
+			name := n.Name(family.GetName())
+			named = append(named, &dto.MetricFamily{
+				Name:   &name,
+				Help:   family.Help,
+				Type:   family.Type,
+				Metric: family.Metric,
+			})
+		}
+
+		return named, err
+	})
+}
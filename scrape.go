@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+// ScrapeContext returns the request context with the scrape deadline, the
+// timeout Prometheus sends in X-Prometheus-Scrape-Timeout-Seconds or the
+// configured timeout, whichever is shorter, minus the offset. Queries still
+// running at the deadline are cancelled and the handler serves what it has,
+// instead of Prometheus marking the whole target down.
+func ScrapeContext(r *http.Request, timeout time.Duration, offset time.Duration) (context.Context, context.CancelFunc) {
+	if header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); header != "" {
+		if seconds, err := strconv.ParseFloat(header, 64); err == nil && seconds > 0 {
+			scrapeTimeout := time.Duration(seconds * float64(time.Second))
+			if timeout <= 0 || scrapeTimeout < timeout {
+				timeout = scrapeTimeout
+			}
+		}
+	}
+
+	if timeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+
+	if timeout > offset {
+		timeout -= offset
+	}
+
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+var (
+	scrapeOnce       bool
+	scrapeInterval   time.Duration
+	scrapeModules    []string
+	scrapeValidators []string
+	scrapeOutput     string
+)
+
+// scrapeResult is a collection cycle of a module in the JSON output.
+type scrapeResult struct {
+	Module    string         `json:"module"`
+	Validator string         `json:"validator,omitempty"`
+	Time      time.Time      `json:"time"`
+	Metrics   []scrapeMetric `json:"metrics"`
+}
+
+type scrapeMetric struct {
+	Name    string         `json:"name"`
+	Help    string         `json:"help"`
+	Type    string         `json:"type"`
+	Samples []scrapeSample `json:"samples"`
+}
+
+type scrapeSample struct {
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+var scrapeCmd = &cobra.Command{
+	Use:   "scrape",
+	Short: "Collect the metrics of the node and print them to stdout",
+	Long: "Run the collectors against --node like a probe and print the metrics in the text exposition format or as JSON, " +
+		"once with --once or at every --interval until interrupted. Exits with an error if a collection fails.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logLevel, err := zerolog.ParseLevel(LogLevel)
+		if err != nil {
+			return err
+		}
+		zerolog.SetGlobalLevel(logLevel)
+
+		if scrapeOutput != "text" && scrapeOutput != "json" {
+			return fmt.Errorf("unsupported output %q, expected text or json", scrapeOutput)
+		}
+
+		for _, module := range scrapeModules {
+			if !containsString(probeModules, module) {
+				return fmt.Errorf("unknown module %q, expected one of %s", module, strings.Join(probeModules, ", "))
+			}
+		}
+
+		if err := SetupExporter(cmd.Flags()); err != nil {
+			return err
+		}
+
+		config := CurrentConfig()
+
+		validators := scrapeValidators
+		if len(validators) == 0 {
+			validators = config.Validators
+		}
+
+		// the connection and the state of the trackers are kept between
+		// cycles, only the node is scraped
+		probes := NewProbeTargets(config.Endpoints.IPFamily, []string{config.Endpoints.Node}, config.Probe.IdleTimeout)
+
+		if scrapeOnce {
+			return scrapeCycle(os.Stdout, probes, validators)
+		}
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+		ticker := time.NewTicker(scrapeInterval)
+		defer ticker.Stop()
+
+		for {
+			// a failed cycle is logged, the next one may succeed
+			if err := scrapeCycle(os.Stdout, probes, validators); err != nil {
+				log.Error().Err(err).Msg("Scrape failed")
+			}
+
+			select {
+			case <-signals:
+				return nil
+			case <-ticker.C:
+			}
+		}
+	},
+}
+
+// scrapeCycle collects every module, once per validator for the modules
+// exporting validator metrics, and writes the metrics to w.
+func scrapeCycle(w io.Writer, probes *ProbeTargets, validators []string) error {
+	config := CurrentConfig()
+
+	var failed []string
+	for _, module := range scrapeModules {
+		moduleValidators := validators
+		if module == "upgrade" {
+			moduleValidators = []string{""}
+		} else if len(validators) == 0 {
+			if module != "gov" {
+				return fmt.Errorf("module %s needs a validator, set --validator or --alert-valopers", module)
+			}
+			moduleValidators = []string{""}
+		}
+
+		for _, validator := range moduleValidators {
+			query := url.Values{}
+			query.Set("target", config.Endpoints.Node)
+			query.Set("module", module)
+			query.Set("chain-type", config.Chain.Type)
+			if validator != "" {
+				query.Set("validator", validator)
+			}
+
+			families, result, err := scrapeProbe(probes, query, config.Feeders)
+			if err != nil {
+				return fmt.Errorf("could not scrape %s: %w", module, err)
+			}
+
+			if result.Failed() > 0 {
+				failed = append(failed, module)
+			}
+
+			if err := writeScrape(w, module, validator, families); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("could not collect %s from %s", strings.Join(failed, ", "), config.Endpoints.Node)
+	}
+
+	return nil
+}
+
+// scrapeProbe serves the probe request internally, bounded by --scrape-timeout.
+// The result counts the queries of the collection that failed.
+func scrapeProbe(probes *ProbeTargets, query url.Values, expectedFeeders map[string]string) ([]*dto.MetricFamily, *CollectResult, error) {
+	ctx, result := WithCollectResult(context.Background())
+	if timeout := CurrentConfig().Queries.ScrapeTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/probe?"+query.Encode(), nil).WithContext(ctx)
+	recorder := httptest.NewRecorder()
+	ProbeHandler(recorder, request, probes, expectedFeeders)
+
+	if recorder.Code != http.StatusOK {
+		return nil, nil, errors.New(strings.TrimSpace(recorder.Body.String()))
+	}
+
+	var parser expfmt.TextParser
+	parsed, err := parser.TextToMetricFamilies(recorder.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	families := make([]*dto.MetricFamily, 0, len(parsed))
+	for _, family := range parsed {
+		families = append(families, family)
+	}
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+
+	return families, result, nil
+}
+
+func writeScrape(w io.Writer, module string, validator string, families []*dto.MetricFamily) error {
+	if scrapeOutput == "json" {
+		result := scrapeResult{
+			Module:    module,
+			Validator: validator,
+			Time:      time.Now().UTC(),
+			Metrics:   make([]scrapeMetric, 0, len(families)),
+		}
+
+		for _, family := range families {
+			result.Metrics = append(result.Metrics, scrapeMetricOf(family))
+		}
+
+		// one object per line, so repeated scrapes can be streamed to jq
+		return json.NewEncoder(w).Encode(result)
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "# module=%s", module)
+	if validator != "" {
+		fmt.Fprintf(&buffer, " validator=%s", validator)
+	}
+	buffer.WriteString("\n")
+
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(&buffer, family); err != nil {
+			return err
+		}
+	}
+
+	_, err := buffer.WriteTo(w)
+	return err
+}
+
+// scrapeMetricOf flattens the family, histograms and summaries are reported
+// as their _sum and _count samples.
+func scrapeMetricOf(family *dto.MetricFamily) scrapeMetric {
+	metric := scrapeMetric{
+		Name:    family.GetName(),
+		Help:    family.GetHelp(),
+		Type:    strings.ToLower(family.GetType().String()),
+		Samples: make([]scrapeSample, 0, len(family.GetMetric())),
+	}
+
+	for _, m := range family.GetMetric() {
+		labels := make(map[string]string, len(m.GetLabel()))
+		for _, label := range m.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			metric.Samples = append(metric.Samples, scrapeSample{Labels: labels, Value: m.GetCounter().GetValue()})
+		case dto.MetricType_GAUGE:
+			metric.Samples = append(metric.Samples, scrapeSample{Labels: labels, Value: m.GetGauge().GetValue()})
+		case dto.MetricType_HISTOGRAM:
+			metric.Samples = append(metric.Samples,
+				scrapeSample{Labels: withLabel(labels, "sample", "sum"), Value: m.GetHistogram().GetSampleSum()},
+				scrapeSample{Labels: withLabel(labels, "sample", "count"), Value: float64(m.GetHistogram().GetSampleCount())},
+			)
+		case dto.MetricType_SUMMARY:
+			metric.Samples = append(metric.Samples,
+				scrapeSample{Labels: withLabel(labels, "sample", "sum"), Value: m.GetSummary().GetSampleSum()},
+				scrapeSample{Labels: withLabel(labels, "sample", "count"), Value: float64(m.GetSummary().GetSampleCount())},
+			)
+		default:
+			metric.Samples = append(metric.Samples, scrapeSample{Labels: labels, Value: m.GetUntyped().GetValue()})
+		}
+	}
+
+	return metric
+}
+
+func withLabel(labels map[string]string, name string, value string) map[string]string {
+	copied := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		copied[k] = v
+	}
+	copied[name] = value
+
+	return copied
+}
+
+func init() {
+	scrapeCmd.Flags().BoolVar(&scrapeOnce, "once", false, "Collect once and exit instead of collecting at every --interval")
+	scrapeCmd.Flags().DurationVar(&scrapeInterval, "interval", time.Minute, "Interval between the collections without --once")
+	scrapeCmd.Flags().StringSliceVar(&scrapeModules, "modules", []string{"general"}, "Collectors to run: "+strings.Join(probeModules, ", "))
+	scrapeCmd.Flags().StringSliceVar(&scrapeValidators, "validator", nil, "Validators to export the metrics of, --alert-valopers by default")
+	scrapeCmd.Flags().StringVarP(&scrapeOutput, "output", "o", "text", "Output format: text or json")
+}
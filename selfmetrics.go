@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	selfRegistry    = prometheus.NewRegistry()
+	selfMetricsOnce sync.Once
+
+	// runtimeRegistry holds the metrics which keep their names whatever the
+	// namespace: the go_ and process_ ones and the build info, so a fleet can
+	// be queried by version across namespaces.
+	runtimeRegistry = prometheus.NewRegistry()
+
+	selfScrapeDuration   *prometheus.HistogramVec
+	selfGRPCRequests     *prometheus.CounterVec
+	selfGRPCErrors       *prometheus.CounterVec
+	selfGRPCDeduplicated *prometheus.CounterVec
+	selfEndpointUp       *prometheus.GaugeVec
+	selfNodeConnected    prometheus.Gauge
+
+	selfGRPCConnectionState *prometheus.GaugeVec
+
+	selfEndpointHeight   *prometheus.GaugeVec
+	selfEndpointLag      *prometheus.GaugeVec
+	selfEndpointLatency  *prometheus.GaugeVec
+	selfEndpointSelected *prometheus.GaugeVec
+
+	selfMetricsSchema *prometheus.GaugeVec
+	selfBuildInfo     *prometheus.GaugeVec
+
+	selfProviderRequests        *prometheus.CounterVec
+	selfProviderBudgetRemaining *prometheus.GaugeVec
+
+	selfIncidentActive *prometheus.GaugeVec
+
+	selfListingsTruncated *prometheus.CounterVec
+
+	selfHTTPRequests *prometheus.CounterVec
+
+	selfQueriesRateLimited *prometheus.CounterVec
+
+	selfCollectorConfigured *prometheus.GaugeVec
+	selfCollectorActive     *prometheus.GaugeVec
+)
+
+// RegisterSelfMetrics creates the exporter's own metrics, served on /metrics
+// unlike the per-request registries of the chain metrics handlers. It has to
+// run after the flags are parsed, as the metrics carry the const labels, and
+// only registers them on the first call.
+func RegisterSelfMetrics() {
+	selfMetricsOnce.Do(registerSelfMetrics)
+}
+
+// SelfGatherer is what /metrics serves: the exporter's own metrics, named
+// like those of the collectors, and the runtime metrics.
+func SelfGatherer() prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return prometheus.Gatherers{CurrentMetricNamer().Gatherer(selfRegistry), runtimeRegistry}.Gather()
+	})
+}
+
+func registerSelfMetrics() {
+	selfScrapeDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:        "exporter_scrape_duration_seconds",
+			Help:        "Time spent serving a given metrics endpoint",
+			ConstLabels: constLabels(),
+			Buckets:     []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"handler"},
+	)
+
+	selfGRPCRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "exporter_grpc_requests_total",
+			Help:        "Number of gRPC requests sent to the node by method and status code",
+			ConstLabels: constLabels(),
+		},
+		[]string{"method", "code"},
+	)
+
+	selfGRPCErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "exporter_grpc_errors_total",
+			Help:        "Number of failed gRPC requests sent to the node by method",
+			ConstLabels: constLabels(),
+		},
+		[]string{"method"},
+	)
+
+	selfGRPCDeduplicated = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "exporter_grpc_deduplicated_total",
+			Help:        "Number of gRPC queries by method answered with the response of an identical query in flight",
+			ConstLabels: constLabels(),
+		},
+		[]string{"method"},
+	)
+
+	selfEndpointUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "exporter_endpoint_up",
+			Help:        "Whether the last request to a given upstream endpoint succeeded",
+			ConstLabels: constLabels(),
+		},
+		[]string{"endpoint"},
+	)
+
+	selfNodeConnected = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "node_connected",
+			Help:        "Whether the gRPC connection to the node is up",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	selfGRPCConnectionState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "grpc_connection_state",
+			Help:        "State of the gRPC connection to the node: 0 idle, 1 connecting, 2 ready, 3 transient failure, 4 shutdown",
+			ConstLabels: constLabels(),
+		},
+		[]string{"endpoint"},
+	)
+
+	selfEndpointHeight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "exporter_endpoint_height",
+			Help:        "Latest block height of a given gRPC endpoint at its last health check",
+			ConstLabels: constLabels(),
+		},
+		[]string{"endpoint"},
+	)
+
+	selfEndpointLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "exporter_endpoint_lag_blocks",
+			Help:        "Blocks a given gRPC endpoint lags behind the highest height seen on the endpoints",
+			ConstLabels: constLabels(),
+		},
+		[]string{"endpoint"},
+	)
+
+	selfEndpointLatency = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "exporter_endpoint_latency_seconds",
+			Help:        "Time a given gRPC endpoint took to answer its last health check",
+			ConstLabels: constLabels(),
+		},
+		[]string{"endpoint"},
+	)
+
+	selfEndpointSelected = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "exporter_endpoint_selected",
+			Help:        "Whether the queries go to a given gRPC endpoint",
+			ConstLabels: constLabels(),
+		},
+		[]string{"endpoint"},
+	)
+
+	selfBuildInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cosmos_exporter_build_info",
+			Help:        "Version and commit of the exporter and the Go version it was built with, always 1",
+			ConstLabels: constLabels(),
+		},
+		[]string{"version", "commit", "go_version"},
+	)
+
+	selfMetricsSchema = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "exporter_metrics_schema_version",
+			Help:        "Metrics schema versions the exporter currently emits",
+			ConstLabels: constLabels(),
+		},
+		[]string{"version"},
+	)
+
+	selfProviderRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "exporter_price_provider_requests_total",
+			Help:        "Number of requests sent to a given external price provider",
+			ConstLabels: constLabels(),
+		},
+		[]string{"provider"},
+	)
+
+	selfProviderBudgetRemaining = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "exporter_price_provider_budget_remaining",
+			Help:        "Requests left in the current budget period of a given external price provider",
+			ConstLabels: constLabels(),
+		},
+		[]string{"provider"},
+	)
+
+	selfIncidentActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "exporter_incident_active",
+			Help:        "Open incident of a given validator correlated from several anomaly signals, with the probable cause",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "cause"},
+	)
+
+	selfListingsTruncated = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "exporter_listings_truncated_total",
+			Help:        "Number of listing queries of a given kind cut short at --max-pages",
+			ConstLabels: constLabels(),
+		},
+		[]string{"query"},
+	)
+
+	selfHTTPRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "http_requests_total",
+			Help:        "Number of requests served by the exporter by route and status code",
+			ConstLabels: constLabels(),
+		},
+		[]string{"path", "code"},
+	)
+
+	selfQueriesRateLimited = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "exporter_grpc_rate_limited_total",
+			Help:        "Number of gRPC queries to a given endpoint that waited for the rate limit",
+			ConstLabels: constLabels(),
+		},
+		[]string{"endpoint"},
+	)
+
+	selfCollectorConfigured = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "exporter_collector_configured",
+			Help:        "Whether a given collector is turned on in the config",
+			ConstLabels: constLabels(),
+		},
+		[]string{"collector"},
+	)
+
+	selfCollectorActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "exporter_collector_active",
+			Help:        "Whether a given collector produces metrics, with the reason if it doesn't",
+			ConstLabels: constLabels(),
+		},
+		[]string{"collector", "reason"},
+	)
+
+	if EnablePprof {
+		runtimeRegistry.MustRegister(collectors.NewGoCollector(
+			collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsGC, collectors.MetricsMemory, collectors.MetricsScheduler),
+		))
+	} else {
+		runtimeRegistry.MustRegister(collectors.NewGoCollector())
+	}
+	runtimeRegistry.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	selfRegistry.MustRegister(selfScrapeDuration)
+	selfRegistry.MustRegister(selfGRPCRequests)
+	selfRegistry.MustRegister(selfGRPCErrors)
+	selfRegistry.MustRegister(selfGRPCDeduplicated)
+	selfRegistry.MustRegister(selfEndpointUp)
+	selfRegistry.MustRegister(selfNodeConnected)
+	selfRegistry.MustRegister(selfGRPCConnectionState)
+	selfRegistry.MustRegister(selfEndpointHeight)
+	selfRegistry.MustRegister(selfEndpointLag)
+	selfRegistry.MustRegister(selfEndpointLatency)
+	selfRegistry.MustRegister(selfEndpointSelected)
+	selfRegistry.MustRegister(selfMetricsSchema)
+	runtimeRegistry.MustRegister(selfBuildInfo)
+	selfRegistry.MustRegister(selfProviderRequests)
+	selfRegistry.MustRegister(selfProviderBudgetRemaining)
+	selfRegistry.MustRegister(selfIncidentActive)
+	selfRegistry.MustRegister(selfListingsTruncated)
+	selfRegistry.MustRegister(selfHTTPRequests)
+	selfRegistry.MustRegister(selfQueriesRateLimited)
+	selfRegistry.MustRegister(selfCollectorConfigured)
+	selfRegistry.MustRegister(selfCollectorActive)
+
+	version, commit := BuildVersion()
+	selfBuildInfo.WithLabelValues(version, commit, runtime.Version()).Set(1)
+}
+
+// SetMetricsSchemas marks the emitted schema versions, replacing the previous ones.
+func SetMetricsSchemas(schemas []string) {
+	selfMetricsSchema.Reset()
+	for _, schema := range schemas {
+		selfMetricsSchema.WithLabelValues(schema).Set(1)
+	}
+}
+
+// selfMetricsInterceptor counts the gRPC requests sent to the node at endpoint.
+func selfMetricsInterceptor(endpoint string) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		code := status.Code(err)
+
+		selfGRPCRequests.WithLabelValues(method, code.String()).Inc()
+		if err != nil {
+			selfGRPCErrors.WithLabelValues(method).Inc()
+		}
+
+		// errors returned by the node itself still mean it is reachable
+		switch code {
+		case codes.Unavailable, codes.DeadlineExceeded:
+			selfEndpointUp.WithLabelValues(endpoint).Set(0)
+		default:
+			selfEndpointUp.WithLabelValues(endpoint).Set(1)
+		}
+
+		return err
+	}
+}
+
+// instrumentHandler records how long the handler takes to serve a scrape
+// and bounds the scrape by its deadline, see ScrapeContext. Collectors
+// disabled with --disable-collectors answer 404.
+func instrumentHandler(name string, timeout time.Duration, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !CurrentMetricFilter().CollectorEnabled(name) {
+			http.Error(w, fmt.Sprintf("the %s collector is disabled", name), http.StatusNotFound)
+			return
+		}
+
+		start := time.Now()
+
+		ctx, cancel := ScrapeContext(r, timeout, CurrentConfig().Queries.ScrapeTimeoutOffset)
+		defer cancel()
+
+		ctx, span := StartSpan(ContinueTrace(r.WithContext(ctx)), "scrape "+name, spanKindServer)
+		span.SetAttribute("http.target", r.URL.RequestURI())
+
+		handler(w, r.WithContext(ctx))
+		span.End(ctx.Err())
+		selfScrapeDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+// slinkyOracleServices are the x/oracle services of Slinky and of Connect,
+// its later name.
+var slinkyOracleServices = []string{"/slinky.oracle.v1.Query/", "/connect.oracle.v2.Query/"}
+
+// Hand-written messages of the Slinky x/oracle module and of the extended
+// commit the proposer injects as the first transaction of a block, only the
+// fields the exporter reads are declared.
+
+type slinkyCurrencyPairsResponse struct {
+	CurrencyPairs []*slinkyCurrencyPair `protobuf:"bytes,1,rep,name=currency_pairs,json=currencyPairs,proto3"`
+}
+
+func (m *slinkyCurrencyPairsResponse) Reset()         { *m = slinkyCurrencyPairsResponse{} }
+func (m *slinkyCurrencyPairsResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*slinkyCurrencyPairsResponse) ProtoMessage()    {}
+
+type slinkyPricesRequest struct {
+	CurrencyPairIDs []string `protobuf:"bytes,1,rep,name=currency_pair_ids,json=currencyPairIds,proto3"`
+}
+
+func (m *slinkyPricesRequest) Reset()         { *m = slinkyPricesRequest{} }
+func (m *slinkyPricesRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*slinkyPricesRequest) ProtoMessage()    {}
+
+type slinkyTimestamp struct {
+	Seconds int64 `protobuf:"varint,1,opt,name=seconds,proto3"`
+	Nanos   int32 `protobuf:"varint,2,opt,name=nanos,proto3"`
+}
+
+func (m *slinkyTimestamp) Reset()         { *m = slinkyTimestamp{} }
+func (m *slinkyTimestamp) String() string { return fmt.Sprintf("%+v", *m) }
+func (*slinkyTimestamp) ProtoMessage()    {}
+
+type slinkyQuotePrice struct {
+	Price          string           `protobuf:"bytes,1,opt,name=price,proto3"`
+	BlockTimestamp *slinkyTimestamp `protobuf:"bytes,2,opt,name=block_timestamp,json=blockTimestamp,proto3"`
+	BlockHeight    uint64           `protobuf:"varint,3,opt,name=block_height,json=blockHeight,proto3"`
+}
+
+func (m *slinkyQuotePrice) Reset()         { *m = slinkyQuotePrice{} }
+func (m *slinkyQuotePrice) String() string { return fmt.Sprintf("%+v", *m) }
+func (*slinkyQuotePrice) ProtoMessage()    {}
+
+type slinkyPrice struct {
+	Price    *slinkyQuotePrice `protobuf:"bytes,1,opt,name=price,proto3"`
+	Nonce    uint64            `protobuf:"varint,2,opt,name=nonce,proto3"`
+	Decimals uint64            `protobuf:"varint,3,opt,name=decimals,proto3"`
+	ID       uint64            `protobuf:"varint,4,opt,name=id,proto3"`
+}
+
+func (m *slinkyPrice) Reset()         { *m = slinkyPrice{} }
+func (m *slinkyPrice) String() string { return fmt.Sprintf("%+v", *m) }
+func (*slinkyPrice) ProtoMessage()    {}
+
+type slinkyPricesResponse struct {
+	Prices []*slinkyPrice `protobuf:"bytes,1,rep,name=prices,proto3"`
+}
+
+func (m *slinkyPricesResponse) Reset()         { *m = slinkyPricesResponse{} }
+func (m *slinkyPricesResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*slinkyPricesResponse) ProtoMessage()    {}
+
+type extendedVoteValidator struct {
+	Address []byte `protobuf:"bytes,1,opt,name=address,proto3"`
+	Power   int64  `protobuf:"varint,3,opt,name=power,proto3"`
+}
+
+func (m *extendedVoteValidator) Reset()         { *m = extendedVoteValidator{} }
+func (m *extendedVoteValidator) String() string { return fmt.Sprintf("%+v", *m) }
+func (*extendedVoteValidator) ProtoMessage()    {}
+
+type extendedVoteInfo struct {
+	Validator     *extendedVoteValidator `protobuf:"bytes,1,opt,name=validator,proto3"`
+	VoteExtension []byte                 `protobuf:"bytes,3,opt,name=vote_extension,json=voteExtension,proto3"`
+	BlockIDFlag   int32                  `protobuf:"varint,5,opt,name=block_id_flag,json=blockIdFlag,proto3"`
+}
+
+func (m *extendedVoteInfo) Reset()         { *m = extendedVoteInfo{} }
+func (m *extendedVoteInfo) String() string { return fmt.Sprintf("%+v", *m) }
+func (*extendedVoteInfo) ProtoMessage()    {}
+
+type extendedCommitInfo struct {
+	Round int32               `protobuf:"varint,1,opt,name=round,proto3"`
+	Votes []*extendedVoteInfo `protobuf:"bytes,2,rep,name=votes,proto3"`
+}
+
+func (m *extendedCommitInfo) Reset()         { *m = extendedCommitInfo{} }
+func (m *extendedCommitInfo) String() string { return fmt.Sprintf("%+v", *m) }
+func (*extendedCommitInfo) ProtoMessage()    {}
+
+type slinkyVoteExtension struct {
+	Prices map[uint64][]byte `protobuf:"bytes,1,rep,name=prices,proto3" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *slinkyVoteExtension) Reset()         { *m = slinkyVoteExtension{} }
+func (m *slinkyVoteExtension) String() string { return fmt.Sprintf("%+v", *m) }
+func (*slinkyVoteExtension) ProtoMessage()    {}
+
+// invokeSlinkyOracle queries the x/oracle of Slinky, or of Connect if the
+// node doesn't serve Slinky's.
+func invokeSlinkyOracle(ctx context.Context, grpcConn *grpc.ClientConn, method string, request interface{}, response interface{}) error {
+	var err error
+	for _, service := range slinkyOracleServices {
+		if err = grpcConn.Invoke(ctx, service+method, request, response); status.Code(err) != codes.Unimplemented {
+			return err
+		}
+	}
+
+	return err
+}
+
+// decodeSlinkyPayload undoes the compression of the extended commit (zstd)
+// and of the vote extensions (zlib) done by Slinky's default codecs. Chains
+// configured without compression send the messages as they are.
+func decodeSlinkyPayload(data []byte, zlibCompressed bool) []byte {
+	if zlibCompressed {
+		reader, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return data
+		}
+		defer reader.Close()
+
+		decoded, err := io.ReadAll(reader)
+		if err != nil {
+			return data
+		}
+
+		return decoded
+	}
+
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return data
+	}
+	defer decoder.Close()
+
+	decoded, err := decoder.DecodeAll(data, nil)
+	if err != nil {
+		return data
+	}
+
+	return decoded
+}
+
+// SlinkyReportTracker remembers the last height each validator reported the
+// price of each currency pair at, a single block only tells whether the
+// pair was in the validator's latest vote extension.
+type SlinkyReportTracker struct {
+	mutex sync.Mutex
+	// valoper -> height the validator was first seen at
+	since map[string]int64
+	// valoper -> pair -> height
+	reported map[string]map[string]int64
+}
+
+func NewSlinkyReportTracker() *SlinkyReportTracker {
+	return &SlinkyReportTracker{
+		since:    make(map[string]int64),
+		reported: make(map[string]map[string]int64),
+	}
+}
+
+// Observe records the pairs the validator reported at height and returns
+// the blocks since each pair was last reported. Pairs not reported since the
+// exporter started count from the first observation.
+func (t *SlinkyReportTracker) Observe(valoper string, height int64, pairs []string, reported map[string]bool) map[string]int64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if _, ok := t.since[valoper]; !ok {
+		t.since[valoper] = height
+		t.reported[valoper] = make(map[string]int64)
+	}
+
+	ages := make(map[string]int64, len(pairs))
+	for _, pair := range pairs {
+		if reported[pair] {
+			t.reported[valoper][pair] = height
+		}
+
+		last, ok := t.reported[valoper][pair]
+		if !ok {
+			last = t.since[valoper]
+		}
+
+		ages[pair] = height - last
+	}
+
+	return ages
+}
+
+// SlinkyCollector exports the currency pairs of the Slinky x/oracle with the
+// age of their prices, and with a validator the pairs of its latest vote
+// extension and the blocks since it last reported each of them.
+type SlinkyCollector struct {
+	uncheckedCollector
+
+	ctx      context.Context
+	grpcConn *grpc.ClientConn
+	tracker  *SlinkyReportTracker
+	valoper  string
+	logger   zerolog.Logger
+}
+
+func NewSlinkyCollector(
+	ctx context.Context,
+	grpcConn *grpc.ClientConn,
+	tracker *SlinkyReportTracker,
+	valoper string,
+	logger zerolog.Logger,
+) *SlinkyCollector {
+	return &SlinkyCollector{
+		ctx:      ctx,
+		grpcConn: grpcConn,
+		tracker:  tracker,
+		valoper:  valoper,
+		logger:   logger,
+	}
+}
+
+func (c *SlinkyCollector) Collect(ch chan<- prometheus.Metric) {
+	slinkyCurrencyPairsGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "slinky_currency_pairs",
+			Help:        "Number of currency pairs in the oracle",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	slinkyPriceHeightGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "slinky_price_last_updated_height",
+			Help:        "Height the price of a given currency pair was last updated at",
+			ConstLabels: constLabels(),
+		},
+		[]string{"pair"},
+	)
+
+	slinkyPriceAgeBlocksGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "slinky_price_age_blocks",
+			Help:        "Blocks since the price of a given currency pair was last updated",
+			ConstLabels: constLabels(),
+		},
+		[]string{"pair"},
+	)
+
+	slinkyPriceAgeSecondsGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "slinky_price_age_seconds",
+			Help:        "Seconds since the price of a given currency pair was last updated",
+			ConstLabels: constLabels(),
+		},
+		[]string{"pair"},
+	)
+
+	slinkyVoteExtensionGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "slinky_validator_vote_extension",
+			Help:        "Whether the latest block has a vote extension of a given validator",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	slinkyValidatorReportGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "slinky_validator_report",
+			Help:        "Whether the latest vote extension of a given validator has a price for a given currency pair",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "pair"},
+	)
+
+	slinkyValidatorReportAgeGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "slinky_validator_report_age_blocks",
+			Help:        "Blocks since a given validator last reported a price for a given currency pair, counted from the exporter start if it hasn't since",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper", "pair"},
+	)
+
+	metrics := []prometheus.Collector{
+		slinkyCurrencyPairsGauge,
+		slinkyPriceHeightGauge,
+		slinkyPriceAgeBlocksGauge,
+		slinkyPriceAgeSecondsGauge,
+	}
+	if c.valoper != "" {
+		metrics = append(metrics, slinkyVoteExtensionGauge, slinkyValidatorReportGauge, slinkyValidatorReportAgeGauge)
+	}
+
+	ctx, height, err := PinHeight(c.ctx, c.grpcConn)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Could not get latest block height")
+		return
+	}
+
+	c.logger.Debug().Msg("Started querying currency pairs")
+	queryStart := time.Now()
+
+	pairsResponse := &slinkyCurrencyPairsResponse{}
+	if err := invokeSlinkyOracle(ctx, c.grpcConn, "GetAllCurrencyPairs", &emptyRequest{}, pairsResponse); err != nil {
+		c.logger.Error().Err(err).Msg("Could not get currency pairs")
+		return
+	}
+
+	pairs := make([]string, 0, len(pairsResponse.CurrencyPairs))
+	for _, pair := range pairsResponse.CurrencyPairs {
+		if pair != nil {
+			pairs = append(pairs, pair.Base+"/"+pair.Quote)
+		}
+	}
+
+	c.logger.Debug().
+		Int("pairs", len(pairs)).
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying currency pairs")
+
+	slinkyCurrencyPairsGauge.Set(float64(len(pairs)))
+
+	c.logger.Debug().Msg("Started querying prices")
+	queryStart = time.Now()
+
+	pricesResponse := &slinkyPricesResponse{}
+	if err := invokeSlinkyOracle(ctx, c.grpcConn, "GetPrices", &slinkyPricesRequest{CurrencyPairIDs: pairs}, pricesResponse); err != nil {
+		c.logger.Error().Err(err).Msg("Could not get prices")
+		return
+	}
+
+	c.logger.Debug().
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying prices")
+
+	// the vote extensions refer to the pairs by id, the prices are in the
+	// order of the request
+	pairIDs := make(map[uint64]string, len(pricesResponse.Prices))
+	for index, price := range pricesResponse.Prices {
+		if index >= len(pairs) || price == nil {
+			continue
+		}
+
+		pairIDs[price.ID] = pairs[index]
+		if price.Price == nil {
+			continue
+		}
+
+		labels := prometheus.Labels{"pair": pairs[index]}
+		slinkyPriceHeightGauge.With(labels).Set(float64(price.Price.BlockHeight))
+		slinkyPriceAgeBlocksGauge.With(labels).Set(float64(height - int64(price.Price.BlockHeight)))
+		if timestamp := price.Price.BlockTimestamp; timestamp != nil {
+			updated := time.Unix(timestamp.Seconds, int64(timestamp.Nanos))
+			slinkyPriceAgeSecondsGauge.With(labels).Set(time.Since(updated).Seconds())
+		}
+	}
+
+	if c.valoper == "" {
+		collectMetrics(ch, metrics)
+		return
+	}
+
+	reported, ok := c.validatorReport(ctx, height, pairIDs)
+	if ok {
+		slinkyVoteExtensionGauge.With(prometheus.Labels{"valoper": c.valoper}).Set(0)
+		if reported != nil {
+			slinkyVoteExtensionGauge.With(prometheus.Labels{"valoper": c.valoper}).Set(1)
+		}
+
+		for pair, age := range c.tracker.Observe(c.valoper, height, pairs, reported) {
+			labels := prometheus.Labels{"valoper": c.valoper, "pair": pair}
+			slinkyValidatorReportAgeGauge.With(labels).Set(float64(age))
+
+			report := 0.0
+			if reported[pair] {
+				report = 1
+			}
+			slinkyValidatorReportGauge.With(labels).Set(report)
+		}
+	}
+
+	collectMetrics(ch, metrics)
+}
+
+// validatorReport returns the pairs in the vote extension of the validator
+// injected into the block at height, nil if it has none. ok is false if the
+// block couldn't be read.
+func (c *SlinkyCollector) validatorReport(ctx context.Context, height int64, pairIDs map[uint64]string) (map[string]bool, bool) {
+	stakingClient := stakingtypes.NewQueryClient(c.grpcConn)
+	validatorResponse, err := stakingClient.Validator(ctx, &stakingtypes.QueryValidatorRequest{ValidatorAddr: c.valoper})
+	if err != nil {
+		c.logger.Error().
+			Str("valoper", c.valoper).
+			Err(err).
+			Msg("Could not get validator")
+		return nil, false
+	}
+
+	consAddress, err := ConsensusAddress(validatorResponse.Validator)
+	if err != nil {
+		c.logger.Error().
+			Str("valoper", c.valoper).
+			Err(err).
+			Msg("Could not get validator consensus address")
+		return nil, false
+	}
+
+	c.logger.Debug().
+		Int64("height", height).
+		Msg("Started querying block vote extensions")
+	queryStart := time.Now()
+
+	serviceClient := tmservice.NewServiceClient(c.grpcConn)
+	blockResponse, err := serviceClient.GetBlockByHeight(ctx, &tmservice.GetBlockByHeightRequest{Height: height})
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Could not get block")
+		return nil, false
+	}
+
+	if blockResponse.Block == nil || len(blockResponse.Block.Data.Txs) == 0 {
+		c.logger.Warn().Int64("height", height).Msg("Block has no extended commit")
+		return nil, false
+	}
+
+	codec := encoding.GetCodec("proto")
+
+	commit := &extendedCommitInfo{}
+	if err := codec.Unmarshal(decodeSlinkyPayload(blockResponse.Block.Data.Txs[0], false), commit); err != nil {
+		c.logger.Error().Err(err).Msg("Could not decode extended commit")
+		return nil, false
+	}
+
+	c.logger.Debug().
+		Int("votes", len(commit.Votes)).
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying block vote extensions")
+
+	for _, vote := range commit.Votes {
+		if vote.Validator == nil || !bytes.Equal(vote.Validator.Address, consAddress) {
+			continue
+		}
+
+		if len(vote.VoteExtension) == 0 {
+			return nil, true
+		}
+
+		extension := &slinkyVoteExtension{}
+		if err := codec.Unmarshal(decodeSlinkyPayload(vote.VoteExtension, true), extension); err != nil {
+			c.logger.Error().
+				Str("valoper", c.valoper).
+				Err(err).
+				Msg("Could not decode vote extension")
+			return nil, false
+		}
+
+		reported := make(map[string]bool, len(extension.Prices))
+		for id := range extension.Prices {
+			if pair, ok := pairIDs[id]; ok {
+				reported[pair] = true
+			}
+		}
+
+		return reported, true
+	}
+
+	return nil, true
+}
+
+// SlinkyHandler serves the currency pairs of the Slinky x/oracle, and the
+// reports of the validator given with ?valoper=, see SlinkyCollector.
+func SlinkyHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn, tracker *SlinkyReportTracker) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	valoper := r.URL.Query().Get("valoper")
+	if valoper != "" {
+		if _, err := ValAddressFromBech32(valoper); err != nil {
+			sublogger.Error().
+				Str("valoper", valoper).
+				Err(err).
+				Msg("Could not get validator address")
+			return
+		}
+	}
+
+	registry := collectorRegistry(NewSlinkyCollector(r.Context(), grpcConn, tracker, valoper, sublogger))
+
+	h := promhttp.HandlerFor(ExportTargetGatherer(registry, valoper), promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/slinky?valoper="+valoper).
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+)
+
+// SLOStatus is the oracle participation of a validator over the SLO window,
+// calculated from the history in the state file.
+type SLOStatus struct {
+	Misses        uint64
+	ExpectedVotes float64
+	Participation float64
+	// share of the allowed misses that is left, negative once exceeded
+	ErrorBudgetRemaining float64
+	Covered              time.Duration
+}
+
+// NewSLOStatus sums up the misses of the samples within the window and
+// compares them to the number of vote periods between the first and the
+// last sample. Samples without a height are skipped.
+func NewSLOStatus(samples []HistorySample, now time.Time, window time.Duration, votePeriod uint64, target float64) (SLOStatus, bool) {
+	var status SLOStatus
+	var first, previous *HistorySample
+
+	for index := range samples {
+		sample := &samples[index]
+		if sample.Height == 0 || now.Sub(sample.Time) > window {
+			continue
+		}
+
+		if first == nil {
+			first = sample
+		} else {
+			status.Misses += missCounterIncrease(previous.MissCounter, sample.MissCounter)
+		}
+		previous = sample
+	}
+
+	if first == nil || previous == first || votePeriod == 0 {
+		return status, false
+	}
+
+	status.ExpectedVotes = float64(previous.Height-first.Height) / float64(votePeriod)
+	status.Covered = previous.Time.Sub(first.Time)
+	if status.ExpectedVotes <= 0 {
+		return status, false
+	}
+
+	status.Participation = 1 - float64(status.Misses)/status.ExpectedVotes
+	if budget := (1 - target) * status.ExpectedVotes; budget > 0 {
+		status.ErrorBudgetRemaining = 1 - float64(status.Misses)/budget
+	}
+
+	return status, true
+}
+
+// SLOCollector exports the oracle participation of the given validator against
+// the SLO target, so alerts can be based on the burnt error budget.
+type SLOCollector struct {
+	uncheckedCollector
+
+	ctx     context.Context
+	oracle  OracleProvider
+	alerter *Alerter
+	target  float64
+	window  time.Duration
+	valoper string
+	logger  zerolog.Logger
+}
+
+func NewSLOCollector(
+	ctx context.Context,
+	oracle OracleProvider,
+	alerter *Alerter,
+	target float64,
+	window time.Duration,
+	valoper string,
+	logger zerolog.Logger,
+) *SLOCollector {
+	return &SLOCollector{
+		ctx:     ctx,
+		oracle:  oracle,
+		alerter: alerter,
+		target:  target,
+		window:  window,
+		valoper: valoper,
+		logger:  logger,
+	}
+}
+
+func (c *SLOCollector) Collect(ch chan<- prometheus.Metric) {
+	sloTargetGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "slo_participation_target",
+			Help:        "Oracle participation objective over the SLO window",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	sloParticipationGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "slo_participation",
+			Help:        "Oracle participation of a given validator over the SLO window",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	sloErrorBudgetGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "slo_error_budget_remaining",
+			Help:        "Share of the allowed misses a given validator has left in the SLO window, negative once exceeded",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	sloCoveredGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "slo_window_covered_seconds",
+			Help:        "Part of the SLO window the history of a given validator covers",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	metrics := []prometheus.Collector{
+		sloTargetGauge,
+		sloParticipationGauge,
+		sloErrorBudgetGauge,
+		sloCoveredGauge,
+	}
+
+	sloTargetGauge.Set(c.target)
+
+	if c.oracle == nil {
+		c.logger.Error().Str("chain-type", CurrentConfig().Chain.Type).Msg("Could not create oracle provider")
+		return
+	}
+
+	c.logger.Debug().Msg("Started querying oracle params")
+	queryStart := time.Now()
+
+	params, err := c.oracle.Params(c.ctx)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Could not get oracle params")
+		return
+	}
+
+	c.logger.Debug().
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying oracle params")
+
+	status, ok := NewSLOStatus(c.alerter.History(c.valoper), time.Now().UTC(), c.window, params.VotePeriod, c.target)
+	if ok {
+		labels := prometheus.Labels{"valoper": c.valoper}
+		sloParticipationGauge.With(labels).Set(status.Participation)
+		sloErrorBudgetGauge.With(labels).Set(status.ErrorBudgetRemaining)
+		sloCoveredGauge.With(labels).Set(status.Covered.Seconds())
+	} else {
+		c.logger.Debug().Str("valoper", c.valoper).Msg("Not enough history for the SLO yet")
+	}
+
+	collectMetrics(ch, metrics)
+}
+
+// SLOHandler serves the participation SLO of the validator given with ?valoper=.
+func SLOHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	oracle OracleProvider,
+	alerter *Alerter,
+	target float64,
+	window time.Duration,
+) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	valoper := r.URL.Query().Get("valoper")
+	if _, err := ValAddressFromBech32(valoper); err != nil {
+		sublogger.Error().
+			Str("valoper", valoper).
+			Err(err).
+			Msg("Could not get validator address")
+		return
+	}
+
+	registry := collectorRegistry(NewSLOCollector(r.Context(), oracle, alerter, target, window, valoper, sublogger))
+
+	h := promhttp.HandlerFor(ExportTargetGatherer(registry, valoper), promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/slo?valoper="+valoper).
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
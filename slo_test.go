@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+)
+
+type fakeOracleProvider struct {
+	OracleProvider
+	params *OracleParams
+}
+
+func (p *fakeOracleProvider) Params(context.Context) (*OracleParams, error) {
+	return p.params, nil
+}
+
+func TestSLOCollector(t *testing.T) {
+	now := time.Now().UTC()
+	valoper := "umeevaloper1test"
+
+	alerter := &Alerter{
+		history: map[string][]HistorySample{
+			valoper: {
+				{Time: now.Add(-2 * time.Hour), MissCounter: 10, Height: 1000},
+				{Time: now.Add(-time.Hour), MissCounter: 12, Height: 1100},
+				{Time: now, MissCounter: 15, Height: 1200},
+			},
+		},
+	}
+
+	oracle := &fakeOracleProvider{params: &OracleParams{VotePeriod: 5}}
+	collector := NewSLOCollector(context.Background(), oracle, alerter, 0.75, 24*time.Hour, valoper, zerolog.Nop())
+
+	// 40 expected votes with 5 misses, 10 allowed by the target
+	expected := `
+# HELP slo_error_budget_remaining Share of the allowed misses a given validator has left in the SLO window, negative once exceeded
+# TYPE slo_error_budget_remaining gauge
+slo_error_budget_remaining{valoper="umeevaloper1test"} 0.5
+# HELP slo_participation Oracle participation of a given validator over the SLO window
+# TYPE slo_participation gauge
+slo_participation{valoper="umeevaloper1test"} 0.875
+# HELP slo_participation_target Oracle participation objective over the SLO window
+# TYPE slo_participation_target gauge
+slo_participation_target 0.75
+# HELP slo_window_covered_seconds Part of the SLO window the history of a given validator covers
+# TYPE slo_window_covered_seconds gauge
+slo_window_covered_seconds{valoper="umeevaloper1test"} 7200
+`
+
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSLOCollectorWithoutHistory(t *testing.T) {
+	oracle := &fakeOracleProvider{params: &OracleParams{VotePeriod: 5}}
+	collector := NewSLOCollector(context.Background(), oracle, &Alerter{}, 0.9, 24*time.Hour, "umeevaloper1test", zerolog.Nop())
+
+	expected := `
+# HELP slo_participation_target Oracle participation objective over the SLO window
+# TYPE slo_participation_target gauge
+slo_participation_target 0.9
+`
+
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
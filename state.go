@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const stateSnapshotVersion = 1
+
+// StateSnapshot is everything the exporter keeps between restarts. It is
+// written to --state-file and is also the format of export/import.
+type StateSnapshot struct {
+	Version    int                            `json:"version"`
+	ExportedAt time.Time                      `json:"exported_at"`
+	Alerts     map[string]validatorAlertState `json:"alerts"`
+	History    map[string][]HistorySample     `json:"history,omitempty"`
+	// valoper -> alert rule -> notification state, missing in states of older versions
+	Notifications map[string]map[string]alertRuleState `json:"notifications,omitempty"`
+	// mutes set through the API, target -> end of the mute
+	Mutes map[string]time.Time `json:"mutes,omitempty"`
+	// records of the scrapes kept in --state-db, bucket -> key -> record,
+	// only in exported snapshots
+	Store map[string]map[string]json.RawMessage `json:"store,omitempty"`
+}
+
+// HistorySample is what was observed about a validator at a given time,
+// the reports are rendered from these.
+type HistorySample struct {
+	Time        time.Time `json:"time"`
+	MissCounter uint64    `json:"miss_counter"`
+	Jailed      bool      `json:"jailed"`
+	// block height of the sample, missing in states of older versions
+	Height int64 `json:"height,omitempty"`
+}
+
+func NewStateSnapshot() *StateSnapshot {
+	return &StateSnapshot{
+		Version: stateSnapshotVersion,
+		Alerts:  make(map[string]validatorAlertState),
+		History: make(map[string][]HistorySample),
+	}
+}
+
+func ReadStateSnapshot(reader io.Reader) (*StateSnapshot, error) {
+	snapshot := NewStateSnapshot()
+	if err := json.NewDecoder(reader).Decode(snapshot); err != nil {
+		return nil, err
+	}
+
+	if snapshot.Version != stateSnapshotVersion {
+		return nil, fmt.Errorf("unsupported state snapshot version %d, expected %d", snapshot.Version, stateSnapshotVersion)
+	}
+
+	if snapshot.Alerts == nil {
+		snapshot.Alerts = make(map[string]validatorAlertState)
+	}
+
+	if snapshot.History == nil {
+		snapshot.History = make(map[string][]HistorySample)
+	}
+
+	return snapshot, nil
+}
+
+func (s *StateSnapshot) Write(writer io.Writer) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// LoadState reads the state file, a missing file is an empty state.
+func LoadState(path string) (*StateSnapshot, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewStateSnapshot(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadStateSnapshot(file)
+}
+
+// SaveState replaces the state file atomically so a crash never leaves it half written.
+func SaveState(path string, snapshot *StateSnapshot) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	snapshot.Version = stateSnapshotVersion
+	if err := snapshot.Write(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Export or import the persisted exporter state",
+	Long: "Export or import the state of --state-file and the records of the scrapes in --state-db. " +
+		"The exporter locks --state-db while it runs, stop it before exporting or importing them.",
+}
+
+var stateExportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Write the state snapshot to a file or stdout",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endpoints := CurrentConfig().Endpoints
+		if endpoints.StateFile == "" && endpoints.StateDB == "" {
+			return errors.New("neither --state-file nor --state-db is set")
+		}
+
+		snapshot := NewStateSnapshot()
+		if endpoints.StateFile != "" {
+			var err error
+			if snapshot, err = LoadState(endpoints.StateFile); err != nil {
+				return err
+			}
+		}
+
+		if endpoints.StateDB != "" {
+			store, err := exportStateDB(endpoints.StateDB)
+			if err != nil {
+				return err
+			}
+			snapshot.Store = store
+		}
+
+		snapshot.ExportedAt = time.Now().UTC()
+
+		if len(args) == 0 {
+			return snapshot.Write(os.Stdout)
+		}
+
+		if err := SaveState(args[0], snapshot); err != nil {
+			return err
+		}
+
+		log.Info().
+			Str("file", args[0]).
+			Int("validators", len(snapshot.Alerts)).
+			Msg("Exported state")
+		return nil
+	},
+}
+
+var stateImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Replace the state file and the state database with a previously exported snapshot",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endpoints := CurrentConfig().Endpoints
+		if endpoints.StateFile == "" && endpoints.StateDB == "" {
+			return errors.New("neither --state-file nor --state-db is set")
+		}
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		snapshot, err := ReadStateSnapshot(file)
+		if err != nil {
+			return fmt.Errorf("could not read snapshot: %w", err)
+		}
+
+		if endpoints.StateDB != "" {
+			if err := importStateDB(endpoints.StateDB, snapshot.Store); err != nil {
+				return err
+			}
+
+			log.Info().
+				Str("file", endpoints.StateDB).
+				Int("records", len(snapshot.Store[storeBucketStreaks])+len(snapshot.Store[storeBucketBalances])).
+				Msg("Imported scrape records")
+		} else if len(snapshot.Store) > 0 {
+			log.Warn().Msg("The snapshot has records of the scrapes but --state-db is not set, they are not imported")
+		}
+
+		if endpoints.StateFile != "" {
+			// the records of the scrapes only live in --state-db
+			snapshot.Store = nil
+			if err := SaveState(endpoints.StateFile, snapshot); err != nil {
+				return err
+			}
+
+			log.Info().
+				Str("file", endpoints.StateFile).
+				Int("validators", len(snapshot.Alerts)).
+				Msg("Imported state")
+		}
+		return nil
+	},
+}
+
+// exportStateDB reads the records of the scrapes from the state database, a
+// missing database has none.
+func exportStateDB(path string) (map[string]map[string]json.RawMessage, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	store, err := OpenStateStore(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s, it is locked while the exporter runs: %w", path, err)
+	}
+	defer store.Close()
+
+	return store.Export()
+}
+
+// importStateDB replaces the records of the scrapes in the state database.
+func importStateDB(path string, records map[string]map[string]json.RawMessage) error {
+	store, err := OpenStateStore(path)
+	if err != nil {
+		return fmt.Errorf("could not open %s, it is locked while the exporter runs: %w", path, err)
+	}
+
+	if err := store.Import(records); err != nil {
+		store.Close()
+		return err
+	}
+
+	return store.Close()
+}
+
+func init() {
+	stateCmd.AddCommand(stateExportCmd)
+	stateCmd.AddCommand(stateImportCmd)
+}
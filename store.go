@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	storeBucketStreaks  = "streaks"
+	storeBucketBalances = "balances"
+)
+
+var storeBuckets = []string{storeBucketStreaks, storeBucketBalances}
+
+// StateStore persists what the trackers saw at the last scrapes in a bbolt
+// file, so deltas and streaks continue after a restart instead of starting
+// over. Values are stored as JSON. A nil store keeps nothing, the trackers
+// then live in memory only.
+type StateStore struct {
+	db *bolt.DB
+}
+
+func OpenStateStore(path string) (*StateStore, error) {
+	// the timeout fails fast if another exporter holds the file
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	return &StateStore{db: db}, nil
+}
+
+func (s *StateStore) Close() error {
+	if s == nil {
+		return nil
+	}
+
+	return s.db.Close()
+}
+
+func (s *StateStore) Put(bucket string, key string, value interface{}) error {
+	if s == nil {
+		return nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	// Batch coalesces the writes of concurrent scrapes into one transaction
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(key), encoded)
+	})
+}
+
+func (s *StateStore) Delete(bucket string, key string) error {
+	if s == nil {
+		return nil
+	}
+
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.Delete([]byte(key))
+	})
+}
+
+// ForEach calls fn with every key of the bucket and its raw JSON value.
+func (s *StateStore) ForEach(bucket string, fn func(key string, value []byte) error) error {
+	if s == nil {
+		return nil
+	}
+
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			return fn(string(k), v)
+		})
+	})
+}
+
+// Export returns the raw JSON values of every bucket, for the state snapshots.
+func (s *StateStore) Export() (map[string]map[string]json.RawMessage, error) {
+	buckets := make(map[string]map[string]json.RawMessage, len(storeBuckets))
+	for _, bucket := range storeBuckets {
+		values := make(map[string]json.RawMessage)
+		err := s.ForEach(bucket, func(key string, value []byte) error {
+			// the value is only valid within the transaction
+			values[key] = append(json.RawMessage(nil), value...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		buckets[bucket] = values
+	}
+
+	return buckets, nil
+}
+
+// Import replaces the buckets with the values of a state snapshot in one
+// transaction.
+func (s *StateStore) Import(buckets map[string]map[string]json.RawMessage) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range storeBuckets {
+			if err := tx.DeleteBucket([]byte(bucket)); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+				return err
+			}
+
+			b, err := tx.CreateBucket([]byte(bucket))
+			if err != nil {
+				return err
+			}
+
+			for key, value := range buckets[bucket] {
+				if err := b.Put([]byte(key), value); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
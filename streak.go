@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+const (
+	StreakMissedBlocks = "blocks"
+	StreakMissedVotes  = "votes"
+)
+
+// streakMaxRecords bounds the records the tracker keeps, the validators come
+// from the scrape requests.
+const streakMaxRecords = 200
+
+type streakRecord struct {
+	Height  int64  `json:"height"`
+	Counter uint64 `json:"counter"`
+	Streak  uint64 `json:"streak"`
+}
+
+// StreakTracker turns what is seen at each scrape into the number of
+// consecutive misses up to the latest scrape. Missed blocks are checked
+// block by block, see ObserveBlocks, as the missed blocks counter of the
+// signing info slides with the signed blocks window. For votes only the miss
+// counter is known between two scrapes, not the order of the misses: the
+// streak continues when every vote period since the previous scrape was
+// missed, ends on a scrape without new misses, and otherwise restarts from
+// zero, so the value is a lower bound that is exact when scraped at every
+// vote period.
+type StreakTracker struct {
+	mutex   sync.Mutex
+	records map[string]*streakRecord
+	store   *StateStore
+}
+
+// NewStreakTracker restores the records kept in the store, if any.
+func NewStreakTracker(store *StateStore) *StreakTracker {
+	tracker := &StreakTracker{
+		records: make(map[string]*streakRecord),
+		store:   store,
+	}
+
+	err := store.ForEach(storeBucketStreaks, func(key string, value []byte) error {
+		record := &streakRecord{}
+		if err := json.Unmarshal(value, record); err != nil {
+			return err
+		}
+
+		tracker.records[key] = record
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Could not restore the miss streaks")
+	}
+
+	return tracker
+}
+
+// Observe records the miss counter of the kind (StreakMissedVotes) of the
+// validator at the height, where a miss is counted every period blocks, and
+// returns the current streak. The first observation
+// has no previous counter to compare with and returns zero.
+func (t *StreakTracker) Observe(kind string, valoper string, height int64, counter uint64, period uint64) uint64 {
+	key := kind + "/" + valoper
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// saved under the lock, so a concurrent scrape can't write an older record last
+	record, changed := t.observe(key, height, counter, period)
+	if changed {
+		t.save(key, record)
+	}
+
+	return record.Streak
+}
+
+// BlocksToCheck returns the heights of the blocks after the one last
+// checked for the validator, up to height and at most limit of the latest.
+// Nothing is returned on the first observation, which starts the streak.
+func (t *StreakTracker) BlocksToCheck(valoper string, height int64, limit int64) (int64, int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	record, ok := t.records[StreakMissedBlocks+"/"+valoper]
+	if !ok || height <= record.Height {
+		return 0, -1
+	}
+
+	from := record.Height + 1
+	if height-from+1 > limit {
+		from = height - limit + 1
+	}
+
+	return from, height
+}
+
+// ObserveBlocks extends the streak of missed blocks of the validator with
+// the blocks from from on, missed tells for each whether the validator didn't
+// sign it, and returns the current streak. Blocks skipped since the previous
+// check, beyond the limit of BlocksToCheck, restart the streak, so it is a
+// lower bound then.
+func (t *StreakTracker) ObserveBlocks(valoper string, height int64, from int64, missed []bool) uint64 {
+	key := StreakMissedBlocks + "/" + valoper
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	record, ok := t.records[key]
+	if !ok {
+		record = &streakRecord{Height: height}
+		t.add(key, record)
+		t.save(key, *record)
+		return 0
+	}
+
+	if height <= record.Height {
+		return record.Streak
+	}
+
+	if from > record.Height+1 {
+		record.Streak = 0
+	}
+
+	for _, blockMissed := range missed {
+		if blockMissed {
+			record.Streak++
+		} else {
+			record.Streak = 0
+		}
+	}
+
+	// the checked blocks end before height if some couldn't be queried
+	record.Height = from - 1 + int64(len(missed))
+	t.save(key, *record)
+
+	return record.Streak
+}
+
+func (t *StreakTracker) save(key string, record streakRecord) {
+	if err := t.store.Put(storeBucketStreaks, key, record); err != nil {
+		log.Error().Err(err).Str("key", key).Msg("Could not save the miss streak")
+	}
+}
+
+// add starts a record, evicting the one observed at the lowest height, the
+// validator scraped the longest ago, once the tracker is full.
+func (t *StreakTracker) add(key string, record *streakRecord) {
+	if len(t.records) >= streakMaxRecords {
+		oldest := ""
+		for candidate, existing := range t.records {
+			if oldest == "" || existing.Height < t.records[oldest].Height {
+				oldest = candidate
+			}
+		}
+
+		delete(t.records, oldest)
+		if err := t.store.Delete(storeBucketStreaks, oldest); err != nil {
+			log.Error().Err(err).Str("key", oldest).Msg("Could not delete the miss streak")
+		}
+	}
+
+	t.records[key] = record
+}
+
+func (t *StreakTracker) observe(key string, height int64, counter uint64, period uint64) (streakRecord, bool) {
+	record, ok := t.records[key]
+	if !ok {
+		record = &streakRecord{Height: height, Counter: counter}
+		t.add(key, record)
+		return *record, true
+	}
+
+	// the same or an older block, e.g. two scrapes within a block
+	if height <= record.Height {
+		return *record, false
+	}
+
+	if period == 0 {
+		period = 1
+	}
+
+	elapsed := uint64(height)/period - uint64(record.Height)/period
+
+	// the counter was reset at the end of a slash window, all its misses are newer
+	missed := counter
+	if counter >= record.Counter {
+		missed = counter - record.Counter
+	}
+
+	switch {
+	case missed == 0:
+		record.Streak = 0
+	case missed >= elapsed:
+		record.Streak += elapsed
+	default:
+		record.Streak = 0
+	}
+
+	record.Height = height
+	record.Counter = counter
+
+	return *record, true
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SdNotify sends the state, e.g. READY=1, to systemd over $NOTIFY_SOCKET.
+// It does nothing when the exporter isn't run by systemd with Type=notify.
+func SdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	// abstract sockets are given with a leading @
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// WatchdogInterval returns the interval to ping the systemd watchdog at, half
+// of WatchdogSec, or 0 if the watchdog isn't enabled for this process.
+func WatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// RunWatchdog pings the systemd watchdog at every interval as long as check
+// passes, so systemd restarts an exporter which hangs for WatchdogSec.
+func RunWatchdog(interval time.Duration, check func() error) {
+	logger := log.With().Str("component", "watchdog").Logger()
+	logger.Info().Dur("interval", interval).Msg("Started pinging the systemd watchdog")
+
+	for {
+		if err := check(); err != nil {
+			logger.Warn().Err(err).Msg("Health check failed, not pinging the watchdog")
+		} else if err := SdNotify("WATCHDOG=1"); err != nil {
+			logger.Error().Err(err).Msg("Could not ping the watchdog")
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// pingHTTP requests the URL of the exporter itself, it fails unless the
+// server answers 200 within the timeout.
+func pingHTTP(url string, timeout time.Duration) error {
+	client := &http.Client{
+		Timeout: timeout,
+		// the certificate is issued for the public name, not for localhost
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+
+	response, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", url, response.Status)
+	}
+
+	return nil
+}
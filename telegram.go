@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const telegramAPIURL = "https://api.telegram.org"
+
+type TelegramNotifier struct {
+	Token  string
+	ChatID string
+
+	client *http.Client
+}
+
+func NewTelegramNotifier(token string, chatID string) *TelegramNotifier {
+	return &TelegramNotifier{
+		Token:  token,
+		ChatID: chatID,
+		client: newHTTPClient(10 * time.Second),
+	}
+}
+
+func (n *TelegramNotifier) Name() string {
+	return "telegram"
+}
+
+func (n *TelegramNotifier) Notify(ctx context.Context, message string) error {
+	body, err := json.Marshal(map[string]string{
+		"chat_id":    n.ChatID,
+		"text":       message,
+		"parse_mode": "HTML",
+	})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/bot%s/sendMessage", telegramAPIURL, n.Token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram responded with status %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+)
+
+// nodeCommitWindow is the number of recent commits checked for the
+// signature of the validator.
+const nodeCommitWindow = 10
+
+// blockIDFlagCommit marks a signature for the committed block.
+const blockIDFlagCommit = 2
+
+// TendermintClient queries the Tendermint RPC of the node, for the node-level
+// state the gRPC services of the SDK don't expose.
+type TendermintClient struct {
+	endpoint string
+	client   *http.Client
+}
+
+// tendermintClient is set with --tendermint-rpc.
+var tendermintClient *TendermintClient
+
+func NewTendermintClient(endpoint string) *TendermintClient {
+	return &TendermintClient{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   newHTTPClient(10 * time.Second),
+	}
+}
+
+type tendermintStatus struct {
+	SyncInfo struct {
+		LatestBlockHeight string    `json:"latest_block_height"`
+		LatestBlockTime   time.Time `json:"latest_block_time"`
+		CatchingUp        bool      `json:"catching_up"`
+	} `json:"sync_info"`
+}
+
+type tendermintNetInfo struct {
+	NPeers string `json:"n_peers"`
+}
+
+type tendermintCommit struct {
+	SignedHeader struct {
+		Commit struct {
+			Signatures []struct {
+				BlockIDFlag      int    `json:"block_id_flag"`
+				ValidatorAddress string `json:"validator_address"`
+			} `json:"signatures"`
+		} `json:"commit"`
+	} `json:"signed_header"`
+}
+
+// call sends a JSON-RPC request over the URI interface of the RPC.
+func (c *TendermintClient) call(ctx context.Context, method string, result interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"/"+method, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var body struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+			Data    string `json:"data"`
+		} `json:"error"`
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return fmt.Errorf("could not decode %s response: %w", method, err)
+	}
+
+	if body.Error != nil {
+		return fmt.Errorf("%s: %s %s", method, body.Error.Message, body.Error.Data)
+	}
+
+	return json.Unmarshal(body.Result, result)
+}
+
+// NodeHealthCollector exports the sync state and connectivity of the node, as
+// oracle misses are often caused by a node falling behind, and with a
+// validator whether it signed the recent blocks.
+type NodeHealthCollector struct {
+	uncheckedCollector
+
+	ctx        context.Context
+	grpcConn   *grpc.ClientConn
+	tendermint *TendermintClient
+	valoper    string
+	logger     zerolog.Logger
+}
+
+func NewNodeHealthCollector(
+	ctx context.Context,
+	grpcConn *grpc.ClientConn,
+	tendermint *TendermintClient,
+	valoper string,
+	logger zerolog.Logger,
+) *NodeHealthCollector {
+	return &NodeHealthCollector{
+		ctx:        ctx,
+		grpcConn:   grpcConn,
+		tendermint: tendermint,
+		valoper:    valoper,
+		logger:     logger,
+	}
+}
+
+func (c *NodeHealthCollector) Collect(ch chan<- prometheus.Metric) {
+	nodeCatchingUpGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "node_catching_up",
+			Help:        "Whether the node is catching up with the chain",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	nodeLatestBlockHeightGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "node_latest_block_height",
+			Help:        "Height of the latest block of the node",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	nodeLatestBlockTimeGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "node_latest_block_time",
+			Help:        "Unix time of the latest block of the node",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	nodeTimeSinceLastBlockGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "node_time_since_last_block_seconds",
+			Help:        "Seconds since the latest block of the node",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	nodePeersGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "node_peers",
+			Help:        "Number of peers of the node",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	validatorRecentCommitsSignedGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_recent_commits_signed",
+			Help:        fmt.Sprintf("Number of the last %d commits signed by a given validator", nodeCommitWindow),
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	metrics := []prometheus.Collector{
+		nodeCatchingUpGauge,
+		nodeLatestBlockHeightGauge,
+		nodeLatestBlockTimeGauge,
+		nodeTimeSinceLastBlockGauge,
+		nodePeersGauge,
+	}
+	if c.valoper != "" {
+		metrics = append(metrics, validatorRecentCommitsSignedGauge)
+	}
+
+	c.logger.Debug().Msg("Started querying node status")
+	queryStart := time.Now()
+
+	var nodeStatus tendermintStatus
+	if err := c.tendermint.call(c.ctx, "status", &nodeStatus); err != nil {
+		c.logger.Error().Err(err).Msg("Could not get node status")
+		return
+	}
+
+	c.logger.Debug().
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying node status")
+
+	latestHeight, err := strconv.ParseInt(nodeStatus.SyncInfo.LatestBlockHeight, 10, 64)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Could not parse latest block height")
+		return
+	}
+
+	catchingUp := 0.0
+	if nodeStatus.SyncInfo.CatchingUp {
+		catchingUp = 1
+	}
+
+	nodeCatchingUpGauge.Set(catchingUp)
+	nodeLatestBlockHeightGauge.Set(float64(latestHeight))
+	nodeLatestBlockTimeGauge.Set(float64(nodeStatus.SyncInfo.LatestBlockTime.Unix()))
+	nodeTimeSinceLastBlockGauge.Set(time.Since(nodeStatus.SyncInfo.LatestBlockTime).Seconds())
+
+	group, groupCtx := errgroup.WithContext(c.ctx)
+
+	group.Go(func() error {
+		c.logger.Debug().Msg("Started querying node peers")
+		queryStart := time.Now()
+
+		var netInfo tendermintNetInfo
+		if err := c.tendermint.call(groupCtx, "net_info", &netInfo); err != nil {
+			c.logger.Error().Err(err).Msg("Could not get node peers")
+			return nil
+		}
+
+		c.logger.Debug().
+			Float64("request-time", time.Since(queryStart).Seconds()).
+			Msg("Finished querying node peers")
+
+		peers, err := strconv.Atoi(netInfo.NPeers)
+		if err != nil {
+			c.logger.Error().Err(err).Msg("Could not parse node peers")
+			return nil
+		}
+
+		nodePeersGauge.Set(float64(peers))
+		return nil
+	})
+
+	if c.valoper != "" {
+		group.Go(func() error {
+			c.logger.Debug().
+				Str("valoper", c.valoper).
+				Msg("Started querying recent commits")
+			queryStart := time.Now()
+
+			stakingClient := stakingtypes.NewQueryClient(c.grpcConn)
+			validatorResponse, err := stakingClient.Validator(
+				groupCtx,
+				&stakingtypes.QueryValidatorRequest{ValidatorAddr: c.valoper},
+			)
+			if err != nil {
+				c.logger.Error().
+					Str("valoper", c.valoper).
+					Err(err).
+					Msg("Could not get validator")
+				return nil
+			}
+
+			consAddress, err := ConsensusAddress(validatorResponse.Validator)
+			if err != nil {
+				c.logger.Error().
+					Str("valoper", c.valoper).
+					Err(err).
+					Msg("Could not get validator consensus address")
+				return nil
+			}
+
+			address := strings.ToUpper(hex.EncodeToString(consAddress))
+
+			// the latest block isn't committed yet
+			signed := 0
+			for height := latestHeight - 1; height > 0 && height >= latestHeight-nodeCommitWindow; height-- {
+				var commit tendermintCommit
+				if err := c.tendermint.call(groupCtx, "commit?height="+strconv.FormatInt(height, 10), &commit); err != nil {
+					c.logger.Error().
+						Int64("height", height).
+						Err(err).
+						Msg("Could not get commit")
+					return nil
+				}
+
+				for _, signature := range commit.SignedHeader.Commit.Signatures {
+					if signature.BlockIDFlag == blockIDFlagCommit && strings.EqualFold(signature.ValidatorAddress, address) {
+						signed++
+						break
+					}
+				}
+			}
+
+			c.logger.Debug().
+				Str("valoper", c.valoper).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying recent commits")
+
+			validatorRecentCommitsSignedGauge.With(prometheus.Labels{
+				"valoper": c.valoper,
+			}).Set(float64(signed))
+
+			return nil
+		})
+	}
+
+	_ = group.Wait()
+
+	collectMetrics(ch, metrics)
+}
+
+// NodeHealthHandler serves the health of the node and, with ?valoper=, the
+// recent commits signed by the validator.
+func NodeHealthHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn, tendermint *TendermintClient) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	valoper := r.URL.Query().Get("valoper")
+	if valoper != "" {
+		if _, err := ValAddressFromBech32(valoper); err != nil {
+			sublogger.Error().
+				Str("valoper", valoper).
+				Err(err).
+				Msg("Could not get validator address")
+			return
+		}
+	}
+
+	registry := collectorRegistry(NewNodeHealthCollector(r.Context(), grpcConn, tendermint, valoper, sublogger))
+
+	h := promhttp.HandlerFor(ExportTargetGatherer(registry, valoper), promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/node?valoper="+valoper).
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
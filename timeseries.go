@@ -0,0 +1,409 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog"
+)
+
+// maxPointsPerSeries is the size of the ring buffer of every series, older
+// points are overwritten once it's full even within the retention.
+const maxPointsPerSeries = 2880
+
+// maxSeries bounds the memory used by series with many label values.
+const maxSeries = 10000
+
+type timeSeriesPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+type timeSeries struct {
+	name   string
+	labels map[string]string
+	// ring buffer, grows up to maxPointsPerSeries
+	points []timeSeriesPoint
+	// index of the oldest point once the buffer is full
+	next int
+}
+
+func (s *timeSeries) append(point timeSeriesPoint) {
+	if len(s.points) < maxPointsPerSeries {
+		s.points = append(s.points, point)
+		return
+	}
+
+	s.points[s.next] = point
+	s.next = (s.next + 1) % maxPointsPerSeries
+}
+
+func (s *timeSeries) last() timeSeriesPoint {
+	return s.points[(s.next-1+len(s.points))%len(s.points)]
+}
+
+// since returns the points newer than from, oldest first.
+func (s *timeSeries) since(from time.Time) []timeSeriesPoint {
+	points := make([]timeSeriesPoint, 0, len(s.points))
+	for index := range s.points {
+		point := s.points[(s.next+index)%len(s.points)]
+		if !point.Time.Before(from) {
+			points = append(points, point)
+		}
+	}
+
+	return points
+}
+
+// TimeSeriesStore keeps the recent values of selected metrics in memory, so
+// bots and other lightweight consumers can graph short-term history without
+// running Prometheus. The values are recorded whenever a metrics endpoint is
+// gathered, by a scrape or by the internal sampler.
+type TimeSeriesStore struct {
+	metrics   map[string]bool
+	retention time.Duration
+	logger    zerolog.Logger
+
+	mutex  sync.RWMutex
+	series map[string]*timeSeries
+}
+
+// timeSeriesStore is set with --timeseries-retention.
+var timeSeriesStore *TimeSeriesStore
+
+func NewTimeSeriesStore(metrics []string, retention time.Duration) *TimeSeriesStore {
+	store := &TimeSeriesStore{
+		metrics:   make(map[string]bool, len(metrics)),
+		retention: retention,
+		logger:    log.With().Str("component", "timeseries").Logger(),
+		series:    make(map[string]*timeSeries),
+	}
+
+	for _, metric := range metrics {
+		store.metrics[metric] = true
+	}
+
+	return store
+}
+
+// Gatherer records the selected metrics of every gathering of gatherer.
+func (s *TimeSeriesStore) Gatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	if s == nil {
+		return gatherer
+	}
+
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+		if err == nil {
+			s.record(families, time.Now())
+		}
+
+		return families, err
+	})
+}
+
+func (s *TimeSeriesStore) record(families []*dto.MetricFamily, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, family := range families {
+		if !s.metrics[family.GetName()] {
+			continue
+		}
+
+		for _, metric := range family.Metric {
+			var value float64
+			switch {
+			case metric.Gauge != nil:
+				value = metric.Gauge.GetValue()
+			case metric.Counter != nil:
+				value = metric.Counter.GetValue()
+			case metric.Untyped != nil:
+				value = metric.Untyped.GetValue()
+			default:
+				continue
+			}
+
+			labels := make(map[string]string, len(metric.Label))
+			for _, label := range metric.Label {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			key := seriesKey(family.GetName(), labels)
+			series, ok := s.series[key]
+			if !ok {
+				if len(s.series) >= maxSeries {
+					continue
+				}
+
+				series = &timeSeries{name: family.GetName(), labels: labels}
+				s.series[key] = series
+			}
+
+			series.append(timeSeriesPoint{Time: now, Value: value})
+		}
+	}
+
+	// series that weren't seen for the whole retention are dropped
+	for key, series := range s.series {
+		if now.Sub(series.last().Time) > s.retention {
+			delete(s.series, key)
+		}
+	}
+}
+
+func seriesKey(name string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for label := range labels {
+		names = append(names, label)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	key.WriteString(name)
+	for _, label := range names {
+		key.WriteByte(0)
+		key.WriteString(label)
+		key.WriteByte(0)
+		key.WriteString(labels[label])
+	}
+
+	return key.String()
+}
+
+// StartSampling requests the given metrics paths every interval, so the
+// history is recorded even if nothing scrapes the exporter. The requests go
+// to the handlers directly and don't pass the authentication.
+func (s *TimeSeriesStore) StartSampling(routes http.Handler, paths []string, interval time.Duration) {
+	s.logger.Info().
+		Strs("paths", paths).
+		Dur("interval", interval).
+		Msg("Started sampling metrics")
+
+	for {
+		for _, path := range paths {
+			request := httptest.NewRequest(http.MethodGet, path, nil)
+			recorder := httptest.NewRecorder()
+			routes.ServeHTTP(recorder, request)
+
+			if recorder.Code != http.StatusOK {
+				s.logger.Warn().Str("path", path).Int("status", recorder.Code).Msg("Could not sample metrics")
+			}
+		}
+
+		<-time.After(interval)
+	}
+}
+
+// labelMatcher is a label condition of a selector, label="value" or
+// label!="value".
+type labelMatcher struct {
+	name   string
+	value  string
+	negate bool
+}
+
+// ParseSelector parses the mini query language of /api/query, a metric name
+// with optional label matchers like in PromQL, e.g.
+// miss_counter{valoper="umeevaloper1...",denom!="uumee"}.
+func ParseSelector(selector string) (string, []labelMatcher, error) {
+	selector = strings.TrimSpace(selector)
+
+	name, rest, hasLabels := strings.Cut(selector, "{")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, fmt.Errorf("missing metric name in %q", selector)
+	}
+
+	if !hasLabels {
+		return name, nil, nil
+	}
+
+	rest, ok := strings.CutSuffix(strings.TrimSpace(rest), "}")
+	if !ok {
+		return "", nil, fmt.Errorf("missing closing brace in %q", selector)
+	}
+
+	var matchers []labelMatcher
+	for _, condition := range strings.Split(rest, ",") {
+		condition = strings.TrimSpace(condition)
+		if condition == "" {
+			continue
+		}
+
+		matcher := labelMatcher{}
+		separator := "="
+		if strings.Contains(condition, "!=") {
+			separator = "!="
+			matcher.negate = true
+		}
+
+		label, value, ok := strings.Cut(condition, separator)
+		if !ok {
+			return "", nil, fmt.Errorf("invalid label matcher %q", condition)
+		}
+
+		matcher.name = strings.TrimSpace(label)
+		matcher.value = strings.Trim(strings.TrimSpace(value), `"`)
+		matchers = append(matchers, matcher)
+	}
+
+	return name, matchers, nil
+}
+
+func (m labelMatcher) matches(labels map[string]string) bool {
+	return (labels[m.name] == m.value) != m.negate
+}
+
+type queryResult struct {
+	Metric map[string]string `json:"metric"`
+	// [unix seconds, "value"] pairs like the Prometheus HTTP API
+	Values [][2]interface{} `json:"values"`
+}
+
+// Query returns the series of the metric matching all matchers with their
+// points within the range, averaged per step if step is positive.
+func (s *TimeSeriesStore) Query(name string, matchers []labelMatcher, from time.Time, step time.Duration) []queryResult {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	results := []queryResult{}
+	for _, series := range s.series {
+		if series.name != name {
+			continue
+		}
+
+		matches := true
+		for _, matcher := range matchers {
+			if !matcher.matches(series.labels) {
+				matches = false
+				break
+			}
+		}
+
+		if !matches {
+			continue
+		}
+
+		points := series.since(from)
+		if len(points) == 0 {
+			continue
+		}
+
+		metric := map[string]string{"__name__": name}
+		for label, value := range series.labels {
+			metric[label] = value
+		}
+
+		results = append(results, queryResult{Metric: metric, Values: downsample(points, step)})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return seriesKey(name, results[i].Metric) < seriesKey(name, results[j].Metric)
+	})
+
+	return results
+}
+
+func downsample(points []timeSeriesPoint, step time.Duration) [][2]interface{} {
+	value := func(at time.Time, value float64) [2]interface{} {
+		return [2]interface{}{at.Unix(), strconv.FormatFloat(value, 'f', -1, 64)}
+	}
+
+	values := make([][2]interface{}, 0, len(points))
+	if step <= 0 {
+		for _, point := range points {
+			values = append(values, value(point.Time, point.Value))
+		}
+
+		return values
+	}
+
+	var bucket time.Time
+	var sum float64
+	var count int
+	for _, point := range points {
+		start := point.Time.Truncate(step)
+		if count > 0 && !start.Equal(bucket) {
+			values = append(values, value(bucket, sum/float64(count)))
+			sum, count = 0, 0
+		}
+
+		bucket = start
+		sum += point.Value
+		count++
+	}
+
+	if count > 0 {
+		values = append(values, value(bucket, sum/float64(count)))
+	}
+
+	return values
+}
+
+// TimeSeriesHandler serves the recorded history of a metric as JSON, e.g.
+// /api/query?metric=miss_counter{valoper="..."}&range=6h&step=5m.
+func TimeSeriesHandler(w http.ResponseWriter, r *http.Request, store *TimeSeriesStore) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	query := r.URL.Query()
+	name, matchers, err := ParseSelector(query.Get("metric"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !store.metrics[name] {
+		http.Error(w, fmt.Sprintf("metric %q is not recorded, see --timeseries-metrics", name), http.StatusBadRequest)
+		return
+	}
+
+	timeRange := store.retention
+	if value := query.Get("range"); value != "" {
+		if timeRange, err = time.ParseDuration(value); err != nil || timeRange <= 0 {
+			http.Error(w, fmt.Sprintf("invalid range %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var step time.Duration
+	if value := query.Get("step"); value != "" {
+		if step, err = time.ParseDuration(value); err != nil || step <= 0 {
+			http.Error(w, fmt.Sprintf("invalid step %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	results := store.Query(name, matchers, time.Now().Add(-timeRange), step)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"resultType": "matrix",
+			"result":     results,
+		},
+	}); err != nil {
+		sublogger.Error().Err(err).Msg("Could not write query response")
+	}
+
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/api/query?metric="+query.Get("metric")).
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
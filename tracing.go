@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	spanKindServer = 2
+	spanKindClient = 3
+
+	spanStatusError = 2
+)
+
+// maxBufferedSpans bounds the memory used while the collector is unreachable,
+// newer spans are dropped beyond it.
+const maxBufferedSpans = 10000
+
+// Span is a timed operation of a trace, a scrape or a gRPC query within it.
+type Span struct {
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	err        string
+}
+
+type spanContextKey struct{}
+
+// Tracer records the spans of the scrapes and sends them to an OpenTelemetry
+// collector with OTLP over HTTP, using the JSON encoding so no OpenTelemetry
+// SDK is needed.
+type Tracer struct {
+	endpoint string
+	headers  map[string]string
+	service  string
+	interval time.Duration
+	client   *http.Client
+	logger   zerolog.Logger
+
+	mutex   sync.Mutex
+	spans   []*Span
+	dropped int
+}
+
+// tracer is set with --otlp-endpoint, spans are not recorded without it.
+var tracer *Tracer
+
+func NewTracer(endpoint string, headers map[string]string, service string, interval time.Duration) *Tracer {
+	return &Tracer{
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		headers:  headers,
+		service:  service,
+		interval: interval,
+		client:   newHTTPClient(10 * time.Second),
+		logger:   log.With().Str("component", "tracer").Logger(),
+	}
+}
+
+// StartSpan starts a span as child of the span in the context, or a new
+// trace if there is none. It returns nil if tracing is disabled, the methods
+// of Span accept a nil receiver.
+func StartSpan(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]string),
+	}
+	rand.Read(span.spanID[:])
+
+	if parent, ok := ctx.Value(spanContextKey{}).(*Span); ok {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		rand.Read(span.traceID[:])
+	}
+
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// ContinueTrace puts the span of the W3C traceparent header of the request
+// into the context, so a scrape started by a traced client joins its trace.
+func ContinueTrace(r *http.Request) context.Context {
+	parts := strings.Split(r.Header.Get("traceparent"), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return r.Context()
+	}
+
+	parent := &Span{}
+	if _, err := hex.Decode(parent.traceID[:], []byte(parts[1])); err != nil {
+		return r.Context()
+	}
+	if _, err := hex.Decode(parent.spanID[:], []byte(parts[2])); err != nil {
+		return r.Context()
+	}
+
+	return context.WithValue(r.Context(), spanContextKey{}, parent)
+}
+
+func (s *Span) SetAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+
+	s.attributes[key] = value
+}
+
+// End finishes the span, marking it failed if err is set, and queues it.
+func (s *Span) End(err error) {
+	if s == nil || tracer == nil {
+		return
+	}
+
+	s.end = time.Now()
+	if err != nil {
+		s.err = err.Error()
+	}
+
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+
+	if len(tracer.spans) >= maxBufferedSpans {
+		tracer.dropped++
+		return
+	}
+
+	tracer.spans = append(tracer.spans, s)
+}
+
+func (s *Span) traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:]))
+}
+
+// Interceptor records a span for every gRPC query made within a traced
+// scrape and passes the trace on to the node in the traceparent metadata.
+func (t *Tracer) Interceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		// background queries of the alerter and the like are not traced
+		if _, ok := ctx.Value(spanContextKey{}).(*Span); !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		ctx, span := StartSpan(ctx, strings.TrimPrefix(method, "/"), spanKindClient)
+		span.SetAttribute("rpc.system", "grpc")
+		span.SetAttribute("rpc.method", method)
+		span.SetAttribute("net.peer.name", cc.Target())
+
+		ctx = metadata.AppendToOutgoingContext(ctx, "traceparent", span.traceparent())
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		span.SetAttribute("rpc.grpc.status_code", strconv.Itoa(int(status.Code(err))))
+		span.End(err)
+
+		return err
+	}
+}
+
+func (t *Tracer) Start() {
+	t.logger.Info().Str("endpoint", t.endpoint).Msg("Started exporting traces")
+
+	for {
+		<-time.After(t.interval)
+		t.flush()
+	}
+}
+
+func (t *Tracer) flush() {
+	t.mutex.Lock()
+	spans := t.spans
+	dropped := t.dropped
+	t.spans = nil
+	t.dropped = 0
+	t.mutex.Unlock()
+
+	if dropped > 0 {
+		t.logger.Warn().Int("spans", dropped).Msg("Dropped spans, the buffer is full")
+	}
+
+	if len(spans) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(t.encode(spans))
+	if err != nil {
+		t.logger.Error().Err(err).Msg("Could not encode spans")
+		return
+	}
+
+	request, err := http.NewRequest(http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		t.logger.Error().Err(err).Msg("Could not create traces request")
+		return
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range t.headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := t.client.Do(request)
+	if err != nil {
+		t.logger.Error().Err(err).Int("spans", len(spans)).Msg("Could not export spans")
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		t.logger.Error().Int("status", response.StatusCode).Int("spans", len(spans)).Msg("Could not export spans")
+		return
+	}
+
+	t.logger.Debug().Int("spans", len(spans)).Msg("Exported spans")
+}
+
+// encode builds the OTLP/JSON ExportTraceServiceRequest, ids are hex encoded
+// and timestamps are strings as the OTLP JSON mapping requires.
+func (t *Tracer) encode(spans []*Span) map[string]interface{} {
+	attributes := func(values map[string]string) []map[string]interface{} {
+		encoded := make([]map[string]interface{}, 0, len(values))
+		for key, value := range values {
+			encoded = append(encoded, map[string]interface{}{
+				"key":   key,
+				"value": map[string]string{"stringValue": value},
+			})
+		}
+
+		return encoded
+	}
+
+	encoded := make([]map[string]interface{}, 0, len(spans))
+	for _, span := range spans {
+		entry := map[string]interface{}{
+			"traceId":           hex.EncodeToString(span.traceID[:]),
+			"spanId":            hex.EncodeToString(span.spanID[:]),
+			"name":              span.name,
+			"kind":              span.kind,
+			"startTimeUnixNano": strconv.FormatInt(span.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.end.UnixNano(), 10),
+			"attributes":        attributes(span.attributes),
+		}
+
+		if span.parentID != [8]byte{} {
+			entry["parentSpanId"] = hex.EncodeToString(span.parentID[:])
+		}
+
+		if span.err != "" {
+			entry["status"] = map[string]interface{}{"code": spanStatusError, "message": span.err}
+		}
+
+		encoded = append(encoded, entry)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []map[string]interface{}{{
+			"resource": map[string]interface{}{
+				"attributes": attributes(map[string]string{"service.name": t.service}),
+			},
+			"scopeSpans": []map[string]interface{}{{
+				"scope": map[string]string{"name": "oracle-exporter"},
+				"spans": encoded,
+			}},
+		}},
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	upgradetypes "github.com/cosmos/cosmos-sdk/x/upgrade/types"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+)
+
+// upgradeBlockTimeWindow is the number of blocks the average block time
+// is measured over.
+const upgradeBlockTimeWindow = 1000
+
+// UpgradeCollector exports the current upgrade plan and the estimated time
+// until the chain halts for it, the oracle feeders stop with the chain.
+type UpgradeCollector struct {
+	uncheckedCollector
+
+	ctx       context.Context
+	grpcConn  *grpc.ClientConn
+	blockTime uint64
+	logger    zerolog.Logger
+}
+
+func NewUpgradeCollector(
+	ctx context.Context,
+	grpcConn *grpc.ClientConn,
+	blockTime uint64,
+	logger zerolog.Logger,
+) *UpgradeCollector {
+	return &UpgradeCollector{
+		ctx:       ctx,
+		grpcConn:  grpcConn,
+		blockTime: blockTime,
+		logger:    logger,
+	}
+}
+
+func (c *UpgradeCollector) Collect(ch chan<- prometheus.Metric) {
+	upgradePlanHeightGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "upgrade_plan_height",
+			Help:        "Height of the current upgrade plan",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	upgradePlanNameGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "upgrade_plan_name",
+			Help:        "Name of the current upgrade plan, always 1",
+			ConstLabels: constLabels(),
+		},
+		[]string{"name"},
+	)
+
+	upgradePlanTimeToUpgradeGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "upgrade_plan_time_to_upgrade_seconds",
+			Help:        "Estimated seconds until the upgrade height is reached",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	averageBlockTimeGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "average_block_time_seconds",
+			Help:        "Average block time of the recent blocks",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	metrics := []prometheus.Collector{
+		upgradePlanNameGauge,
+		averageBlockTimeGauge,
+	}
+
+	upgradeClient := upgradetypes.NewQueryClient(c.grpcConn)
+
+	c.logger.Debug().Msg("Started querying current upgrade plan")
+	queryStart := time.Now()
+
+	planResponse, err := upgradeClient.CurrentPlan(c.ctx, &upgradetypes.QueryCurrentPlanRequest{})
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Could not get current upgrade plan")
+		return
+	}
+
+	c.logger.Debug().
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying current upgrade plan")
+
+	c.logger.Debug().Msg("Started querying average block time")
+	queryStart = time.Now()
+
+	serviceClient := tmservice.NewServiceClient(c.grpcConn)
+	averageBlockTime := time.Duration(c.blockTime) * time.Second
+
+	latestResponse, err := serviceClient.GetLatestBlock(c.ctx, &tmservice.GetLatestBlockRequest{})
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Could not get latest block")
+		return
+	}
+
+	latest := latestResponse.Block.Header
+	if latest.Height > upgradeBlockTimeWindow {
+		pastResponse, err := serviceClient.GetBlockByHeight(c.ctx, &tmservice.GetBlockByHeightRequest{
+			Height: latest.Height - upgradeBlockTimeWindow,
+		})
+		if err != nil {
+			// pruned nodes don't keep the older blocks
+			c.logger.Debug().Err(err).Msg("Could not get past block, using configured block time")
+		} else {
+			averageBlockTime = latest.Time.Sub(pastResponse.Block.Header.Time) / upgradeBlockTimeWindow
+		}
+	}
+
+	c.logger.Debug().
+		Dur("average-block-time", averageBlockTime).
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying average block time")
+
+	averageBlockTimeGauge.Set(averageBlockTime.Seconds())
+
+	if plan := planResponse.Plan; plan != nil {
+		upgradePlanHeightGauge.Set(float64(plan.Height))
+		upgradePlanNameGauge.With(prometheus.Labels{"name": plan.Name}).Set(1)
+		upgradePlanTimeToUpgradeGauge.Set(float64(plan.Height-latest.Height) * averageBlockTime.Seconds())
+		metrics = append(metrics, upgradePlanHeightGauge, upgradePlanTimeToUpgradeGauge)
+	}
+
+	collectMetrics(ch, metrics)
+}
+
+// UpgradeHandler serves the upgrade plan, see UpgradeCollector.
+func UpgradeHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn, blockTime uint64) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	registry := collectorRegistry(NewUpgradeCollector(r.Context(), grpcConn, blockTime, sublogger))
+
+	h := promhttp.HandlerFor(ExportGatherer(registry), promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/upgrade").
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
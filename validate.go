@@ -0,0 +1,653 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// ExporterConfig is the typed configuration of the exporter, built from the
+// flags and the config file and rebuilt on every reload, grouped by what it
+// configures. The components read it with CurrentConfig rather than the flag
+// variables, which are only written while loading it.
+type ExporterConfig struct {
+	Chain      ChainConfig
+	Validators []string
+	// validators whose blocks are checked one by one
+	BlockValidators []string
+	// valoper -> expected feeder
+	Feeders     map[string]string
+	Wallets     []string
+	ICQRelayers []string
+	// target -> label -> value
+	ValidatorLabels map[string]map[string]string
+	WalletLabels    map[string]map[string]string
+	Alerts          AlertsConfig
+	Endpoints       EndpointsConfig
+	Queries         QueriesConfig
+	Prices          PricesConfig
+	Metrics         MetricsConfig
+	Denoms          DenomsConfig
+	Probe           ProbeConfig
+	Push            PushConfig
+}
+
+type ChainConfig struct {
+	Type         string
+	Bech32Prefix string
+	BlockTime    uint64
+}
+
+type AlertsConfig struct {
+	Rules            []AlertRuleConfig
+	Routes           map[string]string
+	Mutes            map[string]string
+	Interval         time.Duration
+	Cooldown         time.Duration
+	FeederMinBalance uint64
+	FeederDenom      string
+	IncidentWindow   time.Duration
+	SLOTarget        float64
+	SLOWindow        time.Duration
+}
+
+type EndpointsConfig struct {
+	Node          string
+	NodeFallbacks []string
+	IPFamily      string
+	MaxLag        int64
+	Proxy         string
+	// endpoint -> proxy
+	Proxies map[string]string
+	// endpoint/header -> value
+	Headers map[string]string
+	// endpoint -> user:password
+	BasicAuth     map[string]string
+	TendermintRPC string
+	BatchRPC      string
+	Listen        string
+	ChainListen   string
+	TLSCert       string
+	TLSKey        string
+	AuthUser      string
+	AuthPassword  string
+	AuthToken     string
+	StateFile     string
+	StateDB       string
+}
+
+type QueriesConfig struct {
+	Timeout          time.Duration
+	RetryAttempts    int
+	RetryBackoff     time.Duration
+	RetryMaxBackoff  time.Duration
+	RetryJitter      float64
+	RetryCodes       []string
+	KeepaliveTime    time.Duration
+	KeepaliveTimeout time.Duration
+	MaxRecvMsgSize   int
+	Rate             float64
+	Burst            int
+	EndpointRates    map[string]string
+	CacheTTLs        map[string]string
+	BatchSize        int
+	PageSize         uint64
+	MaxPages         int
+	RouteTimeouts    map[string]string
+	// deadline of a scrape if Prometheus doesn't send a shorter one
+	ScrapeTimeout       time.Duration
+	ScrapeTimeoutOffset time.Duration
+	// whether ?height= is accepted
+	Historical bool
+}
+
+type PricesConfig struct {
+	Providers    []string
+	CoinGeckoIDs map[string]string
+	BinanceQuote string
+	PythIDs      map[string]string
+	APIKeys      map[string]string
+	Budgets      map[string]int64
+	BudgetPeriod time.Duration
+	CacheTTLs    map[string]string
+}
+
+type MetricsConfig struct {
+	Schemas            []string
+	Namespace          string
+	Renames            map[string]string
+	DisabledCollectors []string
+	Include            []string
+	Exclude            []string
+	ExportRawAmounts   bool
+	ConstLabels        map[string]string
+	LabelLowercase     []string
+	LabelStripSymbols  bool
+	LabelMaxLength     map[string]int64
+}
+
+type DenomsConfig struct {
+	// base denom -> display denom
+	Display   map[string]string
+	Exponent  map[string]int64
+	Precision int
+	Pack      string
+	// denoms balances and rewards are exported for, all if empty
+	Balance []string
+}
+
+type ProbeConfig struct {
+	Enabled        bool
+	AllowedTargets []string
+	IdleTimeout    time.Duration
+}
+
+type PushConfig struct {
+	URL     string
+	Mode    string
+	Job     string
+	Paths   []string
+	Headers map[string]string
+}
+
+// exporterConfig is the config in effect, guarded by configMutex.
+var exporterConfig ExporterConfig
+
+// detectedChainType is the chain type detected for --chain-type auto, kept
+// across reloads of the config.
+var detectedChainType string
+
+// CurrentConfig returns the config in effect. The config of a reload replaces
+// it as a whole, so the returned one is never modified.
+func CurrentConfig() ExporterConfig {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	return exporterConfig
+}
+
+// refreshConfig rebuilds the config in effect from the flag variables, after
+// they were changed outside of a reload, e.g. from the chain registry.
+func refreshConfig() error {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	config, err := configFromFlags()
+	if err != nil {
+		return err
+	}
+
+	exporterConfig = config
+	return nil
+}
+
+// SetChainType replaces the chain type of the config once it was detected.
+func SetChainType(chainType string) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	detectedChainType = chainType
+	exporterConfig.Chain.Type = chainType
+}
+
+// configFromFlags builds the config from the flag variables, which already
+// include the config file, and from the sections of the config file without
+// a flag. The caller holds configMutex.
+func configFromFlags() (ExporterConfig, error) {
+	chainType := ChainType
+	if strings.EqualFold(chainType, ChainTypeAuto) && detectedChainType != "" {
+		chainType = detectedChainType
+	}
+
+	config := ExporterConfig{
+		Chain: ChainConfig{
+			Type:         chainType,
+			Bech32Prefix: Bech32Prefix,
+			BlockTime:    BlockTime,
+		},
+		Validators:      AlertValopers,
+		BlockValidators: BlockValopers,
+		Feeders:         ExpectedFeeders,
+		Wallets:         Wallets,
+		ICQRelayers:     ICQRelayers,
+		Alerts: AlertsConfig{
+			Routes:           AlertRoutes,
+			Mutes:            AlertMutes,
+			Interval:         AlertInterval,
+			Cooldown:         AlertCooldown,
+			FeederMinBalance: AlertFeederMinBalance,
+			FeederDenom:      AlertFeederDenom,
+			IncidentWindow:   IncidentWindow,
+			SLOTarget:        SLOTarget,
+			SLOWindow:        SLOWindow,
+		},
+		Endpoints: EndpointsConfig{
+			Node:          NodeAddress,
+			NodeFallbacks: NodeFallbacks,
+			IPFamily:      IPFamily,
+			MaxLag:        EndpointMaxLag,
+			Proxy:         Proxy,
+			Proxies:       EndpointProxies,
+			Headers:       EndpointHeaders,
+			BasicAuth:     EndpointBasicAuth,
+			TendermintRPC: TendermintRPC,
+			BatchRPC:      BatchRPC,
+			Listen:        ListenAddress,
+			ChainListen:   ChainListenAddress,
+			TLSCert:       TLSCert,
+			TLSKey:        TLSKey,
+			AuthUser:      AuthUser,
+			AuthPassword:  AuthPassword,
+			AuthToken:     AuthToken,
+			StateFile:     StateFile,
+			StateDB:       StateDB,
+		},
+		Queries: QueriesConfig{
+			Timeout:             GRPCTimeout,
+			RetryAttempts:       RetryAttempts,
+			RetryBackoff:        RetryBackoff,
+			RetryMaxBackoff:     RetryMaxBackoff,
+			RetryJitter:         RetryJitter,
+			RetryCodes:          RetryCodes,
+			KeepaliveTime:       GRPCKeepaliveTime,
+			KeepaliveTimeout:    GRPCKeepaliveTimeout,
+			MaxRecvMsgSize:      GRPCMaxRecvMsgSize,
+			Rate:                QueryRate,
+			Burst:               QueryBurst,
+			EndpointRates:       EndpointQueryRates,
+			CacheTTLs:           CacheTTLs,
+			BatchSize:           BatchRPCSize,
+			PageSize:            QueryPageSize,
+			MaxPages:            QueryMaxPages,
+			RouteTimeouts:       RouteTimeouts,
+			ScrapeTimeout:       ScrapeTimeout,
+			ScrapeTimeoutOffset: ScrapeTimeoutOffset,
+			Historical:          HistoricalQueries,
+		},
+		Prices: PricesConfig{
+			Providers:    PriceReferenceProviders,
+			CoinGeckoIDs: CoinGeckoIDs,
+			BinanceQuote: BinanceQuote,
+			PythIDs:      PythIDs,
+			APIKeys:      PriceProviderAPIKeys,
+			Budgets:      PriceProviderBudgets,
+			BudgetPeriod: PriceProviderBudgetTime,
+			CacheTTLs:    PriceProviderCacheTTLs,
+		},
+		Metrics: MetricsConfig{
+			Schemas:            MetricsSchemas,
+			Namespace:          MetricsNamespace,
+			Renames:            MetricRenames,
+			DisabledCollectors: DisabledCollectors,
+			Include:            MetricsInclude,
+			Exclude:            MetricsExclude,
+			ExportRawAmounts:   ExportRawAmounts,
+			ConstLabels:        ConstLabels,
+			LabelLowercase:     LabelLowercase,
+			LabelStripSymbols:  LabelStripSymbols,
+			LabelMaxLength:     LabelMaxLength,
+		},
+		Denoms: DenomsConfig{
+			Display:   DenomDisplay,
+			Exponent:  DenomExponent,
+			Precision: DenomPrecision,
+			Pack:      DenomPack,
+			Balance:   BalanceDenoms,
+		},
+		Probe: ProbeConfig{
+			Enabled:        Probe,
+			AllowedTargets: ProbeAllowedTargets,
+			IdleTimeout:    ProbeIdleTimeout,
+		},
+		Push: PushConfig{
+			URL:     PushURL,
+			Mode:    PushMode,
+			Job:     PushJob,
+			Paths:   PushPaths,
+			Headers: PushHeaders,
+		},
+	}
+
+	if err := viper.UnmarshalKey("alerts", &config.Alerts.Rules); err != nil {
+		return config, fmt.Errorf("could not read alerts: %w", err)
+	}
+
+	if err := viper.UnmarshalKey("validator-labels", &config.ValidatorLabels); err != nil {
+		return config, fmt.Errorf("could not read validator-labels: %w", err)
+	}
+
+	if err := viper.UnmarshalKey("wallet-labels", &config.WalletLabels); err != nil {
+		return config, fmt.Errorf("could not read wallet-labels: %w", err)
+	}
+
+	return config, nil
+}
+
+// Validate checks the config without connecting anywhere and returns every
+// problem found, not only the first one.
+func (c ExporterConfig) Validate() error {
+	var errs []error
+	fail := func(field string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if !strings.EqualFold(c.Chain.Type, ChainTypeAuto) && !containsString(chainTypes, strings.ToLower(c.Chain.Type)) {
+		fail("chain-type", "unknown chain type %q, expected auto or one of %s", c.Chain.Type, strings.Join(chainTypes, ", "))
+	}
+
+	if c.Chain.Bech32Prefix != strings.ToLower(c.Chain.Bech32Prefix) || strings.ContainsAny(c.Chain.Bech32Prefix, "1 ") {
+		fail("bech32-prefix", "invalid prefix %q, expected the lowercase account prefix like umee", c.Chain.Bech32Prefix)
+	}
+
+	if c.Chain.BlockTime == 0 {
+		fail("block-time", "has to be positive")
+	}
+
+	for index, valoper := range c.Validators {
+		if _, err := ValAddressFromBech32(valoper); err != nil {
+			fail(fmt.Sprintf("alert-valopers[%d]", index), "invalid validator address %q: %v", valoper, err)
+		}
+	}
+
+	for index, valoper := range c.BlockValidators {
+		if _, err := ValAddressFromBech32(valoper); err != nil {
+			fail(fmt.Sprintf("block-valopers[%d]", index), "invalid validator address %q: %v", valoper, err)
+		}
+	}
+
+	if len(c.BlockValidators) > 0 && c.Endpoints.TendermintRPC == "" {
+		fail("block-valopers", "requires --tendermint-rpc")
+	}
+
+	for valoper, feeder := range c.Feeders {
+		if _, err := ValAddressFromBech32(valoper); err != nil {
+			fail("expected-feeders", "invalid validator address %q: %v", valoper, err)
+		}
+
+		if _, err := AccAddressFromBech32(feeder); err != nil {
+			fail("expected-feeders", "invalid feeder address %q of %s: %v", feeder, valoper, err)
+		}
+	}
+
+	for index, address := range c.Wallets {
+		if _, err := AccAddressFromBech32(address); err != nil {
+			fail(fmt.Sprintf("wallets[%d]", index), "invalid address %q: %v", address, err)
+		}
+	}
+
+	for index, address := range c.ICQRelayers {
+		if _, err := AccAddressFromBech32(address); err != nil {
+			fail(fmt.Sprintf("icq-relayers[%d]", index), "invalid address %q: %v", address, err)
+		}
+	}
+
+	if _, err := ParseAlertRoutes(c.Alerts.Routes); err != nil {
+		fail("alert-routes", "%v", err)
+	}
+
+	if _, err := ParseAlertMutes(c.Alerts.Mutes); err != nil {
+		fail("alert-mutes", "%v", err)
+	}
+
+	if _, err := ParseAlertRules(c.Alerts.Rules); err != nil {
+		fail("alerts", "%v", err)
+	}
+
+	if err := ValidateTargetLabels(c.ValidatorLabels, c.WalletLabels); err != nil {
+		fail("validator-labels", "%v", err)
+	}
+
+	if c.Alerts.Interval <= 0 {
+		fail("alert-interval", "has to be positive")
+	}
+
+	if c.Alerts.Cooldown < 0 {
+		fail("alert-cooldown", "can't be negative")
+	}
+
+	if c.Alerts.IncidentWindow < 0 {
+		fail("incident-window", "can't be negative")
+	}
+
+	if c.Alerts.SLOTarget < 0 || c.Alerts.SLOTarget >= 1 {
+		fail("slo-target", "has to be between 0 and 1, got %v", c.Alerts.SLOTarget)
+	} else if c.Alerts.SLOTarget > 0 && c.Endpoints.StateFile == "" {
+		fail("slo-target", "needs --state-file for the history")
+	}
+
+	if c.Endpoints.StateDB != "" && c.Endpoints.StateDB == c.Endpoints.StateFile {
+		fail("state-db", "has to be another file than --state-file")
+	}
+
+	if _, _, err := net.SplitHostPort(c.Endpoints.Node); err != nil {
+		fail("node", "%v", err)
+	}
+
+	for _, fallback := range c.Endpoints.NodeFallbacks {
+		if _, _, err := net.SplitHostPort(fallback); err != nil {
+			fail("node-fallbacks", "%v", err)
+		}
+	}
+
+	if c.Endpoints.MaxLag < 0 {
+		fail("endpoint-max-lag", "can't be negative")
+	}
+
+	if _, err := NewProxyConfig(c.Endpoints.Proxy, c.Endpoints.Proxies); err != nil {
+		fail("proxy", "%v", err)
+	}
+
+	if _, err := NewEndpointAuth(c.Endpoints.Headers, c.Endpoints.BasicAuth); err != nil {
+		fail("endpoint-headers", "%v", err)
+	}
+
+	if _, err := networkForFamily(c.Endpoints.IPFamily); err != nil {
+		fail("ip-family", "%v", err)
+	}
+
+	if _, _, err := net.SplitHostPort(c.Endpoints.Listen); err != nil {
+		fail("listen-address", "%v", err)
+	}
+
+	if c.Endpoints.ChainListen != "" {
+		if _, _, err := net.SplitHostPort(c.Endpoints.ChainListen); err != nil {
+			fail("chain-listen-address", "%v", err)
+		}
+	}
+
+	if (c.Endpoints.TLSCert == "") != (c.Endpoints.TLSKey == "") {
+		fail("tls-cert", "--tls-cert and --tls-key have to be set together")
+	}
+
+	if (c.Endpoints.AuthUser == "") != (c.Endpoints.AuthPassword == "") {
+		fail("auth-user", "--auth-user and --auth-password have to be set together")
+	}
+
+	if err := ValidatePriceProviders(c.Prices.Providers, c.Prices.CacheTTLs); err != nil {
+		fail("price-reference-providers", "%v", err)
+	}
+
+	if c.Queries.KeepaliveTime < 0 || c.Queries.KeepaliveTimeout < 0 {
+		fail("grpc-keepalive-time", "can't be negative")
+	}
+
+	if _, err := NewQueryRateLimiter(c.Queries.Rate, c.Queries.Burst, c.Queries.EndpointRates); err != nil {
+		fail("query-rate", "%v", err)
+	}
+
+	if c.Queries.MaxRecvMsgSize < 1 {
+		fail("grpc-max-recv-msg-size", "has to be at least 1, got %d", c.Queries.MaxRecvMsgSize)
+	}
+
+	if c.Endpoints.BatchRPC != "" && c.Queries.BatchSize < 1 {
+		fail("batch-rpc-size", "has to be at least 1, got %d", c.Queries.BatchSize)
+	}
+
+	if c.Queries.PageSize < 1 {
+		fail("page-size", "has to be at least 1")
+	}
+
+	if c.Queries.MaxPages < 0 {
+		fail("max-pages", "can't be negative")
+	}
+
+	for _, pattern := range c.Probe.AllowedTargets {
+		if _, err := path.Match(pattern, ""); err != nil {
+			fail("probe-allowed-targets", "invalid pattern %q: %v", pattern, err)
+		}
+	}
+
+	if c.Probe.Enabled && len(c.Probe.AllowedTargets) == 0 {
+		fail("probe-allowed-targets", "has to be set with --probe, no target is allowed otherwise, e.g. * for any")
+	}
+
+	if c.Probe.IdleTimeout <= 0 {
+		fail("probe-idle-timeout", "has to be positive")
+	}
+
+	if c.Push.URL != "" {
+		if _, err := NewPusher(c.Push.URL, c.Push.Mode, c.Push.Job, c.Push.Paths, c.Push.Headers); err != nil {
+			fail("push-mode", "%v", err)
+		}
+	}
+
+	if err := ValidateMetricsSchemas(c.Metrics.Schemas); err != nil {
+		fail("metrics-schemas", "%v", err)
+	}
+
+	if _, err := NewMetricNamer(c.Metrics.Namespace, c.Metrics.Renames); err != nil {
+		fail("metrics-namespace", "%v", err)
+	}
+
+	if _, err := NewMetricFilter(c.Metrics.DisabledCollectors, c.Metrics.Include, c.Metrics.Exclude); err != nil {
+		fail("disable-collectors", "%v", err)
+	}
+
+	if _, err := ParseRouteTimeouts(c.Queries.RouteTimeouts); err != nil {
+		fail("route-timeouts", "%v", err)
+	}
+
+	if err := NewQueryCache().SetTTLs(c.Queries.CacheTTLs); err != nil {
+		fail("cache-ttls", "%v", err)
+	}
+
+	if err := new(RetryPolicy).Set(c.Queries.RetryAttempts, c.Queries.RetryBackoff, c.Queries.RetryMaxBackoff, c.Queries.RetryJitter, c.Queries.RetryCodes, c.Queries.Timeout); err != nil {
+		fail("grpc-retry-attempts", "%v", err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// unknownConfigKeys returns the keys of the config file no flag is named
+// after, usually typos that would be silently ignored otherwise.
+func unknownConfigKeys(flags *pflag.FlagSet) []string {
+	var unknown []string
+	for _, key := range viper.AllKeys() {
+		// map flags like cache-ttls come as cache-ttls.validator
+		root := strings.SplitN(key, ".", 2)[0]
+		if root == "alerts" || root == "validator-labels" || root == "wallet-labels" || flags.Lookup(root) != nil {
+			continue
+		}
+
+		if !containsString(unknown, root) {
+			unknown = append(unknown, root)
+		}
+	}
+
+	return unknown
+}
+
+// CheckEndpoints connects to the node and binds the listen addresses, to
+// find unreachable endpoints before the exporter is started.
+func (c ExporterConfig) CheckEndpoints(ctx context.Context) error {
+	var errs []error
+
+	for _, address := range append([]string{c.Endpoints.Node}, c.Endpoints.NodeFallbacks...) {
+		node, err := DialNode(address, c.Endpoints.IPFamily, 0)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node: could not connect to %s: %w", address, err))
+			continue
+		}
+		defer node.Close()
+
+		serviceClient := tmservice.NewServiceClient(node)
+		if _, err := serviceClient.GetLatestBlock(ctx, &tmservice.GetLatestBlockRequest{}); err != nil {
+			errs = append(errs, fmt.Errorf("node: %s is not reachable: %w", address, err))
+		}
+	}
+
+	for name, address := range map[string]string{"listen-address": c.Endpoints.Listen, "chain-listen-address": c.Endpoints.ChainListen} {
+		if address == "" {
+			continue
+		}
+
+		listener, err := Listen(address, c.Endpoints.IPFamily)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: could not listen on %s: %w", name, address, err))
+			continue
+		}
+		listener.Close()
+	}
+
+	return errors.Join(errs...)
+}
+
+var validateConfigOffline bool
+
+var validateConfigCmd = &cobra.Command{
+	Use:   "validate-config",
+	Short: "Check the flags and the config file, including the node and listen addresses",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config := CurrentConfig()
+
+		var errs []error
+		if unknown := unknownConfigKeys(cmd.Flags()); len(unknown) > 0 {
+			errs = append(errs, fmt.Errorf("config file: unknown keys %s", strings.Join(unknown, ", ")))
+		}
+
+		if err := config.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+
+		if !validateConfigOffline {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			// the node is dialed through the configured proxy and with the
+			// configured headers, as by the exporter
+			if proxies, err := NewProxyConfig(config.Endpoints.Proxy, config.Endpoints.Proxies); err == nil {
+				proxyConfig = proxies
+			}
+			if auth, err := NewEndpointAuth(config.Endpoints.Headers, config.Endpoints.BasicAuth); err == nil {
+				endpointAuth = auth
+			}
+			// the connections count their queries in the self-metrics
+			RegisterSelfMetrics()
+
+			if err := config.CheckEndpoints(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if err := errors.Join(errs...); err != nil {
+			return err
+		}
+
+		log.Info().Msg("Config is valid")
+		return nil
+	},
+}
+
+func init() {
+	validateConfigCmd.Flags().BoolVar(&validateConfigOffline, "offline", false, "Only check the config, without connecting to the node")
+}
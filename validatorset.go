@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	querytypes "github.com/cosmos/cosmos-sdk/types/query"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+)
+
+// ValidatorSetCollector exports the size of the active set and the position of
+// the given validator in it, falling out of the set also stops oracle voting.
+type ValidatorSetCollector struct {
+	uncheckedCollector
+
+	ctx       context.Context
+	grpcConn  *grpc.ClientConn
+	valoper   string
+	myAddress sdk.ValAddress
+	logger    zerolog.Logger
+}
+
+func NewValidatorSetCollector(
+	ctx context.Context,
+	grpcConn *grpc.ClientConn,
+	valoper string,
+	myAddress sdk.ValAddress,
+	logger zerolog.Logger,
+) *ValidatorSetCollector {
+	return &ValidatorSetCollector{
+		ctx:       ctx,
+		grpcConn:  grpcConn,
+		valoper:   valoper,
+		myAddress: myAddress,
+		logger:    logger,
+	}
+}
+
+func (c *ValidatorSetCollector) Collect(ch chan<- prometheus.Metric) {
+	validatorSetSizeGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "validator_set_size",
+			Help:        "Number of validators in the active set",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	validatorSetMaxValidatorsGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "validator_set_max_validators",
+			Help:        "Maximum number of validators in the active set",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	validatorSetTotalPowerGauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "validator_set_total_voting_power",
+			Help:        "Total voting power of the active set",
+			ConstLabels: constLabels(),
+		},
+	)
+
+	validatorVotingPowerGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_voting_power",
+			Help:        "Voting power of a given validator",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	validatorRankGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_rank",
+			Help:        "Rank of a given validator by voting power in the active set, 0 if not in the set",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	validatorVotingPowerShareGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_voting_power_share",
+			Help:        "Share of a given validator in the total voting power of the active set",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	validatorDistanceToBottomGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "validator_distance_to_bottom",
+			Help:        "Voting power of a given validator above the last validator of the active set",
+			ConstLabels: constLabels(),
+		},
+		[]string{"valoper"},
+	)
+
+	metrics := []prometheus.Collector{
+		validatorSetSizeGauge,
+		validatorSetMaxValidatorsGauge,
+		validatorSetTotalPowerGauge,
+		validatorVotingPowerGauge,
+		validatorRankGauge,
+		validatorVotingPowerShareGauge,
+		validatorDistanceToBottomGauge,
+	}
+
+	stakingClient := stakingtypes.NewQueryClient(c.grpcConn)
+
+	c.logger.Debug().Msg("Started querying staking params")
+	queryStart := time.Now()
+
+	paramsResponse, err := stakingClient.Params(c.ctx, &stakingtypes.QueryParamsRequest{})
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Could not get staking params")
+		return
+	}
+
+	c.logger.Debug().
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying staking params")
+
+	validatorSetMaxValidatorsGauge.Set(float64(paramsResponse.Params.MaxValidators))
+
+	c.logger.Debug().Msg("Started querying active validators")
+	queryStart = time.Now()
+
+	var validators []stakingtypes.Validator
+
+	err = paginate("validators", func(key []byte, limit uint64) ([]byte, error) {
+		response, err := stakingClient.Validators(c.ctx, &stakingtypes.QueryValidatorsRequest{
+			Status:     stakingtypes.BondStatusBonded,
+			Pagination: &querytypes.PageRequest{Key: key, Limit: limit},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		validators = append(validators, response.Validators...)
+		return nextPageKey(response.Pagination), nil
+	})
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Could not get active validators")
+		return
+	}
+
+	c.logger.Debug().
+		Int("validators", len(validators)).
+		Float64("request-time", time.Since(queryStart).Seconds()).
+		Msg("Finished querying active validators")
+
+	sort.Slice(validators, func(i, j int) bool {
+		return validators[i].Tokens.GT(validators[j].Tokens)
+	})
+
+	var totalPower int64
+	for _, validator := range validators {
+		totalPower += validator.ConsensusPower(sdk.DefaultPowerReduction)
+	}
+
+	validatorSetSizeGauge.Set(float64(len(validators)))
+	validatorSetTotalPowerGauge.Set(float64(totalPower))
+
+	labels := prometheus.Labels{"valoper": c.valoper}
+	validatorRankGauge.With(labels).Set(0)
+
+	for index, validator := range validators {
+		// compared decoded, the prefixes of the chain aren't configured
+		if operator, err := ValAddressFromBech32(validator.OperatorAddress); err != nil || !operator.Equals(c.myAddress) {
+			continue
+		}
+
+		power := validator.ConsensusPower(sdk.DefaultPowerReduction)
+		bottom := validators[len(validators)-1].ConsensusPower(sdk.DefaultPowerReduction)
+
+		validatorVotingPowerGauge.With(labels).Set(float64(power))
+		validatorRankGauge.With(labels).Set(float64(index + 1))
+		validatorDistanceToBottomGauge.With(labels).Set(float64(power - bottom))
+		if totalPower > 0 {
+			validatorVotingPowerShareGauge.With(labels).Set(float64(power) / float64(totalPower))
+		}
+		break
+	}
+
+	collectMetrics(ch, metrics)
+}
+
+// ValidatorSetHandler serves the position in the active set of the validator
+// given with ?valoper=.
+func ValidatorSetHandler(w http.ResponseWriter, r *http.Request, grpcConn *grpc.ClientConn) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	valoper := r.URL.Query().Get("valoper")
+	myAddress, err := ValAddressFromBech32(valoper)
+	if err != nil {
+		sublogger.Error().
+			Str("valoper", valoper).
+			Err(err).
+			Msg("Could not get validator address")
+		return
+	}
+
+	registry := collectorRegistry(NewValidatorSetCollector(r.Context(), grpcConn, valoper, myAddress, sublogger))
+
+	h := promhttp.HandlerFor(ExportTargetGatherer(registry, valoper), promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/validators?valoper="+valoper).
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
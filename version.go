@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// Version and Commit are set at build time, e.g. with
+// -ldflags "-X main.Version=v1.4.0 -X main.Commit=$(git rev-parse --short HEAD)".
+// Without them they are taken from the build info Go embeds in the binary.
+var (
+	Version string
+	Commit  string
+)
+
+// BuildVersion returns the version and the commit the exporter was built from.
+func BuildVersion() (string, string) {
+	version, commit := Version, Commit
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if version == "" {
+			version = info.Main.Version
+		}
+
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && commit == "" {
+				commit = setting.Value
+				if len(commit) > 12 {
+					commit = commit[:12]
+				}
+			}
+		}
+	}
+
+	if version == "" {
+		version = "(devel)"
+	}
+	if commit == "" {
+		commit = "unknown"
+	}
+
+	return version, commit
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version of the exporter and what it was built with",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		version, commit := BuildVersion()
+		fmt.Fprintf(cmd.OutOrStdout(), "oracle-exporter %s (commit %s, %s %s/%s)\n", version, commit, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	},
+}
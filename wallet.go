@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+)
+
+// WalletCollector exports the balances of the given wallets.
+type WalletCollector struct {
+	uncheckedCollector
+
+	ctx              context.Context
+	grpcConn         *grpc.ClientConn
+	denoms           *DenomResolver
+	addresses        []string
+	denomOverride    DenomOverride
+	exportRawAmounts bool
+	logger           zerolog.Logger
+}
+
+func NewWalletCollector(
+	ctx context.Context,
+	grpcConn *grpc.ClientConn,
+	denoms *DenomResolver,
+	addresses []string,
+	denomOverride DenomOverride,
+	exportRawAmounts bool,
+	logger zerolog.Logger,
+) *WalletCollector {
+	return &WalletCollector{
+		ctx:              ctx,
+		grpcConn:         grpcConn,
+		denoms:           denoms,
+		addresses:        addresses,
+		denomOverride:    denomOverride,
+		exportRawAmounts: exportRawAmounts,
+		logger:           logger,
+	}
+}
+
+func (c *WalletCollector) Collect(ch chan<- prometheus.Metric) {
+	walletBalanceGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "wallet_balance",
+			Help:        "Balance of a given wallet in display denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"address", "denom", "ibc_path"},
+	)
+
+	walletBalanceRawGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "wallet_balance_raw",
+			Help:        "Balance of a given wallet in base denom",
+			ConstLabels: constLabels(),
+		},
+		[]string{"address", "denom"},
+	)
+
+	denomExponentGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "denom_exponent",
+			Help:        "Exponent the base denom amounts are divided by to get the display denom amounts",
+			ConstLabels: constLabels(),
+		},
+		[]string{"base", "display"},
+	)
+
+	ibcDenomTraceGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "ibc_denom_trace",
+			Help:        "Origin denom and transfer path of an IBC denom, always 1",
+			ConstLabels: constLabels(),
+		},
+		[]string{"denom", "base_denom", "path"},
+	)
+
+	metrics := []prometheus.Collector{
+		walletBalanceGauge,
+		denomExponentGauge,
+		ibcDenomTraceGauge,
+	}
+	if c.exportRawAmounts {
+		metrics = append(metrics, walletBalanceRawGauge)
+	}
+
+	group, ctx := errgroup.WithContext(c.ctx)
+
+	for _, address := range c.addresses {
+		address := address
+
+		group.Go(func() error {
+			if _, err := AccAddressFromBech32(address); err != nil {
+				c.logger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get wallet address")
+				return nil
+			}
+
+			c.logger.Debug().
+				Str("address", address).
+				Msg("Started querying wallet balance")
+			queryStart := time.Now()
+
+			bankClient := banktypes.NewQueryClient(c.grpcConn)
+			balancesResponse, err := bankClient.AllBalances(ctx, &banktypes.QueryAllBalancesRequest{Address: address})
+			if err != nil {
+				c.logger.Error().
+					Str("address", address).
+					Err(err).
+					Msg("Could not get wallet balance")
+				return nil
+			}
+
+			c.logger.Debug().
+				Str("address", address).
+				Float64("request-time", time.Since(queryStart).Seconds()).
+				Msg("Finished querying wallet balance")
+
+			for _, balance := range balancesResponse.Balances {
+				denom := c.denomOverride.Apply(c.denoms.Resolve(ctx, c.grpcConn, balance.Denom))
+				if !c.denoms.Allowed(denom) {
+					continue
+				}
+
+				walletBalanceGauge.With(prometheus.Labels{
+					"address":  address,
+					"denom":    denom.Display,
+					"ibc_path": denom.IBCPath,
+				}).Set(c.denoms.Convert(denom, balance.Amount))
+
+				denomExponentGauge.With(prometheus.Labels{
+					"base":    denom.Base,
+					"display": denom.Display,
+				}).Set(float64(denom.Exponent))
+
+				if denom.IBCBaseDenom != "" {
+					ibcDenomTraceGauge.With(prometheus.Labels{
+						"denom":      denom.Base,
+						"base_denom": denom.IBCBaseDenom,
+						"path":       denom.IBCPath,
+					}).Set(1)
+				}
+
+				walletBalanceRawGauge.With(prometheus.Labels{
+					"address": address,
+					"denom":   denom.Base,
+				}).Set(RawAmount(balance.Amount))
+			}
+
+			return nil
+		})
+	}
+
+	_ = group.Wait()
+
+	collectMetrics(ch, metrics)
+}
+
+// WalletHandler serves the balances of the wallets given with ?address=,
+// repeated or comma separated, or of the configured wallets if none is given.
+func WalletHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	grpcConn *grpc.ClientConn,
+	denoms *DenomResolver,
+	wallets []string,
+	exportRawAmounts bool,
+) {
+	requestStart := time.Now()
+
+	sublogger := log.With().
+		Str("request-id", uuid.New().String()).
+		Logger()
+
+	var addresses []string
+	for _, value := range r.URL.Query()["address"] {
+		for _, address := range strings.Split(value, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+
+	if len(addresses) == 0 {
+		addresses = wallets
+	}
+
+	denomOverride, err := ParseDenomOverride(r.URL.Query())
+	if err != nil {
+		sublogger.Error().
+			Err(err).
+			Msg("Could not get denom override")
+		return
+	}
+
+	registry := collectorRegistry(NewWalletCollector(r.Context(), grpcConn, denoms, addresses, denomOverride, exportRawAmounts, sublogger))
+
+	h := promhttp.HandlerFor(ExportGatherer(registry), promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+	sublogger.Info().
+		Str("method", "GET").
+		Str("endpoint", "/metrics/wallet").
+		Int("wallets", len(addresses)).
+		Float64("request-time", time.Since(requestStart).Seconds()).
+		Msg("Request processed")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Alert messages are written with the HTML subset Telegram understands,
+// the webhook notifiers convert the bold tags to their markdown flavour.
+
+type DiscordNotifier struct {
+	WebhookURL string
+
+	client *http.Client
+}
+
+func NewDiscordNotifier(webhookURL string) *DiscordNotifier {
+	return &DiscordNotifier{
+		WebhookURL: webhookURL,
+		client:     newHTTPClient(10 * time.Second),
+	}
+}
+
+func (n *DiscordNotifier) Name() string {
+	return "discord"
+}
+
+func (n *DiscordNotifier) Notify(ctx context.Context, message string) error {
+	content := strings.NewReplacer("<b>", "**", "</b>", "**").Replace(message)
+	return postWebhook(ctx, n.client, n.WebhookURL, map[string]string{"content": content})
+}
+
+type SlackNotifier struct {
+	WebhookURL string
+
+	client *http.Client
+}
+
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{
+		WebhookURL: webhookURL,
+		client:     newHTTPClient(10 * time.Second),
+	}
+}
+
+func (n *SlackNotifier) Name() string {
+	return "slack"
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, message string) error {
+	text := strings.NewReplacer("<b>", "*", "</b>", "*").Replace(message)
+	return postWebhook(ctx, n.client, n.WebhookURL, map[string]string{"text": text})
+}
+
+func postWebhook(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded with status %s", req.URL.Host, resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	WhitelistAdded   = "added"
+	WhitelistRemoved = "removed"
+)
+
+// WhitelistChange is a denom that was added to or removed from the oracle
+// whitelist since the previous scrape.
+type WhitelistChange struct {
+	Denom  string
+	Change string
+}
+
+// WhitelistTracker remembers the whitelisted denoms between scrapes, so the
+// feeder config can be updated when the whitelist changes, before the
+// validator starts missing votes for the new denom.
+type WhitelistTracker struct {
+	mutex  sync.Mutex
+	denoms map[string]bool
+	// denom -> change -> count
+	changes map[string]map[string]float64
+}
+
+func NewWhitelistTracker() *WhitelistTracker {
+	return &WhitelistTracker{
+		changes: make(map[string]map[string]float64),
+	}
+}
+
+// Observe records the whitelist and returns the changes since the previous
+// call. The first call only records the whitelist.
+func (t *WhitelistTracker) Observe(symbols []string) []WhitelistChange {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	denoms := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		denoms[symbol] = true
+	}
+
+	previous := t.denoms
+	t.denoms = denoms
+	if previous == nil {
+		return nil
+	}
+
+	var changes []WhitelistChange
+	for denom := range denoms {
+		if !previous[denom] {
+			changes = append(changes, WhitelistChange{Denom: denom, Change: WhitelistAdded})
+		}
+	}
+
+	for denom := range previous {
+		if !denoms[denom] {
+			changes = append(changes, WhitelistChange{Denom: denom, Change: WhitelistRemoved})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Denom < changes[j].Denom
+	})
+
+	for _, change := range changes {
+		if t.changes[change.Denom] == nil {
+			t.changes[change.Denom] = make(map[string]float64)
+		}
+		t.changes[change.Denom][change.Change]++
+	}
+
+	return changes
+}
+
+// Changes returns the cumulative number of changes by denom and kind.
+func (t *WhitelistTracker) Changes() map[string]map[string]float64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	changes := make(map[string]map[string]float64, len(t.changes))
+	for denom, counts := range t.changes {
+		changes[denom] = make(map[string]float64, len(counts))
+		for change, count := range counts {
+			changes[denom][change] = count
+		}
+	}
+
+	return changes
+}